@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// pseudonymAlphabet spells pseudonyms without letters and digits that are
+// easily confused, such as O and 0.
+const pseudonymAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// pseudonymEntry is who a pseudonym stands for.
+type pseudonymEntry struct {
+	Name      string `json:"name"`
+	Classroom string `json:"classroom"`
+}
+
+// pseudonyms is the private mapping between students and the pseudonyms
+// they appear under in anonymized exports. It is kept in a local file, never
+// in the input location, so a bucket shared with others doesn't hold it. A
+// pseudonym can be changed by hand in the file, to a roster ID for
+// instance, and is used from then on.
+type pseudonyms struct {
+	path     string
+	Students map[string]pseudonymEntry `json:"students"` // pseudonym → student
+	byKey    map[string]string         // studentKey → pseudonym
+	added    bool
+}
+
+// loadPseudonyms reads the mapping file at path. A missing file is an
+// empty mapping.
+func loadPseudonyms(path string) (*pseudonyms, error) {
+	p := &pseudonyms{path: path, Students: make(map[string]pseudonymEntry), byKey: make(map[string]string)}
+	data, err := readLocalFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	for pseudonym, s := range p.Students {
+		p.byKey[studentKey(ReadingLog{FullName: s.Name, Classroom: s.Classroom})] = pseudonym
+	}
+	return p, nil
+}
+
+// save writes the mapping if any students were added, readable only by the
+// user.
+func (p *pseudonyms) save() error {
+	if !p.added {
+		return nil
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := createLocalFile(p.path, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// of returns the pseudonym of the student on log, making up a new one for
+// a student seen for the first time. The same student, identified as
+// studentKey does, always gets the same pseudonym.
+func (p *pseudonyms) of(log ReadingLog) (string, error) {
+	key := studentKey(log)
+	if pseudonym, ok := p.byKey[key]; ok {
+		return pseudonym, nil
+	}
+	for {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for i := range b {
+			b[i] = pseudonymAlphabet[int(b[i])%len(pseudonymAlphabet)]
+		}
+		pseudonym := "S-" + string(b)
+		if _, taken := p.Students[pseudonym]; taken {
+			continue
+		}
+		p.Students[pseudonym] = pseudonymEntry{Name: log.FullName, Classroom: classroomOf(log)}
+		p.byKey[key] = pseudonym
+		p.added = true
+		return pseudonym, nil
+	}
+}
+
+// anonymize returns logs with each student's name replaced by their
+// pseudonym from the mapping file at path, and with everything else that
+// could identify them removed: the notes written on the form, the image
+// filenames, the email sender, when the photo was taken, and review flags,
+// whose details can quote any of these. Grade, teacher, minutes, and books are kept. The rows are
+// re-sorted by pseudonym so their order doesn't give names away.
+func anonymize(logs []ReadingLog, path string) ([]ReadingLog, error) {
+	names, err := loadPseudonyms(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ReadingLog, len(logs))
+	for i, log := range logs {
+		pseudonym, err := names.of(log)
+		if err != nil {
+			return nil, err
+		}
+		log.FullName = pseudonym
+		log.Notes, log.Source, log.Back, log.Sender, log.PhotoTaken, log.Raw = "", "", "", "", "", ""
+		log.Flags = nil
+		out[i] = log
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].FullName < out[j].FullName })
+	if err := names.save(); err != nil {
+		return nil, fmt.Errorf("saving %s: %w", path, err)
+	}
+	return out, nil
+}
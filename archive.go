@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// archiveModes are what --archive can do with each image once it has a
+// result: nothing, move it into the archive, or copy it there.
+var archiveModes = []string{"", "move", "copy"}
+
+// renamePattern is the archive pattern for --rename, which renames images
+// where they are after the student on them, e.g. Brooks_Chloe_02-06.heic.
+const renamePattern = "{{with .Last}}{{.}}_{{end}}{{.First}}_{{.Week}}{{.Ext}}"
+
+// archiveName is what an archive_pattern names an image with. Each value is
+// made safe for a file name, so a student can't end up in a folder of their
+// own making.
+type archiveName struct {
+	Teacher string // the classroom the result is attributed to
+	Student string
+	First   string // the student's first name
+	Last    string // and last name, empty if only one name was written
+	Grade   string
+	Week    string
+	Name    string // the image's filename without its extension
+	Ext     string // the image's extension, with its dot
+}
+
+// parseArchivePattern parses an archive_pattern, a text/template whose
+// result is a path in the input location.
+func parseArchivePattern(text string) (*template.Template, error) {
+	return template.New("archive_pattern").Option("missingkey=error").Parse(text)
+}
+
+// archiveImages files each image with a result that hasn't been archived
+// yet under the name the archive pattern gives it, moving or copying it as
+// p.archive says. The back page of a two-sided form is named after its
+// front, with -back added, so the two still pair up. Moved images keep their
+// results, which name them by where they now are, in progress and in the
+// history. It returns the number of images archived.
+func (p *pipeline) archiveImages(progress *Progress) int {
+	if p.archive == "" {
+		return 0
+	}
+	names, err := findImages(store)
+	if err != nil {
+		logger.Warn("could not archive images", "err", err)
+		return 0
+	}
+	present := make(map[string]bool)
+	for _, name := range names {
+		present[name] = true
+	}
+	taken := make(map[string]bool)
+	for _, name := range progress.Images {
+		taken[name] = true
+	}
+	for _, name := range progress.Archived {
+		taken[name] = true
+	}
+
+	ids := progress.idsByName()
+	renames := make(map[string]string) // old name → archived name, for moves
+	archived := 0
+	file := func(name, target string) {
+		id, ok := ids[name]
+		if !ok || progress.Archived[id] != "" || !present[name] {
+			return
+		}
+		if target == name {
+			progress.Archived[id] = target // already where it belongs
+			return
+		}
+		data, err := readStoreFile(store, name)
+		if err == nil && p.scrubArchive {
+			data, err = stripMetadata(data)
+		}
+		if err == nil {
+			err = writeStoreFile(store, target, data)
+		}
+		if err == nil && p.archive == "move" {
+			err = store.Remove(name)
+		}
+		if err != nil {
+			logger.Warn("could not archive "+name, "image", name, "err", err)
+			return
+		}
+		taken[target] = true
+		if p.archive == "move" && p.scrubArchive {
+			// The image's content changed, and with it its ID.
+			if scrubbed := imageID(data); scrubbed != id {
+				progress.rekey(id, scrubbed)
+				id = scrubbed
+			}
+		}
+		progress.Archived[id] = target
+		if p.archive == "move" {
+			progress.Images[id] = target
+			renames[name] = target
+			if sender, ok := progress.Senders[name]; ok {
+				delete(progress.Senders, name)
+				progress.Senders[target] = sender
+			}
+		}
+		archived++
+		logger.Info("image archived", "image", name, "to", target, "mode", p.archive)
+	}
+
+	for _, log := range completedLogs(progress) {
+		if imageOf(log.Source) != log.Source {
+			continue // a further student on an image that is filed with the first
+		}
+		target, ok := progress.Archived[ids[log.Source]]
+		if !ok {
+			if !present[log.Source] {
+				continue
+			}
+			if target, err = p.archiveTarget(log, taken); err != nil {
+				logger.Warn("could not archive "+log.Source, "image", log.Source, "err", err)
+				continue
+			}
+			if isBackPage(log.Source) {
+				target = backName(target, path.Ext(target))
+			}
+			file(log.Source, target)
+		}
+		if log.Back != "" {
+			file(log.Back, backName(target, filepath.Ext(log.Back)))
+		}
+	}
+
+	if len(renames) > 0 {
+		if err := saveProgress(progress); err != nil {
+			logger.Warn("could not save progress", "err", err)
+		}
+		err := editHistory(func(h *History) {
+			for _, results := range h.Weeks {
+				moved := make(map[string]ReadingLog)
+				for key, log := range results {
+					if to, ok := renames[imageOf(key)]; ok {
+						delete(results, key)
+						moved[to+key[len(imageOf(key)):]] = log
+					}
+				}
+				for key, log := range moved {
+					results[key] = log
+				}
+			}
+		})
+		if err != nil {
+			logger.Warn("could not update history", "err", err)
+		}
+	}
+	return archived
+}
+
+// archiveTarget returns the name the archive pattern gives log's image,
+// with -2, -3, and so on added if an image already has that name.
+func (p *pipeline) archiveTarget(log ReadingLog, taken map[string]bool) (string, error) {
+	ext := filepath.Ext(log.Source)
+	stem := strings.TrimSuffix(path.Base(filepath.ToSlash(log.Source)), ext)
+	if form, back := pageOf(log.Source); back {
+		stem = path.Base(filepath.ToSlash(form))
+	}
+	first, last := splitName(log.FullName)
+	if last != "" {
+		last = fileSafe(last)
+	}
+	var buf bytes.Buffer
+	err := p.archivePattern.Execute(&buf, archiveName{
+		Teacher: fileSafe(classroomOf(log)),
+		Student: fileSafe(log.FullName),
+		First:   fileSafe(first),
+		Last:    last,
+		Grade:   fileSafe(log.Grade),
+		Week:    fileSafe(weekOf(log)),
+		Name:    stem,
+		Ext:     ext,
+	})
+	if err != nil {
+		return "", fmt.Errorf("archive_pattern: %w", err)
+	}
+	target := path.Clean(filepath.ToSlash(strings.TrimSpace(buf.String())))
+	if !filepath.IsLocal(target) {
+		return "", fmt.Errorf("archive_pattern gives %q, which isn't in the input location", target)
+	}
+	if target == log.Source {
+		return target, nil
+	}
+
+	base, n := strings.TrimSuffix(target, path.Ext(target)), 1
+	for taken[target] || exists(target) {
+		n++
+		target = fmt.Sprintf("%s-%d%s", base, n, path.Ext(target))
+	}
+	return target, nil
+}
+
+// splitName returns a student's first and last names: "Chloe Brooks" and
+// "Brooks, Chloe" both give Chloe and Brooks. Everything before the last
+// word is taken as the first name, and a single name as only a first name.
+func splitName(full string) (first, last string) {
+	if before, after, ok := strings.Cut(full, ","); ok {
+		return strings.TrimSpace(after), strings.TrimSpace(before)
+	}
+	words := strings.Fields(full)
+	if len(words) < 2 {
+		return strings.Join(words, ""), ""
+	}
+	return strings.Join(words[:len(words)-1], " "), words[len(words)-1]
+}
+
+// backName is the name, with extension ext, of the back page of a form
+// whose front is name.
+func backName(name, ext string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + "-back" + ext
+}
+
+// exists reports whether the store has a file called name.
+func exists(name string) bool {
+	r, err := store.Open(name)
+	if err != nil {
+		return false
+	}
+	r.Close()
+	return true
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// artifactDir is the directory in the input directory that holds
+// intermediate files produced while processing images.
+const artifactDir = ".artifacts"
+
+// artifactRoot returns where this run's artifacts are kept: artifactDir in
+// the input directory, or for a bucket, a directory of its own in the user's
+// cache directory, since artifacts are written and aged out as local files.
+func artifactRoot() string {
+	if d, ok := store.(dirStore); ok {
+		return filepath.Join(string(d), artifactDir)
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "reading-logs-parser", fileSafe(store.String()))
+}
+
+// artifactKind groups artifacts that share a retention policy.
+type artifactKind string
+
+const (
+	artifactConverted artifactKind = "converted" // JPEGs converted from HEIC, TIFF, and BMP, flattened pages, and collage tiles
+	artifactRaw       artifactKind = "raw"       // raw API responses and errors
+)
+
+var artifactKinds = []artifactKind{artifactConverted, artifactRaw}
+
+// retention says how long artifacts of one kind are kept after a run.
+type retention struct {
+	mode string // "always-delete", "keep-on-error", "keep-days", or "always-keep"
+	days int
+}
+
+// parseRetention accepts "always-delete", "keep-on-error", "always-keep", or
+// "keep-N-days" (e.g. "keep-7-days").
+func parseRetention(s string) (retention, error) {
+	switch s {
+	case "always-delete", "keep-on-error", "always-keep":
+		return retention{mode: s}, nil
+	}
+	if strings.HasPrefix(s, "keep-") && strings.HasSuffix(s, "-days") {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(s, "keep-"), "-days"))
+		if err == nil && n >= 0 {
+			return retention{mode: "keep-days", days: n}, nil
+		}
+	}
+	return retention{}, fmt.Errorf("invalid retention %q (want always-delete, keep-on-error, keep-N-days, or always-keep)", s)
+}
+
+// artifactSet tracks the artifacts created during a run and which images
+// failed, so cleanup can apply each kind's retention policy explicitly.
+type artifactSet struct {
+	root     string // directory holding a subdirectory per kind
+	policies map[artifactKind]retention
+
+	mu      sync.Mutex
+	created map[string][]string // image name → artifact paths
+	outcome map[string]bool     // image name → succeeded, once finished
+}
+
+// retentionFlags registers a --keep-<kind> flag per artifact kind and returns
+// a constructor for the resulting artifactSet.
+func retentionFlags(fs *flag.FlagSet) func() (*artifactSet, error) {
+	values := make(map[artifactKind]*string)
+	for _, kind := range artifactKinds {
+		def := cfg.Retention[string(kind)]
+		if def == "" {
+			def = "keep-on-error"
+		}
+		values[kind] = fs.String("keep-"+string(kind), def,
+			fmt.Sprintf("retention for %s artifacts: always-delete, keep-on-error, keep-N-days, always-keep", kind))
+	}
+	return func() (*artifactSet, error) {
+		policies := make(map[artifactKind]retention)
+		for kind, v := range values {
+			r, err := parseRetention(*v)
+			if err != nil {
+				return nil, fmt.Errorf("--keep-%s: %w", kind, err)
+			}
+			policies[kind] = r
+		}
+		return newArtifactSet(policies), nil
+	}
+}
+
+func newArtifactSet(policies map[artifactKind]retention) *artifactSet {
+	return &artifactSet{
+		root:     artifactRoot(),
+		policies: policies,
+		created:  make(map[string][]string),
+		outcome:  make(map[string]bool),
+	}
+}
+
+// path returns where an artifact of kind for image is stored, creating the
+// directory if needed.
+func (a *artifactSet) path(kind artifactKind, image, ext string) (string, error) {
+	dir := filepath.Join(a.root, string(kind))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(image)+ext), nil
+}
+
+// write stores data as an artifact of kind for image. Raw responses and
+// error text are encrypted like the parser's other files; images are not.
+func (a *artifactSet) write(kind artifactKind, image, ext string, data []byte) error {
+	path, err := a.path(kind, image, ext)
+	if err != nil {
+		return err
+	}
+	w, err := createLocalFile(path, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	a.add(image, path)
+	return nil
+}
+
+// add records that path was created while processing image.
+func (a *artifactSet) add(image, path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.created[image] = append(a.created[image], path)
+}
+
+// finish records whether processing image ultimately succeeded.
+func (a *artifactSet) finish(image string, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.outcome[image] = ok
+}
+
+// cleanup applies the retention policies to the artifacts of finished images
+// and ages out old ones, returning the number of files removed. Artifacts of
+// images still being processed are left alone.
+func (a *artifactSet) cleanup() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	removed := 0
+	remove := func(path string) {
+		if os.Remove(path) == nil {
+			removed++
+		}
+	}
+
+	inFlight := make(map[string]bool)
+	for image, paths := range a.created {
+		ok, finished := a.outcome[image]
+		if !finished {
+			for _, path := range paths {
+				inFlight[path] = true
+			}
+			continue
+		}
+		for _, path := range paths {
+			kind := artifactKind(filepath.Base(filepath.Dir(path)))
+			switch a.policies[kind].mode {
+			case "always-delete":
+				remove(path)
+			case "keep-on-error":
+				if ok {
+					remove(path)
+				}
+			}
+		}
+		delete(a.created, image)
+		delete(a.outcome, image)
+	}
+
+	// Sweep the artifact directories for leftovers from earlier runs.
+	now := time.Now()
+	for _, kind := range artifactKinds {
+		policy := a.policies[kind]
+		if policy.mode != "keep-days" && policy.mode != "always-delete" {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(a.root, string(kind)))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			path := filepath.Join(a.root, string(kind), e.Name())
+			info, err := e.Info()
+			if err != nil || e.IsDir() || inFlight[path] {
+				continue
+			}
+			age := now.Sub(info.ModTime())
+			if policy.mode == "always-delete" || age > time.Duration(policy.days)*24*time.Hour {
+				remove(path)
+			}
+		}
+	}
+	return removed
+}
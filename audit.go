@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// auditEntry is one API request as recorded in the audit log. Images are
+// identified by size and hash; their bytes are never written.
+type auditEntry struct {
+	Time        time.Time `json:"time"`
+	Image       string    `json:"image,omitempty"` // image filename, when known; a batch's are separated by commas
+	Request     string    `json:"request"`         // parse, parse-multi, parse-batch, detect-template, ask-fields, or ask-days
+	URL         string    `json:"url,omitempty"`   // where the request was sent
+	Model       string    `json:"model"`           // model that answered, after any fallback
+	MaxTokens   int64     `json:"max_tokens"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	MediaType   string    `json:"media_type"`
+	ImageBytes  int       `json:"image_bytes"`
+	ImageSHA256 string    `json:"image_sha256"`
+	Images      int       `json:"images,omitempty"` // images sent at once with --batch-size
+	Sent        string    `json:"sent"`             // "inline" or "file" (--upload)
+	Examples    int       `json:"examples,omitempty"`
+	Crops       int       `json:"crops,omitempty"` // close-ups of the form's regions sent with the image
+	Prompt      string    `json:"prompt"`
+	Response    string    `json:"response,omitempty"` // raw model response
+	Outcome     string    `json:"outcome"`            // ok, invalid-json, or error
+	Error       string    `json:"error,omitempty"`
+	Tokens      struct {
+		Input      int64 `json:"input"`
+		Output     int64 `json:"output"`
+		CacheRead  int64 `json:"cache_read,omitempty"`
+		CacheWrite int64 `json:"cache_write,omitempty"`
+	} `json:"tokens"`
+	LatencyMS int64 `json:"latency_ms"`
+	Attempts  int   `json:"attempts"` // HTTP attempts, including retries and fallback
+}
+
+// auditLog appends entries to a JSONL file, one per run, created when the
+// first request is recorded. With encryption on, the file is encrypted as
+// it is written and can only be read once the run closes it. A nil
+// *auditLog records nothing.
+type auditLog struct {
+	dir  string
+	mu   sync.Mutex
+	name string
+	file io.WriteCloser
+	enc  *json.Encoder
+}
+
+func newAuditLog(dir string) *auditLog {
+	if dir == "" {
+		return nil
+	}
+	return &auditLog{dir: dir}
+}
+
+// record appends an entry. Failing to write the audit log is a warning,
+// not a reason to stop the run.
+func (a *auditLog) record(e auditEntry) {
+	logger.Debug("api request", "image", e.Image, "request", e.Request, "model", e.Model, "outcome", e.Outcome, "error", e.Error,
+		"latency_ms", e.LatencyMS, "attempts", e.Attempts, "input_tokens", e.Tokens.Input, "output_tokens", e.Tokens.Output)
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		if err := os.MkdirAll(a.dir, 0755); err != nil {
+			logger.Warn("could not write audit log", "err", err)
+			return
+		}
+		name := filepath.Join(a.dir, time.Now().Format("20060102-150405")+".jsonl")
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			logger.Warn("could not write audit log", "err", err)
+			return
+		}
+		w, err := seal(name, f)
+		if err != nil {
+			logger.Warn("could not write audit log", "err", err)
+			return
+		}
+		a.name, a.file, a.enc = name, w, json.NewEncoder(w)
+	}
+	if err := a.enc.Encode(e); err != nil {
+		logger.Warn("could not write audit log", "err", err)
+	}
+}
+
+// close closes the run's audit log file, if one was written, and returns
+// its path.
+func (a *auditLog) close() string {
+	if a == nil {
+		return ""
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return ""
+	}
+	if err := a.file.Close(); err != nil {
+		logger.Warn("could not write audit log", "err", err)
+	}
+	name := a.name
+	a.name, a.file, a.enc = "", nil, nil
+	return name
+}
+
+// imageKey is the context key for the image a request is about.
+type imageKey struct{}
+
+// withImage notes in ctx which image requests made with it are about.
+func withImage(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, imageKey{}, name)
+}
+
+// imageFrom returns the image noted by withImage, if any.
+func imageFrom(ctx context.Context) string {
+	name, _ := ctx.Value(imageKey{}).(string)
+	return name
+}
+
+// imageHash returns the SHA-256 of an encoded image's bytes and its size.
+func imageHash(encoded string) (string, int) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		data = []byte(encoded)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), len(data)
+}
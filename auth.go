@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// The API key saved with auth login is kept in the OS keychain under this
+// service and account.
+const (
+	keyringService = "reading-logs-parser"
+	keyringUser    = "anthropic-api-key"
+)
+
+// runAuth manages the API key kept in the OS keychain.
+func runAuth(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: auth login|status|logout")
+	}
+	switch args[0] {
+	case "login":
+		return runAuthLogin(args[1:])
+	case "status":
+		return runAuthStatus(args[1:])
+	case "logout":
+		return runAuthLogout(args[1:])
+	default:
+		return fmt.Errorf("unknown auth command %q", args[0])
+	}
+}
+
+// runAuthLogin checks an API key with the API and saves it in the keychain.
+func runAuthLogin(args []string) error {
+	fs := flag.NewFlagSet("auth login", flag.ContinueOnError)
+	noCheck := fs.Bool("no-check", false, "save the key without checking it with the API first")
+	parseFlags(fs, args)
+
+	key, err := readAPIKey()
+	if err != nil {
+		return err
+	}
+	if !*noCheck {
+		if _, err := checkKey(key); err != nil {
+			return fmt.Errorf("could not check the key (--no-check saves it anyway): %w", err)
+		}
+	}
+	if err := keyring.Set(keyringService, keyringUser, key); err != nil {
+		return fmt.Errorf("could not save the key in the %s: %w", keychainName(), err)
+	}
+	green.Printf("  %s Saved the API key in the %s\n", glyphs("✓"), keychainName())
+	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+		dim.Println("  ANTHROPIC_API_KEY is set too, and is used instead while it is")
+	}
+	return nil
+}
+
+// runAuthStatus shows which API key is used and checks that it works.
+func runAuthStatus(args []string) error {
+	fs := flag.NewFlagSet("auth status", flag.ContinueOnError)
+	parseFlags(fs, args)
+
+	key, source := apiKey()
+	if key == "" {
+		return errNoAPIKey
+	}
+	model := resolveModel(cfg.Model)
+	fmt.Printf("  Key:    %s\n", maskKey(key))
+	fmt.Printf("  From:   %s\n", source)
+	fmt.Printf("  Model:  %s\n", model)
+
+	start := time.Now()
+	hasModel, err := checkKey(key)
+	if err != nil {
+		red.Printf("  %s The key doesn't work\n", glyphs("✗"))
+		return err
+	}
+	green.Printf("  %s The key works (%s)\n", glyphs("✓"), time.Since(start).Round(time.Millisecond))
+	if !hasModel {
+		yellow.Printf("  %s The API doesn't know %s; check model in the config file\n", glyphs("⚠"), model)
+	}
+	return nil
+}
+
+// runAuthLogout removes the API key from the keychain.
+func runAuthLogout(args []string) error {
+	fs := flag.NewFlagSet("auth logout", flag.ContinueOnError)
+	parseFlags(fs, args)
+
+	err := keyring.Delete(keyringService, keyringUser)
+	if errors.Is(err, keyring.ErrNotFound) {
+		dim.Printf("  No API key is saved in the %s\n", keychainName())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not remove the key from the %s: %w", keychainName(), err)
+	}
+	green.Printf("  %s Removed the API key from the %s\n", glyphs("✓"), keychainName())
+	return nil
+}
+
+// apiKey returns the API key to use and where it came from:
+// ANTHROPIC_API_KEY, or else the key saved with auth login. It returns ""
+// if there is neither.
+func apiKey() (key, source string) {
+	if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
+		return key, "ANTHROPIC_API_KEY"
+	}
+	key, err := keyring.Get(keyringService, keyringUser)
+	if err != nil {
+		if !errors.Is(err, keyring.ErrNotFound) {
+			logger.Debug("could not read the keychain", "err", err)
+		}
+		return "", ""
+	}
+	return key, "the " + keychainName()
+}
+
+// useSavedKey puts the key saved with auth login in ANTHROPIC_API_KEY,
+// where the API client looks for it, unless a key or token is set already.
+func useSavedKey() {
+	if os.Getenv("ANTHROPIC_API_KEY") != "" || os.Getenv("ANTHROPIC_AUTH_TOKEN") != "" {
+		return
+	}
+	if key, _ := apiKey(); key != "" {
+		os.Setenv("ANTHROPIC_API_KEY", key)
+	}
+}
+
+// readAPIKey asks for the key without showing it, or reads it from stdin
+// when that isn't a terminal, so it can be piped in.
+func readAPIKey() (string, error) {
+	var key string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Print("  Anthropic API key: ")
+		b, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		key = string(b)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		key = line
+	}
+	if key = strings.TrimSpace(key); key == "" {
+		return "", fmt.Errorf("no key given")
+	}
+	return key, nil
+}
+
+// checkKey makes the cheapest call the API has, looking up the configured
+// model, to check that key works. It also reports whether the API knows
+// the model.
+func checkKey(key string) (hasModel bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client := anthropic.NewClient(option.WithAPIKey(key))
+	_, err = client.Models.Get(ctx, string(resolveModel(cfg.Model)), anthropic.ModelGetParams{})
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// maskKey shows enough of a key to tell which it is.
+func maskKey(key string) string {
+	if len(key) < 20 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:10] + "..." + key[len(key)-4:]
+}
+
+// keychainName is what the OS calls the place keys are kept.
+func keychainName() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "macOS keychain"
+	case "windows":
+		return "Windows Credential Manager"
+	}
+	return "keyring"
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/invopop/jsonschema"
+)
+
+// maxBatchSize is the most images --batch-size sends in one request. Past
+// 20 the API shrinks every image to 2000 pixels a side.
+const maxBatchSize = 20
+
+// batchWait is how long the first image in a batch waits for the rest
+// before the batch is sent as it is.
+const batchWait = 2 * time.Second
+
+// batchPrompt is added to the prompt when several images are read at once.
+const batchPrompt = `
+
+This message has several photos, numbered in the order they are given, each of a different reading log. Read each photo on its own, without letting one student's handwriting or answers affect how you read another's, and return one entry in results for each photo with its number in image.`
+
+// batchImage is one image in a request, with its close-ups.
+type batchImage struct {
+	Name      string
+	MediaType string
+	Data      string // base64-encoded
+	Crops     []crop
+}
+
+// batchResult is what became of one image in a batch.
+type batchResult struct {
+	Log *ReadingLog
+	Err error
+}
+
+// batchReader is a Client that can read several images in one request.
+type batchReader interface {
+	Client
+	// ParseReadingLogBatch reads the log on each image, returning a result
+	// for each in order, or an error if the request as a whole failed.
+	ParseReadingLogBatch(ctx context.Context, images []batchImage, form FormTemplate) ([]batchResult, error)
+}
+
+// batcher gathers the images being read at the same time into requests of
+// up to size images each. Only images read with the same form template are
+// sent together. An image the model gave no answer for, or whose request
+// failed, is read again on its own, so one bad image doesn't fail the rest.
+type batcher struct {
+	reader batchReader
+	size   int
+
+	mu      sync.Mutex
+	pending map[string]*batch // the batch being filled for each form template
+}
+
+// batch is a request being gathered.
+type batch struct {
+	form  FormTemplate
+	items []*batchItem
+	full  chan struct{} // closed once the batch has size images
+}
+
+// batchItem is an image waiting in a batch. Its result is sent on done,
+// or nil if it is to be read on its own.
+type batchItem struct {
+	ctx   context.Context
+	image batchImage
+	done  chan *batchResult
+}
+
+func newBatcher(reader batchReader, size int) *batcher {
+	return &batcher{reader: reader, size: size, pending: make(map[string]*batch)}
+}
+
+// parse reads the log on an image as part of the next batch for its form.
+func (b *batcher) parse(ctx context.Context, mediaType, encoded string, form FormTemplate) (*ReadingLog, error) {
+	item := &batchItem{
+		ctx:   ctx,
+		image: batchImage{Name: imageFrom(ctx), MediaType: mediaType, Data: encoded, Crops: cropsFrom(ctx)},
+		done:  make(chan *batchResult, 1),
+	}
+	b.mu.Lock()
+	pending := b.pending[form.Name]
+	if pending == nil {
+		pending = &batch{form: form, full: make(chan struct{})}
+		b.pending[form.Name] = pending
+		go b.sendWhenReady(pending)
+	}
+	pending.items = append(pending.items, item)
+	if len(pending.items) == b.size {
+		delete(b.pending, form.Name)
+		close(pending.full)
+	}
+	b.mu.Unlock()
+
+	var r *batchResult
+	select {
+	case r = <-item.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if r == nil {
+		return b.reader.ParseReadingLog(ctx, mediaType, encoded, form)
+	}
+	if r.Err != nil && ctx.Err() == nil && failureKind(r.Err) != kindAuth {
+		logger.WarnContext(ctx, "could not read image in a batch; reading it on its own", "image", item.image.Name, "err", r.Err)
+		return b.reader.ParseReadingLog(ctx, mediaType, encoded, form)
+	}
+	return r.Log, r.Err
+}
+
+// sendWhenReady sends a batch once it is full or batchWait has passed.
+func (b *batcher) sendWhenReady(pending *batch) {
+	timer := time.NewTimer(batchWait)
+	defer timer.Stop()
+	select {
+	case <-pending.full:
+	case <-timer.C:
+		b.mu.Lock()
+		if b.pending[pending.form.Name] == pending {
+			delete(b.pending, pending.form.Name)
+		}
+		b.mu.Unlock()
+	}
+
+	items := pending.items
+	if len(items) == 1 {
+		items[0].done <- nil
+		return
+	}
+	ctx, cancel := batchContext(items)
+	defer cancel()
+	images := make([]batchImage, len(items))
+	for i, item := range items {
+		images[i] = item.image
+	}
+	results, err := b.reader.ParseReadingLogBatch(ctx, images, pending.form)
+	for i, item := range items {
+		if err != nil {
+			item.done <- &batchResult{Err: err}
+			continue
+		}
+		item.done <- &results[i]
+	}
+}
+
+// batchContext returns a context for a batch's request that is canceled
+// once every image in it has been given up on, by its timeout or the run
+// stopping.
+func batchContext(items []*batchItem) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(items[0].ctx))
+	var left atomic.Int32
+	left.Store(int32(len(items)))
+	stops := make([]func() bool, len(items))
+	for i, item := range items {
+		stops[i] = context.AfterFunc(item.ctx, func() {
+			if left.Add(-1) == 0 {
+				cancel()
+			}
+		})
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
+
+// ParseReadingLogBatch asks Claude for the reading log on each of several
+// images in one request.
+func (c *anthropicClient) ParseReadingLogBatch(ctx context.Context, images []batchImage, form FormTemplate) ([]batchResult, error) {
+	// Each result is a log with the number of the image it came from.
+	item := extractionSchema(form)
+	props := jsonschema.NewProperties()
+	props.Set("image", &jsonschema.Schema{Type: "integer", Description: "The number of the photo this log was read from, starting at 1"})
+	for pair := item.Properties.Oldest(); pair != nil; pair = pair.Next() {
+		props.Set(pair.Key, pair.Value)
+	}
+	item.Properties = props
+	item.Required = append([]string{"image"}, item.Required...)
+
+	results := jsonschema.NewProperties()
+	results.Set("results", &jsonschema.Schema{
+		Type:        "array",
+		Items:       item,
+		Description: "One entry per photo",
+	})
+	schema := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           results,
+		Required:             []string{"results"},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+
+	prompt, err := renderPrompt(c.prompt, form)
+	if err != nil {
+		return nil, err
+	}
+	shots := pickExemplars(c.examples, form.Name, c.shots)
+	text, err := c.askImages(ctx, "parse-batch", images, prompt+batchPrompt, generateJSONSchema(schema), shots...)
+	if err != nil {
+		return nil, err
+	}
+	var answer struct {
+		Results []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(text), &answer); err != nil {
+		return nil, fmt.Errorf("%w: %w\nraw: %s", errParse, err, text)
+	}
+
+	logs := make([]batchResult, len(images))
+	for _, raw := range answer.Results {
+		var n struct {
+			Image int `json:"image"`
+		}
+		var log ReadingLog
+		if json.Unmarshal(raw, &n) != nil || json.Unmarshal(raw, &log) != nil {
+			continue
+		}
+		// Ignore numbers for images that weren't sent, and any second
+		// answer for the same image.
+		if n.Image < 1 || n.Image > len(images) || logs[n.Image-1].Log != nil {
+			continue
+		}
+		log.Raw = string(raw)
+		logs[n.Image-1].Log = &log
+	}
+	for i := range logs {
+		if logs[i].Log == nil {
+			logs[i].Err = fmt.Errorf("%w: the batch's response had no result for this image", errParse)
+		}
+	}
+	return logs, nil
+}
+
+// ParseReadingLogBatch reads each image as ParseReadingLog does.
+func (f *fakeClient) ParseReadingLogBatch(ctx context.Context, images []batchImage, form FormTemplate) ([]batchResult, error) {
+	results := make([]batchResult, len(images))
+	for i, img := range images {
+		results[i].Log, results[i].Err = f.ParseReadingLog(ctx, img.MediaType, img.Data, form)
+	}
+	return results, nil
+}
+
+// batchAnswer turns a worked example's answer, a single log, into the
+// answer for a batch of one photo.
+func batchAnswer(answer []byte) ([]byte, error) {
+	var log map[string]any
+	if err := json.Unmarshal(answer, &log); err != nil {
+		return nil, err
+	}
+	log["image"] = 1
+	return json.Marshal(map[string]any{"results": []any{log}})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// defaultCertificate is the certificate text. Lines starting with "# " are
+// set as a title and "## " as the student's name; other lines are body text.
+const defaultCertificate = `# Certificate of Reading
+This certifies that
+## {{.Name}}
+of {{.Class}}'s class
+read {{.Minutes}} minutes {{.Period}},
+reaching the goal of {{.Goal}} minutes!
+`
+
+// certificateData is what a certificate template can refer to.
+type certificateData struct {
+	Name    string
+	Grade   string
+	Class   string
+	Minutes int
+	Goal    int
+	Week    string
+	Period  string // "the week of 02-02" or "this season"
+}
+
+// runCertificates writes a certificate page for every student who met the
+// minutes goal.
+func runCertificates(args []string) error {
+	fs := flag.NewFlagSet("certificates", flag.ContinueOnError)
+	goal := fs.Int("goal", 120, "minutes a student must read to earn a certificate")
+	week := fs.String("week", "", "week to award (default: the most recent)")
+	season := fs.Bool("season", false, "award on season totals instead of a single week")
+	tmplPath := fs.String("template", "", "certificate text template (default: built in)")
+	out := fs.String("out", "", "PDF to write (default: certificates-<week>.pdf)")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	text := defaultCertificate
+	if *tmplPath != "" {
+		data, err := os.ReadFile(*tmplPath)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("certificate").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("certificate template: %w", err)
+	}
+
+	logs, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	period := "this season"
+	if *season {
+		*week = "season"
+	} else {
+		if *week == "" {
+			*week = latestWeek(logs)
+		}
+		var inWeek []ReadingLog
+		for _, log := range logs {
+			if weekOf(log) == *week {
+				inWeek = append(inWeek, log)
+			}
+		}
+		if len(inWeek) == 0 {
+			return fmt.Errorf("no results for week %s", *week)
+		}
+		logs = inWeek
+		period = "the week of " + *week
+	}
+	if *out == "" {
+		*out = "certificates-" + fileSafe(*week) + ".pdf"
+	}
+
+	var awards []certificateData
+	for _, t := range aggregateStudents(logs) {
+		if t.Minutes >= *goal {
+			awards = append(awards, certificateData{
+				Name: t.Name, Grade: t.Grade, Class: t.Classroom,
+				Minutes: t.Minutes, Goal: *goal, Week: *week, Period: period,
+			})
+		}
+	}
+	if len(awards) == 0 {
+		yellow.Printf("  No students reached %d minutes for %s\n", *goal, period)
+		return nil
+	}
+
+	pdf := fpdf.New("L", "mm", "Letter", "")
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	for _, a := range awards {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, a); err != nil {
+			return fmt.Errorf("certificate template: %w", err)
+		}
+		drawCertificate(pdf, tr, buf.String())
+		fmt.Printf("  %s %s\n", green.Sprint("★"), fmt.Sprintf("%s (%s) — %d min", a.Name, a.Class, a.Minutes))
+	}
+	if err := savePDF(pdf, *out); err != nil {
+		return err
+	}
+	boldGrn.Printf("  Wrote %d certificate(s) to %s\n", len(awards), *out)
+	return nil
+}
+
+// drawCertificate lays out one certificate page: a double border and the
+// rendered template lines centred down the page.
+func drawCertificate(pdf *fpdf.Fpdf, tr func(string) string, text string) {
+	pdf.AddPage()
+	w, h := pdf.GetPageSize()
+
+	pdf.SetDrawColor(37, 99, 235)
+	pdf.SetLineWidth(2)
+	pdf.Rect(10, 10, w-20, h-20, "D")
+	pdf.SetLineWidth(0.5)
+	pdf.Rect(15, 15, w-30, h-30, "D")
+
+	type line struct {
+		text  string
+		style string
+		size  float64
+	}
+	var lines []line
+	height := 0.0
+	for _, l := range strings.Split(strings.TrimSpace(text), "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(l, "## "):
+			lines = append(lines, line{strings.TrimPrefix(l, "## "), "BI", 36})
+		case strings.HasPrefix(l, "# "):
+			lines = append(lines, line{strings.TrimPrefix(l, "# "), "B", 30})
+		default:
+			lines = append(lines, line{l, "", 16})
+		}
+		height += lines[len(lines)-1].size * 0.6
+	}
+
+	pdf.SetY((h - height) / 2)
+	for _, l := range lines {
+		pdf.SetFont("Times", l.style, l.size)
+		if l.size > 16 {
+			pdf.SetTextColor(30, 58, 138)
+		} else {
+			pdf.SetTextColor(40, 40, 40)
+		}
+		pdf.CellFormat(0, l.size*0.6, tr(l.text), "", 1, "C", false, 0, "")
+	}
+}
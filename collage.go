@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// A collage's tiles are separated by gutters: bands at least minGutter
+// pixels across, running the full width or height of what they divide,
+// whose gray levels vary by no more than gutterRange. Each tile must be at
+// least minTileSide pixels on its shorter side and no more than
+// maxTileAspect times longer than it is wide, which keeps the blank bands
+// across a scanned form from being taken for gutters.
+const (
+	minGutter     = 2
+	gutterRange   = 12
+	minTileSide   = 200
+	maxTileAspect = 3.0
+)
+
+// splitCollage cuts a collage of photos, as made by a phone's collage app,
+// into its tiles, each returned as a JPEG, in order from top to bottom and
+// left to right. An image that isn't a collage is returned as nil. The
+// image is turned upright first if its EXIF data says it was taken
+// sideways.
+func splitCollage(data []byte) ([][]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if orientation := exifOrientationOf(exifOf(data)); orientation > 1 {
+		img = orient(img, img.Bounds(), orientation)
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Rect, img, img.Bounds().Min, draw.Src)
+
+	tiles := collageTiles(gray, gray.Rect)
+	if len(tiles) < 2 {
+		return nil, nil
+	}
+	for _, t := range tiles {
+		short, long := min(t.Dx(), t.Dy()), max(t.Dx(), t.Dy())
+		if short < minTileSide || float64(long) > maxTileAspect*float64(short) {
+			return nil, nil
+		}
+	}
+
+	type subImager interface {
+		SubImage(r image.Rectangle) image.Image
+	}
+	sub, ok := img.(subImager)
+	if !ok {
+		rgba := image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Rect, img, img.Bounds().Min, draw.Src)
+		sub = rgba
+	}
+	out := make([][]byte, len(tiles))
+	for i, t := range tiles {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, sub.SubImage(t), &jpeg.Options{Quality: 90}); err != nil {
+			return nil, fmt.Errorf("JPEG encoding failed: %w", err)
+		}
+		out[i] = buf.Bytes()
+	}
+	return out, nil
+}
+
+// collageTiles divides r into the tiles between its gutters: the bands
+// across it first, then the columns in each band, and so on, so that any
+// layout made by cutting the collage straight across or down is found.
+func collageTiles(gray *image.Gray, r image.Rectangle) []image.Rectangle {
+	r = trimBorder(gray, r)
+	if r.Empty() {
+		return nil
+	}
+	for _, across := range []bool{true, false} {
+		parts := cutAtGutters(gray, r, across)
+		if len(parts) < 2 {
+			continue
+		}
+		var tiles []image.Rectangle
+		for _, part := range parts {
+			tiles = append(tiles, collageTiles(gray, part)...)
+		}
+		return tiles
+	}
+	return []image.Rectangle{r}
+}
+
+// cutAtGutters returns the parts of r between the gutters running across it
+// (or down it, if across is false), or just r if it has none.
+func cutAtGutters(gray *image.Gray, r image.Rectangle, across bool) []image.Rectangle {
+	lo, hi := r.Min.Y, r.Max.Y
+	if !across {
+		lo, hi = r.Min.X, r.Max.X
+	}
+	var parts []image.Rectangle
+	start, run := lo, 0
+	for i := lo; i <= hi; i++ {
+		if i < hi && isUniformLine(gray, r, i, across) {
+			run++
+			continue
+		}
+		if run >= minGutter && i-run > start {
+			parts = append(parts, span(r, start, i-run, across))
+			start = i
+		}
+		run = 0
+	}
+	if len(parts) == 0 {
+		return []image.Rectangle{r}
+	}
+	return append(parts, span(r, start, hi, across))
+}
+
+// span returns the part of r from lo to hi, as rows if across is true and
+// as columns otherwise.
+func span(r image.Rectangle, lo, hi int, across bool) image.Rectangle {
+	if across {
+		return image.Rect(r.Min.X, lo, r.Max.X, hi)
+	}
+	return image.Rect(lo, r.Min.Y, hi, r.Max.Y)
+}
+
+// trimBorder returns r without the uniform rows and columns around its
+// edges, such as a collage's outer border.
+func trimBorder(gray *image.Gray, r image.Rectangle) image.Rectangle {
+	for r.Min.Y < r.Max.Y && isUniformLine(gray, r, r.Min.Y, true) {
+		r.Min.Y++
+	}
+	for r.Max.Y > r.Min.Y && isUniformLine(gray, r, r.Max.Y-1, true) {
+		r.Max.Y--
+	}
+	for r.Min.X < r.Max.X && isUniformLine(gray, r, r.Min.X, false) {
+		r.Min.X++
+	}
+	for r.Max.X > r.Min.X && isUniformLine(gray, r, r.Max.X-1, false) {
+		r.Max.X--
+	}
+	return r
+}
+
+// isUniformLine reports whether row i of r (or column i, if across is
+// false) is all close to one gray level.
+func isUniformLine(gray *image.Gray, r image.Rectangle, i int, across bool) bool {
+	lo, hi := uint8(255), uint8(0)
+	if across {
+		row := gray.Pix[gray.PixOffset(r.Min.X, i) : gray.PixOffset(r.Max.X-1, i)+1]
+		for _, v := range row {
+			lo, hi = min(lo, v), max(hi, v)
+		}
+	} else {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			v := gray.Pix[gray.PixOffset(i, y)]
+			lo, hi = min(lo, v), max(hi, v)
+		}
+	}
+	return hi-lo <= gutterRange
+}
+
+// mergeTiles combines the results read from a collage's tiles, so that
+// tiles showing the same student's log, on different days say, become one
+// result. Tiles are matched by the student's name; a tile where no name
+// could be read joins the only student named on the others, if there is
+// just one. Blank tiles are dropped, unless every tile is blank.
+func mergeTiles(logs []*ReadingLog) []*ReadingLog {
+	var merged []*ReadingLog
+	var nameless []*ReadingLog
+	byName := make(map[string]*ReadingLog)
+	for _, log := range logs {
+		if log.Blank {
+			continue
+		}
+		key := nameKey(log.FullName)
+		if key == "" {
+			nameless = append(nameless, log)
+			continue
+		}
+		if first, ok := byName[key]; ok {
+			*first = mergeTile(*first, *log)
+			continue
+		}
+		byName[key] = log
+		merged = append(merged, log)
+	}
+	for _, log := range nameless {
+		if len(merged) == 1 {
+			*merged[0] = mergeTile(*merged[0], *log)
+			continue
+		}
+		merged = append(merged, log)
+	}
+	if len(merged) == 0 {
+		return logs[:1]
+	}
+	return merged
+}
+
+// mergeTile adds what a second tile shows of a student's log to the first,
+// as the back page of a form is added to its front. A missing name, grade,
+// or day is flagged again afterward, as the other tile may have had it.
+func mergeTile(first, second ReadingLog) ReadingLog {
+	merged := mergePages(first, second)
+	merged.Back, merged.Raw = "", first.Raw
+	flags := merged.Flags[:0]
+	for _, f := range merged.Flags {
+		if f.Check != "incomplete" || !strings.HasSuffix(f.Detail, " could be read") {
+			flags = append(flags, f)
+		}
+	}
+	merged.Flags = flags
+	flagMissing(&merged)
+	flagMissingDays(&merged, reconcileDays(&merged, formOf(merged).days()))
+	return merged
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the tunable settings. Values are layered: built-in defaults,
+// then a readinglogs.yaml (or .toml) file, then READING_LOGS_* environment
+// variables, and finally command-line flags, which use the layered values as
+// their defaults.
+type Config struct {
+	Model             string              `yaml:"model" toml:"model"`
+	MaxTokens         int64               `yaml:"max_tokens" toml:"max_tokens"`
+	Input             string              `yaml:"input" toml:"input"`
+	Output            string              `yaml:"output" toml:"output"`
+	Format            string              `yaml:"format" toml:"format"`               // csv, json, ndjson, xlsx, or parquet, or several separated by commas; empty means by extension
+	CSVDelimiter      string              `yaml:"csv_delimiter" toml:"csv_delimiter"` // comma, semicolon, tab, or pipe; empty for comma
+	CSVBOM            bool                `yaml:"csv_bom" toml:"csv_bom"`             // start CSV files with a byte-order mark, for Excel
+	CSVCRLF           bool                `yaml:"csv_crlf" toml:"csv_crlf"`           // end CSV lines with CRLF, as Windows does
+	Summary           bool                `yaml:"summary" toml:"summary"`             // add subtotal, total, mean, and median rows to CSV and XLSX files
+	SortBy            []string            `yaml:"sort_by" toml:"sort_by"`             // row order: teacher, grade, student, week, total, file; -KEY for descending
+	ICloudAlbum       string              `yaml:"icloud_album" toml:"icloud_album"`   // shared album link for ingest icloud
+	Sources           []string            `yaml:"sources" toml:"sources"`             // where to copy new images from before each run, e.g. dropbox:/ReadingLogs
+	History           string              `yaml:"history" toml:"history"`             // season history file, shared across weekly runs
+	Concurrency       int                 `yaml:"concurrency" toml:"concurrency"`
+	BatchSize         int                 `yaml:"batch_size" toml:"batch_size"`                   // images read in each API request
+	Timeout           string              `yaml:"timeout" toml:"timeout"`                         // how long one image may take to read, e.g. 5m; empty for no limit
+	RequestsPerMinute int                 `yaml:"requests_per_minute" toml:"requests_per_minute"` // API rate limits to stay under; 0 learns the account's
+	TokensPerMinute   int                 `yaml:"tokens_per_minute" toml:"tokens_per_minute"`
+	Archive           string              `yaml:"archive" toml:"archive"`                 // move or copy images into the archive once read; empty for neither
+	ArchivePattern    string              `yaml:"archive_pattern" toml:"archive_pattern"` // where archived images go, as a text/template
+	ScrubArchive      bool                `yaml:"scrub_archive" toml:"scrub_archive"`     // remove metadata from archived images
+	OutlierThreshold  float64             `yaml:"outlier_threshold" toml:"outlier_threshold"`
+	Goal              int                 `yaml:"goal" toml:"goal"`     // weekly minutes goal; 0 for none
+	Goals             map[string]int      `yaml:"goals" toml:"goals"`   // grade → weekly minutes goal, overriding goal
+	Roster            string              `yaml:"roster" toml:"roster"` // CSV of every student and their teacher, for report missing
+	Days              []DayConfig         `yaml:"days" toml:"days"`
+	Year              int                 `yaml:"year" toml:"year"`           // year of the first configured day, for dates written without one; 0 to go by the photo
+	Retention         map[string]string   `yaml:"retention" toml:"retention"` // artifact kind → policy
+	Validation        ValidationRules     `yaml:"validation" toml:"validation"`
+	Email             EmailConfig         `yaml:"email" toml:"email"`
+	SlackWebhook      string              `yaml:"slack_webhook" toml:"slack_webhook"` // posted a summary when a run finishes
+	TeamsWebhook      string              `yaml:"teams_webhook" toml:"teams_webhook"`
+	Webhook           string              `yaml:"webhook" toml:"webhook"`             // posted each result as it is read
+	WebhookBatch      bool                `yaml:"webhook_batch" toml:"webhook_batch"` // post them all at the end of the run instead
+	OnRecord          string              `yaml:"on_record" toml:"on_record"`         // command run with each result on stdin as it is read
+	OnComplete        string              `yaml:"on_complete" toml:"on_complete"`     // command run with the run's results and outputs on stdin at the end
+	Daemon            []DaemonJob         `yaml:"daemon" toml:"daemon"`               // commands the daemon runs on a schedule
+	Grades            map[string]string   `yaml:"grades" toml:"grades"`               // grade as written → canonical grade
+	Teachers          map[string][]string `yaml:"teachers" toml:"teachers"`           // canonical teacher → aliases
+	Fields            []FieldConfig       `yaml:"fields" toml:"fields"`               // extra values to extract
+	Templates         []FormTemplate      `yaml:"templates" toml:"templates"`         // form layouts, when grades use different forms
+	Regions           map[string]Region   `yaml:"regions" toml:"regions"`             // where things are on the form, sent as close-ups
+	PromptFile        string              `yaml:"prompt_file" toml:"prompt_file"`
+	Examples          int                 `yaml:"examples" toml:"examples"`             // corrected examples to include in each prompt
+	Exemplars         string              `yaml:"exemplars" toml:"exemplars"`           // file corrected examples are kept in
+	Pseudonyms        string              `yaml:"pseudonyms" toml:"pseudonyms"`         // local file mapping students to their pseudonyms in anonymized exports
+	Identity          string              `yaml:"identity" toml:"identity"`             // age identity file that progress and outputs are encrypted to; empty for none
+	EncryptTo         []string            `yaml:"encrypt_to" toml:"encrypt_to"`         // further age recipients that can decrypt them
+	Recordings        string              `yaml:"recordings" toml:"recordings"`         // file --record saves responses to
+	AuditDir          string              `yaml:"audit_dir" toml:"audit_dir"`           // per-run JSONL logs of API requests; empty for none
+	RunsDir           string              `yaml:"runs_dir" toml:"runs_dir"`             // each run's output and the run ledger; empty for none
+	LogFile           string              `yaml:"log_file" toml:"log_file"`             // structured log of each run; empty for none
+	LogLevel          string              `yaml:"log_level" toml:"log_level"`           // debug, info, warn, or error
+	LogFormat         string              `yaml:"log_format" toml:"log_format"`         // text or json
+	ImagePreview      string              `yaml:"image_preview" toml:"image_preview"`   // how review shows images: auto, kitty, iterm, sixel, open, or none
+	UploadImages      bool                `yaml:"upload_images" toml:"upload_images"`   // send images by Files API ID rather than inline
+	MultiStudent      bool                `yaml:"multi_student" toml:"multi_student"`   // images may show several students' logs
+	FlattenPages      bool                `yaml:"flatten_pages" toml:"flatten_pages"`   // straighten photos of pages taken at an angle
+	SplitCollages     bool                `yaml:"split_collages" toml:"split_collages"` // read each photo in a collage on its own
+	FallbackModel     string              `yaml:"fallback_model" toml:"fallback_model"` // used while model is overloaded; "none" for no fallback
+	VerifyModel       string              `yaml:"verify_model" toml:"verify_model"`     // second model for --verify; empty means the same model
+	Headless          bool                `yaml:"headless" toml:"headless"`             // run with no terminal, as in a container (also --headless)
+
+	path       string // file the config was loaded from, if any
+	profile    string // the profile in use, if any
+	profileDir string // the profile's folder, which is the working directory
+}
+
+// DayConfig is one day column on the reading log form.
+type DayConfig struct {
+	Day  string `yaml:"day" toml:"day"`
+	Date string `yaml:"date" toml:"date"`
+}
+
+// cfg is the configuration for this invocation, loaded before any command runs.
+var cfg = defaultConfig()
+
+func defaultConfig() *Config {
+	return &Config{
+		Model:            string(defaultModel),
+		MaxTokens:        defaultMaxTokens,
+		Input:            ".",
+		Output:           "reading_logs.csv",
+		SortBy:           []string{"teacher", "grade", "student"},
+		History:          ".history.json",
+		Exemplars:        ".exemplars.json",
+		Pseudonyms:       ".pseudonyms.json",
+		AuditDir:         ".audit",
+		RunsDir:          "runs",
+		LogLevel:         "info",
+		LogFormat:        "text",
+		ImagePreview:     "auto",
+		ArchivePattern:   "processed/{{.Teacher}}/{{.Student}}_{{.Week}}{{.Ext}}",
+		Recordings:       ".recordings.json",
+		Concurrency:      1,
+		BatchSize:        1,
+		OutlierThreshold: outlierThreshold,
+		Days: []DayConfig{
+			{"Friday", "1/30"}, {"Saturday", "1/31"}, {"Sunday", "2/1"},
+			{"Monday", "2/2"}, {"Tuesday", "2/3"}, {"Wednesday", "2/4"},
+			{"Thursday", "2/5"},
+		},
+		Retention:  map[string]string{},
+		Validation: defaultValidationRules(),
+		Email: EmailConfig{
+			Subject: defaultEmailSubject,
+			Body:    defaultEmailBody,
+			Attach:  []string{"csv", "pdf"},
+		},
+	}
+}
+
+// configNames are the file names looked for, in order, in each directory.
+var configNames = []string{"readinglogs.yaml", "readinglogs.yml", "readinglogs.toml"}
+
+// loadConfig builds the layered configuration. READING_LOGS_CONFIG names an
+// explicit file; otherwise the working directory and then ~/.config are
+// searched. READING_LOGS_PROFILE (or --profile) applies one of the file's
+// profiles over its other settings.
+func loadConfig() (*Config, error) {
+	c := defaultConfig()
+
+	path := os.Getenv("READING_LOGS_CONFIG")
+	if path == "" {
+		path = findConfigFile()
+	}
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		c.path = path
+	}
+	if name := os.Getenv("READING_LOGS_PROFILE"); name != "" {
+		if err := c.useProfile(path, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return c, nil
+}
+
+func findConfigFile() string {
+	dirs := []string{"."}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config"), filepath.Join(home, ".config", "readinglogs"))
+	}
+	for _, dir := range dirs {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".toml") {
+		return toml.Unmarshal(data, c)
+	}
+	return yaml.Unmarshal(data, c)
+}
+
+// applyEnv overrides settings from READING_LOGS_* environment variables.
+func (c *Config) applyEnv() error {
+	str := func(key string, dst *string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	str("READING_LOGS_MODEL", &c.Model)
+	str("READING_LOGS_INPUT", &c.Input)
+	str("READING_LOGS_OUTPUT", &c.Output)
+	str("READING_LOGS_FORMAT", &c.Format)
+	str("READING_LOGS_CSV_DELIMITER", &c.CSVDelimiter)
+	str("READING_LOGS_HISTORY", &c.History)
+	str("READING_LOGS_ICLOUD_ALBUM", &c.ICloudAlbum)
+	str("READING_LOGS_ROSTER", &c.Roster)
+	str("READING_LOGS_PROMPT_FILE", &c.PromptFile)
+	str("READING_LOGS_LOG_FILE", &c.LogFile)
+	str("READING_LOGS_LOG_LEVEL", &c.LogLevel)
+	str("READING_LOGS_LOG_FORMAT", &c.LogFormat)
+	str("READING_LOGS_IMAGE_PREVIEW", &c.ImagePreview)
+	str("READING_LOGS_TIMEOUT", &c.Timeout)
+	str("READING_LOGS_IDENTITY", &c.Identity)
+	str("READING_LOGS_SLACK_WEBHOOK", &c.SlackWebhook)
+	str("READING_LOGS_TEAMS_WEBHOOK", &c.TeamsWebhook)
+	str("READING_LOGS_WEBHOOK", &c.Webhook)
+	str("READING_LOGS_ON_RECORD", &c.OnRecord)
+	str("READING_LOGS_ON_COMPLETE", &c.OnComplete)
+	str("READING_LOGS_ARCHIVE_PATTERN", &c.ArchivePattern)
+	str("READING_LOGS_EXEMPLARS", &c.Exemplars)
+	str("READING_LOGS_PSEUDONYMS", &c.Pseudonyms)
+	str("READING_LOGS_RECORDINGS", &c.Recordings)
+	str("READING_LOGS_FALLBACK_MODEL", &c.FallbackModel)
+	str("READING_LOGS_VERIFY_MODEL", &c.VerifyModel)
+	if v := os.Getenv("READING_LOGS_SORT_BY"); v != "" {
+		c.SortBy = strings.Split(v, ",")
+	}
+	if v := os.Getenv("READING_LOGS_SOURCES"); v != "" {
+		c.Sources = strings.Split(v, ",")
+	}
+	if v := os.Getenv("READING_LOGS_ENCRYPT_TO"); v != "" {
+		c.EncryptTo = strings.Split(v, ",")
+	}
+
+	// These are off when empty, so being set at all counts.
+	for key, dst := range map[string]*string{
+		"READING_LOGS_ARCHIVE":   &c.Archive,
+		"READING_LOGS_AUDIT_DIR": &c.AuditDir,
+		"READING_LOGS_RUNS_DIR":  &c.RunsDir,
+	} {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+
+	for key, dst := range map[string]*bool{
+		"READING_LOGS_HEADLESS":       &c.Headless,
+		"READING_LOGS_CSV_BOM":        &c.CSVBOM,
+		"READING_LOGS_CSV_CRLF":       &c.CSVCRLF,
+		"READING_LOGS_SUMMARY":        &c.Summary,
+		"READING_LOGS_SCRUB_ARCHIVE":  &c.ScrubArchive,
+		"READING_LOGS_UPLOAD_IMAGES":  &c.UploadImages,
+		"READING_LOGS_MULTI_STUDENT":  &c.MultiStudent,
+		"READING_LOGS_FLATTEN_PAGES":  &c.FlattenPages,
+		"READING_LOGS_SPLIT_COLLAGES": &c.SplitCollages,
+	} {
+		if v := os.Getenv(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = b
+		}
+	}
+
+	if v := os.Getenv("READING_LOGS_MAX_TOKENS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("READING_LOGS_MAX_TOKENS: %w", err)
+		}
+		c.MaxTokens = n
+	}
+	if v := os.Getenv("READING_LOGS_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("READING_LOGS_CONCURRENCY: %w", err)
+		}
+		c.Concurrency = n
+	}
+	if v := os.Getenv("READING_LOGS_GOAL"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("READING_LOGS_GOAL: %w", err)
+		}
+		c.Goal = n
+	}
+	for key, dst := range map[string]*int{
+		"READING_LOGS_EXAMPLES":            &c.Examples,
+		"READING_LOGS_BATCH_SIZE":          &c.BatchSize,
+		"READING_LOGS_REQUESTS_PER_MINUTE": &c.RequestsPerMinute,
+		"READING_LOGS_TOKENS_PER_MINUTE":   &c.TokensPerMinute,
+	} {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = n
+		}
+	}
+	if v := os.Getenv("READING_LOGS_OUTLIER_THRESHOLD"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("READING_LOGS_OUTLIER_THRESHOLD: %w", err)
+		}
+		c.OutlierThreshold = f
+	}
+	return nil
+}
+
+// imageTimeout is the timeout setting as a duration; zero for no limit.
+func (c *Config) imageTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.Timeout)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("can't be negative")
+	}
+	return d, err
+}
+
+func (c *Config) validate() error {
+	if c.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be positive")
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	if c.BatchSize < 1 || c.BatchSize > maxBatchSize {
+		return fmt.Errorf("batch_size must be from 1 to %d", maxBatchSize)
+	}
+	if _, err := c.imageTimeout(); err != nil {
+		return fmt.Errorf("timeout: %w", err)
+	}
+	if _, err := csvDelimiter(c.CSVDelimiter); err != nil {
+		return fmt.Errorf("csv_delimiter: %w", err)
+	}
+	if err := checkSortKeys(c.SortBy); err != nil {
+		return fmt.Errorf("sort_by: %w", err)
+	}
+	for _, spec := range c.Sources {
+		if _, err := openSource(spec); err != nil {
+			return fmt.Errorf("sources: %w", err)
+		}
+	}
+	if c.RequestsPerMinute < 0 || c.TokensPerMinute < 0 {
+		return fmt.Errorf("requests_per_minute and tokens_per_minute cannot be negative")
+	}
+	if !slices.Contains(archiveModes, c.Archive) {
+		return fmt.Errorf("archive must be move or copy")
+	}
+	if _, err := parseArchivePattern(c.ArchivePattern); err != nil {
+		return fmt.Errorf("archive_pattern: %w", err)
+	}
+	if c.Examples < 0 {
+		return fmt.Errorf("examples cannot be negative")
+	}
+	if _, err := parseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("log_level: %w", err)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("log_format must be text or json")
+	}
+	if !slices.Contains(previewModes, c.ImagePreview) {
+		return fmt.Errorf("image_preview must be one of %s", strings.Join(previewModes, ", "))
+	}
+	if len(c.Days) == 0 {
+		return fmt.Errorf("at least one day must be configured")
+	}
+	if c.Year != 0 && (c.Year < 1000 || c.Year > 9999) {
+		return fmt.Errorf("year must be a four-digit year")
+	}
+	if v := c.Validation; v.MaxPerDay < 0 || v.MaxPerWeek < 0 || v.RepeatedMin < 0 || v.PhotoDays < 0 {
+		return fmt.Errorf("validation limits cannot be negative")
+	}
+	if c.Goal < 0 {
+		return fmt.Errorf("goal cannot be negative")
+	}
+	for grade, goal := range c.Goals {
+		if goal < 0 {
+			return fmt.Errorf("goals: the goal for grade %s cannot be negative", grade)
+		}
+	}
+	if c.SlackWebhook != "" {
+		if err := validateWebhook(c.SlackWebhook); err != nil {
+			return fmt.Errorf("slack_webhook: %w", err)
+		}
+	}
+	if c.TeamsWebhook != "" {
+		if err := validateWebhook(c.TeamsWebhook); err != nil {
+			return fmt.Errorf("teams_webhook: %w", err)
+		}
+	}
+	if err := validateDaemon(c.Daemon); err != nil {
+		return err
+	}
+	if err := c.Email.validate(); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if err := validateFields(c.Fields); err != nil {
+		return err
+	}
+	if err := validateRegions(c.Regions, c.Days, c.Fields); err != nil {
+		return err
+	}
+	if err := validateTemplates(c.Templates, c.Days, c.Fields); err != nil {
+		return err
+	}
+	for kind, policy := range c.Retention {
+		if _, err := parseRetention(policy); err != nil {
+			return fmt.Errorf("retention.%s: %w", kind, err)
+		}
+	}
+	return nil
+}
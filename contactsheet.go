@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image/jpeg"
+	"sort"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Contact sheet layout, in millimetres on US Letter: three results a page,
+// each a thumbnail of its image beside what was read from it.
+const (
+	sheetPerPage = 3
+	sheetThumbW  = 72.0
+	sheetPixels  = 900 // longest side of an embedded thumbnail
+)
+
+// runReportContactSheet writes a PDF for checking results against their
+// images before they are published: each result's image beside its
+// student, days, total, and anything flagged.
+func runReportContactSheet(args []string) error {
+	fs := flag.NewFlagSet("report contact-sheet", flag.ContinueOnError)
+	week := fs.String("week", "", "only results for this week (default: every result in the progress file)")
+	flagged := fs.Bool("flagged", false, "only results with something flagged")
+	out := fs.String("out", "contact-sheet.pdf", "PDF to write")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	var logs []ReadingLog
+	for _, log := range completedLogs(progress) {
+		if *week != "" && weekOf(log) != *week {
+			continue
+		}
+		if *flagged && len(log.Flags)+len(cfg.Validation.check(log)) == 0 {
+			continue
+		}
+		logs = append(logs, log)
+	}
+	if len(logs) == 0 {
+		return fmt.Errorf("no results to check in %s", progressFile)
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		if a, b := classroomOf(logs[i]), classroomOf(logs[j]); a != b {
+			return a < b
+		}
+		return logs[i].FullName < logs[j].FullName
+	})
+
+	missing, err := writeContactSheet(*out, logs)
+	if err != nil {
+		return err
+	}
+	for _, name := range missing {
+		logger.Warn("could not show "+name+" on the contact sheet", "image", name)
+	}
+	pages := (len(logs) + sheetPerPage - 1) / sheetPerPage
+	boldGrn.Printf("  Wrote %d result(s) on %d page(s) to %s\n", len(logs), pages, *out)
+	return nil
+}
+
+// writeContactSheet lays out logs on a contact sheet at path. It returns
+// the images that couldn't be shown, whose results appear beside an empty
+// frame.
+func writeContactSheet(path string, logs []ReadingLog) (missing []string, err error) {
+	pdf := fpdf.New("P", "mm", "Letter", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(false, pageMargin)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // core fonts are cp1252
+
+	pageW, pageH := pdf.GetPageSize()
+	width := pageW - 2*pageMargin
+	cellH := (pageH - 2*pageMargin) / sheetPerPage
+	textX := pageMargin + sheetThumbW + 6
+	textW := width - sheetThumbW - 6
+
+	for i, log := range logs {
+		if i%sheetPerPage == 0 {
+			pdf.AddPage()
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.SetTextColor(140, 140, 140)
+			pdf.SetXY(pageMargin, pageH-pageMargin+3)
+			pdf.CellFormat(width, 4, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "R", false, 0, "")
+		}
+		top := pageMargin + float64(i%sheetPerPage)*cellH
+		if i%sheetPerPage > 0 {
+			pdf.SetDrawColor(200, 200, 200)
+			pdf.Line(pageMargin, top, pageW-pageMargin, top)
+		}
+		if !placeThumbnail(pdf, log.Source, pageMargin, top+4, sheetThumbW, cellH-8) {
+			missing = append(missing, log.Source)
+		}
+
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetXY(textX, top+4)
+		pdf.SetFont("Helvetica", "B", 13)
+		pdf.CellFormat(textW, 7, tr(orUnknown(log.FullName)), "", 1, "L", false, 0, "")
+		pdf.SetX(textX)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(textW, 5, tr(fmt.Sprintf("Grade %s  ·  %s  ·  week of %s", orUnknown(log.Grade), orUnknown(classroomOf(log)), weekOf(log))), "", 1, "L", false, 0, "")
+		pdf.Ln(2)
+
+		pdf.SetTextColor(0, 0, 0)
+		for _, e := range log.ReadingEntries {
+			pdf.SetX(textX)
+			minutes := "—"
+			if e.Minutes > 0 {
+				minutes = fmt.Sprintf("%d min", e.Minutes)
+			}
+			pdf.CellFormat(38, 5, tr(e.Day+" "+shortDate(e.Date)), "", 0, "L", false, 0, "")
+			pdf.CellFormat(20, 5, tr(minutes), "", 1, "R", false, 0, "")
+		}
+		pdf.SetX(textX)
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(38, 7, "Total", "T", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 7, fmt.Sprintf("%d min", totalMinutes(log)), "T", 1, "R", false, 0, "")
+
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.SetTextColor(180, 40, 40)
+		var problems []string
+		problems = append(problems, cfg.Validation.check(log)...)
+		for _, f := range log.Flags {
+			problems = append(problems, f.Check+": "+f.Detail)
+		}
+		for _, problem := range problems {
+			if pdf.GetY() > top+cellH-12 {
+				break
+			}
+			pdf.SetX(textX)
+			pdf.MultiCell(textW, 4, tr("! "+problem), "", "L", false)
+		}
+
+		pdf.SetTextColor(140, 140, 140)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetXY(textX, top+cellH-8)
+		source := log.Source
+		if log.Back != "" {
+			source += " + " + log.Back
+		}
+		pdf.CellFormat(textW, 4, tr(source), "", 0, "L", false, 0, "")
+	}
+	return missing, savePDF(pdf, path)
+}
+
+// placeThumbnail draws an image scaled to fit the box at x, y, or an empty
+// frame if it can't be loaded, and reports whether it was drawn.
+func placeThumbnail(pdf *fpdf.Fpdf, name string, x, y, w, h float64) bool {
+	img, err := decodeImage(imageOf(name))
+	var buf bytes.Buffer
+	if err == nil {
+		err = jpeg.Encode(&buf, thumbnail(img, sheetPixels), &jpeg.Options{Quality: 80})
+	}
+	if err != nil {
+		pdf.SetDrawColor(180, 180, 180)
+		pdf.Rect(x, y, w, h, "D")
+		return false
+	}
+
+	b := img.Bounds()
+	scale := min(w/float64(b.Dx()), h/float64(b.Dy()))
+	opts := fpdf.ImageOptions{ImageType: "JPG"}
+	pdf.RegisterImageOptionsReader(name, opts, &buf)
+	pdf.ImageOptions(name, x, y, float64(b.Dx())*scale, float64(b.Dy())*scale, false, opts, 0, "")
+	return true
+}
+
+// orUnknown returns s, or a question mark if nothing was read.
+func orUnknown(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "?"
+	}
+	return s
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"slices"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Region is where something is on a form: its left edge, top edge, width,
+// and height, each a fraction of the upright page's width or height, so
+// [0.1, 0.05, 0.5, 0.08] starts a tenth of the way across.
+type Region [4]float64
+
+// Crops are enlarged until their shorter side is cropMinSide pixels, but
+// not past cropMaxSide on the longer, so a few handwritten digits fill the
+// image the model sees.
+const (
+	cropMinSide = 400
+	cropMaxSide = 1568
+)
+
+// crop is a close-up of one region of an image, sent with it.
+type crop struct {
+	Label     string // what it shows, e.g. "the minutes for Friday 1/30"
+	MediaType string
+	Data      string // base64-encoded
+}
+
+type cropsKey struct{}
+
+// withCrops notes the close-ups to send with the image being read.
+func withCrops(ctx context.Context, crops []crop) context.Context {
+	return context.WithValue(ctx, cropsKey{}, crops)
+}
+
+// cropsFrom returns the close-ups noted by withCrops, if any.
+func cropsFrom(ctx context.Context) []crop {
+	crops, _ := ctx.Value(cropsKey{}).([]crop)
+	return crops
+}
+
+// regions returns the form's regions: its own, or else the top-level ones.
+func (t FormTemplate) regions() map[string]Region {
+	if len(t.Regions) > 0 {
+		return t.Regions
+	}
+	return cfg.Regions
+}
+
+// regionLabels returns what each region a form can have shows, keyed by
+// the name it is given in the config, in the order the close-ups are sent:
+// the student's details, each day's minutes, then the extra fields.
+func regionLabels(days []DayConfig, fields []FieldConfig) ([]string, map[string]string) {
+	keys := []string{"name", "grade", "teacher"}
+	labels := map[string]string{
+		"name":    "the student's name",
+		"grade":   "the grade",
+		"teacher": "the homeroom teacher's name",
+	}
+	for _, d := range days {
+		label := "the minutes for " + d.Day + " " + d.Date
+		for _, key := range []string{d.Date, d.Day} {
+			if _, ok := labels[key]; key != "" && !ok {
+				keys = append(keys, key)
+				labels[key] = label
+			}
+		}
+	}
+	for _, f := range fields {
+		keys = append(keys, f.Name)
+		labels[f.Name] = "the " + f.Name
+	}
+	return keys, labels
+}
+
+// validateRegions checks that each region is named for something on the
+// form and lies on the page.
+func validateRegions(regions map[string]Region, days []DayConfig, fields []FieldConfig) error {
+	_, labels := regionLabels(days, fields)
+	for key, r := range regions {
+		if _, ok := labels[key]; !ok {
+			return fmt.Errorf("regions: %q isn't name, grade, teacher, a day, or an extra field", key)
+		}
+		if r[0] < 0 || r[1] < 0 || r[2] <= 0 || r[3] <= 0 || r[0]+r[2] > 1.001 || r[1]+r[3] > 1.001 {
+			return fmt.Errorf("regions.%s: [left, top, width, height] must be fractions of the page that stay on it", key)
+		}
+	}
+	return nil
+}
+
+// cropRegions returns a close-up of each of the form's regions in an
+// image, which is turned upright first when its EXIF data says it was
+// taken sideways. It returns nil for a form with no regions.
+func cropRegions(data []byte, form FormTemplate) ([]crop, error) {
+	regions := form.regions()
+	if len(regions) == 0 {
+		return nil, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	orientation := exifOrientationOf(exifOf(data))
+
+	keys, labels := regionLabels(form.days(), form.fields())
+	var crops []crop
+	for _, key := range keys {
+		r, ok := regions[key]
+		if !ok || slices.ContainsFunc(crops, func(c crop) bool { return c.Label == labels[key] }) {
+			continue
+		}
+		encoded, err := cropJPEG(img, orientation, r)
+		if err != nil {
+			return nil, fmt.Errorf("cropping %s: %w", key, err)
+		}
+		crops = append(crops, crop{Label: labels[key], MediaType: "image/jpeg", Data: encoded})
+	}
+	return crops, nil
+}
+
+// cropJPEG cuts region r of the upright page out of img, enlarges it, and
+// returns it as a base64-encoded JPEG.
+func cropJPEG(img image.Image, orientation int, r Region) (string, error) {
+	// Find the region in the image as stored, which is on its side or
+	// mirrored for orientations 2 to 8.
+	x, y, w, h := r[0], r[1], r[2], r[3]
+	switch orientation {
+	case 2:
+		x = 1 - x - w
+	case 3:
+		x, y = 1-x-w, 1-y-h
+	case 4:
+		y = 1 - y - h
+	case 5:
+		x, y, w, h = y, x, h, w
+	case 6:
+		x, y, w, h = y, 1-x-w, h, w
+	case 7:
+		x, y, w, h = 1-y-h, 1-x-w, h, w
+	case 8:
+		x, y, w, h = 1-y-h, x, h, w
+	}
+	b := img.Bounds()
+	rect := image.Rect(
+		b.Min.X+int(x*float64(b.Dx())), b.Min.Y+int(y*float64(b.Dy())),
+		b.Min.X+int((x+w)*float64(b.Dx())), b.Min.Y+int((y+h)*float64(b.Dy())),
+	).Intersect(b)
+	if rect.Empty() {
+		return "", fmt.Errorf("the region is empty")
+	}
+	part := orient(img, rect, orientation)
+
+	pw, ph := part.Bounds().Dx(), part.Bounds().Dy()
+	scale := float64(cropMinSide) / float64(min(pw, ph))
+	scale = min(scale, float64(cropMaxSide)/float64(max(pw, ph)))
+	if scale > 1 {
+		big := image.NewRGBA(image.Rect(0, 0, int(float64(pw)*scale), int(float64(ph)*scale)))
+		draw.CatmullRom.Scale(big, big.Bounds(), part, part.Bounds(), draw.Src, nil)
+		part = big
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, part, &jpeg.Options{Quality: 90}); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// orient copies rect of img, turned and flipped the way an EXIF orientation
+// says to draw it.
+func orient(img image.Image, rect image.Rectangle, orientation int) image.Image {
+	w, h := rect.Dx(), rect.Dy()
+	if orientation >= 5 && orientation <= 8 {
+		w, h = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := rect.Dx(), rect.Dy()
+	for y := range h {
+		for x := range w {
+			sx, sy := x, y
+			switch orientation {
+			case 2:
+				sx = sw - 1 - x
+			case 3:
+				sx, sy = sw-1-x, sh-1-y
+			case 4:
+				sy = sh - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, sh-1-x
+			case 7:
+				sx, sy = sw-1-y, sh-1-x
+			case 8:
+				sx, sy = sw-1-y, x
+			}
+			out.Set(x, y, img.At(rect.Min.X+sx, rect.Min.Y+sy))
+		}
+	}
+	return out
+}
+
+// cropIntro introduces an image's close-ups to the model.
+const cropIntro = "Close-ups of parts of the form follow, enlarged so small handwriting is easier to read. Where a close-up and the whole image seem to disagree, trust the close-up."
+
+// cropBlock returns a close-up as an image content block.
+func cropBlock(c crop) anthropic.BetaContentBlockParamUnion {
+	return anthropic.NewBetaImageBlock(anthropic.BetaBase64ImageSourceParam{
+		Data:      c.Data,
+		MediaType: anthropic.BetaBase64ImageSourceMediaType(c.MediaType),
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvDelimiters are the field separators --delimiter accepts, by name.
+var csvDelimiters = map[string]rune{
+	",": ',', "comma": ',',
+	";": ';', "semicolon": ';',
+	"tab": '\t', "\t": '\t', `\t`: '\t',
+	"|": '|', "pipe": '|',
+}
+
+// csvDelimiter returns the separator a --delimiter value names; empty is a
+// comma.
+func csvDelimiter(name string) (rune, error) {
+	if name == "" {
+		return ',', nil
+	}
+	if r, ok := csvDelimiters[strings.ToLower(name)]; ok {
+		return r, nil
+	}
+	return 0, fmt.Errorf("unknown delimiter %q (want comma, semicolon, tab, or pipe)", name)
+}
+
+// csvWriter is a csv.Writer that escapes cells a spreadsheet would take for
+// formulas.
+type csvWriter struct{ *csv.Writer }
+
+func (w csvWriter) Write(row []string) error {
+	safe := make([]string, len(row))
+	for i, cell := range row {
+		safe[i] = escapeFormula(cell)
+	}
+	return w.Writer.Write(safe)
+}
+
+func (w csvWriter) WriteAll(rows [][]string) error {
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// isFormula reports whether a spreadsheet would evaluate cell as a formula:
+// it starts with =, +, -, or @ (or a tab or carriage return, which some
+// strip first) and isn't simply a number.
+func isFormula(cell string) bool {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return false
+	}
+	_, err := strconv.ParseFloat(cell, 64)
+	return err != nil
+}
+
+// escapeFormula puts an apostrophe before a cell that would be taken for a
+// formula, so text the model read off a form, such as a name written
+// "=HYPERLINK(...)", is shown as text rather than run when the CSV is opened.
+func escapeFormula(cell string) string {
+	if isFormula(cell) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// unescapeFormula undoes escapeFormula, for CSV files read back in.
+func unescapeFormula(cell string) string {
+	if rest, ok := strings.CutPrefix(cell, "'"); ok && isFormula(rest) {
+		return rest
+	}
+	return cell
+}
+
+// newCSVWriter returns a writer for CSV files this program writes, with
+// the configured delimiter and line endings. With csv_bom it first writes a
+// UTF-8 byte-order mark, which Excel needs to read accented names
+// correctly.
+func newCSVWriter(w io.Writer) (csvWriter, error) {
+	comma, err := csvDelimiter(cfg.CSVDelimiter)
+	if err != nil {
+		return csvWriter{}, err
+	}
+	if cfg.CSVBOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return csvWriter{}, err
+		}
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma, cw.UseCRLF = comma, cfg.CSVCRLF
+	return csvWriter{cw}, nil
+}
+
+// readCSV reads every row of a CSV file, such as a roster or an edited
+// export. A byte-order mark is dropped and the delimiter is whichever of
+// comma, semicolon, tab, or pipe the first line has most of, so files saved
+// by spreadsheets in any locale are read alike. Cells escaped by csvWriter
+// are read as they were before.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	comma, most := ',', 0
+	for _, c := range []rune{',', ';', '\t', '|'} {
+		if n := strings.Count(unquoted(string(first)), string(c)); n > most {
+			comma, most = c, n
+		}
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = unescapeFormula(cell)
+		}
+	}
+	return rows, err
+}
+
+// unquoted returns line without its quoted parts, so separators inside
+// quoted fields aren't counted.
+func unquoted(line string) string {
+	var b strings.Builder
+	quoted := false
+	for _, r := range line {
+		if r == '"' {
+			quoted = !quoted
+			continue
+		}
+		if !quoted {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
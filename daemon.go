@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DaemonJob is a command the daemon runs on a schedule.
+type DaemonJob struct {
+	Schedule string `yaml:"schedule" toml:"schedule"` // cron expression, e.g. "0 20 * * *" for every evening at 8pm
+	Run      string `yaml:"run" toml:"run"`           // the command and its flags, e.g. "email --teacher Baker"; "parse" for the default run
+}
+
+// args returns the job's command line as arguments to this program.
+func (j DaemonJob) args() ([]string, error) {
+	args, err := splitArgs(j.Run)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	}
+	if args[0] == "daemon" {
+		return nil, fmt.Errorf("a daemon can't run another daemon")
+	}
+	if args[0] == "parse" {
+		args = args[1:]
+	}
+	return args, nil
+}
+
+// validateDaemon checks every job's schedule and command line.
+func validateDaemon(jobs []DaemonJob) error {
+	for i, j := range jobs {
+		s, err := parseSchedule(j.Schedule)
+		if err != nil {
+			return fmt.Errorf("daemon job %d: schedule: %w", i+1, err)
+		}
+		if s.next(time.Now()).IsZero() {
+			return fmt.Errorf("daemon job %d: schedule %q never runs", i+1, j.Schedule)
+		}
+		if _, err := j.args(); err != nil {
+			return fmt.Errorf("daemon job %d: run: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// runDaemon runs the configured jobs on their schedules until it is stopped.
+// Each job runs as its own process, one at a time, so a job that fails
+// doesn't stop the daemon and a slow one delays rather than overlaps the next.
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	list := fs.Bool("list", false, "list the jobs and when each runs next, then exit")
+	parseFlags(fs, args)
+
+	if len(cfg.Daemon) == 0 {
+		return configErrorf("no jobs; list them under daemon in the config file")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	schedules := make([]*schedule, len(cfg.Daemon))
+	for i, j := range cfg.Daemon {
+		schedules[i], _ = parseSchedule(j.Schedule) // checked with the config
+	}
+
+	now := time.Now()
+	printBanner()
+	var rows [][]string
+	for i, j := range cfg.Daemon {
+		rows = append(rows, []string{j.Schedule, j.Run, schedules[i].next(now).Format("Mon Jan 2 15:04")})
+	}
+	printTable([]string{"Schedule", "Run", "Next"}, rows)
+	if *list {
+		return nil
+	}
+	fmt.Println()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Info("daemon started", "jobs", len(cfg.Daemon))
+
+	// Each wake covers every minute since the last, so jobs due while
+	// another ran, or while the machine slept, still run (once).
+	last := now.Truncate(time.Minute)
+	for {
+		wake := last.Add(time.Minute)
+		select {
+		case <-ctx.Done():
+			logger.Info("daemon stopped")
+			return nil
+		case <-time.After(time.Until(wake)):
+		}
+		now := time.Now().Truncate(time.Minute)
+		for i, j := range cfg.Daemon {
+			due := false
+			for t := last.Add(time.Minute); !t.After(now); t = t.Add(time.Minute) {
+				if schedules[i].matches(t) {
+					due = true
+					break
+				}
+			}
+			if due && ctx.Err() == nil {
+				runJob(ctx, exe, j)
+			}
+		}
+		last = now
+	}
+}
+
+// runJob runs one job to completion. If the daemon is stopped meanwhile, the
+// job is interrupted and given a minute to finish.
+func runJob(ctx context.Context, exe string, j DaemonJob) {
+	args, _ := j.args() // checked with the config
+	cyan.Printf("  %s Running %s\n", time.Now().Format("Mon Jan 2 15:04"), j.Run)
+	logger.Info("job started", "run", j.Run)
+
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = time.Minute
+	start := time.Now()
+	err := cmd.Run()
+	took := time.Since(start).Round(time.Second)
+	if err != nil {
+		red.Printf("  %s %s failed after %s: %v\n\n", glyphs("✗"), j.Run, took, err)
+		logger.ErrorContext(displayed, "job failed", "run", j.Run, "err", err, "duration", took)
+		return
+	}
+	green.Printf("  %s %s finished in %s\n\n", glyphs("✓"), j.Run, took)
+	logger.Info("job finished", "run", j.Run, "duration", took)
+}
+
+// splitArgs splits a command line into arguments at spaces, keeping
+// anything in single or double quotes together.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed %c", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// --- cron schedules -----------------------------------------------------
+
+// schedule is a parsed cron expression: the minutes, hours, days of the
+// month, months, and weekdays it runs in.
+type schedule struct {
+	minute, hour, dom, month, dow [64]bool
+	anyDOM, anyDOW                bool // the field was *, so only the other one counts
+}
+
+// scheduleAliases are the @ shorthands cron understands.
+var scheduleAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+var monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+
+var dayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// parseSchedule parses a five-field cron expression (minute, hour, day of
+// the month, month, day of the week) or an @ shorthand. Fields take *,
+// numbers, names for months and weekdays, ranges, lists, and /steps.
+func parseSchedule(expr string) (*schedule, error) {
+	if alias, ok := scheduleAliases[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = alias
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q should have 5 fields (minute hour day month weekday)", expr)
+	}
+	s := &schedule{anyDOM: fields[2] == "*", anyDOW: fields[4] == "*"}
+	specs := []struct {
+		set      *[64]bool
+		min, max int
+		names    map[string]int
+		label    string
+	}{
+		{&s.minute, 0, 59, nil, "minute"},
+		{&s.hour, 0, 23, nil, "hour"},
+		{&s.dom, 1, 31, nil, "day"},
+		{&s.month, 1, 12, monthNames, "month"},
+		{&s.dow, 0, 7, dayNames, "weekday"},
+	}
+	for i, spec := range specs {
+		if err := parseCronField(fields[i], spec.set, spec.min, spec.max, spec.names); err != nil {
+			return nil, fmt.Errorf("%s: %w", spec.label, err)
+		}
+	}
+	if s.dow[7] {
+		s.dow[0] = true // 7 is Sunday too
+	}
+	return s, nil
+}
+
+// parseCronField marks the values one field selects.
+func parseCronField(field string, set *[64]bool, min, max int, names map[string]int) error {
+	value := func(v string) (int, error) {
+		if n, ok := names[strings.ToLower(v)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("%q is not between %d and %d", v, min, max)
+		}
+		return n, nil
+	}
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return fmt.Errorf("bad step %q", stepText)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(from); err != nil {
+				return err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(to); err != nil {
+					return err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return fmt.Errorf("range %q runs backwards", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// matches reports whether the schedule runs in the minute starting at t.
+func (s *schedule) matches(t time.Time) bool {
+	return s.minute[t.Minute()] && s.hour[t.Hour()] && s.month[int(t.Month())] && s.dayMatches(t)
+}
+
+// next returns the next minute after t the schedule runs in, or the zero
+// time if it runs in none within five years (such as February 30).
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		switch {
+		case !s.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the schedule runs on t's day. As in cron, when
+// both the day of the month and the weekday are given, either one matching
+// is enough.
+func (s *schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.anyDOM && s.anyDOW:
+		return true
+	case s.anyDOM:
+		return dow
+	case s.anyDOW:
+		return dom
+	}
+	return dom || dow
+}
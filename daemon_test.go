@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// Monday, February 2, 2026, 08:30.
+	from := time.Date(2026, time.February, 2, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 2, 2, 8, 45, 0, 0, time.UTC)},
+		{"0 18 * * *", time.Date(2026, 2, 2, 18, 0, 0, 0, time.UTC)},
+		{"0 7 * * *", time.Date(2026, 2, 3, 7, 0, 0, 0, time.UTC)},
+		{"30 8 * * *", time.Date(2026, 2, 3, 8, 30, 0, 0, time.UTC)},
+		{"0 20 * * fri", time.Date(2026, 2, 6, 20, 0, 0, 0, time.UTC)},
+		{"0 20 * * Mon-Wed", time.Date(2026, 2, 2, 20, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1,3,5", time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1,3,5", time.Date(2026, 2, 4, 8, 0, 0, 0, time.UTC)},
+		{"0 0 1 mar *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 10-20/5 * *", time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)},
+		// With both a day of the month and a weekday, either one will do.
+		{"0 6 15 * sat", time.Date(2026, 2, 7, 6, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}}, // never
+	}
+	for _, tt := range tests {
+		s, err := parseSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: next after %s = %s, want %s", tt.expr, from.Format(time.DateTime), got.Format(time.DateTime), tt.want.Format(time.DateTime))
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * * funday",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@fortnightly",
+	} {
+		if _, err := parseSchedule(expr); err == nil {
+			t.Errorf("parseSchedule(%q) succeeded, want an error", expr)
+		}
+	}
+}
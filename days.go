@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// reconcileDays puts a log's entries in the order of the form's days. An
+// entry without a readable date whose day of the week is one of the form's
+// takes that day's date. A day that came back twice keeps the reading that
+// was filled in, or else the first; two that were filled in differently are
+// flagged as a verify check. Entries for dates not on the form are kept
+// after the form's days. It returns the form's days with no entry, which is
+// none if no entry is for one of the form's days at all, as with a form for
+// another week.
+func reconcileDays(log *ReadingLog, days []DayConfig) []DayConfig {
+	index := make(map[string]int, len(days))
+	byName := make(map[string]int, len(days))
+	for i, d := range days {
+		index[dateKey(d.Date)] = i
+		name := dayKey(d.Day)
+		if _, twice := byName[name]; twice {
+			byName[name] = -1 // a two-week form: the day alone doesn't say which
+		} else {
+			byName[name] = i
+		}
+	}
+
+	found := make([]*ReadingEntry, len(days))
+	var others []ReadingEntry
+	for _, e := range log.ReadingEntries {
+		i, ok := index[dateKey(e.Date)]
+		if _, _, _, dated := parseDate(e.Date); !ok && !dated {
+			if j, named := byName[dayKey(e.Day)]; named && j >= 0 {
+				i, ok = j, true
+				e.Date = days[j].Date
+			}
+		}
+		switch {
+		case !ok:
+			others = append(others, e)
+		case found[i] == nil || !filledIn(*found[i]):
+			found[i] = &e
+		case filledIn(e) && e.Minutes != found[i].Minutes:
+			log.Flags = append(log.Flags, Flag{Check: "verify", Detail: fmt.Sprintf("%s %s: %d vs %d min, read twice", days[i].Day, days[i].Date, found[i].Minutes, e.Minutes)})
+		}
+	}
+	if len(others) > 0 && len(others) == len(log.ReadingEntries) {
+		return nil
+	}
+
+	var missing []DayConfig
+	entries := make([]ReadingEntry, 0, len(log.ReadingEntries))
+	for i, e := range found {
+		if e == nil {
+			missing = append(missing, days[i])
+			continue
+		}
+		entries = append(entries, *e)
+	}
+	log.ReadingEntries = append(entries, others...)
+	return missing
+}
+
+// dayKey is the form day names are compared in, so that "lunes" and "Mon."
+// are both Monday.
+func dayKey(day string) string {
+	if name := weekdayOf(day); name != "" {
+		return name
+	}
+	return strings.ToLower(strings.TrimSpace(day))
+}
+
+// flagMissingDays flags each of the form's days the log has no entry for.
+func flagMissingDays(log *ReadingLog, missing []DayConfig) {
+	for _, d := range missing {
+		log.Flags = append(log.Flags, Flag{Check: "incomplete", Detail: fmt.Sprintf("no entry for %s %s could be read", d.Day, d.Date)})
+	}
+}
+
+// fillDays checks that the log has an entry for each of the form's days,
+// in order, and asks the model again for any that are missing. Days still
+// missing after that are flagged as incomplete.
+func (p *pipeline) fillDays(ctx context.Context, mediaType, encoded string, form FormTemplate, log *ReadingLog) error {
+	missing := reconcileDays(log, form.days())
+	if len(missing) == 0 {
+		return nil
+	}
+	entries, err := p.client.AskDays(ctx, mediaType, encoded, form, missing)
+	if err != nil {
+		return fmt.Errorf("asking again for %s: %w", FormTemplate{Days: missing}.dayList(), err)
+	}
+	want := make(map[string]bool, len(missing))
+	for _, d := range missing {
+		want[dateKey(d.Date)] = true
+	}
+	for _, e := range entries {
+		if want[dateKey(e.Date)] {
+			delete(want, dateKey(e.Date))
+			log.ReadingEntries = append(log.ReadingEntries, e)
+		}
+	}
+	flagMissingDays(log, reconcileDays(log, form.days()))
+	return nil
+}
+
+// formOf returns the form template a log was read with, or the top-level
+// form if it was read without one or its template is no longer configured.
+func formOf(log ReadingLog) FormTemplate {
+	if log.Template != "" {
+		if form, err := lookupTemplate(log.Template); err == nil {
+			return form
+		}
+	}
+	return defaultTemplate()
+}
+
+// AskDays asks Claude to look again for days that were missing from its
+// first answer, returning an entry for each it finds.
+func (c *anthropicClient) AskDays(ctx context.Context, mediaType, encodedImage string, form FormTemplate, days []DayConfig) ([]ReadingEntry, error) {
+	entries, _ := extractionSchema(form).Properties.Get("reading_entries")
+	props := jsonschema.NewProperties()
+	props.Set("reading_entries", entries)
+	schema := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           props,
+		Required:             []string{"reading_entries"},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+
+	prompt := fmt.Sprintf(`This reading log was read once already, but the answer had no entry for %s. Look carefully at the form again and return one entry for each of these days: the reading time as a number of minutes, the time copied letter for letter as written, any book title, and whether a parent signed or initialed it. If a day is on the form but left blank, use 0 minutes and leave the written time empty.`, FormTemplate{Days: days}.dayList())
+
+	text, err := c.ask(ctx, "ask-days", mediaType, encodedImage, prompt, generateJSONSchema(schema))
+	if err != nil {
+		return nil, err
+	}
+	var answer struct {
+		ReadingEntries []ReadingEntry `json:"reading_entries"`
+	}
+	if err := json.Unmarshal([]byte(text), &answer); err != nil {
+		return nil, fmt.Errorf("%w: %w\nraw: %s", errParse, err, text)
+	}
+	return answer.ReadingEntries, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestReconcileDays(t *testing.T) {
+	week := []DayConfig{{Day: "Friday", Date: "1/30"}, {Day: "Saturday", Date: "1/31"}, {Day: "Sunday", Date: "2/1"}}
+	twoWeeks := []DayConfig{{Day: "Monday", Date: "2/2"}, {Day: "Tuesday", Date: "2/3"}, {Day: "Monday", Date: "2/9"}}
+	tests := []struct {
+		name    string
+		days    []DayConfig
+		entries []ReadingEntry
+		want    []string // each entry's day, date, and minutes, in order
+		missing []string // the days reported missing
+		flagged bool
+	}{
+		{
+			name:    "put in the form's order",
+			days:    week,
+			entries: []ReadingEntry{{Day: "Sunday", Date: "2/1", Minutes: 10}, {Day: "Friday", Date: "01/30", Minutes: 20}, {Day: "Saturday", Date: "1/31", Minutes: 30}},
+			want:    []string{"Friday 01/30 20", "Saturday 1/31 30", "Sunday 2/1 10"},
+		},
+		{
+			name:    "date taken from the day",
+			days:    week,
+			entries: []ReadingEntry{{Day: "Fri", Minutes: 20}, {Day: "sábado", Date: "?", Minutes: 30}},
+			want:    []string{"Fri 1/30 20", "sábado 1/31 30"},
+			missing: []string{"Sunday"},
+		},
+		{
+			name:    "day alone is ambiguous on a two-week form",
+			days:    twoWeeks,
+			entries: []ReadingEntry{{Day: "Monday", Minutes: 20}, {Day: "Tuesday", Date: "2/3", Minutes: 15}},
+			want:    []string{"Tuesday 2/3 15", "Monday  20"},
+			missing: []string{"Monday", "Monday"},
+		},
+		{
+			name:    "blank reading gives way to a filled-in one",
+			days:    week,
+			entries: []ReadingEntry{{Day: "Friday", Date: "1/30"}, {Day: "Friday", Date: "1/30", Minutes: 25}},
+			want:    []string{"Friday 1/30 25"},
+			missing: []string{"Saturday", "Sunday"},
+		},
+		{
+			name:    "two different readings of a day are flagged",
+			days:    week,
+			entries: []ReadingEntry{{Day: "Friday", Date: "1/30", Minutes: 25}, {Day: "Friday", Date: "1/30", Minutes: 35}},
+			want:    []string{"Friday 1/30 25"},
+			missing: []string{"Saturday", "Sunday"},
+			flagged: true,
+		},
+		{
+			name:    "days off the form kept at the end",
+			days:    week,
+			entries: []ReadingEntry{{Day: "Monday", Date: "2/2", Minutes: 5}, {Day: "Friday", Date: "1/30", Minutes: 20}},
+			want:    []string{"Friday 1/30 20", "Monday 2/2 5"},
+			missing: []string{"Saturday", "Sunday"},
+		},
+		{
+			name:    "form for another week",
+			days:    week,
+			entries: []ReadingEntry{{Day: "Monday", Date: "3/2", Minutes: 5}, {Day: "Tuesday", Date: "3/3", Minutes: 10}},
+			want:    []string{"Monday 3/2 5", "Tuesday 3/3 10"},
+		},
+	}
+	for _, tt := range tests {
+		log := ReadingLog{ReadingEntries: tt.entries}
+		missing := reconcileDays(&log, tt.days)
+
+		var got, gotMissing []string
+		for _, e := range log.ReadingEntries {
+			got = append(got, e.Day+" "+e.Date+" "+strconv.Itoa(e.Minutes))
+		}
+		for _, d := range missing {
+			gotMissing = append(gotMissing, d.Day)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: entries = %q, want %q", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(gotMissing, tt.missing) {
+			t.Errorf("%s: missing = %q, want %q", tt.name, gotMissing, tt.missing)
+		}
+		if flagged := len(log.Flags) > 0; flagged != tt.flagged {
+			t.Errorf("%s: flags = %v, want flagged %v", tt.name, log.Flags, tt.flagged)
+		}
+	}
+}
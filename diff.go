@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runDiff compares two sets of results — two runs from the run ledger, or
+// two exported files — and reports the students added and removed and the
+// values that changed, such as after reading the images again with another
+// model.
+func runDiff(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: diff <run|file> <run|file> (a run ID from `runs`, latest, or a .csv or .json export)")
+	}
+	before, err := loadResults(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := loadResults(args[1])
+	if err != nil {
+		return err
+	}
+
+	pairs, removed, added := pairResults(before, after)
+	changed, totalBefore, totalAfter := 0, 0, 0
+	for _, log := range before {
+		totalBefore += totalMinutes(log)
+	}
+	for _, log := range after {
+		totalAfter += totalMinutes(log)
+	}
+
+	for _, log := range removed {
+		red.Printf("  - %s", log.FullName)
+		dim.Printf(" | %s | %s | %d min\n", classroomOf(log), log.Source, totalMinutes(log))
+	}
+	for _, log := range added {
+		green.Printf("  + %s", log.FullName)
+		dim.Printf(" | %s | %s | %d min\n", classroomOf(log), log.Source, totalMinutes(log))
+	}
+	for _, p := range pairs {
+		changes := compareResults(p[0], p[1])
+		if len(changes) == 0 {
+			continue
+		}
+		changed++
+		yellow.Printf("  ~ %s", p[1].FullName)
+		dim.Printf(" | %s | %s\n", classroomOf(p[1]), p[1].Source)
+		for _, c := range changes {
+			fmt.Printf("    %s %s\n", dim.Sprint("│"), c)
+		}
+	}
+
+	fmt.Printf("\n  %d added, %d removed, %d changed, %d unchanged\n", len(added), len(removed), changed, len(pairs)-changed)
+	if totalBefore != totalAfter {
+		fmt.Printf("  Total minutes: %d → %d\n", totalBefore, totalAfter)
+	}
+	return nil
+}
+
+// loadResults reads the results of a run named by its ID (or a unique start
+// of it, or latest) or of an exported CSV or JSON file.
+func loadResults(arg string) ([]ReadingLog, error) {
+	switch strings.ToLower(filepath.Ext(arg)) {
+	case ".csv":
+		return readCSVResults(arg)
+	case ".json":
+		data, err := readLocalFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		var logs []ReadingLog
+		if err := json.Unmarshal(data, &logs); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", arg, err)
+		}
+		return logs, nil
+	}
+
+	id, err := findRun(arg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readStoreFile(store, path.Join(cfg.RunsDir, id, "results.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("run %s kept no results", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var logs []ReadingLog
+	if err := json.Unmarshal(data, &logs); err != nil {
+		return nil, fmt.Errorf("could not parse the results of run %s: %w", id, err)
+	}
+	return logs, nil
+}
+
+// findRun returns the ID of the run in the ledger that arg names.
+func findRun(arg string) (string, error) {
+	runs, err := loadRuns()
+	if err != nil {
+		return "", err
+	}
+	if len(runs) == 0 {
+		return "", fmt.Errorf("%s: not a .csv or .json file, and no runs are recorded in %s", arg, cfg.RunsDir)
+	}
+	if arg == "latest" {
+		return runs[len(runs)-1].ID, nil
+	}
+	var found []string
+	for _, run := range runs {
+		if run.ID == arg {
+			return run.ID, nil
+		}
+		if strings.HasPrefix(run.ID, arg) {
+			found = append(found, run.ID)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no run %s; `runs` lists them", arg)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("%d runs start with %s: %s", len(found), arg, strings.Join(found, ", "))
+	}
+}
+
+// readCSVResults reads the rows of an exported CSV back as results: the
+// student, grade, teacher, notes, and minutes for each day column.
+func readCSVResults(filename string) ([]ReadingLog, error) {
+	data, err := readLocalFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := readCSV(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", filename, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", filename)
+	}
+	header := rows[0]
+
+	var logs []ReadingLog
+	for i, row := range rows[1:] {
+		var log ReadingLog
+		for j, col := range header {
+			if j >= len(row) {
+				break
+			}
+			col, v := strings.TrimSpace(col), strings.TrimSpace(row[j])
+			switch col {
+			case "Full Name":
+				log.FullName = v
+			case "Grade":
+				log.Grade = v
+			case "Homeroom Teacher":
+				log.HomeroomTeacher = v
+			case "Notes":
+				log.Notes = v
+			case "Source File":
+				log.Source = v
+			default:
+				space := strings.LastIndex(col, " ")
+				if space < 0 {
+					continue
+				}
+				if _, _, _, ok := parseDate(col[space+1:]); !ok {
+					continue
+				}
+				minutes := 0
+				if v != "" {
+					if minutes, err = strconv.Atoi(v); err != nil {
+						return nil, fmt.Errorf("%s line %d: %s: %q is not a number of minutes", filename, i+2, col, v)
+					}
+				}
+				log.ReadingEntries = append(log.ReadingEntries, ReadingEntry{Day: col[:space], Date: col[space+1:], Minutes: minutes})
+			}
+		}
+		if isSummaryRow(log.FullName, log.Source) {
+			continue
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+// pairResults matches each result in after to the one in before read from
+// the same image, or, for results without a source, of the same student in
+// the same classroom. It returns the matched pairs and the results only
+// one side has.
+func pairResults(before, after []ReadingLog) (pairs [][2]ReadingLog, removed, added []ReadingLog) {
+	matched := make([]bool, len(before))
+	find := func(log ReadingLog) int {
+		for i, b := range before {
+			if !matched[i] && log.Source != "" && b.Source == log.Source {
+				return i
+			}
+		}
+		for i, b := range before {
+			if !matched[i] && sameText(b.FullName, log.FullName) && sameText(classroomOf(b), classroomOf(log)) {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, log := range after {
+		if i := find(log); i >= 0 {
+			matched[i] = true
+			pairs = append(pairs, [2]ReadingLog{before[i], log})
+		} else {
+			added = append(added, log)
+		}
+	}
+	for i, log := range before {
+		if !matched[i] {
+			removed = append(removed, log)
+		}
+	}
+	return pairs, removed, added
+}
+
+// compareResults describes how the result b differs from a: its student,
+// grade, and teacher, and the minutes read each day.
+func compareResults(a, b ReadingLog) []string {
+	var changes []string
+	text := func(label, x, y string) {
+		if !sameText(x, y) {
+			changes = append(changes, fmt.Sprintf("%s: %q → %q", label, x, y))
+		}
+	}
+	text("Full Name", a.FullName, b.FullName)
+	text("Grade", a.Grade, b.Grade)
+	text("Homeroom Teacher", a.HomeroomTeacher, b.HomeroomTeacher)
+
+	for _, d := range csvColumns([]ReadingLog{a, b}) {
+		x, y := minutesOn(a, d.Date), minutesOn(b, d.Date)
+		if x != y {
+			changes = append(changes, fmt.Sprintf("%s %s: %d → %d min", d.Day, d.Date, x, y))
+		}
+	}
+	if x, y := totalMinutes(a), totalMinutes(b); x != y {
+		changes = append(changes, fmt.Sprintf("Total: %d → %d min", x, y))
+	}
+	return changes
+}
+
+// minutesOn returns the minutes a result has for a date.
+func minutesOn(log ReadingLog, date string) int {
+	minutes := 0
+	for _, e := range log.ReadingEntries {
+		if dateKey(e.Date) == dateKey(date) {
+			minutes += e.Minutes
+		}
+	}
+	return minutes
+}
+
+// totalMinutes returns the minutes a result has for the whole week.
+func totalMinutes(log ReadingLog) int {
+	total := 0
+	for _, e := range log.ReadingEntries {
+		total += e.Minutes
+	}
+	return total
+}
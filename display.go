@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// displayMode is how much a run shows on the terminal.
+type displayMode int
+
+const (
+	normalDisplay displayMode = iota
+	quietDisplay              // errors only
+	jsonDisplay               // a JSON report on stdout when the run ends, errors on stderr
+)
+
+var display = normalDisplay
+
+// useTUI asks for the live display (--tui) instead of printed lines.
+var useTUI bool
+
+// reportOutput is where the JSON report goes: the real stdout, which the
+// terminal display no longer writes to.
+var reportOutput io.Writer = os.Stdout
+
+// displayFlags registers --tui, and --quiet and --json for running from
+// scripts and cron jobs.
+func displayFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&useTUI, "tui", false, "show a live display of the run's progress, the images being read, and their results (on a terminal)")
+	fs.BoolFunc("quiet", "print errors only", func(s string) error { return setDisplay(quietDisplay, s) })
+	fs.BoolFunc("json", "print a JSON report of the run on stdout instead of the progress display", func(s string) error { return setDisplay(jsonDisplay, s) })
+}
+
+// setDisplay switches to mode when its flag is set, sending everything the
+// terminal display prints to stdout nowhere. Errors and warnings go to
+// stderr and aren't affected.
+func setDisplay(mode displayMode, value string) error {
+	if on, err := strconv.ParseBool(value); err != nil || !on {
+		return err
+	}
+	if display == mode {
+		return nil
+	}
+	if display != normalDisplay {
+		return fmt.Errorf("--quiet and --json can't be used together")
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	display = mode
+	os.Stdout, color.Output = devNull, io.Discard
+	return nil
+}
+
+// batchView shows a batch's progress as its images are read.
+type batchView interface {
+	begin(i int, path string)                 // the batch's ith image is being read
+	end(done int, path string, r imageReport) // done images are finished, this one with r
+	close()
+}
+
+// newView returns the view for a batch of images, skipped of which are
+// already done: the live display with --tui on a terminal, or else lines
+// printed as images are read.
+func (p *pipeline) newView(total, skipped int) batchView {
+	if useTUI && !headless && display == normalDisplay && isatty.IsTerminal(os.Stdout.Fd()) {
+		return newTUIView(total-skipped, p.limit)
+	}
+	return &printView{total: total, skipped: skipped, concurrent: p.concurrency > 1}
+}
+
+// printView prints a line of progress for each image and then its result.
+// Sequential runs announce each image before the slow API call; concurrent
+// runs report images as they finish instead.
+type printView struct {
+	total, skipped int
+	concurrent     bool
+}
+
+func (v *printView) begin(i int, path string) {
+	if !v.concurrent {
+		printProgress(i+1, v.total, v.skipped, path)
+	}
+}
+
+func (v *printView) end(done int, path string, r imageReport) {
+	if v.concurrent {
+		printProgress(v.skipped+done, v.total, v.skipped, path)
+	}
+	switch r.Outcome {
+	case "failed":
+		printError(path, errors.New(r.Error))
+	case "blank":
+		dim.Println(glyphs("    ○ blank form, no result recorded"))
+	default:
+		for _, log := range r.Results {
+			printResult(&log)
+			for _, problem := range cfg.Validation.check(log) {
+				yellow.Printf("    ⚠ %s\n", problem)
+			}
+			for _, f := range log.Flags {
+				yellow.Printf("    ⚠ %s: %s\n", f.Check, f.Detail)
+			}
+		}
+	}
+}
+
+func (v *printView) close() {}
+
+// imageReport is what became of one image in the JSON report.
+type imageReport struct {
+	Image   string       `json:"image"`
+	Outcome string       `json:"outcome"` // completed, blank, or failed
+	Error   string       `json:"error,omitempty"`
+	Kind    string       `json:"kind,omitempty"`    // of failure: auth, rate_limit, parse, image, timeout, api, or other
+	Results []ReadingLog `json:"results,omitempty"` // with the flags raised while reading
+}
+
+// runSummary is the JSON report of a run: the summary, the API usage, and
+// each image read.
+type runSummary struct {
+	Found       int           `json:"images_found"`
+	AlreadyDone int           `json:"already_done"`
+	Processed   int           `json:"processed"`
+	Blank       int           `json:"blank"`
+	Failed      int           `json:"failed"`
+	Flagged     int           `json:"flagged"`
+	Results     int           `json:"results"` // in the output, including earlier runs'
+	Output      string        `json:"output"`
+	Outputs     []string      `json:"outputs"` // every file written, with several formats
+	Usage       *usageReport  `json:"usage,omitempty"`
+	Images      []imageReport `json:"images"`
+}
+
+type usageReport struct {
+	Requests      int     `json:"requests"`
+	InputTokens   int64   `json:"input_tokens"`
+	CacheRead     int64   `json:"cache_read_tokens"`
+	CacheWrite    int64   `json:"cache_write_tokens"`
+	OutputTokens  int64   `json:"output_tokens"`
+	EstimatedCost float64 `json:"estimated_cost_usd"`
+}
+
+// report ends a run the way the display mode asks: the summary, usage, and
+// where the output went on the terminal, the JSON report on stdout, or
+// nothing when quiet.
+func (p *pipeline) report(found, succeeded, blank, failed, skipped, flagged, rows int, paths []string) {
+	switch display {
+	case normalDisplay:
+		printSummary(found, succeeded, blank, failed, skipped, flagged)
+		printUsage(p.usage)
+		printWrote(rows, paths)
+	case jsonDisplay:
+		r := runSummary{
+			Found: found, AlreadyDone: skipped, Processed: succeeded, Blank: blank, Failed: failed,
+			Flagged: flagged, Results: rows, Output: paths[0], Outputs: paths, Images: p.images,
+		}
+		if r.Images == nil {
+			r.Images = []imageReport{}
+		}
+		if u := p.usage; u != nil && u.requests > 0 {
+			r.Usage = &usageReport{
+				Requests:      u.requests,
+				InputTokens:   u.input + u.cacheRead + u.cacheWrite,
+				CacheRead:     u.cacheRead,
+				CacheWrite:    u.cacheWrite,
+				OutputTokens:  u.output,
+				EstimatedCost: u.cost(),
+			}
+		}
+		enc := json.NewEncoder(reportOutput)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			logger.Error("could not write the report", "err", err)
+		}
+	}
+	notifyRun(runNotice{
+		Processed: succeeded, Blank: blank, Failed: failed, Flagged: flagged,
+		Results: rows, Minutes: p.minutes, Output: storeLocations(paths),
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Dropbox's API servers: one for calls, one for file contents.
+const (
+	dropboxAPI     = "https://api.dropboxapi.com"
+	dropboxContent = "https://content.dropboxapi.com"
+)
+
+// dropboxSource is a Dropbox folder, written dropbox:/Folder/Path. Each
+// sync downloads only the images added or changed since the last, using
+// the cursor Dropbox returns, which is kept in progress.
+type dropboxSource string
+
+func newDropboxSource(location string) (ImageSource, error) {
+	if !strings.HasPrefix(location, "/") {
+		return nil, fmt.Errorf("want a folder path starting with /, such as dropbox:/ReadingLogs")
+	}
+	return dropboxSource(location), nil
+}
+
+func (s dropboxSource) String() string { return "dropbox:" + string(s) }
+
+func (s dropboxSource) Sync(ctx context.Context, progress *Progress) ([]string, error) {
+	d, err := newDropbox(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, cursor, err := d.changes(ctx, string(s), progress.Sources[s.String()])
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []string
+	for _, f := range files {
+		data, err := d.download(ctx, f.ID)
+		if err != nil {
+			return saved, fmt.Errorf("downloading %s: %w", f.Name, err)
+		}
+		if err := writeStoreFile(store, f.Name, data); err != nil {
+			return saved, err
+		}
+		dim.Printf("  ↓ %s\n", f.Name)
+		saved = append(saved, f.Name)
+	}
+	progress.Sources[s.String()] = cursor
+	return saved, nil
+}
+
+// dropbox calls the Dropbox API with an access token.
+type dropbox struct {
+	client *http.Client
+	token  string
+}
+
+// newDropbox returns a Dropbox client using DROPBOX_TOKEN, or a token
+// fetched with DROPBOX_REFRESH_TOKEN and DROPBOX_APP_KEY (and
+// DROPBOX_APP_SECRET, unless the app uses PKCE), which unlike an access
+// token doesn't expire.
+func newDropbox(ctx context.Context) (*dropbox, error) {
+	d := &dropbox{client: &http.Client{Timeout: 5 * time.Minute}, token: os.Getenv("DROPBOX_TOKEN")}
+	refresh, key := os.Getenv("DROPBOX_REFRESH_TOKEN"), os.Getenv("DROPBOX_APP_KEY")
+	if refresh != "" && key != "" {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}, "client_id": {key}}
+		if secret := os.Getenv("DROPBOX_APP_SECRET"); secret != "" {
+			form.Set("client_secret", secret)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxAPI+"/oauth2/token", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		body, err := d.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("DROPBOX_REFRESH_TOKEN: %w", err)
+		}
+		var token struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.Unmarshal(body, &token); err != nil {
+			return nil, err
+		}
+		d.token = token.AccessToken
+	}
+	if d.token == "" {
+		return nil, configErrorf("no Dropbox credentials; set DROPBOX_TOKEN, or DROPBOX_REFRESH_TOKEN and DROPBOX_APP_KEY")
+	}
+	return d, nil
+}
+
+// dropboxError is an error response from the API. Summary is its
+// error_summary, such as "path/not_found/..".
+type dropboxError struct {
+	Status  int
+	Summary string
+}
+
+func (e *dropboxError) Error() string {
+	if e.Summary == "" {
+		return fmt.Sprintf("Dropbox: %s", http.StatusText(e.Status))
+	}
+	return "Dropbox: " + e.Summary
+}
+
+// do sends req, signed with the access token if there is one, and returns
+// the response body.
+func (d *dropbox) do(req *http.Request) ([]byte, error) {
+	if d.token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.token)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Summary string `json:"error_summary"`
+		}
+		if json.Unmarshal(body, &e) != nil || e.Summary == "" {
+			e.Summary = strings.TrimSpace(string(body))
+		}
+		return nil, &dropboxError{Status: resp.StatusCode, Summary: e.Summary}
+	}
+	return body, nil
+}
+
+// call makes an RPC call to an endpoint such as "files/list_folder".
+func (d *dropbox) call(ctx context.Context, endpoint string, arg, out any) error {
+	body, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxAPI+"/2/"+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	body, err = d.do(req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// dropboxFile is a file in a folder listing.
+type dropboxFile struct {
+	Tag  string `json:".tag"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// changes returns the image files in folder that were added or changed
+// since cursor, or all of them if cursor is empty or Dropbox has reset it,
+// and the cursor to pass next time.
+func (d *dropbox) changes(ctx context.Context, folder, cursor string) ([]dropboxFile, string, error) {
+	if folder == "/" {
+		folder = "" // how Dropbox names the root
+	}
+	var files []dropboxFile
+	for {
+		var page struct {
+			Entries []dropboxFile `json:"entries"`
+			Cursor  string        `json:"cursor"`
+			HasMore bool          `json:"has_more"`
+		}
+		var err error
+		if cursor == "" {
+			err = d.call(ctx, "files/list_folder", map[string]any{"path": folder}, &page)
+		} else {
+			err = d.call(ctx, "files/list_folder/continue", map[string]any{"cursor": cursor}, &page)
+			var de *dropboxError
+			if errors.As(err, &de) && strings.HasPrefix(de.Summary, "reset") {
+				files, cursor = nil, ""
+				continue
+			}
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		for _, e := range page.Entries {
+			if e.Tag == "file" && isImageFile(e.Name) {
+				files = append(files, e)
+			}
+		}
+		cursor = page.Cursor
+		if !page.HasMore {
+			return files, cursor, nil
+		}
+	}
+}
+
+// download returns the contents of the file with id.
+func (d *dropbox) download(ctx context.Context, id string) ([]byte, error) {
+	arg, err := json.Marshal(map[string]string{"path": id})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxContent+"/2/files/download", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Dropbox-API-Arg", string(arg))
+	return d.do(req)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+// Rough per-request token counts used for cost estimates. Images are billed
+// at about width×height/750 tokens after the API downscales them to fit
+// within ~1.15 megapixels.
+const (
+	promptTokens   = 600  // instructions plus the JSON schema
+	outputTokens   = 300  // a typical structured response
+	maxImageTokens = 1600 // cap for large images, also used when dimensions are unknown
+)
+
+// modelPrices are USD prices per million input and output tokens, by model
+// name prefix, most specific first. Models not listed are estimated at
+// Claude Sonnet 4.5 prices.
+var modelPrices = []struct {
+	prefix        string
+	input, output float64
+}{
+	{"claude-opus-4-5", 5.00, 25.00},
+	{"claude-opus-4-1", 15.00, 75.00},
+	{"claude-opus-4-0", 15.00, 75.00},
+	{"claude-opus-4-2025", 15.00, 75.00}, // claude-opus-4-20250514
+	{"claude-sonnet-4", 3.00, 15.00},
+	{"claude-3-7-sonnet", 3.00, 15.00},
+	{"claude-haiku-4-5", 1.00, 5.00},
+	{"claude-3-5-haiku", 0.80, 4.00},
+}
+
+// pricesFor returns the per-million-token input and output prices of a
+// model, and whether they are known rather than assumed.
+func pricesFor(model string) (input, output float64, known bool) {
+	for _, p := range modelPrices {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.input, p.output, true
+		}
+	}
+	return 3.00, 15.00, false
+}
+
+// printDryRun reports what a run would do without calling the API or writing
+// the progress file.
+func printDryRun(images []string, progress *Progress, isDone func(string) bool, model string) {
+	var process, retry, done []string
+
+	for _, name := range images {
+		switch {
+		case isDone(name):
+			done = append(done, name)
+		case progress.Errors[name] != "":
+			retry = append(retry, name)
+		default:
+			process = append(process, name)
+		}
+	}
+
+	bold.Println("  Dry run — no API calls will be made")
+	fmt.Println()
+	for _, name := range process {
+		fmt.Printf("  %s %s\n", green.Sprint("+"), name)
+	}
+	for _, name := range retry {
+		fmt.Printf("  %s %s %s\n", yellow.Sprint("↻"), name, dim.Sprintf("(previously failed: %s)", firstLine(progress.Errors[name])))
+	}
+	for _, name := range done {
+		fmt.Printf("  %s %s\n", dim.Sprint("✓"), dim.Sprint(name))
+	}
+
+	var inTok, outTok int
+	for _, name := range append(process, retry...) {
+		inTok += promptTokens + estimateImageTokens(name)
+		outTok += outputTokens
+	}
+	inPrice, outPrice, known := pricesFor(model)
+	cost := float64(inTok)/1e6*inPrice + float64(outTok)/1e6*outPrice
+
+	fmt.Println()
+	bold.Println(glyphs("─── Dry run ──────────────────────────"))
+	fmt.Printf("  Would process:    %s\n", green.Sprintf("%d", len(process)))
+	if len(retry) > 0 {
+		fmt.Printf("  Would retry:      %s\n", yellow.Sprintf("%d", len(retry)))
+	}
+	fmt.Printf("  Would skip:       %s\n", cyan.Sprintf("%d (already completed)", len(done)))
+	fmt.Printf("  Estimated tokens: %s\n", bold.Sprintf("~%d in / ~%d out", inTok, outTok))
+	fmt.Printf("  Estimated cost:   %s %s\n", bold.Sprintf("~$%.2f", cost), dim.Sprintf("(%s)", model))
+	if !known {
+		dim.Printf("                    no prices known for %s; assuming Claude Sonnet 4.5's\n", model)
+	}
+	bold.Println(glyphs("──────────────────────────────────────"))
+}
+
+// estimateImageTokens approximates the input tokens for an image from its
+// dimensions, falling back to the cap when they can't be read cheaply.
+func estimateImageTokens(name string) int {
+	data, err := readStoreFile(store, name)
+	if err != nil {
+		return maxImageTokens
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return maxImageTokens
+	}
+	tokens := cfg.Width * cfg.Height / 750
+	if tokens > maxImageTokens {
+		tokens = maxImageTokens
+	}
+	return tokens
+}
+
+// firstLine returns the first line of s, for one-line error summaries.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+)
+
+// EmailConfig is how the email command sends each teacher their class's
+// results.
+type EmailConfig struct {
+	SMTP     string            `yaml:"smtp" toml:"smtp"`         // server address with port, e.g. smtp.gmail.com:587
+	Username string            `yaml:"username" toml:"username"` // SMTP login; the password is SMTP_PASSWORD
+	From     string            `yaml:"from" toml:"from"`         // sender address; username if empty
+	Subject  string            `yaml:"subject" toml:"subject"`   // text/template
+	Body     string            `yaml:"body" toml:"body"`         // text/template
+	Attach   []string          `yaml:"attach" toml:"attach"`     // csv, pdf, or both
+	Teachers map[string]string `yaml:"teachers" toml:"teachers"` // teacher → address
+}
+
+const defaultEmailSubject = "Reading logs for the week of {{.Week}}"
+
+const defaultEmailBody = `Hi {{.Teacher}},
+
+Attached are your class's reading logs for the week of {{.Week}}. {{.Students}} students read {{.Minutes}} minutes in all, {{.Average}} minutes each on average.
+{{- if .Missing}}
+
+No log has come in yet from {{join .Missing ", "}}.
+{{- end}}
+
+Thank you!
+`
+
+// attachKinds are the files an email can attach.
+var attachKinds = []string{"csv", "pdf"}
+
+// emailData is what the subject and body templates can refer to.
+type emailData struct {
+	Teacher  string
+	Week     string
+	Students int
+	Minutes  int
+	Average  int
+	Missing  []string // roster students with no log, if there is a roster
+}
+
+// templates parses the subject and body templates.
+func (e EmailConfig) templates() (subject, body *template.Template, err error) {
+	funcs := template.FuncMap{"join": strings.Join}
+	if subject, err = template.New("subject").Funcs(funcs).Option("missingkey=error").Parse(e.Subject); err != nil {
+		return nil, nil, fmt.Errorf("subject: %w", err)
+	}
+	if body, err = template.New("body").Funcs(funcs).Option("missingkey=error").Parse(e.Body); err != nil {
+		return nil, nil, fmt.Errorf("body: %w", err)
+	}
+	return subject, body, nil
+}
+
+// validate checks the email settings that can be checked without sending.
+func (e EmailConfig) validate() error {
+	if _, _, err := e.templates(); err != nil {
+		return err
+	}
+	for _, kind := range e.Attach {
+		if !slices.Contains(attachKinds, kind) {
+			return fmt.Errorf("attach must be csv or pdf, not %q", kind)
+		}
+	}
+	if e.SMTP != "" {
+		if _, _, err := net.SplitHostPort(e.SMTP); err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+	}
+	for teacher, addr := range e.Teachers {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("teachers: %s: %w", teacher, err)
+		}
+	}
+	return nil
+}
+
+// addressFor returns the configured address of a teacher, matched like
+// teacher names are everywhere else, or "" if there is none.
+func (e EmailConfig) addressFor(teacher string) string {
+	for t, addr := range e.Teachers {
+		if teacherKey(normalizeTeacher(t)) == teacherKey(teacher) {
+			return addr
+		}
+	}
+	return ""
+}
+
+// runEmail sends each homeroom teacher their class's results for a week.
+func runEmail(args []string) error {
+	fs := flag.NewFlagSet("email", flag.ContinueOnError)
+	week := fs.String("week", "", "week to send (default: the most recent)")
+	teacher := fs.String("teacher", "", "only email this teacher")
+	dryRun := fs.Bool("dry-run", false, "print each message instead of sending it")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	ec := cfg.Email
+	if !*dryRun && ec.SMTP == "" {
+		return configErrorf("no SMTP server; set email.smtp in the config file")
+	}
+	if ec.from() == "" {
+		return configErrorf("no sender; set email.from (or email.username) in the config file")
+	}
+	if len(ec.Teachers) == 0 {
+		return configErrorf("no teacher addresses; list them under email.teachers in the config file")
+	}
+	subjectTmpl, bodyTmpl, err := ec.templates()
+	if err != nil {
+		return fmt.Errorf("email %w", err)
+	}
+
+	season, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	if *week == "" {
+		*week = latestWeek(season)
+	}
+	classes := make(map[string][]ReadingLog)
+	for _, log := range season {
+		if weekOf(log) != *week {
+			continue
+		}
+		if *teacher != "" && teacherKey(classroomOf(log)) != teacherKey(normalizeTeacher(*teacher)) {
+			continue
+		}
+		classes[classroomOf(log)] = append(classes[classroomOf(log)], log)
+	}
+	if len(classes) == 0 && *teacher != "" {
+		return fmt.Errorf("no results for %s in week %s", *teacher, *week)
+	}
+	if len(classes) == 0 {
+		return fmt.Errorf("no results for week %s", *week)
+	}
+
+	var roster []rosterStudent
+	if cfg.Roster != "" {
+		if roster, err = loadRoster(cfg.Roster); err != nil {
+			return err
+		}
+	}
+
+	var sender *smtpSender
+	if !*dryRun {
+		if sender, err = dialSMTP(ec); err != nil {
+			return err
+		}
+		defer sender.Close()
+	}
+
+	sent := 0
+	for _, class := range sortedKeys(classes) {
+		to := ec.addressFor(class)
+		if to == "" {
+			yellow.Printf("  %s No address for %s; add it under email.teachers\n", glyphs("⚠"), class)
+			continue
+		}
+		logs := classes[class]
+		sort.SliceStable(logs, func(i, j int) bool { return logs[i].FullName < logs[j].FullName })
+
+		data := emailData{Teacher: class, Week: *week}
+		for _, t := range aggregateStudents(logs) {
+			data.Students++
+			data.Minutes += t.Minutes
+		}
+		data.Average = data.Minutes / data.Students
+		for _, s := range roster {
+			if teacherKey(s.Teacher) == teacherKey(class) && !turnedIn(s, logs) {
+				data.Missing = append(data.Missing, s.Name)
+			}
+		}
+
+		var subject, body strings.Builder
+		if err := subjectTmpl.Execute(&subject, data); err != nil {
+			return fmt.Errorf("email subject: %w", err)
+		}
+		if err := bodyTmpl.Execute(&body, data); err != nil {
+			return fmt.Errorf("email body: %w", err)
+		}
+		msg, err := composeEmail(ec, to, subject.String(), body.String(), class, *week, logs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", class, err)
+		}
+
+		if *dryRun {
+			fmt.Printf("%s\n  To: %s\n  Subject: %s\n  Attached: %s\n\n%s\n", bold.Sprint(class), to, subject.String(), strings.Join(ec.Attach, ", "), body.String())
+			continue
+		}
+		if err := sender.send(ec.from(), to, msg); err != nil {
+			return fmt.Errorf("sending to %s: %w", to, err)
+		}
+		fmt.Printf("  %s %s\n", dim.Sprintf("%-20s", class), fmt.Sprintf("%d student(s) → %s", data.Students, to))
+		sent++
+	}
+	if !*dryRun {
+		boldGrn.Printf("  Sent %d email(s)\n", sent)
+	}
+	return nil
+}
+
+// from is the sender address.
+func (e EmailConfig) from() string {
+	if e.From != "" {
+		return e.From
+	}
+	return e.Username
+}
+
+// composeEmail builds the message to one teacher with their class's results
+// attached.
+func composeEmail(ec EmailConfig, to, subject, body, class, week string, logs []ReadingLog) ([]byte, error) {
+	from, err := mail.ParseAddress(ec.from())
+	if err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	toAddr, err := mail.ParseAddress(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var h mail.Header
+	h.SetDate(time.Now())
+	h.SetAddressList("From", []*mail.Address{from})
+	h.SetAddressList("To", []*mail.Address{toAddr})
+	h.SetSubject(subject)
+	if err := h.GenerateMessageID(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w, err := mail.CreateWriter(&buf, h)
+	if err != nil {
+		return nil, err
+	}
+	var th mail.InlineHeader
+	th.SetContentType("text/plain", map[string]string{"charset": "utf-8"})
+	part, err := w.CreateSingleInline(th)
+	if err != nil {
+		return nil, err
+	}
+	io.WriteString(part, body)
+	part.Close()
+
+	name := fileSafe(class) + "-" + fileSafe(week)
+	for _, kind := range ec.Attach {
+		var data bytes.Buffer
+		var contentType string
+		switch kind {
+		case "csv":
+			contentType = "text/csv"
+			if err := encodeCSV(&data, sortLogs(logs)); err != nil {
+				return nil, err
+			}
+		case "pdf":
+			contentType = "application/pdf"
+			if err := summaryPDF(class, week, logs).Output(&data); err != nil {
+				return nil, err
+			}
+		}
+		var ah mail.AttachmentHeader
+		ah.SetContentType(contentType, nil)
+		ah.SetFilename(name + "." + kind)
+		aw, err := w.CreateAttachment(ah)
+		if err != nil {
+			return nil, err
+		}
+		aw.Write(data.Bytes())
+		aw.Close()
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// smtpSender sends messages over one SMTP connection.
+type smtpSender struct {
+	client *smtp.Client
+}
+
+// dialSMTP connects and logs in to the SMTP server. Port 465 is spoken to
+// over TLS from the start; any other port is upgraded with STARTTLS when the
+// server offers it.
+func dialSMTP(ec EmailConfig) (*smtpSender, error) {
+	host, port, _ := net.SplitHostPort(ec.SMTP)
+	var c *smtp.Client
+	if port == "465" {
+		conn, err := tls.Dial("tcp", ec.SMTP, &tls.Config{ServerName: host})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		if c, err = smtp.NewClient(conn, host); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+	} else {
+		var err error
+		if c, err = smtp.Dial(ec.SMTP); err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				c.Close()
+				return nil, fmt.Errorf("STARTTLS: %w", err)
+			}
+		}
+	}
+	if ec.Username != "" {
+		password := os.Getenv("SMTP_PASSWORD")
+		if password == "" {
+			c.Close()
+			return nil, fmt.Errorf("the SMTP_PASSWORD environment variable is required to log in as %s", ec.Username)
+		}
+		if err := c.Auth(smtp.PlainAuth("", ec.Username, password, host)); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("login failed: %w", err)
+		}
+	}
+	return &smtpSender{client: c}, nil
+}
+
+// send delivers one message.
+func (s *smtpSender) send(from, to string, msg []byte) error {
+	f, err := mail.ParseAddress(from)
+	if err != nil {
+		return err
+	}
+	t, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Mail(f.Address); err != nil {
+		return err
+	}
+	if err := s.client.Rcpt(t.Address); err != nil {
+		return err
+	}
+	w, err := s.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *smtpSender) Close() error {
+	return s.client.Quit()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ageHeader begins every file age encrypts, which is how encrypted files are
+// told apart from plain ones when they are read.
+const ageHeader = "age-encryption.org/v1\n"
+
+// passphraseWorkFactor is the scrypt cost of a passphrase-encrypted file,
+// paid again each time progress is saved, so it is below age's default.
+const passphraseWorkFactor = 15
+
+// errCannotDecrypt is returned when reading an encrypted file without the
+// key, or with the wrong one.
+var errCannotDecrypt = errors.New("could not decrypt")
+
+// keys are what files holding student data are encrypted to and decrypted
+// with: an age identity file, which encrypts to itself and to any further
+// encrypt_to recipients, or a passphrase.
+type keys struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+// encryption is the keys for this invocation, or nil when files are written
+// in the clear. Encrypted files can't be read without them.
+var encryption *keys
+
+// loadKeys returns the keys the configuration and READING_LOGS_PASSPHRASE
+// name, or nil if there are none.
+func loadKeys(c *Config) (*keys, error) {
+	passphrase := os.Getenv("READING_LOGS_PASSPHRASE")
+	if passphrase != "" {
+		if c.Identity != "" || len(c.EncryptTo) > 0 {
+			return nil, fmt.Errorf("READING_LOGS_PASSPHRASE cannot be combined with identity or encrypt_to")
+		}
+		r, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		r.SetWorkFactor(passphraseWorkFactor)
+		id, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return &keys{recipients: []age.Recipient{r}, identities: []age.Identity{id}}, nil
+	}
+	if c.Identity == "" {
+		if len(c.EncryptTo) > 0 {
+			return nil, fmt.Errorf("encrypt_to needs an identity to read the files back")
+		}
+		return nil, nil
+	}
+
+	k := &keys{}
+	data, err := os.ReadFile(c.Identity)
+	if err != nil {
+		return nil, fmt.Errorf("identity: %w", err)
+	}
+	if k.identities, err = age.ParseIdentities(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("identity %s: %w", c.Identity, err)
+	}
+	for _, id := range k.identities {
+		if x, ok := id.(*age.X25519Identity); ok {
+			k.recipients = append(k.recipients, x.Recipient())
+		}
+	}
+	for _, to := range c.EncryptTo {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("encrypt_to: %w", err)
+		}
+		k.recipients = append(k.recipients, r)
+	}
+	return k, nil
+}
+
+// sealed reports whether a file is written encrypted: everything but images,
+// which are the school's own copies, and the lock file, which holds no
+// student data and is read by runs without the key.
+func sealed(name string) bool {
+	return encryption != nil && !isImageFile(name) && path.Base(filepath.ToSlash(name)) != lockFile
+}
+
+// seal returns a writer that encrypts what is written to w if name is
+// sealed, or w itself if not. Closing it closes w.
+func seal(name string, w io.WriteCloser) (io.WriteCloser, error) {
+	if !sealed(name) {
+		return w, nil
+	}
+	enc, err := age.Encrypt(w, encryption.recipients...)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return &sealWriter{WriteCloser: enc, file: w}, nil
+}
+
+// sealWriter finishes the encryption and then closes the file under it.
+type sealWriter struct {
+	io.WriteCloser
+	file io.WriteCloser
+}
+
+func (s *sealWriter) Close() error {
+	err := s.WriteCloser.Close()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// unseal returns the contents of the file name, decrypted if it is
+// encrypted. Plain files are returned as they are, so encryption can be
+// turned on partway through a season.
+func unseal(name string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(ageHeader)) {
+		return data, nil
+	}
+	if encryption == nil {
+		return nil, fmt.Errorf("%w %s: it is encrypted, and neither identity nor READING_LOGS_PASSPHRASE is set", errCannotDecrypt, name)
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), encryption.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", errCannotDecrypt, name, err)
+	}
+	return io.ReadAll(r)
+}
+
+// readLocalFile reads a local file, decrypting it if it is encrypted.
+func readLocalFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return unseal(name, data)
+}
+
+// createLocalFile creates a local file, encrypting what is written to it if
+// it is sealed.
+func createLocalFile(name string, perm os.FileMode) (io.WriteCloser, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+	return seal(name, f)
+}
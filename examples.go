@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// exemplar is a corrected result, kept with its image so it can be shown
+// to the model as a worked example of this school's forms and handwriting.
+type exemplar struct {
+	Image     string     `json:"image"` // image filename
+	Template  string     `json:"template,omitempty"`
+	MediaType string     `json:"media_type"`
+	Data      string     `json:"data"` // base64-encoded image
+	Log       ReadingLog `json:"log"`  // the corrected result, as the model should return it
+	Saved     time.Time  `json:"saved"`
+}
+
+// loadExemplars reads the saved examples. A missing file means none.
+func loadExemplars() ([]exemplar, error) {
+	data, err := readStoreFile(store, cfg.Exemplars)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []exemplar
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", cfg.Exemplars, err)
+	}
+	return all, nil
+}
+
+// saveExemplar adds an example, replacing any earlier one for the same image.
+func saveExemplar(e exemplar) error {
+	all, err := loadExemplars()
+	if err != nil {
+		return err
+	}
+	kept := all[:0]
+	for _, old := range all {
+		if old.Image != e.Image {
+			kept = append(kept, old)
+		}
+	}
+	data, err := json.MarshalIndent(append(kept, e), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStoreFile(store, cfg.Exemplars, data)
+}
+
+// pickExemplars chooses up to n examples for a form, most recent first,
+// preferring examples read with the same form template.
+func pickExemplars(all []exemplar, form string, n int) []exemplar {
+	sorted := append([]exemplar(nil), all...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if (sorted[i].Template == form) != (sorted[j].Template == form) {
+			return sorted[i].Template == form
+		}
+		return sorted[i].Saved.After(sorted[j].Saved)
+	})
+	return sorted[:min(n, len(sorted))]
+}
+
+// modelFields returns just the parts of a log the model fills in, dropping
+// what the tool records alongside.
+func modelFields(log ReadingLog) ReadingLog {
+	return ReadingLog{
+		FullName:        log.FullName,
+		Grade:           log.Grade,
+		HomeroomTeacher: log.HomeroomTeacher,
+		ReadingEntries:  log.ReadingEntries,
+		Notes:           log.Notes,
+		Extra:           log.Extra,
+	}
+}
+
+// loadImage reads an image from the store and encodes it for the API,
+// converting HEIC, TIFF, and BMP images to JPEG first.
+func loadImage(name string) (mediaType, encoded string, err error) {
+	data, err := readStoreFile(store, name)
+	if err != nil {
+		return "", "", err
+	}
+	mediaName := name
+	if needsConversion(name) {
+		if data, err = convertedJPEG(name, data, ""); err != nil {
+			return "", "", err
+		}
+		mediaName = name + ".jpg"
+	}
+	return encodeImage(mediaName, data)
+}
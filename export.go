@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ChunkIndex ties together the per-week CSV files written by a chunked export.
+type ChunkIndex struct {
+	Generated string      `json:"generated"`
+	Chunks    []ChunkInfo `json:"chunks"`
+}
+
+// ChunkInfo describes a single per-week CSV chunk.
+type ChunkInfo struct {
+	Week string `json:"week"`
+	File string `json:"file"`
+	Rows int    `json:"rows"`
+}
+
+// runExport re-exports completed results from the progress file without
+// processing any images.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	chunked := fs.Bool("chunked", false, "write one CSV per week plus an index.json instead of a single CSV")
+	dir := fs.String("dir", "exports", "output directory for chunked and split exports")
+	splitBy := fs.String("split-by", "", "write one file per group: teacher or grade")
+	anon := fs.Bool("anonymize", false, "replace student names with pseudonyms and leave out notes, filenames, and senders, for sharing outside the school")
+	outputFlags(fs)
+	parseFlags(fs, args)
+
+	if *chunked && *splitBy != "" {
+		return fmt.Errorf("--chunked and --split-by cannot be combined")
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	if len(progress.Completed) == 0 {
+		return fmt.Errorf("no completed results in %s", progressFile)
+	}
+
+	if *chunked {
+		src := newWeekLogs(progress)
+		if *anon {
+			src.prepare = func(logs []ReadingLog) ([]ReadingLog, error) { return anonymize(logs, cfg.Pseudonyms) }
+		}
+		index, err := exportChunked(*dir, src, nil)
+		if err != nil {
+			return err
+		}
+		if *anon {
+			dim.Printf("  Student names replaced with pseudonyms; the key is in %s\n", cfg.Pseudonyms)
+		}
+		for _, c := range index.Chunks {
+			fmt.Printf("  %s %s\n", dim.Sprintf("%-12s", c.Week), fmt.Sprintf("%d row(s) → %s", c.Rows, filepath.Join(*dir, c.File)))
+		}
+		boldGrn.Printf("  Wrote %d chunk(s) to %s\n", len(index.Chunks), filepath.Join(*dir, "index.json"))
+		return nil
+	}
+
+	allLogs := completedLogs(progress)
+	if *anon {
+		var err error
+		if allLogs, err = anonymize(allLogs, cfg.Pseudonyms); err != nil {
+			return err
+		}
+		dim.Printf("  Student names replaced with pseudonyms; the key is in %s\n", cfg.Pseudonyms)
+	}
+
+	if *splitBy != "" {
+		return exportSplit(*dir, *splitBy, allLogs)
+	}
+	paths, err := writeOutput(allLogs)
+	if err != nil {
+		return err
+	}
+	boldGrn.Printf("  Wrote %d reading log(s) to %s\n", len(allLogs), strings.Join(paths, ", "))
+	return nil
+}
+
+// weekLogs reads a progress file's completed results one week at a time,
+// so a chunked export never builds the whole season's rows at once.
+type weekLogs struct {
+	progress *Progress
+	keys     map[string][]string // week → record keys, with back pages under their front's week
+
+	// prepare, if set, is applied to each week's logs before they are
+	// written, e.g. to anonymize them.
+	prepare func([]ReadingLog) ([]ReadingLog, error)
+}
+
+// newWeekLogs groups the completed results in p by the week of the form
+// each belongs to. Both sides of a two-sided form go in the same week, so
+// they are merged as completedLogs merges them.
+func newWeekLogs(p *Progress) *weekLogs {
+	forms := make(map[string][]string)
+	for key := range p.Completed {
+		form, _ := pageOf(p.nameOf(key))
+		forms[form] = append(forms[form], key)
+	}
+	w := &weekLogs{progress: p, keys: make(map[string][]string)}
+	for _, keys := range forms {
+		if logs := w.build(keys); len(logs) == 1 {
+			week := weekOf(logs[0])
+			w.keys[week] = append(w.keys[week], keys...)
+			continue
+		}
+		// Pages that don't pair up each go by their own week.
+		for _, key := range keys {
+			week := weekOf(w.build([]string{key})[0])
+			w.keys[week] = append(w.keys[week], key)
+		}
+	}
+	return w
+}
+
+// build returns the results for keys as completedLogs would.
+func (w *weekLogs) build(keys []string) []ReadingLog {
+	p := w.progress
+	logs := make([]ReadingLog, 0, len(keys))
+	for _, key := range p.sortedByName(slices.Clone(keys)) {
+		log := withSource(p.Completed[key], p.nameOf(key))
+		normalizeLog(&log)
+		log.Flags = p.Review[key]
+		logs = append(logs, log)
+	}
+	return pairPages(logs)
+}
+
+// weeks returns the weeks that have results, in order.
+func (w *weekLogs) weeks() []string {
+	weeks := make([]string, 0, len(w.keys))
+	for week := range w.keys {
+		weeks = append(weeks, week)
+	}
+	sort.Strings(weeks)
+	return weeks
+}
+
+// logs returns the results for one week.
+func (w *weekLogs) logs(week string) ([]ReadingLog, error) {
+	logs := w.build(w.keys[week])
+	if w.prepare != nil {
+		return w.prepare(logs)
+	}
+	return logs, nil
+}
+
+// exportChunked writes one CSV per week under dir and an index.json
+// describing the chunks. Weeks are read and written one at a time, and each
+// chunk is closed before the next is opened, so memory use is bounded by
+// the largest week rather than the whole season.
+//
+// If only is non-nil, just those weeks are rewritten and the rest of the
+// existing index is kept as-is.
+func exportChunked(dir string, src *weekLogs, only map[string]bool) (*ChunkIndex, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	indexPath := filepath.Join(dir, "index.json")
+
+	written := make(map[string]bool)
+	var chunks []ChunkInfo
+	for _, week := range src.weeks() {
+		if only != nil && !only[week] {
+			continue
+		}
+		logs, err := src.logs(week)
+		if err != nil {
+			return nil, err
+		}
+		info, err := writeChunk(dir, week, logs)
+		if err != nil {
+			return nil, err
+		}
+		written[week] = true
+		chunks = append(chunks, info)
+	}
+
+	index := &ChunkIndex{Generated: time.Now().Format(time.RFC3339)}
+	if only != nil {
+		if prev, err := loadChunkIndex(indexPath); err == nil {
+			for _, c := range prev.Chunks {
+				if !only[c.Week] {
+					index.Chunks = append(index.Chunks, c)
+				}
+			}
+		}
+		// Weeks that no longer have any rows lose their chunk entirely.
+		for week := range only {
+			if !written[week] {
+				os.Remove(filepath.Join(dir, "week-"+week+".csv"))
+			}
+		}
+	}
+	index.Chunks = append(index.Chunks, chunks...)
+	sort.Slice(index.Chunks, func(i, j int) bool { return index.Chunks[i].Week < index.Chunks[j].Week })
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(indexPath, data, 0644); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// writeChunk writes one week's logs to its CSV under dir, with the columns
+// for the dates in that week, in a stable order so repeated exports
+// produce identical chunks.
+func writeChunk(dir, week string, logs []ReadingLog) (ChunkInfo, error) {
+	info := ChunkInfo{Week: week, File: "week-" + week + ".csv"}
+	logs = sortLogs(logs)
+	cols := csvColumns(logs)
+
+	file, err := createLocalFile(filepath.Join(dir, info.File), 0644)
+	if err != nil {
+		return info, err
+	}
+	writer, err := newCSVWriter(file)
+	if err != nil {
+		file.Close()
+		return info, err
+	}
+	if err := writer.Write(csvHeader(cols)); err != nil {
+		file.Close()
+		return info, err
+	}
+	for _, log := range logs {
+		if err := writer.Write(csvRow(log, cols)); err != nil {
+			file.Close()
+			return info, err
+		}
+		info.Rows++
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return info, err
+	}
+	return info, file.Close()
+}
+
+// exportSplit writes one file per teacher or grade under dir, named after the
+// group, in each format of the configured output (CSV by default).
+func exportSplit(dir, by string, logs []ReadingLog) error {
+	groupOf, err := groupBy(by)
+	if err != nil {
+		return fmt.Errorf("--split-by: %w", err)
+	}
+
+	now := time.Now()
+	files, err := resolveOutput(cfg.Output, cfg.Format, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	groups := make(map[string][]ReadingLog)
+	for _, log := range logs {
+		name := fileSafe(groupOf(log))
+		groups[name] = append(groups[name], log)
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, f := range files {
+			path := filepath.Join(dir, name+"."+f.format)
+			if err := writeLogs(path, f.format, groups[name], now); err != nil {
+				return err
+			}
+			fmt.Printf("  %s %s\n", dim.Sprintf("%-20s", name), fmt.Sprintf("%d row(s) → %s", len(groups[name]), path))
+		}
+	}
+	boldGrn.Printf("  Wrote %d file(s) to %s\n", len(names)*len(files), dir)
+	return nil
+}
+
+// groupBy returns the function that puts a log in a teacher or grade group.
+func groupBy(by string) (func(ReadingLog) string, error) {
+	switch by {
+	case "teacher":
+		return classroomOf, nil
+	case "grade":
+		return func(log ReadingLog) string { return log.Grade }, nil
+	default:
+		return nil, fmt.Errorf("unknown grouping %q (want teacher or grade)", by)
+	}
+}
+
+// fileSafe turns a teacher or grade name into a file name, keeping letters,
+// digits, dashes, and dots and replacing everything else with underscores.
+func fileSafe(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// loadChunkIndex reads the index.json written by a previous chunked export.
+func loadChunkIndex(path string) (*ChunkIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var index ChunkIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// weekOf returns a filesystem-safe key for the week a log covers. An explicit
+// attribution wins; otherwise it is taken from the date of the first entry
+// (e.g. "1/30" becomes "01-30").
+func weekOf(log ReadingLog) string {
+	if log.Week != "" {
+		return log.Week
+	}
+	for _, e := range log.ReadingEntries {
+		if _, month, day, ok := parseDate(e.Date); ok {
+			return fmt.Sprintf("%02d-%02d", month, day)
+		}
+	}
+	return "unknown"
+}
+
+// weekKey turns a week given on the command line, such as "1/30" or
+// "01-30", into the key weekOf uses, so it is also safe as a file name.
+func weekKey(s string) (string, error) {
+	_, month, day, ok := parseDate(s)
+	if !ok {
+		_, month, day, ok = parseDate(strings.ReplaceAll(s, "-", "/"))
+	}
+	if !ok {
+		return "", fmt.Errorf("invalid week %q (want a date such as 1/30 or 01-30)", s)
+	}
+	return fmt.Sprintf("%02d-%02d", month, day), nil
+}
+
+// normalizeWeek rewrites a --week flag with weekKey, leaving it empty when
+// it wasn't given.
+func normalizeWeek(week *string) error {
+	if *week == "" {
+		return nil
+	}
+	w, err := weekKey(*week)
+	if err != nil {
+		return err
+	}
+	*week = w
+	return nil
+}
+
+// classroomOf returns the classroom a log is attributed to, falling back to
+// its homeroom teacher.
+func classroomOf(log ReadingLog) string {
+	if log.Classroom != "" {
+		return log.Classroom
+	}
+	return log.HomeroomTeacher
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestWeekKey(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"01-30", "01-30", true},
+		{"1-30", "01-30", true},
+		{"1/30", "01-30", true},
+		{"01/30", "01-30", true},
+		{"1/30/2026", "01-30", true},
+		{"2026-01-30", "01-30", true},
+		{"Jan 30", "01-30", true},
+		{"2/6", "02-06", true},
+		{"13/1", "", false},
+		{"2-31-extra", "", false},
+		{"week 5", "", false},
+		{"unknown", "", false},
+	}
+	for _, tt := range tests {
+		got, err := weekKey(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("weekKey(%q) = %q, %v, want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestWeekKeyRoundTrip checks that a week given on the command line in any
+// form finds the logs weekOf files under it, whether the week came from the
+// log's dates or was set with --week when it was read.
+func TestWeekKeyRoundTrip(t *testing.T) {
+	for _, date := range []string{"1/30", "01/30", "2/6", "12/31", "2026-02-06", "Feb 6"} {
+		fromDates := weekOf(ReadingLog{ReadingEntries: []ReadingEntry{{Day: "?"}, {Date: date}}})
+		for _, given := range []string{date, fromDates, dateKey(date)} {
+			key, err := weekKey(given)
+			if err != nil {
+				t.Errorf("weekKey(%q): %v", given, err)
+				continue
+			}
+			if key != fromDates {
+				t.Errorf("weekKey(%q) = %q, but weekOf a log dated %q is %q", given, key, date, fromDates)
+			}
+			if set := weekOf(ReadingLog{Week: key}); set != key {
+				t.Errorf("weekOf a log set to week %q = %q", key, set)
+			}
+		}
+	}
+
+	if got := weekOf(ReadingLog{ReadingEntries: []ReadingEntry{{Date: "?"}}}); got != "unknown" {
+		t.Errorf("weekOf a log with no dates = %q, want unknown", got)
+	}
+}
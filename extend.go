@@ -0,0 +1,54 @@
+package main
+
+// The parser is a single program rather than a library, so it is extended
+// by adding a Go file to this directory that registers what it adds from
+// an init function, without editing the files here:
+//
+//	func init() {
+//		registerValidator(func(log ReadingLog) []string {
+//			if log.HomeroomTeacher == "" {
+//				return []string{"no homeroom teacher"}
+//			}
+//			return nil
+//		})
+//	}
+//
+// Image sources and output formats are registered with registerSource and
+// registerFormat.
+
+// A Validator returns the problems it finds with a result, each a short
+// description for the review queue, like the configured validation rules.
+type Validator func(log ReadingLog) []string
+
+// A Normalizer tidies a result after the built-in normalizing. Results are
+// normalized again each time they are read back, so it must leave a result
+// it has already tidied as it is.
+type Normalizer func(log *ReadingLog)
+
+var (
+	validators  []Validator
+	normalizers []Normalizer
+	prompts     = map[string]string{} // registered prompt templates, by name
+)
+
+// registerValidator adds a check that every result is put through along
+// with the validation rules.
+func registerValidator(v Validator) {
+	validators = append(validators, v)
+}
+
+// registerNormalizer adds a step run on every result after the built-in
+// normalizing, in the order registered.
+func registerNormalizer(n Normalizer) {
+	normalizers = append(normalizers, n)
+}
+
+// registerPrompt adds an extraction prompt that --prompt-file (or
+// prompt_file) can name instead of a file. The text is a template of the
+// same shape as a prompt file.
+func registerPrompt(name, text string) {
+	if _, ok := prompts[name]; ok {
+		panic("prompt " + name + " registered twice")
+	}
+	prompts[name] = text
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Exit codes, so scripts that run the program can tell what went wrong.
+const (
+	exitFailure = 1 // any error not covered below
+	exitPartial = 2 // the run finished, but some images couldn't be read or it was stopped
+	exitConfig  = 3 // the config file, an environment variable, or a flag is invalid
+	exitAuth    = 4 // the API key is missing or was refused
+	exitLocked  = 5 // another run is using the progress file
+)
+
+// configError is an error in the settings rather than in what was done
+// with them.
+type configError struct{ err error }
+
+func (e configError) Error() string { return e.err.Error() }
+func (e configError) Unwrap() error { return e.err }
+
+// configErrorf formats a configError.
+func configErrorf(format string, args ...any) error {
+	return configError{fmt.Errorf(format, args...)}
+}
+
+// errLocked is returned when another run holds the progress lock.
+var errLocked = errors.New("in use by another run")
+
+// errNoAPIKey is returned when there is no API key to read images with.
+var errNoAPIKey = errors.New("no API key; set ANTHROPIC_API_KEY or save one with `auth login`")
+
+// exitCode is the code to exit with after a command fails with err.
+func exitCode(err error) int {
+	var ce configError
+	switch {
+	case errors.As(err, &ce):
+		return exitConfig
+	case failureKind(err) == kindAuth:
+		return exitAuth
+	case errors.Is(err, errLocked):
+		return exitLocked
+	}
+	return exitFailure
+}
+
+// exitCode is the code a run that read images exits with: auth when an
+// image was refused for the API key, partial when any other failed or the
+// run was stopped, and otherwise success.
+func (p *pipeline) exitCode() int {
+	switch {
+	case p.failures[kindAuth] > 0:
+		return exitAuth
+	case len(p.failures) > 0 || p.stopped:
+		return exitPartial
+	}
+	return 0
+}
+
+// Kinds of image failure, recorded in the progress file under failures so
+// that retry and scripts can treat them differently.
+const (
+	kindAuth      = "auth"       // the API key is missing or was refused
+	kindRateLimit = "rate_limit" // the API's rate limit was still hit after retrying
+	kindParse     = "parse"      // the model's response couldn't be understood
+	kindImage     = "image"      // the image couldn't be read, converted, or sent
+	kindTimeout   = "timeout"    // it took longer than --timeout, or the run ended first
+	kindAPI       = "api"        // any other API or network error, such as an overload
+	kindOther     = "other"
+)
+
+// failureKinds are the kinds, in the order they are listed.
+var failureKinds = []string{kindAuth, kindRateLimit, kindParse, kindImage, kindTimeout, kindAPI, kindOther}
+
+// errParse marks a model response that couldn't be understood.
+var errParse = errors.New("failed to parse response JSON")
+
+// imageError is an error reading, converting, or encoding an image, before
+// it is sent anywhere.
+type imageError struct{ err error }
+
+func (e imageError) Error() string { return e.err.Error() }
+func (e imageError) Unwrap() error { return e.err }
+
+// failureKind sorts an error into one of the kinds of failure.
+func failureKind(err error) string {
+	var apiErr *anthropic.Error
+	var imgErr imageError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errNoAPIKey):
+		return kindAuth
+	case errors.As(err, &apiErr):
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return kindAuth
+		case http.StatusTooManyRequests:
+			return kindRateLimit
+		}
+		return kindAPI
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return kindTimeout
+	case errors.Is(err, errParse):
+		return kindParse
+	case errors.As(err, &imgErr):
+		return kindImage
+	case errors.As(err, &netErr):
+		return kindAPI
+	}
+	return kindOther
+}
+
+// checkAPIKey returns errNoAPIKey if images are to be read with the API and
+// there is no key for it, so the run stops before failing on every image.
+func (p *pipeline) checkAPIKey() error {
+	c, ok := p.client.(*anthropicClient)
+	if !ok || (c.recordings != nil && c.recordings.replay) {
+		return nil
+	}
+	if os.Getenv("ANTHROPIC_API_KEY") == "" && os.Getenv("ANTHROPIC_AUTH_TOKEN") == "" {
+		return errNoAPIKey
+	}
+	return nil
+}
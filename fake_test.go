@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFakeClientDeterministic(t *testing.T) {
+	form := FormTemplate{Days: []DayConfig{{Day: "Monday", Date: "2/2"}, {Day: "Tuesday", Date: "2/3"}, {Day: "Wednesday", Date: "2/4"}}}
+	ctx := context.Background()
+	a, err := (&fakeClient{}).ParseReadingLog(ctx, "image/png", "image one", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := (&fakeClient{}).ParseReadingLog(ctx, "image/png", "image one", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("the same image gave different logs:\n%+v\n%+v", a, b)
+	}
+	if len(a.ReadingEntries) != len(form.Days) {
+		t.Errorf("got %d entries, want one for each of the form's %d days", len(a.ReadingEntries), len(form.Days))
+	}
+
+	// The written times the fake gives are read back as its minutes.
+	for _, e := range a.ReadingEntries {
+		if e.Written == "" {
+			continue
+		}
+		if m, ok := parseDuration(e.Written); !ok || m != e.Minutes {
+			t.Errorf("written %q reads as %d, %v, want %d", e.Written, m, ok, e.Minutes)
+		}
+	}
+}
+
+func TestFakeClientErrors(t *testing.T) {
+	errBusy := errors.New("overloaded")
+	f := &fakeClient{Errors: []error{errBusy, errBusy}}
+	ctx := context.Background()
+	for i := range 2 {
+		if _, err := f.ParseReadingLog(ctx, "image/png", "image", defaultTemplate()); !errors.Is(err, errBusy) {
+			t.Fatalf("call %d: got %v, want %v", i+1, err, errBusy)
+		}
+	}
+	if _, err := f.ParseReadingLog(ctx, "image/png", "image", defaultTemplate()); err != nil {
+		t.Fatalf("call 3: %v", err)
+	}
+	if f.Calls != 3 {
+		t.Errorf("Calls = %d, want 3", f.Calls)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := f.ParseReadingLog(canceled, "image/png", "image", defaultTemplate()); !errors.Is(err, context.Canceled) {
+		t.Errorf("with a canceled context got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFakeClientSecondReader(t *testing.T) {
+	form := FormTemplate{Days: []DayConfig{{Day: "Monday", Date: "2/2"}, {Day: "Tuesday", Date: "2/3"}, {Day: "Wednesday", Date: "2/4"}}}
+	ctx := context.Background()
+	differ := 0
+	for i := range 40 {
+		image := fmt.Sprintf("image %d", i)
+		first, err1 := (&fakeClient{}).ParseReadingLog(ctx, "image/png", image, form)
+		second, err2 := (&fakeClient{Second: true}).ParseReadingLog(ctx, "image/png", image, form)
+		if err1 != nil || err2 != nil {
+			t.Fatal(err1, err2)
+		}
+		if totalMinutes(*first) != totalMinutes(*second) {
+			differ++
+		}
+	}
+	if differ == 0 || differ == 40 {
+		t.Errorf("the second reader disagreed on %d of 40 images, want some but not all", differ)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// FieldConfig is an extra value to extract from the form, beyond the
+// built-in fields. Values are stored under "extra" on the log or on each
+// day's entry.
+type FieldConfig struct {
+	Name        string `yaml:"name" toml:"name"`
+	Description string `yaml:"description" toml:"description"` // tells the model what to look for
+	Type        string `yaml:"type" toml:"type"`               // string, integer, number, or boolean; default string
+	Per         string `yaml:"per" toml:"per"`                 // log or entry; default log
+}
+
+// fieldTypes are the JSON Schema types an extra field may have.
+var fieldTypes = map[string]bool{"string": true, "integer": true, "number": true, "boolean": true}
+
+func (f FieldConfig) perEntry() bool { return f.Per == "entry" }
+
+// validateFields checks the configured extra fields, filling in defaults.
+func validateFields(fields []FieldConfig) error {
+	seen := make(map[string]bool)
+	for i := range fields {
+		f := &fields[i]
+		if f.Name == "" {
+			return fmt.Errorf("fields[%d]: name is required", i)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("fields: %q is defined twice", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Type == "" {
+			f.Type = "string"
+		}
+		if !fieldTypes[f.Type] {
+			return fmt.Errorf("fields.%s: unknown type %q (want string, integer, number, or boolean)", f.Name, f.Type)
+		}
+		if f.Per == "" {
+			f.Per = "log"
+		}
+		if f.Per != "log" && f.Per != "entry" {
+			return fmt.Errorf("fields.%s: per must be log or entry, not %q", f.Name, f.Per)
+		}
+	}
+	return nil
+}
+
+// addExtraFields adds the configured fields to the extraction schema as a
+// required "extra" object on the log or on each reading entry.
+func addExtraFields(schema *jsonschema.Schema, fields []FieldConfig) {
+	var logFields, entryFields []FieldConfig
+	for _, f := range fields {
+		if f.perEntry() {
+			entryFields = append(entryFields, f)
+		} else {
+			logFields = append(logFields, f)
+		}
+	}
+	if len(logFields) > 0 {
+		setExtra(schema, logFields, "Additional values written on the form")
+	}
+	if entries, ok := schema.Properties.Get("reading_entries"); ok && entries.Items != nil && len(entryFields) > 0 {
+		setExtra(entries.Items, entryFields, "Additional values written for this day")
+	}
+}
+
+func setExtra(parent *jsonschema.Schema, fields []FieldConfig, description string) {
+	extra := &jsonschema.Schema{
+		Type:                 "object",
+		Description:          description,
+		Properties:           jsonschema.NewProperties(),
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+	for _, f := range fields {
+		extra.Properties.Set(f.Name, &jsonschema.Schema{Type: f.Type, Description: f.Description})
+		extra.Required = append(extra.Required, f.Name)
+	}
+	parent.Properties.Set("extra", extra)
+	parent.Required = append(parent.Required, "extra")
+}
+
+// fieldColumn returns a log's value for an extra field as a single CSV cell.
+// Entry-level values are the distinct values across the week.
+func fieldColumn(log ReadingLog, f FieldConfig) string {
+	if !f.perEntry() {
+		return formatValue(log.Extra[f.Name])
+	}
+	values := make([]string, len(log.ReadingEntries))
+	for i, e := range log.ReadingEntries {
+		values[i] = formatValue(e.Extra[f.Name])
+	}
+	return joinDistinct(values)
+}
+
+// formatValue renders an extracted value as text. Whole numbers print
+// without a decimal point.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseValue reads a CSV cell back into a value of the field's type. An
+// empty cell is no value.
+func parseValue(f FieldConfig, s string) (any, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	switch f.Type {
+	case "integer":
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a whole number", f.Name, s)
+		}
+		return float64(v), nil // as decoded from JSON
+	case "number":
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a number", f.Name, s)
+		}
+		return v, nil
+	case "boolean":
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not true or false", f.Name, s)
+		}
+		return v, nil
+	default:
+		return s, nil
+	}
+}
+
+// joinDistinct joins the non-empty values in first-seen order, skipping
+// repeats.
+func joinDistinct(values []string) string {
+	seen := make(map[string]bool)
+	var kept []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v != "" && !seen[v] {
+			seen[v] = true
+			kept = append(kept, v)
+		}
+	}
+	return strings.Join(kept, "; ")
+}
+
+// bookTitles returns the distinct book titles a log lists.
+func bookTitles(log ReadingLog) string {
+	titles := make([]string, len(log.ReadingEntries))
+	for i, e := range log.ReadingEntries {
+		titles[i] = e.BookTitle
+	}
+	return joinDistinct(titles)
+}
+
+// fieldPrompt describes the configured extra fields for the prompt, or
+// returns "" when there are none.
+func fieldPrompt(fields []FieldConfig) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(" Also extract these additional values, leaving a value empty (or 0, or false) if it isn't on the form:\n")
+	for _, f := range fields {
+		where := "for the whole form"
+		if f.perEntry() {
+			where = "for each day"
+		}
+		fmt.Fprintf(&b, "\n- %s (%s, %s): %s", f.Name, f.Type, where, f.Description)
+	}
+	return b.String()
+}
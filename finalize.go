@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// runFinalize locks every result in a week once its numbers are approved,
+// so later runs leave them alone; --undo unlocks the week again.
+func runFinalize(args []string) error {
+	fs := flag.NewFlagSet("finalize", flag.ContinueOnError)
+	undo := fs.Bool("undo", false, "unlock the week so its results can be read again")
+	forceUnlock := lockFlag(fs)
+	weeks := parseArgs(fs, args)
+	if len(weeks) != 1 {
+		return fmt.Errorf("usage: finalize <week> [--undo]")
+	}
+	week := weeks[0]
+	if err := lockProgress(*forceUnlock); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, log := range completedLogs(progress) {
+		if weekOf(log) != week {
+			continue
+		}
+		for _, name := range []string{log.Source, log.Back} {
+			if name == "" {
+				continue
+			}
+			key := progress.keyOf(name)
+			r := progress.Completed[key]
+			r.Locked = !*undo
+			progress.Completed[key] = r
+		}
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no results for week %s", week)
+	}
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf("could not save progress: %w", err)
+	}
+	if *undo {
+		green.Printf("  ✓ Unlocked %d result(s) for week %s\n", count, week)
+	} else {
+		green.Printf("  ✓ Finalized %d result(s) for week %s\n", count, week)
+		dim.Println("  Later runs, --force, and retry will leave them as they are")
+	}
+	return nil
+}
+
+// protected reports whether an image's results were verified by a person or
+// finalized, so reading the image again mustn't replace them.
+func (p *Progress) protected(id string) bool {
+	for key, log := range p.Completed {
+		if imageOf(key) == id && (log.Verified || log.Locked) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// The page is looked for in a copy of the photo scaled down to
+// flattenSearchSide pixels on its longer side. It must cover at least
+// minPageArea of the photo, and fill most of the four-sided shape its
+// corners make, or the photo is sent as it is. Images with a side shorter
+// than minFlattenSide are too small to be photos of a page.
+const (
+	flattenSearchSide = 512
+	minFlattenSide    = 256
+	minPageArea       = 0.2
+	minPageFill       = 0.85
+)
+
+// flattenPage finds the page in a photo taken at an angle and warps it into
+// a flat rectangle, so that the rows and columns of the form run straight.
+// It returns the flattened page as a JPEG, or ok false if it couldn't find
+// the page or the page already fills the photo. The page is found as the
+// largest light area, so this works for a white form on a darker table.
+func flattenPage(data []byte) (flat []byte, ok bool, err error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	if orientation := exifOrientationOf(exifOf(data)); orientation > 1 {
+		img = orient(img, img.Bounds(), orientation)
+	}
+	b := img.Bounds()
+	if min(b.Dx(), b.Dy()) < minFlattenSide {
+		return nil, false, nil
+	}
+
+	scale := float64(flattenSearchSide) / float64(max(b.Dx(), b.Dy()))
+	small := image.NewGray(image.Rect(0, 0, max(1, int(float64(b.Dx())*scale)), max(1, int(float64(b.Dy())*scale))))
+	draw.BiLinear.Scale(small, small.Bounds(), img, b, draw.Src, nil)
+	corners, ok := findPage(small)
+	if !ok {
+		return nil, false, nil
+	}
+	sx, sy := float64(b.Dx())/float64(small.Rect.Dx()), float64(b.Dy())/float64(small.Rect.Dy())
+	for i := range corners {
+		corners[i] = point{float64(b.Min.X) + (corners[i].x+0.5)*sx, float64(b.Min.Y) + (corners[i].y+0.5)*sy}
+	}
+
+	page := warpPage(img, corners)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, page, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, false, fmt.Errorf("JPEG encoding failed: %w", err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// point is a position in an image, in pixels.
+type point struct{ x, y float64 }
+
+// findPage returns the corners of the page in a grayscale photo, clockwise
+// from the top left, or ok false if there is no clear page or it fills the
+// photo.
+func findPage(gray *image.Gray) (corners [4]point, ok bool) {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	light := make([]bool, w*h)
+	t := otsuThreshold(gray.Pix)
+	for i, v := range gray.Pix {
+		light[i] = v > t
+	}
+	// Close up the writing and the form's lines, which would otherwise cut
+	// the page into pieces.
+	light = erode(dilate(light, w, h, 3), w, h, 3)
+
+	area := largestRegion(light, w, h)
+	if float64(len(area)) < minPageArea*float64(w*h) {
+		return corners, false
+	}
+	// The corners are the points furthest toward each corner of the photo.
+	tl, tr, br, bl := area[0], area[0], area[0], area[0]
+	for _, i := range area {
+		x, y := i%w, i/w
+		if x+y < tl%w+tl/w {
+			tl = i
+		}
+		if x+y > br%w+br/w {
+			br = i
+		}
+		if x-y > tr%w-tr/w {
+			tr = i
+		}
+		if x-y < bl%w-bl/w {
+			bl = i
+		}
+	}
+	for i, c := range []int{tl, tr, br, bl} {
+		corners[i] = point{float64(c % w), float64(c / w)}
+	}
+
+	// A page whose corners are the photo's needs no flattening, and one
+	// that is far from four-sided, or folded in on itself, isn't a page.
+	margin := 0.02 * float64(max(w, h))
+	photo := [4]point{{0, 0}, {float64(w - 1), 0}, {float64(w - 1), float64(h - 1)}, {0, float64(h - 1)}}
+	filled := true
+	for i := range corners {
+		if math.Abs(corners[i].x-photo[i].x) > margin || math.Abs(corners[i].y-photo[i].y) > margin {
+			filled = false
+		}
+	}
+	quad := quadArea(corners)
+	if filled || !isConvex(corners) || quad < minPageArea*float64(w*h) || float64(len(area)) < minPageFill*quad {
+		return corners, false
+	}
+	return corners, true
+}
+
+// otsuThreshold returns the gray level that best separates pix into dark
+// and light, by Otsu's method.
+func otsuThreshold(pix []uint8) uint8 {
+	var hist [256]int
+	for _, v := range pix {
+		hist[v]++
+	}
+	var sum float64
+	for v, n := range hist {
+		sum += float64(v * n)
+	}
+	var best float64
+	var threshold uint8
+	var darkSum float64
+	dark := 0
+	for v, n := range hist {
+		dark += n
+		if dark == 0 {
+			continue
+		}
+		lightCount := len(pix) - dark
+		if lightCount == 0 {
+			break
+		}
+		darkSum += float64(v * n)
+		darkMean := darkSum / float64(dark)
+		lightMean := (sum - darkSum) / float64(lightCount)
+		between := float64(dark) * float64(lightCount) * (darkMean - lightMean) * (darkMean - lightMean)
+		if between > best {
+			best, threshold = between, uint8(v)
+		}
+	}
+	return threshold
+}
+
+// dilate grows the set pixels of a w×h mask by r pixels in each direction.
+func dilate(mask []bool, w, h, r int) []bool {
+	return spread(mask, w, h, r, true)
+}
+
+// erode shrinks the set pixels of a w×h mask by r pixels in each direction.
+func erode(mask []bool, w, h, r int) []bool {
+	return spread(mask, w, h, r, false)
+}
+
+// spread sets each pixel of a mask to want if any pixel within r of it,
+// horizontally and then vertically, is want.
+func spread(mask []bool, w, h, r int, want bool) []bool {
+	rows := make([]bool, len(mask))
+	for y := range h {
+		for x := range w {
+			v := !want
+			for dx := max(0, x-r); dx <= min(w-1, x+r); dx++ {
+				if mask[y*w+dx] == want {
+					v = want
+					break
+				}
+			}
+			rows[y*w+x] = v
+		}
+	}
+	out := make([]bool, len(mask))
+	for y := range h {
+		for x := range w {
+			v := !want
+			for dy := max(0, y-r); dy <= min(h-1, y+r); dy++ {
+				if rows[dy*w+x] == want {
+					v = want
+					break
+				}
+			}
+			out[y*w+x] = v
+		}
+	}
+	return out
+}
+
+// largestRegion returns the indexes of the pixels in the largest connected
+// area of set pixels in a w×h mask.
+func largestRegion(mask []bool, w, h int) []int {
+	seen := make([]bool, len(mask))
+	var largest []int
+	for start := range mask {
+		if !mask[start] || seen[start] {
+			continue
+		}
+		region := []int{start}
+		seen[start] = true
+		for i := 0; i < len(region); i++ {
+			p := region[i]
+			x, y := p%w, p/w
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[0] >= w || n[1] < 0 || n[1] >= h {
+					continue
+				}
+				q := n[1]*w + n[0]
+				if mask[q] && !seen[q] {
+					seen[q] = true
+					region = append(region, q)
+				}
+			}
+		}
+		if len(region) > len(largest) {
+			largest = region
+		}
+	}
+	return largest
+}
+
+// quadArea returns the area of a four-sided shape, by the shoelace formula.
+func quadArea(c [4]point) float64 {
+	var a float64
+	for i := range c {
+		j := (i + 1) % 4
+		a += c[i].x*c[j].y - c[j].x*c[i].y
+	}
+	return math.Abs(a) / 2
+}
+
+// isConvex reports whether the corners, in order, turn the same way at
+// each corner.
+func isConvex(c [4]point) bool {
+	sign := 0.0
+	for i := range c {
+		a, b, d := c[i], c[(i+1)%4], c[(i+2)%4]
+		cross := (b.x-a.x)*(d.y-b.y) - (b.y-a.y)*(d.x-b.x)
+		if cross == 0 || (sign != 0 && (cross > 0) != (sign > 0)) {
+			return false
+		}
+		sign = cross
+	}
+	return true
+}
+
+// warpPage maps the four-sided area of img with the given corners onto an
+// upright rectangle as wide as its longer top or bottom edge and as tall
+// as its longer side.
+func warpPage(img image.Image, c [4]point) image.Image {
+	dist := func(a, b point) float64 { return math.Hypot(a.x-b.x, a.y-b.y) }
+	w := int(math.Max(dist(c[0], c[1]), dist(c[3], c[2])))
+	h := int(math.Max(dist(c[0], c[3]), dist(c[1], c[2])))
+	m := homography([4]point{{0, 0}, {float64(w), 0}, {float64(w), float64(h)}, {0, float64(h)}}, c)
+
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = image.NewRGBA(img.Bounds())
+		draw.Draw(src, src.Rect, img, img.Bounds().Min, draw.Src)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			u, v := float64(x)+0.5, float64(y)+0.5
+			d := m[6]*u + m[7]*v + 1
+			sx := (m[0]*u + m[1]*v + m[2]) / d
+			sy := (m[3]*u + m[4]*v + m[5]) / d
+			copy(out.Pix[out.PixOffset(x, y):], bilinear(src, sx-0.5, sy-0.5))
+		}
+	}
+	return out
+}
+
+// bilinear returns the color of src at a point between pixels, blended
+// from the four pixels around it.
+func bilinear(src *image.RGBA, x, y float64) []uint8 {
+	b := src.Rect
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	clampX := func(x int) int { return min(max(x, b.Min.X), b.Max.X-1) }
+	clampY := func(y int) int { return min(max(y, b.Min.Y), b.Max.Y-1) }
+	p00 := src.PixOffset(clampX(x0), clampY(y0))
+	p10 := src.PixOffset(clampX(x0+1), clampY(y0))
+	p01 := src.PixOffset(clampX(x0), clampY(y0+1))
+	p11 := src.PixOffset(clampX(x0+1), clampY(y0+1))
+	var px [4]uint8
+	for i := range px {
+		top := float64(src.Pix[p00+i])*(1-fx) + float64(src.Pix[p10+i])*fx
+		bottom := float64(src.Pix[p01+i])*(1-fx) + float64(src.Pix[p11+i])*fx
+		px[i] = uint8(top*(1-fy) + bottom*fy + 0.5)
+	}
+	return px[:]
+}
+
+// homography returns the projective transform taking each point in from to
+// the matching point in to, as the first eight entries of its 3×3 matrix
+// (the ninth is 1).
+func homography(from, to [4]point) [8]float64 {
+	// Each pair of points gives two rows of an 8×8 linear system.
+	var a [8][9]float64
+	for i := range from {
+		u, v, x, y := from[i].x, from[i].y, to[i].x, to[i].y
+		a[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+	// Gaussian elimination with partial pivoting.
+	for col := range 8 {
+		pivot := col
+		for r := col + 1; r < 8; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for r := range 8 {
+			if r == col || a[col][col] == 0 {
+				continue
+			}
+			f := a[r][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[r][k] -= f * a[col][k]
+			}
+		}
+	}
+	var m [8]float64
+	for i := range m {
+		if a[i][i] != 0 {
+			m[i] = a[i][8] / a[i][i]
+		}
+	}
+	return m
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+// hasGoals reports whether a weekly minutes goal is set for any grade.
+func (c *Config) hasGoals() bool {
+	return c.Goal > 0 || len(c.Goals) > 0
+}
+
+// goalFor returns the weekly minutes goal for a grade: its own from goals,
+// written any way normalizeGrade understands, or else goal. It is 0 if
+// there is none.
+func (c *Config) goalFor(grade string) int {
+	if goal, ok := c.Goals[grade]; ok {
+		return goal
+	}
+	for g, goal := range c.Goals {
+		if normalizeGrade(g) == grade {
+			return goal
+		}
+	}
+	return c.Goal
+}
+
+// goalColumns returns the Goal and Goal Met cells for a week's total
+// minutes, both empty if the grade has no goal.
+func goalColumns(grade string, minutes int) (goal, met string) {
+	g := cfg.goalFor(grade)
+	if g == 0 {
+		return "", ""
+	}
+	if minutes >= g {
+		return fmt.Sprint(g), "yes"
+	}
+	return fmt.Sprint(g), "no"
+}
+
+// runReportGoals reports how many students in each class read their weekly
+// goal, for the reading challenge scoreboard.
+func runReportGoals(args []string) error {
+	fs := flag.NewFlagSet("report goals", flag.ContinueOnError)
+	week := fs.String("week", "", "week to report (default: the most recent)")
+	by := fs.String("by", "teacher", "count students by teacher or grade")
+	students := fs.Bool("students", false, "list each student with their minutes and goal instead")
+	csvPath := fs.String("csv", "", "also write the report to this CSV file")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	if !cfg.hasGoals() {
+		return configErrorf("no goal is set; set goal (or goals for each grade) in the config file")
+	}
+	groupOf, err := groupBy(*by)
+	if err != nil {
+		return fmt.Errorf("--by: %w", err)
+	}
+	season, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	if *week == "" {
+		*week = latestWeek(season)
+	}
+	var logs []ReadingLog
+	for _, log := range season {
+		if weekOf(log) == *week {
+			logs = append(logs, log)
+		}
+	}
+	if len(logs) == 0 {
+		return fmt.Errorf("no results for week %s", *week)
+	}
+
+	var header []string
+	var rows [][]string
+	if *students {
+		header = []string{"Student", "Grade", "Classroom", "Minutes", "Goal", "Met"}
+		for _, t := range aggregateStudents(logs) {
+			goal, met := goalColumns(t.Grade, t.Minutes)
+			rows = append(rows, []string{t.Name, t.Grade, t.Classroom, fmt.Sprint(t.Minutes), goal, met})
+		}
+	} else {
+		header, rows = goalSummary(*by, groupOf, logs)
+	}
+
+	bold.Printf("\n  Weekly goal for the week of %s\n\n", *week)
+	printTable(header, rows)
+	if *csvPath != "" {
+		if err := writeTableCSV(*csvPath, header, rows); err != nil {
+			return err
+		}
+		boldGrn.Printf("\n  Wrote %d row(s) to %s\n", len(rows), *csvPath)
+	}
+	return nil
+}
+
+// goalSummary counts, for each teacher or grade, the students with a goal
+// and how many of them met it, with a row for the whole school last.
+// Students whose grade has no goal aren't counted.
+func goalSummary(by string, groupOf func(ReadingLog) string, logs []ReadingLog) ([]string, [][]string) {
+	type tally struct{ students, met int }
+	groups := make(map[string]*tally)
+	var all tally
+	for _, t := range aggregateStudents(logs) {
+		goal := cfg.goalFor(t.Grade)
+		if goal == 0 {
+			continue
+		}
+		name := groupOf(ReadingLog{Grade: t.Grade, Classroom: t.Classroom})
+		if groups[name] == nil {
+			groups[name] = &tally{}
+		}
+		for _, g := range []*tally{groups[name], &all} {
+			g.students++
+			if t.Minutes >= goal {
+				g.met++
+			}
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	percent := func(t tally) string {
+		return fmt.Sprintf("%.0f%%", 100*float64(t.met)/float64(t.students))
+	}
+	label := map[string]string{"teacher": "Classroom", "grade": "Grade"}[by]
+	header := []string{label, "Students", "Met Goal", "Percent"}
+	var rows [][]string
+	for _, name := range names {
+		t := *groups[name]
+		rows = append(rows, []string{name, fmt.Sprint(t.students), fmt.Sprint(t.met), percent(t)})
+	}
+	if all.students > 0 {
+		rows = append(rows, []string{"All", fmt.Sprint(all.students), fmt.Sprint(all.met), percent(all)})
+	}
+	return header, rows
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// History keeps completed results from every week of the season, so
+// cumulative reports survive starting each week in a fresh directory.
+type History struct {
+	Weeks map[string]map[string]ReadingLog `json:"weeks"` // week → image filename → result
+}
+
+func newHistory() *History {
+	return &History{Weeks: make(map[string]map[string]ReadingLog)}
+}
+
+// loadHistory reads the history file named by the history setting. A missing
+// file is an empty history; an unreadable one is an error, so a season of
+// results is never silently replaced.
+func loadHistory() (*History, error) {
+	h := newHistory()
+	data, err := readStoreFile(store, cfg.History)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", cfg.History, err)
+	}
+	if h.Weeks == nil {
+		h.Weeks = make(map[string]map[string]ReadingLog)
+	}
+	return h, nil
+}
+
+func saveHistory(h *History) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStoreFile(store, cfg.History, data)
+}
+
+// record files each log under its week, replacing any earlier result for
+// the same image that week. Filenames are only unique within a week, since
+// each week's photos may come from a fresh camera roll.
+func (h *History) record(logs []ReadingLog) {
+	for _, log := range logs {
+		week := weekOf(log)
+		if h.Weeks[week] == nil {
+			h.Weeks[week] = make(map[string]ReadingLog)
+		}
+		h.Weeks[week][log.Source] = log
+		if log.Back != "" {
+			delete(h.Weeks[week], log.Back) // now part of its front page's result
+		}
+	}
+}
+
+// remove drops the result for an image from a week, e.g. after it was
+// reassigned to another week.
+func (h *History) remove(week, name string) {
+	delete(h.Weeks[week], name)
+	if len(h.Weeks[week]) == 0 {
+		delete(h.Weeks, week)
+	}
+}
+
+// logs returns every recorded result, ordered by week and then filename.
+func (h *History) logs() []ReadingLog {
+	weeks := make([]string, 0, len(h.Weeks))
+	for w := range h.Weeks {
+		weeks = append(weeks, w)
+	}
+	sort.Strings(weeks)
+
+	var logs []ReadingLog
+	for _, w := range weeks {
+		names := make([]string, 0, len(h.Weeks[w]))
+		for name := range h.Weeks[w] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			log := h.Weeks[w][name]
+			log.Source = name
+			logs = append(logs, log)
+		}
+	}
+	return logs
+}
+
+// updateHistory records logs in the history file.
+func updateHistory(logs []ReadingLog) error {
+	return editHistory(func(h *History) { h.record(logs) })
+}
+
+// editHistory loads the history file, applies edit, and saves it.
+func editHistory(edit func(h *History)) error {
+	h, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	edit(h)
+	return saveHistory(h)
+}
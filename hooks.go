@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hookTimeout is how long a hook script may run before it is stopped.
+const hookTimeout = 5 * time.Minute
+
+// hookRun is what on_complete is given on stdin when a run ends.
+type hookRun struct {
+	Run     string          `json:"run,omitempty"` // the run's ID in the run history, if kept
+	Outputs []string        `json:"outputs"`       // the files written
+	Records []webhookRecord `json:"records"`       // the results read in this run, as on_record was given them
+}
+
+// hooks runs the user's scripts: on_record with each result as it is read,
+// and on_complete once the run's output is written. Scripts for on_record
+// run one at a time in the background, and results wait for them in a
+// queue with no limit, so a slow one doesn't hold up reading. A nil *hooks
+// runs nothing.
+type hooks struct {
+	onRecord   []string
+	onComplete []string
+
+	mu      sync.Mutex // guards pending, closed, and records
+	pending []webhookRecord
+	closed  bool
+	wake    chan struct{} // signalled when pending grows or the hooks close
+	done    chan struct{}
+	records []webhookRecord
+	ran     int
+	failed  int
+}
+
+// newHooks returns the hooks for the two command lines, which are split
+// into arguments like a daemon job's and run without a shell.
+func newHooks(onRecord, onComplete string) (*hooks, error) {
+	if onRecord == "" && onComplete == "" {
+		return nil, nil
+	}
+	h := &hooks{wake: make(chan struct{}, 1), done: make(chan struct{})}
+	var err error
+	if h.onRecord, err = splitArgs(onRecord); err != nil {
+		return nil, fmt.Errorf("on_record: %w", err)
+	}
+	if h.onComplete, err = splitArgs(onComplete); err != nil {
+		return nil, fmt.Errorf("on_complete: %w", err)
+	}
+	go h.run()
+	return h, nil
+}
+
+// run runs on_record for each queued result in turn until the hooks are
+// closed and the queue is empty.
+func (h *hooks) run() {
+	defer close(h.done)
+	for {
+		h.mu.Lock()
+		batch, closed := h.pending, h.closed
+		h.pending = nil
+		h.mu.Unlock()
+		if len(batch) == 0 {
+			if closed {
+				return
+			}
+			<-h.wake
+			continue
+		}
+		for _, r := range batch {
+			if err := runHook("on_record", h.onRecord, r); err != nil {
+				h.failed++
+				logger.Warn("on_record hook failed", "image", r.Image, "err", err)
+				continue
+			}
+			h.ran++
+		}
+	}
+}
+
+// signal wakes run if it is waiting. It never blocks.
+func (h *hooks) signal() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// record queues a result for on_record, and keeps it for on_complete. It
+// never waits for a script.
+func (h *hooks) record(r webhookRecord) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	if len(h.onComplete) > 0 {
+		h.records = append(h.records, r)
+	}
+	if len(h.onRecord) > 0 {
+		h.pending = append(h.pending, r)
+	}
+	h.mu.Unlock()
+	h.signal()
+}
+
+// close waits for on_record to finish with every result and returns how
+// many times it ran and failed.
+func (h *hooks) close() (ran, failed int) {
+	if h == nil {
+		return 0, 0
+	}
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+	h.signal()
+	<-h.done
+	return h.ran, h.failed
+}
+
+// complete runs on_complete with the run's results and the paths of its
+// output.
+func (h *hooks) complete(run string, outputs []string) error {
+	if h == nil || len(h.onComplete) == 0 {
+		return nil
+	}
+	records := h.records
+	if records == nil {
+		records = []webhookRecord{}
+	}
+	return runHook("on_complete", h.onComplete, hookRun{Run: run, Outputs: outputs, Records: records})
+}
+
+// runHook runs a hook's command with input as JSON on stdin. The hook's
+// name is in READING_LOGS_HOOK. What the command prints is logged, and
+// included in the error if it fails.
+func runHook(name string, argv []string, input any) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Env = append(os.Environ(), "READING_LOGS_HOOK="+name)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	err = cmd.Run()
+	printed := strings.TrimSpace(out.String())
+	if ctx.Err() != nil {
+		return fmt.Errorf("stopped after %s", hookTimeout)
+	}
+	if err != nil {
+		if printed != "" {
+			return fmt.Errorf("%w: %s", err, lastLine(printed))
+		}
+		return err
+	}
+	if printed != "" {
+		logger.Debug(name+" hook output", "output", printed)
+	}
+	return nil
+}
+
+// lastLine returns the last line of s, where a failing command usually
+// says why.
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// languages are the languages messages can be shown in.
+var languages = []string{"en", "es"}
+
+// language is the language messages are shown in, from --lang or else the
+// locale.
+var language = localeLanguage()
+
+// translations are the messages in each language other than English, keyed
+// by their English text. A message with no translation is shown in English.
+var translations = map[string]map[string]string{
+	"es": spanishMessages,
+}
+
+// msg returns a message in the current language. Spaces and newlines around
+// it are kept as they are, so "  Flagged: %s\n" is looked up as
+// "Flagged: %s".
+func msg(s string) string {
+	core := strings.TrimSpace(s)
+	t, ok := translations[language][core]
+	if !ok {
+		return s
+	}
+	start := strings.Index(s, core)
+	return s[:start] + t + s[start+len(core):]
+}
+
+// localeLanguage returns the language named by LC_ALL, LC_MESSAGES, or
+// LANG, the first that is set ("es_MX.UTF-8" is Spanish), if messages can
+// be shown in it, or else English.
+func localeLanguage() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			lang, _, _ := strings.Cut(strings.ToLower(v), "_")
+			lang, _, _ = strings.Cut(lang, ".")
+			if slices.Contains(languages, lang) {
+				return lang
+			}
+			return "en"
+		}
+	}
+	return "en"
+}
+
+// langOption applies --lang, which every command accepts wherever it
+// appears, and returns the other arguments.
+func langOption(args []string) ([]string, error) {
+	rest := args[:0:0]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var lang string
+		switch {
+		case arg == "--lang" || arg == "-lang":
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("--lang needs a language (%s)", strings.Join(languages, ", "))
+			}
+			i++
+			lang = args[i]
+		case strings.HasPrefix(arg, "--lang="), strings.HasPrefix(arg, "-lang="):
+			_, lang, _ = strings.Cut(arg, "=")
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		if !slices.Contains(languages, lang) {
+			return nil, fmt.Errorf("--lang must be one of %s", strings.Join(languages, ", "))
+		}
+		language = lang
+	}
+	return rest, nil
+}
+
+// summaryLabels are the labels of the run summary and the API usage that
+// follows it, whose values line up in one column.
+var summaryLabels = []string{
+	"Images found", "Already done", "Newly processed", "Blank forms", "Failed", "Flagged",
+	"Requests", "Input tokens", "Cache reads", "Cache writes", "Output tokens", "Estimated cost",
+}
+
+// summaryLine prints one line of a summary, with its label in the current
+// language and padded so that the values line up.
+func summaryLine(label, value string) {
+	width := 17
+	for _, l := range summaryLabels {
+		width = max(width, utf8.RuneCountInString(msg(l))+1)
+	}
+	label = msg(label) + ":"
+	fmt.Printf("  %s%s %s\n", label, strings.Repeat(" ", width-utf8.RuneCountInString(label)), value)
+}
+
+// summaryRule returns the line across the top of a summary, with its title
+// in the current language, or across the bottom if title is empty.
+func summaryRule(title string) string {
+	const width = 38
+	if title == "" {
+		return glyphs(strings.Repeat("─", width))
+	}
+	title = msg(title)
+	return glyphs("─── " + title + " " + strings.Repeat("─", max(3, width-5-utf8.RuneCountInString(title))))
+}
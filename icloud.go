@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// runIngestICloud downloads the photos in an iCloud Shared Album that
+// haven't been downloaded before, then processes them.
+func runIngestICloud(args []string) error {
+	fs := flag.NewFlagSet("ingest icloud", flag.ContinueOnError)
+	album := fs.String("album", cfg.ICloudAlbum, "the shared album's public link, e.g. https://www.icloud.com/sharedalbum/#B0aGWZuqDGKkVa")
+	noParse := fs.Bool("no-parse", false, "only download photos, don't run the parser")
+	forceUnlock := lockFlag(fs)
+	parseFlags(fs, args)
+
+	if *album == "" {
+		return configErrorf("--album (or icloud_album in the config file) is required")
+	}
+	src, err := newICloudSource(*album)
+	if err != nil {
+		return configErrorf("--album: %w", err)
+	}
+	return ingest(src, *noParse, *forceUnlock)
+}
+
+// icloudSource is an iCloud Shared Album, written icloud: followed by its
+// public link or the token at the end of it.
+type icloudSource string
+
+func newICloudSource(location string) (ImageSource, error) {
+	token, err := albumToken(location)
+	if err != nil {
+		return nil, err
+	}
+	return icloudSource(token), nil
+}
+
+func (s icloudSource) String() string { return "icloud:" + string(s) }
+
+func (s icloudSource) Sync(ctx context.Context, progress *Progress) ([]string, error) {
+	return fetchSharedAlbum(ctx, string(s), progress)
+}
+
+// albumToken returns the token at the end of a shared album's public link,
+// after the #. A bare token is returned as it is.
+func albumToken(link string) (string, error) {
+	token := link
+	if _, after, ok := strings.Cut(link, "#"); ok {
+		token = after
+	}
+	token = strings.Trim(token, "/ ")
+	if len(token) < 3 || strings.ContainsAny(token, "/:?") {
+		return "", fmt.Errorf("%q is not a shared album link", link)
+	}
+	return token, nil
+}
+
+// albumHost returns the server a shared album is kept on, which is named in
+// its token. The server redirects if it is wrong.
+func albumHost(token string) string {
+	const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	digits := token[1:3]
+	if token[0] == 'A' {
+		digits = token[1:2]
+	}
+	n := 0
+	for _, c := range digits {
+		n = n*62 + strings.IndexRune(base62, c)
+	}
+	return fmt.Sprintf("p%02d-sharedstreams.icloud.com", n)
+}
+
+// albumPhoto is a photo in a shared album's stream. Each derivative is a
+// size of it, keyed by its longest side.
+type albumPhoto struct {
+	GUID        string `json:"photoGuid"`
+	Contributor string `json:"contributorFullName"`
+	MediaType   string `json:"mediaAssetType"`
+	Derivatives map[string]struct {
+		Checksum string `json:"checksum"`
+		Width    string `json:"width"`
+		Height   string `json:"height"`
+		FileSize string `json:"fileSize"`
+	} `json:"derivatives"`
+}
+
+// largest returns the checksum of the photo's largest derivative.
+func (p albumPhoto) largest() string {
+	best, size := "", -1
+	for _, d := range p.Derivatives {
+		var n int
+		fmt.Sscan(d.FileSize, &n)
+		if n > size {
+			best, size = d.Checksum, n
+		}
+	}
+	return best
+}
+
+// sharedAlbum is a client for a shared album's public web stream.
+type sharedAlbum struct {
+	client *http.Client
+	host   string
+	token  string
+}
+
+// post sends a request to one of the album's endpoints and decodes the
+// response into out, following the redirect to the album's own server.
+func (a *sharedAlbum) post(ctx context.Context, endpoint string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	for range 2 {
+		u := "https://" + a.host + "/" + a.token + "/sharedstreams/" + endpoint
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		switch {
+		case resp.StatusCode == 330:
+			// The album is on another server, named in the response.
+			var moved struct {
+				Host string `json:"X-Apple-MMe-Host"`
+			}
+			json.Unmarshal(respBody, &moved)
+			if moved.Host == "" {
+				moved.Host = resp.Header.Get("X-Apple-MMe-Host")
+			}
+			if moved.Host == "" || moved.Host == a.host {
+				return fmt.Errorf("%s: redirected without a new server", endpoint)
+			}
+			a.host = moved.Host
+			continue
+		case resp.StatusCode == http.StatusNotFound:
+			return fmt.Errorf("no shared album found; check that the link is right and the album has a public website")
+		case resp.StatusCode != http.StatusOK:
+			return fmt.Errorf("%s: %s", endpoint, resp.Status)
+		}
+		return json.Unmarshal(respBody, out)
+	}
+	return fmt.Errorf("%s: redirected too many times", endpoint)
+}
+
+// fetchSharedAlbum downloads the photos in the shared album with token
+// into the store, skipping videos, photos downloaded on an earlier run, and
+// photos whose bytes match an image already read, such as one also sent by
+// email. Each photo's contributor is recorded as its sender. It returns the
+// names of the saved files.
+func fetchSharedAlbum(ctx context.Context, token string, progress *Progress) ([]string, error) {
+	a := &sharedAlbum{client: &http.Client{Timeout: time.Minute}, host: albumHost(token), token: token}
+
+	var stream struct {
+		Name   string       `json:"streamName"`
+		Photos []albumPhoto `json:"photos"`
+	}
+	if err := a.post(ctx, "webstream", map[string]any{"streamCtag": nil}, &stream); err != nil {
+		return nil, err
+	}
+
+	var pending []albumPhoto
+	for _, photo := range stream.Photos {
+		if photo.MediaType == "video" || photo.largest() == "" {
+			continue
+		}
+		if _, seen := progress.Album[photo.GUID]; !seen {
+			pending = append(pending, photo)
+		}
+	}
+	dim.Printf("  %s: %d photo(s), %d new\n", stream.Name, len(stream.Photos), len(pending))
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	guids := make([]string, len(pending))
+	for i, photo := range pending {
+		guids[i] = photo.GUID
+	}
+	var assets struct {
+		Items map[string]struct {
+			Location string `json:"url_location"`
+			Path     string `json:"url_path"`
+		} `json:"items"`
+	}
+	if err := a.post(ctx, "webasseturls", map[string]any{"photoGuids": guids}, &assets); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(progress.Images))
+	for id := range progress.Images {
+		known[id] = true
+	}
+	var saved []string
+	for _, photo := range pending {
+		item, ok := assets.Items[photo.largest()]
+		if !ok {
+			printError(photo.GUID, fmt.Errorf("the album has no download for this photo"))
+			continue
+		}
+		data, err := a.download(ctx, "https://"+item.Location+item.Path)
+		if err != nil {
+			printError(photo.GUID, err)
+			continue
+		}
+		id := imageID(data)
+		if known[id] {
+			dim.Printf("  = %s from %s was already read\n", photo.GUID, photo.Contributor)
+			progress.Album[photo.GUID] = id
+			continue
+		}
+
+		file, _, _ := strings.Cut(item.Path, "?")
+		ext := strings.ToLower(path.Ext(file))
+		if !imageExts[ext] {
+			ext = imageExt(http.DetectContentType(data))
+		}
+		if ext == "" {
+			printError(photo.GUID, fmt.Errorf("not a supported image"))
+			continue
+		}
+		name := "icloud-" + fileSafe(photo.GUID) + ext
+		if err := writeStoreFile(store, name, data); err != nil {
+			return saved, err
+		}
+		known[id] = true
+		progress.Album[photo.GUID] = id
+		if photo.Contributor != "" {
+			progress.Senders[name] = photo.Contributor
+		}
+		dim.Printf("  ↓ %s from %s\n", name, photo.Contributor)
+		saved = append(saved, name)
+	}
+	return saved, nil
+}
+
+// download fetches a photo from the album.
+func (a *sharedAlbum) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
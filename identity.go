@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// progressVersion is the layout of the progress file. Version 2 records
+// results by image content rather than filename; older files are migrated
+// when loaded.
+const progressVersion = 2
+
+// imageID is what progress records an image's results under: the SHA-256
+// of its bytes, so a result follows its image through a rename.
+func imageID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// idsByName returns the ID of each image whose filename is known.
+func (p *Progress) idsByName() map[string]string {
+	ids := make(map[string]string, len(p.Images))
+	for id, name := range p.Images {
+		ids[name] = id
+	}
+	return ids
+}
+
+// identify returns the IDs of the named images, hashing those whose names
+// aren't known yet, and records each as the current filename for its ID.
+// Images that can't be read are left out; processing them reports why.
+func (p *Progress) identify(names []string) map[string]string {
+	known := p.idsByName()
+	ids := make(map[string]string, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			data, err := readStoreFile(store, name)
+			if err != nil {
+				continue
+			}
+			id = imageID(data)
+			if stamp, ok := stampOf(name); ok {
+				p.Stamps[id] = stamp
+			}
+		}
+		ids[name] = id
+		p.Images[id] = name
+	}
+	return ids
+}
+
+// imageStamp is an image's size and modification time when it was last
+// hashed, so checking it for changes needn't read it again.
+type imageStamp struct {
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// stampOf returns an image's current stamp, if the store can tell it
+// without reading the image.
+func stampOf(name string) (imageStamp, bool) {
+	s, ok := store.(statStore)
+	if !ok {
+		return imageStamp{}, false
+	}
+	info, err := s.Stat(name)
+	if err != nil {
+		return imageStamp{}, false
+	}
+	return imageStamp{Size: info.Size(), Modified: info.ModTime().UTC()}, true
+}
+
+// changed finds the named images whose content is no longer what their
+// results were read from, such as a clearer retake saved under the same
+// name, and drops those results so the images are read again. Images whose
+// stamp still matches aren't read; in a bucket, every image is. Verified
+// and finalized results are kept. It returns the names of the images whose
+// results were dropped and of those whose results were kept.
+func (p *Progress) changed(names []string) (dropped, kept []string) {
+	known := p.idsByName()
+	present := make(map[string]bool) // IDs of the images as they are now
+	stale := make(map[string]string) // ID read earlier → name it was read from
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			continue
+		}
+		stamp, stamped := stampOf(name)
+		if old, ok := p.Stamps[id]; stamped && ok && old.Size == stamp.Size && old.Modified.Equal(stamp.Modified) {
+			present[id] = true
+			continue
+		}
+		data, err := readStoreFile(store, name)
+		if err != nil {
+			present[id] = true // processing it reports why
+			continue
+		}
+		now := imageID(data)
+		if now != id && p.protected(id) {
+			kept = append(kept, name)
+			present[id] = true
+			continue
+		}
+		if stamped {
+			p.Stamps[now] = stamp
+		}
+		present[now] = true
+		if now != id {
+			stale[id] = name
+		}
+	}
+
+	for id, name := range stale {
+		delete(p.Images, id) // identify records the name's new ID
+		if present[id] {
+			continue // renamed rather than replaced; its results follow it
+		}
+		delete(p.Stamps, id)
+		delete(p.Blank, id)
+		for key := range p.Completed {
+			if imageOf(key) == id {
+				delete(p.Completed, key)
+			}
+		}
+		for key := range p.Review {
+			if imageOf(key) == id {
+				delete(p.Review, key)
+			}
+		}
+		dropped = append(dropped, name)
+	}
+	sort.Strings(dropped)
+	sort.Strings(kept)
+	return dropped, kept
+}
+
+// rekey moves everything recorded for an image from one ID to another, for
+// an image whose content was changed by the parser itself.
+func (p *Progress) rekey(from, to string) {
+	if name, ok := p.Images[from]; ok {
+		delete(p.Images, from)
+		p.Images[to] = name
+	}
+	for key, log := range p.Completed {
+		if imageOf(key) == from {
+			delete(p.Completed, key)
+			p.Completed[to+key[len(from):]] = log
+		}
+	}
+	for key, flags := range p.Review {
+		if imageOf(key) == from {
+			delete(p.Review, key)
+			p.Review[to+key[len(from):]] = flags
+		}
+	}
+	if blank, ok := p.Blank[from]; ok {
+		delete(p.Blank, from)
+		p.Blank[to] = blank
+	}
+	if archived, ok := p.Archived[from]; ok {
+		delete(p.Archived, from)
+		p.Archived[to] = archived
+	}
+	delete(p.Stamps, from) // the next run stamps it afresh
+}
+
+// idOf returns the ID of one image, or its name if it can't be read.
+func (p *Progress) idOf(name string) string {
+	if id, ok := p.identify([]string{name})[name]; ok {
+		return id
+	}
+	return name
+}
+
+// nameOf returns the filename a record key stands for, keeping the "#2"
+// of a further student on the image.
+func (p *Progress) nameOf(key string) string {
+	id := imageOf(key)
+	name, ok := p.Images[id]
+	if !ok {
+		name = id
+	}
+	return name + key[len(id):]
+}
+
+// keyOf returns the record key for a filename as shown to people, such as
+// "IMG_0912.heic" or "IMG_0912.heic#2".
+func (p *Progress) keyOf(name string) string {
+	image := imageOf(name)
+	id, ok := p.idsByName()[image]
+	if !ok {
+		return name
+	}
+	return id + name[len(image):]
+}
+
+// sortedByName returns record keys in the order of the filenames they
+// stand for.
+func (p *Progress) sortedByName(keys []string) []string {
+	sort.Slice(keys, func(i, j int) bool { return p.nameOf(keys[i]) < p.nameOf(keys[j]) })
+	return keys
+}
+
+// migrate converts a progress file from before version 2, whose results
+// are keyed by filename, by hashing each image that is still present.
+// Results for images that are gone keep their filename as their ID.
+func (p *Progress) migrate() {
+	ids := make(map[string]string)
+	rekey := func(key string) string {
+		image := imageOf(key)
+		id, ok := ids[image]
+		if !ok {
+			id = image
+			if data, err := readStoreFile(store, image); err == nil {
+				id = imageID(data)
+			}
+			ids[image] = id
+			p.Images[id] = image
+		}
+		return id + key[len(image):]
+	}
+
+	completed := make(map[string]ReadingLog, len(p.Completed))
+	for key, log := range p.Completed {
+		completed[rekey(key)] = log
+	}
+	review := make(map[string][]Flag, len(p.Review))
+	for key, flags := range p.Review {
+		review[rekey(key)] = flags
+	}
+	blank := make(map[string]bool, len(p.Blank))
+	for key := range p.Blank {
+		blank[rekey(key)] = true
+	}
+	p.Completed, p.Review, p.Blank = completed, review, blank
+	p.Version = progressVersion
+}
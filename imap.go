@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message/mail"
+)
+
+// runIngest pulls images from an external source into the current directory
+// and then runs the normal parse pipeline over them.
+func runIngest(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ingest imap|icloud [flags]")
+	}
+	switch args[0] {
+	case "imap":
+		return runIngestIMAP(args[1:])
+	case "icloud":
+		return runIngestICloud(args[1:])
+	default:
+		return fmt.Errorf("unknown ingest source %q", args[0])
+	}
+}
+
+func runIngestIMAP(args []string) error {
+	fs := flag.NewFlagSet("ingest imap", flag.ContinueOnError)
+	server := fs.String("server", "", "IMAP server address with port (e.g. imap.gmail.com:993)")
+	username := fs.String("user", "", "mailbox username")
+	mailbox := fs.String("mailbox", "INBOX", "mailbox to read unread messages from")
+	noParse := fs.Bool("no-parse", false, "only download attachments, don't run the parser")
+	forceUnlock := lockFlag(fs)
+	parseFlags(fs, args)
+
+	if *server == "" || *username == "" || os.Getenv("IMAP_PASSWORD") == "" {
+		return fmt.Errorf("--server, --user, and the IMAP_PASSWORD environment variable are required")
+	}
+	return ingest(imapSource{server: *server, username: *username, mailbox: *mailbox}, *noParse, *forceUnlock)
+}
+
+// imapSource is the unread mail in a mailbox, written
+// imap://user@server:993/MAILBOX, with the password in IMAP_PASSWORD. The
+// mailbox defaults to INBOX.
+type imapSource struct {
+	server, username, mailbox string
+}
+
+func newIMAPSource(location string) (ImageSource, error) {
+	u, err := url.Parse("imap:" + location)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" || u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("want imap://user@server:993/MAILBOX")
+	}
+	s := imapSource{server: u.Host, username: u.User.Username(), mailbox: strings.TrimPrefix(u.Path, "/")}
+	if u.Port() == "" {
+		s.server += ":993"
+	}
+	if s.mailbox == "" {
+		s.mailbox = "INBOX"
+	}
+	return s, nil
+}
+
+func (s imapSource) String() string {
+	return "imap://" + s.username + "@" + s.server + "/" + s.mailbox
+}
+
+func (s imapSource) Sync(ctx context.Context, progress *Progress) ([]string, error) {
+	password := os.Getenv("IMAP_PASSWORD")
+	if password == "" {
+		return nil, configErrorf("set IMAP_PASSWORD to the mailbox password")
+	}
+	return fetchIMAPAttachments(s.server, s.username, password, s.mailbox, progress)
+}
+
+// fetchIMAPAttachments downloads image attachments from unread messages into
+// the store, records each file's sender in the progress store, and marks the
+// messages as read. It returns the names of the saved files.
+func fetchIMAPAttachments(server, username, password, mailbox string, progress *Progress) ([]string, error) {
+	c, err := client.DialTLS(server, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer c.Logout()
+
+	if err := c.Login(username, password); err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	if _, err := c.Select(mailbox, false); err != nil {
+		return nil, fmt.Errorf("failed to select %s: %w", mailbox, err)
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope, section.FetchItem()}
+
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqset, items, messages)
+	}()
+
+	var saved []string
+	read := new(imap.SeqSet)
+	for msg := range messages {
+		sender := ""
+		if msg.Envelope != nil && len(msg.Envelope.From) > 0 {
+			sender = msg.Envelope.From[0].Address()
+		}
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		files, err := saveImageParts(body, fmt.Sprintf("mail-%d-", msg.Uid))
+		if err != nil {
+			printError(fmt.Sprintf("message %d", msg.Uid), err)
+			continue
+		}
+		for _, f := range files {
+			progress.Senders[f] = sender
+			dim.Printf("  ↓ %s from %s\n", f, sender)
+		}
+		saved = append(saved, files...)
+		read.AddNum(msg.Uid)
+	}
+	if err := <-done; err != nil {
+		return saved, fmt.Errorf("fetch failed: %w", err)
+	}
+
+	if !read.Empty() {
+		flags := []interface{}{imap.SeenFlag}
+		if err := c.UidStore(read, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+			return saved, fmt.Errorf("failed to mark messages read: %w", err)
+		}
+	}
+	return saved, nil
+}
+
+// saveImageParts writes every image part of a MIME message into the store,
+// naming each file with the given prefix to avoid collisions between
+// messages.
+func saveImageParts(r io.Reader, prefix string) ([]string, error) {
+	mr, err := mail.CreateReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []string
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return saved, err
+		}
+
+		var name, contentType string
+		switch h := part.Header.(type) {
+		case *mail.AttachmentHeader:
+			name, _ = h.Filename()
+			contentType, _, _ = h.ContentType()
+		case *mail.InlineHeader:
+			contentType, _, _ = h.ContentType()
+			// Photos pasted into the body arrive as inline parts without a
+			// filename; derive one from the content type.
+			if strings.HasPrefix(contentType, "image/") {
+				name = fmt.Sprintf("inline-%d.%s", i, strings.TrimPrefix(contentType, "image/"))
+			}
+		}
+		if name == "" || !strings.HasPrefix(contentType, "image/") && !isImageFile(name) {
+			continue
+		}
+
+		name = prefix + filepath.Base(name)
+		file, err := createStoreFile(store, name)
+		if err != nil {
+			return saved, err
+		}
+		_, err = io.Copy(file, part.Body)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			store.Remove(name)
+			return saved, err
+		}
+		saved = append(saved, name)
+	}
+	return saved, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// runImport reads the corrections a teacher made to an exported CSV back
+// into the stored results, so later exports keep them. Each row is matched
+// to the result it was exported from, and the cells that differ are applied
+// as corrections; corrected results are marked as verified by a person and
+// leave the review queue.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report the corrections without saving them")
+	forceUnlock := lockFlag(fs)
+	outputFlags(fs)
+	files := parseArgs(fs, args)
+	if len(files) != 1 {
+		return fmt.Errorf("usage: import <corrections.csv> [--dry-run]")
+	}
+
+	data, err := readLocalFile(files[0])
+	if err != nil {
+		return err
+	}
+	rows, err := readCSV(data)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", files[0], err)
+	}
+	if len(rows) < 2 {
+		return fmt.Errorf("%s has no rows", files[0])
+	}
+	header := rows[0]
+
+	if !*dryRun {
+		if err := lockProgress(*forceUnlock); err != nil {
+			return err
+		}
+	}
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	logs := completedLogs(progress)
+
+	var keys []string
+	corrected, unchanged, skipped := 0, 0, 0
+	for i, row := range rows[1:] {
+		cells := make(map[string]string, len(header))
+		for j, col := range header {
+			if j < len(row) {
+				cells[strings.TrimSpace(col)] = row[j]
+			}
+		}
+		if isSummaryRow(cells["Full Name"], cells["Source File"]) {
+			continue
+		}
+		var changes, changed []string
+		log, err := matchRow(logs, cells)
+		if err == nil {
+			changes, changed, err = correctFromRow(progress, log, header, cells)
+		}
+		switch {
+		case err != nil:
+			yellow.Printf("  ? line %d: %v\n", i+2, err)
+			skipped++
+		case len(changes) == 0:
+			unchanged++
+		default:
+			green.Printf("  ✓ %s", log.Source)
+			dim.Printf(" | %s\n", log.FullName)
+			for _, c := range changes {
+				fmt.Printf("    %s %s\n", dim.Sprint("│"), c)
+			}
+			keys = append(keys, changed...)
+			corrected++
+		}
+	}
+
+	fmt.Printf("\n  %d result(s) corrected, %d unchanged, %d row(s) skipped\n", corrected, unchanged, skipped)
+	if *dryRun {
+		dim.Println("  Dry run: nothing was saved")
+		return nil
+	}
+	if corrected == 0 {
+		return nil
+	}
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf("could not save progress: %w", err)
+	}
+	paths, err := writeOutput(completedLogs(progress))
+	if err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	dim.Printf("  Refreshed %s\n", strings.Join(paths, ", "))
+	if err := updateHistory(recordsFor(progress, keys)); err != nil {
+		return fmt.Errorf("could not update history: %w", err)
+	}
+	return nil
+}
+
+// matchRow finds the result a row of an edited CSV was exported from: by
+// its Source File if the row has one, otherwise by the student's name,
+// narrowed by teacher when several students share it.
+func matchRow(logs []ReadingLog, cells map[string]string) (ReadingLog, error) {
+	if source := strings.TrimSpace(cells["Source File"]); source != "" {
+		for _, log := range logs {
+			if log.Source == source {
+				return log, nil
+			}
+		}
+		return ReadingLog{}, fmt.Errorf("no result for %s", source)
+	}
+
+	name := cells["Full Name"]
+	var found []ReadingLog
+	for _, log := range logs {
+		if sameText(log.FullName, name) {
+			found = append(found, log)
+		}
+	}
+	if len(found) > 1 {
+		var sameTeacher []ReadingLog
+		for _, log := range found {
+			if sameText(log.HomeroomTeacher, cells["Homeroom Teacher"]) {
+				sameTeacher = append(sameTeacher, log)
+			}
+		}
+		found = sameTeacher
+	}
+	switch len(found) {
+	case 0:
+		return ReadingLog{}, fmt.Errorf("no result for %q; keep the Source File column to match renamed students", name)
+	case 1:
+		return found[0], nil
+	default:
+		return ReadingLog{}, fmt.Errorf("%d results for %q; keep the Source File column to tell them apart", len(found), name)
+	}
+}
+
+// correctFromRow applies the cells of a row that differ from the result it
+// was exported from to the stored records behind that result: the front
+// page and, for a two-sided form, the back. Total minutes, books, and
+// per-day fields are derived from other values and aren't read back. It
+// returns a description of each change and the record keys it changed.
+func correctFromRow(p *Progress, log ReadingLog, header []string, cells map[string]string) (changes, keys []string, err error) {
+	frontKey := p.keyOf(log.Source)
+	front := p.Completed[frontKey]
+	fixed := modelFields(front)
+	fixed.ReadingEntries = append([]ReadingEntry(nil), front.ReadingEntries...)
+	fixed.Extra = maps.Clone(front.Extra)
+
+	var back ReadingLog
+	backKey := ""
+	if log.Back != "" {
+		backKey = p.keyOf(log.Back)
+		back = p.Completed[backKey]
+		back.ReadingEntries = append([]ReadingEntry(nil), back.ReadingEntries...)
+	}
+
+	text := func(col, was string, set func(string)) {
+		v, ok := cells[col]
+		if v = strings.TrimSpace(v); ok && v != strings.TrimSpace(was) {
+			set(v)
+			changes = append(changes, fmt.Sprintf("%s: %q → %q", col, was, v))
+		}
+	}
+	text("Full Name", log.FullName, func(v string) { fixed.FullName = v })
+	text("Grade", log.Grade, func(v string) { fixed.Grade = v })
+	text("Homeroom Teacher", log.HomeroomTeacher, func(v string) { fixed.HomeroomTeacher = v })
+	text("Notes", log.Notes, func(v string) { fixed.Notes, back.Notes = v, "" })
+
+	for _, col := range header {
+		col = strings.TrimSpace(col)
+		space := strings.LastIndex(col, " ")
+		if space < 0 {
+			continue
+		}
+		day, date := col[:space], col[space+1:]
+		if _, _, _, ok := parseDate(date); !ok {
+			continue
+		}
+		was, v := formatMinutes(log.ReadingEntries, date), strings.TrimSpace(cells[col])
+		if v == was {
+			continue
+		}
+		minutes := 0
+		if v != "" {
+			if minutes, err = strconv.Atoi(v); err != nil || minutes < 0 {
+				return nil, nil, fmt.Errorf("%s: %q is not a number of minutes", col, v)
+			}
+		}
+		setMinutes(&fixed, day, date, minutes)
+		if minutes == 0 {
+			// Clear the back too, or merging would bring its reading back.
+			for i, e := range back.ReadingEntries {
+				if dateKey(e.Date) == dateKey(date) {
+					back.ReadingEntries[i].Minutes, back.ReadingEntries[i].Written = 0, ""
+				}
+			}
+		}
+		if was == "" {
+			was = "0"
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s → %d min", col, was, minutes))
+	}
+
+	for _, f := range allFields() {
+		v, ok := cells[f.Name]
+		was := fieldColumn(log, f)
+		if v = strings.TrimSpace(v); !ok || f.perEntry() || v == was {
+			continue
+		}
+		value, err := parseValue(f, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fixed.Extra == nil {
+			fixed.Extra = make(map[string]any)
+		}
+		fixed.Extra[f.Name] = value
+		changes = append(changes, fmt.Sprintf("%s: %q → %q", f.Name, was, v))
+	}
+
+	if len(changes) == 0 {
+		return nil, nil, nil
+	}
+	corrected := applyCorrection(front, fixed)
+	normalizeLog(&corrected)
+	corrected.Verified = true
+	p.Completed[frontKey] = corrected
+	delete(p.Review, frontKey)
+	keys = append(keys, frontKey)
+	if backKey != "" {
+		back.Verified = true
+		p.Completed[backKey] = back
+		delete(p.Review, backKey)
+		keys = append(keys, backKey)
+	}
+	return changes, keys, nil
+}
+
+// setMinutes sets the minutes read on a date, adding the day if the log
+// doesn't have it.
+func setMinutes(log *ReadingLog, day, date string, minutes int) {
+	written := ""
+	if minutes > 0 {
+		written = strconv.Itoa(minutes)
+	}
+	for i, e := range log.ReadingEntries {
+		if dateKey(e.Date) == dateKey(date) {
+			log.ReadingEntries[i].Minutes, log.ReadingEntries[i].Written = minutes, written
+			return
+		}
+	}
+	if minutes > 0 {
+		log.ReadingEntries = append(log.ReadingEntries, ReadingEntry{Day: day, Date: date, Minutes: minutes, Written: written})
+	}
+}
+
+// sameText reports whether two values are the same apart from case and
+// spacing.
+func sameText(x, y string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(x), " "), strings.Join(strings.Fields(y), " "))
+}
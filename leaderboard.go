@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+// ranked is one line of a leaderboard.
+type ranked struct {
+	Rank    int
+	Name    string
+	Grade   string
+	Class   string
+	Minutes int
+	Members int // students in a class; 0 for a student
+}
+
+// board is a ranked list for one grouping, e.g. all students or one grade.
+type board struct {
+	Kind  string // "students" or "classes"
+	Group string // "all" or a grade
+	Rows  []ranked
+}
+
+// rank orders rows by minutes, most first, and assigns competition ranks:
+// tied rows share a rank and the next rank is skipped (1, 2, 2, 4). With
+// top > 0, rows ranked below top are dropped, but ties at the cutoff are kept.
+func rank(rows []ranked, top int) []ranked {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Minutes != rows[j].Minutes {
+			return rows[i].Minutes > rows[j].Minutes
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	for i := range rows {
+		if i > 0 && rows[i].Minutes == rows[i-1].Minutes {
+			rows[i].Rank = rows[i-1].Rank
+		} else {
+			rows[i].Rank = i + 1
+		}
+		if top > 0 && rows[i].Rank > top {
+			return rows[:i]
+		}
+	}
+	return rows
+}
+
+// leaderboards builds the student and class boards from per-student totals,
+// one pair per grade when byGrade is set.
+func leaderboards(totals []*studentTotal, byGrade bool, top int) []board {
+	groupOf := func(grade string) string {
+		if byGrade {
+			return grade
+		}
+		return "all"
+	}
+
+	students := make(map[string][]ranked)
+	type class struct {
+		ranked
+		grades map[string]int
+	}
+	classes := make(map[string]*class)
+	for _, t := range totals {
+		g := groupOf(t.Grade)
+		students[g] = append(students[g], ranked{Name: t.Name, Grade: t.Grade, Class: t.Classroom, Minutes: t.Minutes})
+
+		c, ok := classes[t.Classroom]
+		if !ok {
+			c = &class{ranked: ranked{Name: t.Classroom, Class: t.Classroom}, grades: make(map[string]int)}
+			classes[t.Classroom] = c
+		}
+		c.Minutes += t.Minutes
+		c.Members++
+		c.grades[t.Grade]++
+	}
+
+	// A class competes in the grade most of its students are in.
+	classRows := make(map[string][]ranked)
+	for _, c := range classes {
+		best := 0
+		for grade, n := range c.grades {
+			if n > best || n == best && grade < c.Grade {
+				c.Grade, best = grade, n
+			}
+		}
+		g := groupOf(c.Grade)
+		classRows[g] = append(classRows[g], c.ranked)
+	}
+
+	groups := make([]string, 0, len(students))
+	for g := range students {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	var boards []board
+	for _, g := range groups {
+		boards = append(boards, board{Kind: "students", Group: g, Rows: rank(students[g], top)})
+		if len(classRows[g]) > 0 {
+			boards = append(boards, board{Kind: "classes", Group: g, Rows: rank(classRows[g], top)})
+		}
+	}
+	return boards
+}
+
+func runReportLeaderboard(args []string) error {
+	fs := flag.NewFlagSet("report leaderboard", flag.ContinueOnError)
+	top := fs.Int("top", 10, "show the top N places (0 for everyone); ties at the cutoff are kept")
+	byGrade := fs.Bool("by-grade", false, "rank each grade level separately")
+	week := fs.String("week", "", "only count this week (default: the whole season)")
+	csvPath := fs.String("csv", "", "also write the leaderboards to this CSV file")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	logs, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	if *week != "" {
+		var inWeek []ReadingLog
+		for _, log := range logs {
+			if weekOf(log) == *week {
+				inWeek = append(inWeek, log)
+			}
+		}
+		if len(inWeek) == 0 {
+			return fmt.Errorf("no results for week %s", *week)
+		}
+		logs = inWeek
+	}
+
+	boards := leaderboards(aggregateStudents(logs), *byGrade, *top)
+
+	var csvRows [][]string
+	for i, b := range boards {
+		if i > 0 {
+			fmt.Println()
+		}
+		title := "Top readers"
+		if b.Kind == "classes" {
+			title = "Top classes"
+		}
+		if b.Group != "all" {
+			title += " — " + b.Group
+		}
+		boldCyn.Printf("  %s\n", title)
+
+		var header []string
+		var rows [][]string
+		for _, r := range b.Rows {
+			if b.Kind == "classes" {
+				rows = append(rows, []string{fmt.Sprint(r.Rank), r.Name, fmt.Sprint(r.Members), fmt.Sprint(r.Minutes), fmt.Sprintf("%.0f", float64(r.Minutes)/float64(r.Members))})
+			} else {
+				rows = append(rows, []string{fmt.Sprint(r.Rank), r.Name, r.Grade, r.Class, fmt.Sprint(r.Minutes)})
+			}
+			csvRows = append(csvRows, []string{b.Kind, b.Group, fmt.Sprint(r.Rank), r.Name, r.Grade, r.Class, fmt.Sprint(r.Minutes)})
+		}
+		if b.Kind == "classes" {
+			header = []string{"#", "Class", "Students", "Minutes", "Per Student"}
+		} else {
+			header = []string{"#", "Student", "Grade", "Class", "Minutes"}
+		}
+		printTable(header, rows)
+	}
+
+	if *csvPath != "" {
+		header := []string{"Board", "Group", "Rank", "Name", "Grade", "Class", "Minutes"}
+		if err := writeTableCSV(*csvPath, header, csvRows); err != nil {
+			return err
+		}
+		boldGrn.Printf("\n  Wrote %d row(s) to %s\n", len(csvRows), *csvPath)
+	}
+	return nil
+}
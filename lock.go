@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lockFile marks the progress file as in use by a run, so two runs in the
+// same place don't overwrite each other's progress.
+const lockFile = ".progress.lock"
+
+// progressLock is what the lock file records about the run holding it.
+type progressLock struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+}
+
+// locked is whether this process holds the lock.
+var locked bool
+
+// lockFlag registers --force-unlock for a command that writes progress.
+func lockFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("force-unlock", false, "remove the lock left by a run that is no longer running")
+}
+
+// lockProgress takes the lock on the progress file in the current store.
+// A lock left by a run on this machine that has since exited is removed;
+// any other lock is an error unless force is set.
+func lockProgress(force bool) error {
+	if locked {
+		return nil
+	}
+	host, _ := os.Hostname()
+	data, err := json.Marshal(progressLock{
+		PID:     os.Getpid(),
+		Host:    host,
+		Command: commandLine(),
+		Started: time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	for range 2 {
+		ok, err := createLock(data)
+		if err != nil {
+			return fmt.Errorf("could not lock %s: %w", progressFile, err)
+		}
+		if ok {
+			locked = true
+			return nil
+		}
+
+		var holder progressLock
+		if data, err := readStoreFile(store, lockFile); err == nil {
+			json.Unmarshal(data, &holder)
+		}
+		stale := holder.Host == host && holder.PID != 0 && !running(holder.PID)
+		if !force && !stale {
+			return fmt.Errorf("%s is %w (%q, pid %d on %s, started %s); if that run is no longer running, remove %s or rerun with --force-unlock",
+				progressFile, errLocked, holder.Command, holder.PID, holder.Host, holder.Started.Local().Format("Jan 2 15:04"), lockFile)
+		}
+		if err := store.Remove(lockFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("could not remove %s: %w", lockFile, err)
+		}
+		dim.Printf("  Removed the lock left by pid %d on %s\n", holder.PID, holder.Host)
+	}
+	return fmt.Errorf("could not lock %s: another run took the lock first", progressFile)
+}
+
+// unlockProgress releases the lock, if this process holds it. Runs that
+// exit without releasing it leave a lock that the next run on the same
+// machine removes.
+func unlockProgress() {
+	if !locked {
+		return
+	}
+	if err := store.Remove(lockFile); err != nil {
+		logger.Warn("could not remove "+lockFile, "err", err)
+	}
+	locked = false
+}
+
+// createLock writes the lock file unless one exists, reporting whether it
+// did. Only a directory can do this atomically; in a bucket, two runs
+// starting at the same moment can both get the lock.
+func createLock(data []byte) (bool, error) {
+	if d, ok := store.(dirStore); ok {
+		f, err := os.OpenFile(d.path(lockFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err == nil, err
+	}
+
+	if _, err := readStoreFile(store, lockFile); err == nil {
+		return false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, writeStoreFile(store, lockFile, data)
+}
+
+// running reports whether a process on this machine is still running.
+func running(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false // on Windows, the process is gone
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+// commandLine returns how this process was started, for the lock file.
+func commandLine() string {
+	return strings.Join(append([]string{"reading-logs-parser"}, os.Args[1:]...), " ")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logger records what a run did — each image's outcome, each API request,
+// and every warning — to the log file, for diagnosing a run afterwards.
+// Warnings and errors are also shown on the terminal as before; the rest of
+// the terminal output is the progress display, which isn't logged.
+var logger = slog.New(consoleHandler{})
+
+// logOutput is the open log file, if any.
+var logOutput *os.File
+
+// displayed marks a record as already shown by the progress display or the
+// --json report, such as a failed image, so only the log file gets it. With
+// --quiet, which shows neither, errors among them are shown after all.
+var displayed = context.WithValue(context.Background(), displayedKey{}, true)
+
+type displayedKey struct{}
+
+// logFlags registers --log-file, --log-level, and --log-format, bound
+// directly to cfg so the flags override the config file and environment.
+func logFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.LogFile, "log-file", cfg.LogFile, "file to append a structured log of the run to (empty for none)")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "least severe messages to write to the log file: debug, info, warn, or error")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log file format: text or json")
+}
+
+// startLog points logger at the configured log file, closing any file it
+// wrote to before.
+func startLog() error {
+	level, err := parseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	if logOutput != nil {
+		logOutput.Close()
+		logOutput = nil
+	}
+	var console slog.Handler = consoleHandler{}
+	if headless {
+		// With no one watching, the console gets the log itself.
+		if console, err = logHandler(os.Stderr, level); err != nil {
+			return err
+		}
+	}
+	logger = slog.New(console)
+	if cfg.LogFile == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	file, err := logHandler(f, level)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	logOutput = f
+	logger = slog.New(teeHandler{console, file})
+	return nil
+}
+
+// logHandler writes records at level and above to w in the log format,
+// each naming the profile in use, if any.
+func logHandler(w io.Writer, level slog.Level) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch cfg.LogFormat {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", cfg.LogFormat)
+	}
+	if cfg.profile != "" {
+		h = h.WithAttrs([]slog.Attr{slog.String("profile", cfg.profile)})
+	}
+	return h, nil
+}
+
+// parseLevel reads a log level as written in the config file or a flag.
+func parseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn, or error)", s)
+	}
+	return level, nil
+}
+
+// consoleHandler shows warnings and errors on the terminal the way the rest
+// of the output looks: "  Warning: could not save progress: <err>" in
+// yellow, or "Error: ..." in red. Other attributes and lower levels are for
+// the log file only.
+type consoleHandler struct {
+	attrs []slog.Attr
+}
+
+func (h consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if display == quietDisplay {
+		return level >= slog.LevelError
+	}
+	return level >= slog.LevelWarn
+}
+
+func (h consoleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx.Value(displayedKey{}) != nil && display != quietDisplay {
+		return nil
+	}
+	line := msg(r.Message)
+	add := func(a slog.Attr) bool {
+		if a.Key == "err" {
+			line += ": " + a.Value.String()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+
+	if r.Level >= slog.LevelError {
+		red.Fprintf(os.Stderr, msg("Error: %s\n"), line)
+	} else {
+		yellow.Fprintf(os.Stderr, msg("  Warning: %s\n"), line)
+	}
+	return nil
+}
+
+func (h consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return consoleHandler{append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h consoleHandler) WithGroup(string) slog.Handler { return h }
+
+// teeHandler sends each record to both of its handlers.
+type teeHandler [2]slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return t[0].Enabled(ctx, level) || t[1].Enabled(ctx, level)
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return teeHandler{t[0].WithAttrs(attrs), t[1].WithAttrs(attrs)}
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	return teeHandler{t[0].WithGroup(name), t[1].WithGroup(name)}
+}
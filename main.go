@@ -1,20 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"image"
+	"image/jpeg"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
 	"github.com/fatih/color"
+	"github.com/gen2brain/heic"
 	"github.com/invopop/jsonschema"
+	_ "golang.org/x/image/bmp" // registered with image.Decode for scans
+	_ "golang.org/x/image/tiff"
 )
 
 // Build-time variables (set via -ldflags)
@@ -28,23 +46,61 @@ const progressFile = ".progress.json"
 
 // ReadingLog represents the structured data extracted from a reading log image.
 type ReadingLog struct {
+	Blank           bool           `json:"blank,omitempty" jsonschema:"description=True if this is an unused form: no student name and no reading times filled in"`
 	FullName        string         `json:"full_name" jsonschema:"description=The student full name as written on the form"`
 	Grade           string         `json:"grade" jsonschema:"description=The student grade level (e.g. Kinder or 1st or 2nd)"`
 	HomeroomTeacher string         `json:"homeroom_teacher" jsonschema:"description=The homeroom teacher name"`
 	ReadingEntries  []ReadingEntry `json:"reading_entries" jsonschema:"description=Reading time entries for each day on the log"`
+	Notes           string         `json:"notes" jsonschema:"description=Any free-text comments written on the form by the student or parent. Empty if none."`
+	Extra           map[string]any `json:"extra,omitempty" jsonschema:"-"` // configured extra fields
+
+	// Metadata recorded by the tool; hidden from the extraction schema.
+	GradeRaw   string `json:"grade_raw,omitempty" jsonschema:"-"`            // grade as read, when normalization changed it
+	TeacherRaw string `json:"homeroom_teacher_raw,omitempty" jsonschema:"-"` // teacher as read, when normalization changed it
+	Source     string `json:"source,omitempty" jsonschema:"-"`               // image filename the log was parsed from
+	Sender     string `json:"sender,omitempty" jsonschema:"-"`
+	PhotoTaken string `json:"photo_taken,omitempty" jsonschema:"-"` // when the photo was taken, from its EXIF data
+	Week       string `json:"week,omitempty" jsonschema:"-"`
+	Classroom  string `json:"classroom,omitempty" jsonschema:"-"`
+	Template   string `json:"template,omitempty" jsonschema:"-"` // form template the image was read with
+	Back       string `json:"back,omitempty" jsonschema:"-"`     // back page image merged into this result
+	Flags      []Flag `json:"flags,omitempty" jsonschema:"-"`    // review flags, filled in for exports
+	Verified   bool   `json:"verified,omitempty" jsonschema:"-"` // checked or corrected by a person
+	Locked     bool   `json:"locked,omitempty" jsonschema:"-"`   // in a finalized week
+
+	Raw string `json:"-"` // raw model response, kept only as an artifact
 }
 
 // ReadingEntry represents a single day's reading time.
 type ReadingEntry struct {
-	Day     string `json:"day" jsonschema:"description=Day of the week (e.g. Friday)"`
-	Date    string `json:"date" jsonschema:"description=The date in M/D format (e.g. 1/30)"`
-	Minutes int    `json:"minutes" jsonschema:"description=Number of minutes read as an integer. Use 0 if not filled in or blank."`
+	Day       string         `json:"day" jsonschema:"description=Day of the week (e.g. Friday)"`
+	Date      string         `json:"date" jsonschema:"description=The date in M/D format (e.g. 1/30)"`
+	DateRaw   string         `json:"date_raw,omitempty" jsonschema:"-"` // date as read, when normalization changed it
+	Minutes   int            `json:"minutes" jsonschema:"description=Number of minutes read as an integer. Convert hours to minutes (1.5 hrs is 90). Use 0 if not filled in or blank."`
+	Written   string         `json:"written" jsonschema:"description=The reading time copied letter for letter as written including any units or words (e.g. 20 or 20m or twenty min or 1.5 hrs or ½ hour). Do not convert or correct it. Empty if blank."`
+	BookTitle string         `json:"book_title" jsonschema:"description=The title of the book read that day as written. Empty if none."`
+	Signed    *bool          `json:"signed" jsonschema:"description=Whether a parent signature or initials appear for this day"` // nil for logs read before signatures were captured
+	Extra     map[string]any `json:"extra,omitempty" jsonschema:"-"`                                                             // configured extra fields
 }
 
 // Progress tracks which files have been processed and their results.
+//
+// Results are recorded under the image's ID (see imageID), with "#2" and so
+// on added for further students on the same image; Images gives each ID's
+// current filename. Errors and senders are recorded by filename.
 type Progress struct {
+	Version   int                   `json:"version"`
+	Images    map[string]string     `json:"images"` // image ID → filename
 	Completed map[string]ReadingLog `json:"completed"`
 	Errors    map[string]string     `json:"errors"`
+	Failures  map[string]string     `json:"failures,omitempty"` // filename → kind of failure, such as auth or parse
+	Senders   map[string]string     `json:"senders,omitempty"`  // filename → email sender or shared album contributor, from ingest
+	Review    map[string][]Flag     `json:"review,omitempty"`   // record key → reasons it needs a human check
+	Blank     map[string]bool       `json:"blank,omitempty"`    // images of unfilled forms, which have no result
+	Stamps    map[string]imageStamp `json:"stamps,omitempty"`   // image ID → size and modification time when last hashed
+	Archived  map[string]string     `json:"archived,omitempty"` // image ID → where --archive or --rename filed it
+	Album     map[string]string     `json:"album,omitempty"`    // shared album photo ID → image ID, once downloaded by ingest icloud
+	Sources   map[string]string     `json:"sources,omitempty"`  // --source → where its last sync left off, such as a Dropbox cursor
 }
 
 // --- pretty printers ---------------------------------------------------
@@ -61,9 +117,12 @@ var (
 )
 
 func printBanner() {
-	boldCyn.Println("┌─────────────────────────────────────┐")
-	boldCyn.Println("│     Reading Logs Parser              │")
-	boldCyn.Println("└─────────────────────────────────────┘")
+	boldCyn.Println(glyphs("┌─────────────────────────────────────┐"))
+	boldCyn.Println(glyphs("│     Reading Logs Parser              │"))
+	boldCyn.Println(glyphs("└─────────────────────────────────────┘"))
+	if cfg.profile != "" {
+		dim.Printf("  Profile %s, in %s\n", cfg.profile, cfg.profileDir)
+	}
 }
 
 func printProgress(current, total, skipped int, filename string) {
@@ -83,7 +142,7 @@ func printProgress(current, total, skipped int, filename string) {
 func renderBar(current, total, width int) string {
 	filled := width * current / total
 	bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
-	return bar
+	return glyphs(bar)
 }
 
 func printResult(log *ReadingLog) {
@@ -91,66 +150,137 @@ func printResult(log *ReadingLog) {
 	for _, e := range log.ReadingEntries {
 		total += e.Minutes
 	}
-	green.Printf("  ✓ %s", log.FullName)
+	green.Printf(glyphs("  ✓ %s"), log.FullName)
 	dim.Printf(" | %s | %s\n", log.Grade, log.HomeroomTeacher)
 	for _, entry := range log.ReadingEntries {
 		if entry.Minutes > 0 {
 			fmt.Printf("    %s %-10s %s\n",
-				dim.Sprint("│"),
-				dim.Sprintf("%s %s", entry.Day, entry.Date),
+				dim.Sprint(glyphs("│")),
+				dim.Sprintf("%s %s", entry.Day, shortDate(entry.Date)),
 				green.Sprintf("%d min", entry.Minutes),
 			)
 		} else {
 			fmt.Printf("    %s %-10s %s\n",
-				dim.Sprint("│"),
-				dim.Sprintf("%s %s", entry.Day, entry.Date),
-				dim.Sprint("—"),
+				dim.Sprint(glyphs("│")),
+				dim.Sprintf("%s %s", entry.Day, shortDate(entry.Date)),
+				dim.Sprint(glyphs("—")),
 			)
 		}
 	}
 	fmt.Printf("    %s %s\n",
-		dim.Sprint("└"),
-		boldGrn.Sprintf("Total: %d min", total),
+		dim.Sprint(glyphs("└")),
+		boldGrn.Sprintf(msg("Total: %d min"), total),
 	)
 }
 
 func printError(filename string, err error) {
-	red.Printf("  ✗ %s: %v\n", filepath.Base(filename), err)
+	red.Printf(glyphs("  ✗ %s: %v\n"), filepath.Base(filename), err)
 }
 
-func printSummary(total, succeeded, failed, skipped int) {
+func printSummary(total, succeeded, blank, failed, skipped, flagged int) {
 	fmt.Println()
-	bold.Println("─── Summary ──────────────────────────")
-	fmt.Printf("  Images found:     %s\n", bold.Sprintf("%d", total))
+	bold.Println(summaryRule("Summary"))
+	summaryLine("Images found", bold.Sprintf("%d", total))
 	if skipped > 0 {
-		fmt.Printf("  Already done:     %s\n", cyan.Sprintf("%d", skipped))
+		summaryLine("Already done", cyan.Sprintf("%d", skipped))
+	}
+	summaryLine("Newly processed", green.Sprintf("%d", succeeded))
+	if blank > 0 {
+		summaryLine("Blank forms", dim.Sprintf("%d", blank)+" "+dim.Sprint(msg("(no result recorded)")))
 	}
-	fmt.Printf("  Newly processed:  %s\n", green.Sprintf("%d", succeeded))
 	if failed > 0 {
-		fmt.Printf("  Failed:           %s\n", red.Sprintf("%d", failed))
+		summaryLine("Failed", red.Sprintf("%d", failed))
 	}
-	bold.Println("──────────────────────────────────────")
+	if flagged > 0 {
+		summaryLine("Flagged", yellow.Sprintf("%d", flagged)+" "+dim.Sprint(msg("(run `review` to see why)")))
+	}
+	bold.Println(summaryRule(""))
 }
 
 // --- progress persistence -----------------------------------------------
 
-func loadProgress() *Progress {
-	p := &Progress{
+func newProgress() *Progress {
+	return &Progress{
+		Version:   progressVersion,
+		Images:    make(map[string]string),
 		Completed: make(map[string]ReadingLog),
 		Errors:    make(map[string]string),
+		Failures:  make(map[string]string),
+		Senders:   make(map[string]string),
+		Review:    make(map[string][]Flag),
+		Blank:     make(map[string]bool),
+		Stamps:    make(map[string]imageStamp),
+		Archived:  make(map[string]string),
+		Album:     make(map[string]string),
+		Sources:   make(map[string]string),
+	}
+}
+
+// loadProgress reads the progress file, starting fresh only when there is
+// none yet. A file that can't be read, decrypted, or parsed is an error:
+// starting fresh would overwrite it on the next save.
+func loadProgress() (*Progress, error) {
+	data, err := readStoreFile(store, progressFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return newProgress(), nil
 	}
-	data, err := os.ReadFile(progressFile)
 	if err != nil {
-		return p // no progress file yet, start fresh
+		return nil, err
 	}
-	if err := json.Unmarshal(data, p); err != nil {
-		yellow.Printf("  Warning: could not parse %s, starting fresh\n", progressFile)
-		return &Progress{
-			Completed: make(map[string]ReadingLog),
-			Errors:    make(map[string]string),
+	p, migrated, err := decodeProgress(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", progressFile, err)
+	}
+	// Only a command holding the lock may write the upgraded file; read-only
+	// ones use it as upgraded in memory and leave the file for the next run.
+	if migrated && locked {
+		if err := saveProgress(p); err != nil {
+			logger.Warn("could not save progress", "err", err)
+		} else {
+			dim.Printf("  Upgraded %s to record images by content\n", progressFile)
 		}
 	}
-	return p
+	return p, nil
+}
+
+// decodeProgress parses a progress file, migrating it if it is from an
+// older version.
+func decodeProgress(data []byte) (p *Progress, migrated bool, err error) {
+	p = newProgress()
+	p.Version = 0 // files from before versioning have none
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, false, err
+	}
+	if p.Version < progressVersion {
+		p.migrate()
+		return p, true, nil
+	}
+	return p, false, nil
+}
+
+// completedLogs returns every completed result in filename order, with
+// Source filled in with the image's filename and the two sides of two-sided
+// forms merged.
+func completedLogs(p *Progress) []ReadingLog {
+	keys := make([]string, 0, len(p.Completed))
+	for key := range p.Completed {
+		keys = append(keys, key)
+	}
+
+	logs := make([]ReadingLog, 0, len(keys))
+	for _, key := range p.sortedByName(keys) {
+		log := withSource(p.Completed[key], p.nameOf(key))
+		normalizeLog(&log)
+		log.Flags = p.Review[key]
+		logs = append(logs, log)
+	}
+	return pairPages(logs)
+}
+
+// withSource returns log with its Source set to the image filename.
+func withSource(log ReadingLog, name string) ReadingLog {
+	log.Source = name
+	return log
 }
 
 func saveProgress(p *Progress) error {
@@ -158,183 +288,1102 @@ func saveProgress(p *Progress) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(progressFile, data, 0644)
+	return writeStoreFile(store, progressFile, data)
 }
 
 // --- main ---------------------------------------------------------------
 
+// commands maps subcommand names to their entry points. Anything else falls
+// through to the default parse run.
+var commands = map[string]func(args []string) error{
+	"auth":         runAuth,
+	"certificates": runCertificates,
+	"daemon":       runDaemon,
+	"delete":       runDelete,
+	"diff":         runDiff,
+	"edit":         runEdit,
+	"email":        runEmail,
+	"export":       runExport,
+	"finalize":     runFinalize,
+	"import":       runImport,
+	"ingest":       runIngest,
+	"merge":        runMerge,
+	"parse":        runParseImage,
+	"reassign":     runReassign,
+	"report":       runReport,
+	"retry":        runRetry,
+	"runs":         runRuns,
+	"schema":       runSchema,
+	"self-update":  runSelfUpdate,
+	"status":       runStatus,
+	"review":       runReview,
+	"serve":        runServe,
+	"show":         runShow,
+	"version":      runVersion,
+}
+
 func main() {
+	args, err := langOption(os.Args[1:])
+	if err != nil {
+		red.Fprintf(os.Stderr, msg("Error: %v\n"), err)
+		exit(exitConfig)
+	}
+	name, args, err := profileOption(args)
+	if err != nil {
+		red.Fprintf(os.Stderr, msg("Error: %v\n"), err)
+		exit(exitConfig)
+	}
+	if name != "" {
+		os.Setenv("READING_LOGS_PROFILE", name)
+	}
+	os.Args = append(os.Args[:1], args...)
+	c, err := loadConfig()
+	if err != nil {
+		red.Fprintf(os.Stderr, msg("Error: %v\n"), err)
+		exit(exitConfig)
+	}
+	cfg = c
+	if encryption, err = loadKeys(cfg); err != nil {
+		red.Fprintf(os.Stderr, msg("Error: %v\n"), err)
+		exit(exitConfig)
+	}
+	if cfg.Headless {
+		setHeadless()
+	}
+	os.Args = append(os.Args[:1], terminalOptions(os.Args[1:])...)
+	if err := startLog(); err != nil {
+		red.Fprintf(os.Stderr, msg("Error: %v\n"), err)
+		exit(exitConfig)
+	}
+
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				logger.Error(err.Error(), "command", os.Args[1])
+				exit(exitCode(err))
+			}
+			unlockProgress()
+			return
+		}
+	}
+	runParse(os.Args[1:])
+	unlockProgress()
+}
+
+// exit releases the progress lock and exits with code.
+func exit(code int) {
+	unlockProgress()
+	os.Exit(code)
+}
+
+// parseFlags parses fs from args. A flag that isn't known or has a bad value
+// exits with exitConfig once the flag package has said why; -h exits after
+// printing the usage.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		exit(0)
+	} else if err != nil {
+		exit(exitConfig)
+	}
+}
+
+// parseArgs parses fs from args while allowing flags to follow positional
+// arguments (e.g. "reassign IMG_1.heic --week 02-06"), which the flag package
+// alone does not. It returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		parseFlags(fs, args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// matches reports whether name matches any of the list's glob patterns.
+func (l stringList) matches(name string) bool {
+	for _, pattern := range l {
+		if ok, _ := filepath.Match(pattern, name); ok || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runParse processes every image in the current directory and writes the CSV.
+func runParse(args []string) {
+	fs := flag.NewFlagSet("reading-logs-parser", flag.ContinueOnError)
+	week := fs.String("week", "", "week to attribute new results to (default: derived from the log dates)")
+	classroom := fs.String("classroom", "", "classroom to attribute new results to (default: the homeroom teacher)")
+	outlierZ := fs.Float64("outlier-threshold", cfg.OutlierThreshold, "robust z-score above which a day's minutes are flagged for review")
+	dryRun := fs.Bool("dry-run", false, "report what would be processed and the estimated cost without calling the API")
+	var only, force stringList
+	fs.Var(&only, "only", "only consider images matching this glob (repeatable)")
+	fs.Var(&force, "force", "reprocess images matching this glob even if already completed (repeatable)")
+	ignoreChanges := fs.Bool("ignore-changes", false, "don't check completed images for changes; a retake saved under the same name keeps the old result")
+	var sources stringList
+	fs.Var(&sources, "source", "first copy new images from this source, e.g. dropbox:/ReadingLogs/Week6 or zip:week6.zip (repeatable)")
+	newPipeline := pipelineFlags(fs)
+	outputFlags(fs)
+	displayFlags(fs)
+	parseFlags(fs, args)
+
+	if err := normalizeWeek(week); err != nil {
+		logger.Error(err.Error())
+		exit(1)
+	}
+
 	printBanner()
 
-	// Find all image files in current directory
-	images, err := findImages(".")
+	pipe, err := newPipeline()
 	if err != nil {
-		red.Fprintf(os.Stderr, "Error finding images: %v\n", err)
-		os.Exit(1)
+		logger.Error(err.Error())
+		exit(exitCode(err))
+	}
+
+	if len(sources) == 0 {
+		sources = cfg.Sources
+	}
+	var srcs []ImageSource
+	for _, spec := range sources {
+		src, err := openSource(spec)
+		if err != nil {
+			logger.Error(err.Error())
+			exit(exitCode(err))
+		}
+		srcs = append(srcs, src)
+	}
+	if len(srcs) > 0 && *dryRun {
+		dim.Printf("  Not syncing %s on a dry run\n\n", sources.String())
+	} else if len(srcs) > 0 {
+		progress, err := loadProgress()
+		if err != nil {
+			logger.Error(err.Error())
+			exit(exitCode(err))
+		}
+		_, err = syncSources(context.Background(), srcs, progress)
+		if err := saveProgress(progress); err != nil {
+			logger.Error("could not save progress", "err", err)
+			exit(1)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			exit(exitCode(err))
+		}
+	}
+
+	// Find all image files in the input location
+	images, err := findImages(store)
+	if err != nil {
+		logger.Error("finding images", "err", err)
+		exit(1)
+	}
+
+	if len(only) > 0 {
+		images = filterImages(images, only)
 	}
 
 	if len(images) == 0 {
-		yellow.Println("No image files found in current directory")
-		os.Exit(1)
+		logger.Error("no image files found in " + store.String())
+		exit(1)
 	}
 
 	// Load existing progress
-	progress := loadProgress()
-	skipped := 0
+	progress, err := loadProgress()
+	if err != nil {
+		logger.Error(err.Error())
+		exit(exitCode(err))
+	}
+	if !*ignoreChanges {
+		dropped, kept := progress.changed(images)
+		for _, name := range dropped {
+			yellow.Printf("  %s has changed since it was read; reading it again\n", name)
+		}
+		for _, name := range kept {
+			yellow.Printf("  %s has changed since it was read, but its result is verified or finalized; keeping it\n", name)
+		}
+	}
+	ids := progress.identify(images)
+	isDone := func(name string) bool {
+		_, done := progress.Completed[ids[name]]
+		return ((done || progress.Blank[ids[name]]) && !force.matches(name)) || progress.protected(ids[name])
+	}
+	skipped, protected := 0, 0
 	for _, img := range images {
-		if _, done := progress.Completed[filepath.Base(img)]; done {
+		if isDone(img) {
 			skipped++
+			if force.matches(img) {
+				protected++
+			}
 		}
 	}
+	if protected > 0 {
+		dim.Printf("  Keeping %d verified or finalized result(s) matched by --force\n", protected)
+	}
+
+	if *dryRun {
+		if display == jsonDisplay {
+			logger.Error("--dry-run can't be used with --json")
+			exit(1)
+		}
+		printDryRun(images, progress, isDone, pipe.model)
+		return
+	}
+	if err := pipe.checkAPIKey(); err != nil {
+		logger.Error(err.Error())
+		exit(exitAuth)
+	}
 
 	if skipped > 0 {
-		cyan.Printf("  Resuming: %d of %d already completed\n", skipped, len(images))
+		cyan.Printf(msg("  Resuming: %d of %d already completed\n"), skipped, len(images))
 	}
-	fmt.Printf("  %s images to process\n\n", bold.Sprintf("%d", len(images)-skipped))
+	fmt.Printf(msg("  %s images to process\n\n"), bold.Sprintf("%d", len(images)-skipped))
 
-	succeeded := 0
-	failed := 0
+	succeeded, blank, failed := pipe.runBatch(progress, images, isDone, func(log *ReadingLog) {
+		log.Week = *week
+		log.Classroom = *classroom
+	})
 
-	for i, imgPath := range images {
-		baseName := filepath.Base(imgPath)
+	flagged, rows, paths := pipe.finishRun(progress, *outlierZ)
+	pipe.report(len(images), succeeded, blank, failed, skipped, flagged, rows, paths)
+	if code := pipe.exitCode(); code != 0 {
+		exit(code)
+	}
+}
 
-		// Skip already-completed files
-		if _, done := progress.Completed[baseName]; done {
-			continue
+// pipelineFlags registers the flags shared by every command that processes
+// images and returns a function that builds the pipeline from them. Building
+// it also points the global store at the chosen input location.
+func pipelineFlags(fs *flag.FlagSet) func() (*pipeline, error) {
+	input := fs.String("input", cfg.Input, "directory or s3://, gs:// bucket prefix to read images from; progress and CSV are written there too")
+	fake := fs.Bool("fake", false, "use the built-in fake client instead of the Anthropic API (no API key needed)")
+	model := fs.String("model", cfg.Model, "model to read images with: a model ID or fast, balanced, or accurate")
+	fallback := fs.String("fallback-model", cfg.FallbackModel, "model to use while --model is overloaded, or none (default: balanced, or accurate if --model is balanced)")
+	concurrency := fs.Int("concurrency", cfg.Concurrency, "number of images to process in parallel")
+	rpm := fs.Int("rpm", cfg.RequestsPerMinute, "API requests per minute to stay under (default: the account's limit, learned from the API's responses)")
+	tpm := fs.Int("tpm", cfg.TokensPerMinute, "API tokens per minute to stay under (default: the account's limit, learned from the API's responses)")
+	configured, _ := cfg.imageTimeout()
+	timeout := fs.Duration("timeout", configured, "give up on an image that takes longer than this to read, e.g. 5m, leaving it to retry (0 for no limit)")
+	deadline := fs.Duration("deadline", 0, "stop starting new images this long after the run starts, e.g. 2h, leaving the rest for the next run (0 for no limit)")
+	form := fs.String("template", "", "form template to read every image with (default: detect each image's template)")
+	promptFile := fs.String("prompt-file", cfg.PromptFile, "Go template to use as the extraction prompt (default: built in)")
+	examples := fs.Int("examples", cfg.Examples, "include up to N corrected examples (from review correct) in each prompt")
+	multi := fs.Bool("multi", cfg.MultiStudent, "images may show several students' logs side by side")
+	flatten := fs.Bool("flatten", cfg.FlattenPages, "find the page in each photo and straighten it if it was taken at an angle")
+	collages := fs.Bool("split-collages", cfg.SplitCollages, "cut collages of several photos apart and read each photo on its own")
+	batchSize := fs.Int("batch-size", cfg.BatchSize, fmt.Sprintf("read up to N images in each API request (at most %d); --concurrency is then the number of requests at once", maxBatchSize))
+	verify := fs.Bool("verify", false, "read every image twice and send disagreements to the review queue")
+	verifyModel := fs.String("verify-model", cfg.VerifyModel, "model for the second reading with --verify (default: the same model at a higher temperature)")
+	record := fs.Bool("record", false, "save every model response so the run can be repeated with --replay")
+	replay := fs.Bool("replay", false, "answer from responses saved with --record instead of calling the API")
+	auditDir := fs.String("audit-dir", cfg.AuditDir, "directory for the JSONL log of every API request made in a run (empty for none)")
+	runsDir := fs.String("runs-dir", cfg.RunsDir, "directory in the input location to keep each run's output and the run ledger in (empty for none)")
+	archive := fs.String("archive", cfg.Archive, "move or copy each image with a result into an organized folder named by archive_pattern")
+	rename := fs.Bool("rename", false, "rename each image with a result after its student and week, e.g. Brooks_Chloe_02-06.heic")
+	scrub := fs.Bool("scrub-archive", cfg.ScrubArchive, "remove location and other metadata from images as --archive or --rename files them")
+	hook := fs.String("webhook", cfg.Webhook, "POST each result to this URL as JSON as it is read")
+	hookBatch := fs.Bool("webhook-batch", cfg.WebhookBatch, "POST the run's results to --webhook in one array when the run ends instead")
+	onRecord := fs.String("on-record", cfg.OnRecord, "run this command with each result as JSON on stdin as it is read")
+	onComplete := fs.String("on-complete", cfg.OnComplete, "run this command with the run's results and outputs as JSON on stdin when the run ends")
+	upload := fs.Bool("upload", cfg.UploadImages, "upload each image once with the Files API instead of sending it inline with every request")
+	forceUnlock := lockFlag(fs)
+	newArtifacts := retentionFlags(fs)
+	logFlags(fs)
+
+	return func() (*pipeline, error) {
+		if err := startLog(); err != nil {
+			return nil, configError{err}
+		}
+		s, err := openStore(*input)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", *input, err)
+		}
+		store = s
+		if err := lockProgress(*forceUnlock); err != nil {
+			return nil, err
 		}
 
-		printProgress(i+1, len(images), skipped, imgPath)
-
-		// Convert HEIC to JPEG if needed
-		processPath := imgPath
-		if isHEIC(imgPath) {
-			jpgPath, err := convertHEICtoJPEG(imgPath)
-			if err != nil {
-				printError(imgPath, err)
-				progress.Errors[baseName] = err.Error()
-				saveProgress(progress)
-				failed++
-				continue
+		artifacts, err := newArtifacts()
+		if err != nil {
+			return nil, err
+		}
+		if *concurrency < 1 {
+			return nil, configErrorf("--concurrency must be at least 1")
+		}
+		if *timeout < 0 || *deadline < 0 {
+			return nil, configErrorf("--timeout and --deadline can't be negative")
+		}
+		if *rpm < 0 || *tpm < 0 {
+			return nil, configErrorf("--rpm and --tpm can't be negative")
+		}
+		if *batchSize < 1 || *batchSize > maxBatchSize {
+			return nil, configErrorf("--batch-size must be from 1 to %d", maxBatchSize)
+		}
+		if *batchSize > 1 && *multi {
+			return nil, configErrorf("--batch-size can't be used with --multi")
+		}
+		if *batchSize > 1 && (*record || *replay) {
+			return nil, configErrorf("--batch-size can't be used with --record or --replay, which keep one response per image")
+		}
+		if !slices.Contains(archiveModes, *archive) {
+			return nil, configErrorf("--archive must be move or copy")
+		}
+		if *form != "" {
+			if _, err := lookupTemplate(*form); err != nil {
+				return nil, configErrorf("--template: %w", err)
 			}
-			processPath = jpgPath
-			defer os.Remove(jpgPath)
 		}
-
-		// Read and base64-encode the image
-		mediaType, encoded, err := encodeImage(processPath)
+		prompt, err := loadPrompt(*promptFile)
 		if err != nil {
-			printError(imgPath, err)
-			progress.Errors[baseName] = err.Error()
-			saveProgress(progress)
-			failed++
-			continue
+			return nil, configErrorf("--prompt-file: %w", err)
+		}
+		if !*fake && !*replay {
+			useSavedKey()
 		}
+		client := newAnthropicClient(prompt)
+		client.model = resolveModel(*model)
+		client.fallback = fallbackModel(*fallback, client.model)
+		if *examples > 0 {
+			if client.examples, err = loadExemplars(); err != nil {
+				return nil, err
+			}
+			client.shots = *examples
+		}
+		client.usage, client.audit, client.limit = &usage{}, newAuditLog(*auditDir), newRateLimiter(*rpm, *tpm)
+		pipe := &pipeline{client: client, artifacts: artifacts, concurrency: *concurrency, timeout: *timeout, template: *form, multi: *multi, flatten: *flatten, splitCollages: *collages, usage: client.usage, audit: client.audit, limit: client.limit}
+		if *deadline > 0 {
+			pipe.deadline = time.Now().Add(*deadline)
+		}
+		if pipe.archive = *archive; pipe.archive != "" {
+			if pipe.archivePattern, err = parseArchivePattern(cfg.ArchivePattern); err != nil {
+				return nil, configErrorf("archive_pattern: %w", err)
+			}
+		}
+		if pipe.rename = *rename; pipe.rename {
+			if pipe.archive != "" {
+				return nil, configErrorf("--rename can't be used with --archive (or archive in the config file)")
+			}
+			pipe.archive, pipe.archivePattern = "move", template.Must(parseArchivePattern(renamePattern))
+		}
+		pipe.scrubArchive = *scrub
+		if pipe.hooks, err = newHooks(*onRecord, *onComplete); err != nil {
+			return nil, configError{err}
+		}
+		if *hook != "" {
+			if err := validateWebhook(*hook); err != nil {
+				return nil, configErrorf("--webhook: %w", err)
+			}
+		}
+		if *verify {
+			second := newAnthropicClient(prompt)
+			second.examples, second.shots = client.examples, client.shots
+			second.usage, second.audit, second.limit = client.usage, client.audit, client.limit
+			second.model, second.fallback = client.model, client.fallback
+			if m := resolveModel(*verifyModel); m != "" && m != client.model {
+				second.model, second.fallback = m, fallbackModel(*fallback, m)
+			} else {
+				client.temperature = anthropic.Float(primaryTemperature)
+				second.temperature = anthropic.Float(verifyTemperature)
+			}
+			pipe.verifier = second
+		}
+		if *record && *replay {
+			return nil, configErrorf("--record and --replay can't be used together")
+		}
+		if *record || *replay {
+			if *fake {
+				return nil, configErrorf("--record and --replay can't be used with --fake")
+			}
+			if client.recordings, err = openRecordings(*replay); err != nil {
+				return nil, fmt.Errorf("recordings: %w", err)
+			}
+			if second, ok := pipe.verifier.(*anthropicClient); ok {
+				second.recordings = client.recordings
+			}
+		}
+		if *upload && !*replay {
+			pipe.uploads = newUploads(client.client)
+			client.files = pipe.uploads
+			if second, ok := pipe.verifier.(*anthropicClient); ok {
+				second.files = pipe.uploads
+			}
+		}
+		if *fake {
+			pipe.client, pipe.uploads, pipe.usage, pipe.audit, pipe.limit = &fakeClient{}, nil, nil, nil, nil
+			if *verify {
+				pipe.verifier = &fakeClient{Second: true}
+			}
+			yellow.Println("  Using the fake client: results are synthetic")
+		}
+		if reader, ok := pipe.client.(batchReader); ok && *batchSize > 1 {
+			pipe.batch = newBatcher(reader, *batchSize)
+			pipe.concurrency *= *batchSize
+		}
+		model := string(client.model)
+		if *fake {
+			model = "fake"
+		}
+		pipe.runs = newRunLog(*runsDir, model)
+		pipe.model, pipe.webhook = model, newWebhook(*hook, *hookBatch)
+		logger.Info("run started", "command", commandLine(), "input", store.String(), "model", model, "concurrency", *concurrency)
+		return pipe, nil
+	}
+}
+
+// runBatch processes each image that isDone reports as pending, saving
+// progress after every image. stamp adds run-specific metadata to each new
+// result. Up to p.concurrency images are processed at once. Once the run's
+// deadline passes, or the run is interrupted or sent SIGTERM, images being
+// read are given up on and the rest are left pending; a second signal stops
+// the program at once. It returns the number of images that succeeded,
+// turned out to be blank forms, and failed.
+func (p *pipeline) runBatch(progress *Progress, images []string, isDone func(string) bool, stamp func(*ReadingLog)) (succeeded, blank, failed int) {
+	ctx := context.Background()
+	if !p.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, p.deadline)
+		defer cancel()
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop() // so another signal isn't caught
+	}()
+
+	// Work out what is already done before any image is read: the workers
+	// change progress as they go, and isDone reads it.
+	skipped := 0
+	done := make(map[string]bool, len(images))
+	for _, img := range images {
+		if isDone(filepath.Base(img)) {
+			done[img] = true
+			skipped++
+		}
+	}
+
+	var mu sync.Mutex // guards progress, counters, and the view
+	finished := 0
+	view := p.newView(len(images), skipped)
+	process := func(i int, imgPath string) {
+		baseName := filepath.Base(imgPath)
+		view.begin(i, imgPath)
+
+		start := time.Now()
+		logs, err := p.processImage(ctx, imgPath)
+		took := time.Since(start)
+
+		mu.Lock()
+		defer mu.Unlock()
+		finished++
 
-		// Send to Claude and parse the structured output
-		log, err := parseReadingLog(mediaType, encoded)
 		if err != nil {
-			printError(imgPath, err)
-			progress.Errors[baseName] = err.Error()
+			storeFailure(progress, baseName, err)
 			saveProgress(progress)
 			failed++
-			continue
+			kind := progress.Failures[baseName]
+			if p.failures == nil {
+				p.failures = make(map[string]int)
+			}
+			p.failures[kind]++
+			p.runs.note(baseName, "failed")
+			report := imageReport{Image: baseName, Outcome: "failed", Error: err.Error(), Kind: kind}
+			p.images = append(p.images, report)
+			view.end(finished, imgPath, report)
+			logger.ErrorContext(displayed, "could not read "+baseName, "image", baseName, "kind", kind, "err", err, "duration", took)
+			return
+		}
+		raised := make([][]Flag, len(logs))
+		for i, log := range logs {
+			log.Sender = progress.Senders[baseName]
+			if stamp != nil {
+				stamp(log)
+			}
+			raised[i] = log.Flags
 		}
 
 		// Save progress immediately after each success
-		progress.Completed[baseName] = *log
-		delete(progress.Errors, baseName) // clear any previous error for this file
+		kept := storeResults(progress, baseName, logs)
 		if err := saveProgress(progress); err != nil {
-			red.Fprintf(os.Stderr, "  Warning: could not save progress: %v\n", err)
+			logger.Warn("could not save progress", "err", err)
 		}
 
-		printResult(log)
+		if len(kept) == 0 {
+			blank++
+			p.runs.note(baseName, "blank")
+			report := imageReport{Image: baseName, Outcome: "blank"}
+			p.images = append(p.images, report)
+			view.end(finished, imgPath, report)
+			logger.Info("blank form", "image", baseName, "duration", took)
+			return
+		}
 		succeeded++
+		p.runs.note(baseName, "completed")
+		report := imageReport{Image: baseName, Outcome: "completed"}
+		for i, log := range logs {
+			if log.Blank {
+				continue
+			}
+			r := *log
+			r.Flags = raised[i]
+			report.Results = append(report.Results, r)
+			record := webhookRecord{Image: baseName, ParsedAt: time.Now(), Model: p.model, Run: p.runs.id(), Result: r}
+			p.webhook.send(record)
+			p.hooks.record(record)
+			for _, f := range raised[i] {
+				logger.WarnContext(displayed, "result flagged", "image", log.Source, "check", f.Check, "detail", f.Detail)
+			}
+		}
+		p.images = append(p.images, report)
+		view.end(finished, imgPath, report)
+		logger.Info("image read", "image", baseName, "results", len(kept), "duration", took)
+	}
+
+	sem := make(chan struct{}, max(p.concurrency, 1))
+	var wg sync.WaitGroup
+	left := 0
+	for i, imgPath := range images {
+		// Skip already-completed files
+		if done[imgPath] {
+			continue
+		}
+		if ctx.Err() == nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			left++
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			process(i, imgPath)
+		}()
+	}
+	wg.Wait()
+	view.close()
+	p.stopped = ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if left > 0 {
+		why := "the run was stopped"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			why = "the run's deadline passed"
+		}
+		logger.Warn(fmt.Sprintf("%s with %d image(s) not read; the next run picks up from here", why, left), "left", left)
 	}
+	return succeeded, blank, failed
+}
+
+// readingChecks are the review checks raised while an image is read, rather
+// than by re-checking completed results.
+var readingChecks = []string{"incomplete", "verify", "written"}
+
+// storeFailure records why an image couldn't be read, and what kind of
+// failure it was.
+func storeFailure(p *Progress, name string, err error) {
+	p.Errors[name] = err.Error()
+	p.Failures[name] = failureKind(err)
+}
 
-	// Write all completed results (including previous runs) to CSV
-	allLogs := make([]ReadingLog, 0, len(progress.Completed))
-	for _, log := range progress.Completed {
-		allLogs = append(allLogs, log)
+// storeResults records the results read from an image, replacing any
+// earlier results or error for it. Each student's log is stored under its
+// own record key. Flags raised while reading move from the logs into the
+// review queue, and blank forms are dropped; an image with nothing else is
+// noted as blank. It returns the record keys of the results stored.
+func storeResults(p *Progress, name string, logs []*ReadingLog) []string {
+	id := p.idOf(name)
+	delete(p.Errors, name)
+	delete(p.Failures, name)
+	delete(p.Blank, id)
+	for key := range p.Completed {
+		if imageOf(key) == id {
+			delete(p.Completed, key)
+			delete(p.Review, key)
+		}
 	}
 
+	var kept []string
+	for _, log := range logs {
+		if log.Blank {
+			continue
+		}
+		key := recordKey(id, len(kept))
+		kept = append(kept, key)
+		for _, check := range readingChecks {
+			details := make(map[string][]string)
+			for _, f := range log.Flags {
+				if f.Check == check {
+					details[key] = append(details[key], f.Detail)
+				}
+			}
+			setFlags(p, check, details, map[string]bool{key: true})
+		}
+		log.Flags = nil
+		p.Completed[key] = *log
+	}
+	if len(kept) == 0 {
+		p.Blank[id] = true
+	}
+	return kept
+}
+
+// finishRun cleans up artifacts, re-checks the cohort, and rewrites the output
+// from every completed result, including those from previous runs. It
+// returns the number of flagged results, the rows written, and the paths.
+func (p *pipeline) finishRun(progress *Progress, outlierZ float64) (flagged, rows int, paths []string) {
+	if n := p.artifacts.cleanup(); n > 0 {
+		dim.Printf("  Cleaned up %d artifact(s)\n", n)
+	}
+	p.uploads.releaseAll()
+	if path := p.audit.close(); path != "" {
+		dim.Printf("  Audit log: %s\n", path)
+	}
+	if posted, failed := p.webhook.close(); failed > 0 {
+		yellow.Printf("  Posted %d result(s) to the webhook; %d could not be posted\n", posted, failed)
+	} else if posted > 0 {
+		dim.Printf("  Posted %d result(s) to the webhook\n", posted)
+	}
+	if ran, failed := p.hooks.close(); failed > 0 {
+		yellow.Printf("  Ran on_record for %d result(s); it failed for %d\n", ran+failed, failed)
+	} else if ran > 0 {
+		dim.Printf("  Ran on_record for %d result(s)\n", ran)
+	}
+	if n := p.archiveImages(progress); n > 0 && p.rename {
+		dim.Printf("  Renamed %d image(s)\n", n)
+	} else if n > 0 {
+		dim.Printf("  Archived %d image(s)\n", n)
+	}
+
+	// Re-check the whole cohort: new results shift every classroom's baseline
+	validateLogs(progress, nil)
+	detectOutliers(progress, outlierZ, nil)
+	flagged = len(progress.Review)
+	if err := saveProgress(progress); err != nil {
+		logger.Warn("could not save progress", "err", err)
+	}
+
+	// Write all completed results (including previous runs) to the output
+	allLogs := completedLogs(progress)
+
 	if len(allLogs) == 0 {
 		red.Println("No reading logs were successfully parsed")
-		os.Exit(1)
+		exit(1)
 	}
 
-	if err := writeCSV("reading_logs.csv", allLogs); err != nil {
-		red.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
-		os.Exit(1)
+	paths, err := writeOutput(allLogs)
+	if err != nil {
+		logger.Error("writing output", "err", err)
+		exit(1)
+	}
+	for _, log := range allLogs {
+		p.minutes += totalMinutes(log)
 	}
+	if dir, err := p.runs.save(allLogs, paths); err != nil {
+		logger.Warn("could not keep a copy of this run", "err", err)
+	} else if dir != "" {
+		dim.Printf("  Run kept in %s\n", dir)
+	}
+	if err := updateHistory(allLogs); err != nil {
+		logger.Warn("could not update history", "err", err)
+	}
+	if err := p.hooks.complete(p.runs.id(), paths); err != nil {
+		logger.Warn("on_complete hook failed", "err", err)
+	}
+	logger.Info("run finished", "results", len(allLogs), "flagged", flagged, "output", strings.Join(paths, ","))
+	return flagged, len(allLogs), paths
+}
 
-	printSummary(len(images), succeeded, failed, skipped)
-	boldGrn.Printf("  Wrote %d reading log(s) to reading_logs.csv\n\n", len(allLogs))
+// printWrote reports where the output was written.
+func printWrote(rows int, paths []string) {
+	boldGrn.Printf(msg("  Wrote %d reading log(s) to %s"), rows, strings.Join(paths, ", "))
+	if store.String() != "." {
+		boldGrn.Printf(msg(" in %s"), store)
+	}
+	fmt.Print("\n\n")
 }
 
-// --- image handling -----------------------------------------------------
+// pipeline holds what is needed to turn one image into a reading log.
+type pipeline struct {
+	client         Client
+	artifacts      *artifactSet
+	concurrency    int
+	timeout        time.Duration      // how long one image may take to read; 0 for no limit
+	deadline       time.Time          // when the run stops reading images; zero for no deadline
+	template       string             // form template to use; empty to detect
+	multi          bool               // images may show several students' logs
+	flatten        bool               // straighten photos of pages taken at an angle
+	splitCollages  bool               // read each photo in a collage on its own
+	verifier       Client             // reads each image a second time with --verify; nil otherwise
+	uploads        *uploads           // images uploaded with --upload, deleted once read; nil otherwise
+	batch          *batcher           // reads several images per request with --batch-size; nil otherwise
+	usage          *usage             // API tokens used; nil with --fake
+	audit          *auditLog          // API requests made; nil with --fake or no --audit-dir
+	runs           *runLog            // this run's ledger entry and outputs; nil with no --runs-dir
+	archive        string             // move or copy images into the archive once read; empty for neither
+	archivePattern *template.Template // names archived images
+	rename         bool               // archiving is renaming in place, with --rename
+	scrubArchive   bool               // archived images have their metadata removed
+	limit          *rateLimiter       // paces API requests; nil with --fake
+	images         []imageReport      // what became of each image, for --json
+	minutes        int                // total minutes in the output, for notifications
+	model          string             // what reads the images, for the webhook
+	webhook        *webhook           // posts each result with --webhook; nil otherwise
+	hooks          *hooks             // runs --on-record and --on-complete; nil with neither
+	stopped        bool               // the run was interrupted or sent SIGTERM
+	failures       map[string]int     // images that failed this run, by kind of failure
+}
 
-// findImages scans a directory for supported image files.
-func findImages(dir string) ([]string, error) {
-	var images []string
-	entries, err := os.ReadDir(dir)
+// processImage reads, converts, encodes, and parses a single image from the
+// store, recording any intermediate artifacts for later cleanup. It returns
+// one log per student on the image. An image that takes longer than
+// p.timeout, or is still being read when the run's deadline passes or it is
+// stopped, fails with an error saying so, and is read again by retry or the
+// next run like any other failure.
+func (p *pipeline) processImage(ctx context.Context, name string) ([]*ReadingLog, error) {
+	run := ctx
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	logs, err := p.extract(ctx, name)
+	if err != nil && ctx.Err() != nil {
+		switch {
+		case errors.Is(run.Err(), context.DeadlineExceeded):
+			err = fmt.Errorf("stopped at the run's deadline before it was read: %w", run.Err())
+		case run.Err() != nil:
+			err = fmt.Errorf("the run was stopped before it was read: %w", run.Err())
+		default:
+			err = fmt.Errorf("timed out after %s: %w", p.timeout, ctx.Err())
+		}
+	}
+	if err != nil {
+		p.artifacts.write(artifactRaw, name, ".error.txt", []byte(err.Error()))
+	} else {
+		if logs[0].Raw != "" {
+			p.artifacts.write(artifactRaw, name, ".json", []byte(logs[0].Raw))
+		}
+		for _, log := range logs {
+			normalizeLog(log)
+		}
+	}
+	p.artifacts.finish(name, err == nil)
+	return logs, err
+}
+
+func (p *pipeline) extract(ctx context.Context, name string) ([]*ReadingLog, error) {
+	data, err := readStoreFile(store, name)
+	if err != nil {
+		return nil, imageError{err}
+	}
+	return p.extractData(ctx, name, data)
+}
+
+// extractData reads the logs on an image from its bytes. name is the image's
+// filename, which gives its format. Converted images are kept as artifacts
+// when the pipeline has an artifact set.
+func (p *pipeline) extractData(ctx context.Context, name string, data []byte) ([]*ReadingLog, error) {
+	// Read when the photo was taken before encodeImage strips it
+	taken := photoTaken(data)
+
+	// Convert HEIC, TIFF, and BMP to JPEG, which the API accepts
+	mediaName := name
+	if needsConversion(name) {
+		jpgPath := ""
+		if p.artifacts != nil {
+			path, err := p.artifacts.path(artifactConverted, name, ".jpg")
+			if err != nil {
+				return nil, err
+			}
+			p.artifacts.add(name, path)
+			jpgPath = path
+		}
+		converted, err := convertedJPEG(name, data, jpgPath)
+		if err != nil {
+			return nil, imageError{err}
+		}
+		data, mediaName = converted, name+".jpg"
+	}
+
+	// Cut a collage into its tiles, each read as a photo of its own
+	if p.splitCollages && !p.multi {
+		tiles, err := splitCollage(data)
+		if err != nil {
+			logger.WarnContext(ctx, "could not look for a collage; reading the image whole", "image", name, "err", err)
+		}
+		if len(tiles) > 1 {
+			logger.InfoContext(ctx, fmt.Sprintf("collage of %d photos", len(tiles)), "image", name, "tiles", len(tiles))
+			var logs []*ReadingLog
+			for i, tile := range tiles {
+				tileName := fmt.Sprintf("%s.tile%d.jpg", name, i+1)
+				if p.artifacts != nil {
+					p.artifacts.write(artifactConverted, name, fmt.Sprintf(".tile%d.jpg", i+1), tile)
+				}
+				tileLogs, err := p.readPhoto(ctx, name, tileName, tile)
+				if err != nil {
+					return nil, fmt.Errorf("photo %d of %d in the collage: %w", i+1, len(tiles), err)
+				}
+				logs = append(logs, tileLogs...)
+			}
+			logs = mergeTiles(logs)
+			for _, log := range logs {
+				log.PhotoTaken = taken
+			}
+			return logs, nil
+		}
+	}
+
+	logs, err := p.readPhoto(ctx, name, mediaName, data)
 	if err != nil {
 		return nil, err
 	}
+	for _, log := range logs {
+		log.PhotoTaken = taken
+	}
+	return logs, nil
+}
 
-	imageExts := map[string]bool{
-		".jpg": true, ".jpeg": true, ".png": true,
-		".gif": true, ".webp": true, ".heic": true,
+// readPhoto reads the logs on one photo: an image, or a tile of a collage.
+// mediaName is a filename that gives its format, and name the image's, for
+// the audit log and artifacts.
+func (p *pipeline) readPhoto(ctx context.Context, name, mediaName string, data []byte) ([]*ReadingLog, error) {
+	// Straighten a page photographed at an angle
+	if p.flatten && !p.multi {
+		flat, ok, err := flattenPage(data)
+		switch {
+		case err != nil:
+			logger.WarnContext(ctx, "could not flatten the page; sending the photo as it is", "image", name, "err", err)
+		case ok:
+			// e.g. IMG_0912.heic.flat.jpg, or IMG_0912.heic.tile2.flat.jpg
+			suffix := strings.TrimSuffix(strings.TrimPrefix(mediaName, name), ".jpg") + ".flat.jpg"
+			data, mediaName = flat, name+suffix
+			if p.artifacts != nil {
+				p.artifacts.write(artifactConverted, name, suffix, flat)
+			}
+		}
 	}
 
-	for _, e := range entries {
-		if e.IsDir() {
+	// Base64-encode the image
+	mediaType, encoded, err := encodeImage(mediaName, data)
+	if err != nil {
+		return nil, imageError{err}
+	}
+	defer p.uploads.release(encoded)
+
+	ctx = withImage(ctx, name)
+	form, err := p.chooseTemplate(ctx, mediaType, encoded)
+	if err != nil {
+		return nil, err
+	}
+	if !p.multi {
+		crops, err := cropRegions(data, form)
+		if err != nil {
+			logger.WarnContext(ctx, "could not crop the form's regions; sending the whole image only", "image", name, "err", err)
+		}
+		ctx = withCrops(ctx, crops)
+	}
+
+	// Send to Claude and parse the structured output
+	logs, err := p.read(ctx, p.client, mediaType, encoded, form)
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		if len(cfg.Templates) > 0 {
+			log.Template = form.Name
+		}
+		if isBlank(*log) {
+			log.Blank = true
 			continue
 		}
-		ext := strings.ToLower(filepath.Ext(e.Name()))
-		if imageExts[ext] {
-			images = append(images, filepath.Join(dir, e.Name()))
+		flagWritten(log)
+		switch {
+		case isBackPage(name):
+			// The student's name and grade are on the front page, and some
+			// of the days may be too.
+			reconcileDays(log, form.days())
+		case len(logs) == 1:
+			if err := p.fillMissing(ctx, mediaType, encoded, log); err != nil {
+				return nil, err
+			}
+			if err := p.fillDays(ctx, mediaType, encoded, form, log); err != nil {
+				return nil, err
+			}
+		default:
+			// With several students on one image, "the student's name" is
+			// ambiguous, so a blank name or missing day is flagged without
+			// asking again.
+			flagMissing(log)
+			flagMissingDays(log, reconcileDays(log, form.days()))
+		}
+	}
+	if p.verifier != nil {
+		if err := p.verify(ctx, name, mediaType, encoded, form, logs); err != nil {
+			return nil, err
+		}
+	}
+	return logs, nil
+}
+
+// --- image handling -----------------------------------------------------
+
+// findImages scans a store for supported image files.
+func findImages(s Store) ([]string, error) {
+	var images []string
+	names, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if isImageFile(name) {
+			images = append(images, name)
 		}
 	}
 	return images, nil
 }
 
-// isHEIC returns true if the file has a .heic extension.
+// filterImages keeps only the images matching one of the glob patterns.
+func filterImages(images []string, patterns stringList) []string {
+	var kept []string
+	for _, img := range images {
+		if patterns.matches(img) {
+			kept = append(kept, img)
+		}
+	}
+	return kept
+}
+
+// imageExts lists the file extensions treated as reading log images.
+var imageExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true,
+	".gif": true, ".webp": true, ".heic": true, ".heif": true,
+	".tif": true, ".tiff": true, ".bmp": true,
+}
+
+// isImageFile returns true if the file has a supported image extension.
+func isImageFile(name string) bool {
+	return imageExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// isHEIC returns true if the file has a .heic or .heif extension.
 func isHEIC(path string) bool {
-	return strings.ToLower(filepath.Ext(path)) == ".heic"
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".heic" || ext == ".heif"
+}
+
+// needsConversion returns true for images the API doesn't accept, which are
+// converted to JPEG before they are sent: HEIC and HEIF photos, and the TIFF
+// and BMP files scanners write.
+func needsConversion(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".heic", ".heif", ".tif", ".tiff", ".bmp":
+		return true
+	}
+	return false
+}
+
+// convertToJPEG converts the bytes of the image name to a JPEG at jpgPath.
+// Only the first page of a multi-page TIFF is kept.
+func convertToJPEG(name string, data []byte, jpgPath string) error {
+	if isHEIC(name) {
+		return convertHEICtoJPEG(data, jpgPath)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s conversion failed: %w", strings.ToUpper(strings.TrimPrefix(filepath.Ext(name), ".")), err)
+	}
+	return writeJPEG(img, jpgPath)
 }
 
-// convertHEICtoJPEG uses macOS sips to convert a HEIC file to JPEG in a temp directory.
-func convertHEICtoJPEG(heicPath string) (string, error) {
-	tmpFile, err := os.CreateTemp("", "reading-log-*.jpg")
+// convertedJPEG converts the bytes of the image name to JPEG and returns
+// them. The JPEG is kept at path, or if path is empty, in a temporary file
+// that is removed.
+func convertedJPEG(name string, data []byte, path string) ([]byte, error) {
+	if path == "" {
+		tmp, err := os.CreateTemp("", "reading-log-*.jpg")
+		if err != nil {
+			return nil, err
+		}
+		tmp.Close()
+		path = tmp.Name()
+		defer os.Remove(path)
+	}
+	if err := convertToJPEG(name, data, path); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// convertHEICtoJPEG converts HEIC bytes to a JPEG at jpgPath, with macOS
+// sips where it is installed, and otherwise with the built-in decoder, so
+// HEIC photos can be read anywhere, such as in a Linux container.
+func convertHEICtoJPEG(data []byte, jpgPath string) error {
+	if _, err := exec.LookPath("sips"); err == nil {
+		return sipsHEICtoJPEG(data, jpgPath)
+	}
+	img, err := heic.Decode(bytes.NewReader(data))
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return fmt.Errorf("HEIC conversion failed: %w", err)
 	}
-	tmpFile.Close()
-	jpgPath := tmpFile.Name()
+	return writeJPEG(img, jpgPath)
+}
 
-	cmd := exec.Command("sips", "-s", "format", "jpeg", heicPath, "--out", jpgPath)
-	output, err := cmd.CombinedOutput()
+// writeJPEG encodes img as a JPEG file at path.
+func writeJPEG(img image.Image, path string) error {
+	f, err := os.Create(path)
 	if err != nil {
-		os.Remove(jpgPath)
-		return "", fmt.Errorf("sips conversion failed: %w\n%s", err, string(output))
+		return err
 	}
-	return jpgPath, nil
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 90}); err != nil {
+		f.Close()
+		return fmt.Errorf("JPEG encoding failed: %w", err)
+	}
+	return f.Close()
 }
 
-// encodeImage reads an image file and returns its media type and base64 encoding.
-func encodeImage(path string) (string, string, error) {
-	file, err := os.Open(path)
+// sipsHEICtoJPEG uses macOS sips to convert HEIC bytes to a JPEG at jpgPath.
+func sipsHEICtoJPEG(heic []byte, jpgPath string) error {
+	tmpFile, err := os.CreateTemp("", "reading-log-*.heic")
 	if err != nil {
-		return "", "", err
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	heicPath := tmpFile.Name()
+	_, err = tmpFile.Write(heic)
+	tmpFile.Close()
+	defer os.Remove(heicPath)
+	if err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	cmd := exec.Command("sips", "-s", "format", "jpeg", heicPath, "--out", jpgPath)
+	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", "", err
+		return fmt.Errorf("sips conversion failed: %w\n%s", err, string(output))
 	}
+	return nil
+}
 
-	ext := strings.ToLower(filepath.Ext(path))
+// encodeImage returns the media type and base64 encoding of an image's
+// bytes, with its metadata removed.
+func encodeImage(name string, data []byte) (string, string, error) {
+	ext := strings.ToLower(filepath.Ext(name))
 	mediaTypes := map[string]string{
 		".jpg":  "image/jpeg",
 		".jpeg": "image/jpeg",
@@ -347,6 +1396,10 @@ func encodeImage(path string) (string, string, error) {
 	if !ok {
 		return "", "", fmt.Errorf("unsupported image type: %s", ext)
 	}
+	data, err := stripMetadata(data) // nothing but the picture leaves the machine
+	if err != nil {
+		return "", "", err
+	}
 
 	encoded := base64.StdEncoding.EncodeToString(data)
 	return mediaType, encoded, nil
@@ -354,112 +1407,535 @@ func encodeImage(path string) (string, string, error) {
 
 // --- claude API ---------------------------------------------------------
 
-// parseReadingLog sends an image to Claude and returns the structured reading log data.
-func parseReadingLog(mediaType, encodedImage string) (*ReadingLog, error) {
-	client := anthropic.NewClient()
+// Client extracts a reading log from a base64-encoded image. anthropicClient
+// is used for real runs; fakeClient stands in for tests and keyless trials.
+type Client interface {
+	ParseReadingLog(ctx context.Context, mediaType, encodedImage string, form FormTemplate) (*ReadingLog, error)
+	// DetectTemplate returns the name of the template the image matches.
+	DetectTemplate(ctx context.Context, mediaType, encodedImage string, templates []FormTemplate) (string, error)
+	// ParseReadingLogs is ParseReadingLog for an image that may show
+	// several students' logs; it returns one per student.
+	ParseReadingLogs(ctx context.Context, mediaType, encodedImage string, form FormTemplate) ([]*ReadingLog, error)
+	// AskFields looks again for top-level fields that came back blank.
+	AskFields(ctx context.Context, mediaType, encodedImage string, fields []string) (map[string]string, error)
+	// AskDays looks again for days missing from a log.
+	AskDays(ctx context.Context, mediaType, encodedImage string, form FormTemplate, days []DayConfig) ([]ReadingEntry, error)
+}
+
+// Defaults for extraction requests.
+const (
+	defaultModel     = anthropic.ModelClaudeSonnet4_5_20250929
+	defaultMaxTokens = 1024
+)
+
+// anthropicClient extracts reading logs with Claude's structured outputs.
+type anthropicClient struct {
+	client      anthropic.Client
+	model       anthropic.Model
+	fallback    anthropic.Model // used when model is overloaded; empty for none
+	maxTokens   int64
+	prompt      *template.Template // extraction prompt; see promptData
+	examples    []exemplar         // corrected results to pick few-shot examples from
+	shots       int                // examples to include per request
+	temperature param.Opt[float64] // unset uses the API default
+	files       *uploads           // images sent by file ID with --upload; nil sends them inline
+	usage       *usage             // tokens used, shared with the --verify client
+	audit       *auditLog          // every request, shared with the --verify client; nil for none
+	recordings  *recordings        // responses saved with --record or answered from with --replay
+	limit       *rateLimiter       // paces requests, shared with the --verify client
+
+	mu        sync.Mutex // guards model and overloads
+	overloads int        // requests that failed as overloaded
+}
 
-	schemaMap := generateJSONSchema(&ReadingLog{})
+// newAnthropicClient returns a Client configured from ANTHROPIC_API_KEY.
+func newAnthropicClient(prompt *template.Template) *anthropicClient {
+	return &anthropicClient{
+		client:    anthropic.NewClient(),
+		model:     resolveModel(cfg.Model),
+		maxTokens: cfg.MaxTokens,
+		prompt:    prompt,
+	}
+}
 
-	prompt := `Analyze this reading log image carefully. Extract the following information exactly as written:
+// ParseReadingLog sends an image to Claude and returns the structured reading log data.
+func (c *anthropicClient) ParseReadingLog(ctx context.Context, mediaType, encodedImage string, form FormTemplate) (*ReadingLog, error) {
+	schemaMap := generateJSONSchema(extractionSchema(form))
 
-1. The student's full name
-2. The grade level
-3. The homeroom teacher's name
+	prompt, err := renderPrompt(c.prompt, form)
+	if err != nil {
+		return nil, err
+	}
 
-Then for each day listed on the reading log (Friday 1/30, Saturday 1/31, Sunday 2/1, Monday 2/2, Tuesday 2/3, Wednesday 2/4, Thursday 2/5), extract the reading time as a number of minutes (integer only, e.g. if it says "10 min" or "10mi" return 10). If a day has no reading time filled in, use 0.
+	shots := pickExemplars(c.examples, form.Name, c.shots)
+	text, err := c.ask(ctx, "parse", mediaType, encodedImage, prompt, schemaMap, shots...)
+	if err != nil {
+		return nil, err
+	}
+	var log ReadingLog
+	if err := json.Unmarshal([]byte(text), &log); err != nil {
+		return nil, fmt.Errorf("%w: %w\nraw: %s", errParse, err, text)
+	}
+	log.Raw = text
+	return &log, nil
+}
 
-Return all information in the structured JSON format requested.`
+// ask sends an image and prompt to Claude and returns the structured JSON
+// response, which follows schemaMap. Each shot is sent first as an earlier
+// exchange, showing the model a worked example.
+//
+// The prompt goes in the system prompt and the image after it, so that the
+// part every request shares, the prompt and any shots, is a prefix that
+// can be served from the prompt cache.
+func (c *anthropicClient) ask(ctx context.Context, request, mediaType, encodedImage, prompt string, schemaMap map[string]any, shots ...exemplar) (string, error) {
+	image := batchImage{Name: imageFrom(ctx), MediaType: mediaType, Data: encodedImage, Crops: cropsFrom(ctx)}
+	return c.askImages(ctx, request, []batchImage{image}, prompt, schemaMap, shots...)
+}
 
-	imageSource := anthropic.BetaBase64ImageSourceParam{
-		Data:      encodedImage,
-		MediaType: anthropic.BetaBase64ImageSourceMediaType(mediaType),
+// askImages is ask for one or more images. With several, each is numbered,
+// and the shots' answers are given as a batch's results.
+func (c *anthropicClient) askImages(ctx context.Context, request string, images []batchImage, prompt string, schemaMap map[string]any, shots ...exemplar) (string, error) {
+	batched := len(images) > 1
+	entry := auditEntry{
+		Time:      time.Now(),
+		Request:   request,
+		MaxTokens: c.maxTokens * int64(len(images)),
+		MediaType: images[0].MediaType,
+		Sent:      "inline",
+		Examples:  len(shots),
+		Prompt:    prompt,
+	}
+	var names, hashes []string
+	for _, img := range images {
+		hash, size := imageHash(img.Data)
+		names, hashes = append(names, img.Name), append(hashes, hash)
+		entry.ImageBytes += size
+		entry.Crops += len(img.Crops)
+	}
+	entry.Image, entry.ImageSHA256 = strings.Join(names, ", "), strings.Join(hashes, ",")
+	if batched {
+		entry.Images = len(images)
+	}
+	if c.temperature.Valid() {
+		entry.Temperature = &c.temperature.Value
+	}
+	if c.files != nil {
+		entry.Sent = "file"
+	}
+	c.mu.Lock()
+	key := recordingKey(request, string(c.model), entry.Temperature, entry.ImageSHA256)
+	c.mu.Unlock()
+	if c.recordings != nil && c.recordings.replay {
+		if text, ok := c.recordings.lookup(key); ok {
+			return text, nil
+		}
+		return "", fmt.Errorf("no recorded %s response for this image (run with --record first)", request)
 	}
 
-	msg, err := client.Beta.Messages.New(context.TODO(), anthropic.BetaMessageNewParams{
-		Model:     anthropic.ModelClaudeSonnet4_5_20250929,
-		MaxTokens: 1024,
-		Messages: []anthropic.BetaMessageParam{
-			anthropic.NewBetaUserMessage(
-				anthropic.NewBetaImageBlock(imageSource),
-				anthropic.NewBetaTextBlock(prompt),
-			),
-		},
-		OutputFormat: anthropic.BetaJSONSchemaOutputFormat(schemaMap),
-		Betas:        []anthropic.AnthropicBeta{"structured-outputs-2025-11-13"},
+	system := []anthropic.BetaTextBlockParam{{
+		Text:         prompt,
+		CacheControl: anthropic.NewBetaCacheControlEphemeralParam(),
+	}}
+	var messages []anthropic.BetaMessageParam
+	for i, shot := range shots {
+		answer, err := json.Marshal(shot.Log)
+		if err != nil {
+			return "", err
+		}
+		n := 0
+		if batched {
+			n = 1
+			if answer, err = batchAnswer(answer); err != nil {
+				return "", err
+			}
+		}
+		example, err := c.imageBlocks(ctx, batchImage{MediaType: shot.MediaType, Data: shot.Data}, n)
+		if err != nil {
+			return "", err
+		}
+		reply := anthropic.BetaTextBlockParam{Text: string(answer)}
+		if i == len(shots)-1 {
+			reply.CacheControl = anthropic.NewBetaCacheControlEphemeralParam()
+		}
+		messages = append(messages, anthropic.NewBetaUserMessage(example...), anthropic.BetaMessageParam{
+			Role:    anthropic.BetaMessageParamRoleAssistant,
+			Content: []anthropic.BetaContentBlockParamUnion{{OfText: &reply}},
+		})
+	}
+	var question []anthropic.BetaContentBlockParamUnion
+	for i, img := range images {
+		n := 0
+		if batched {
+			n = i + 1
+		}
+		blocks, err := c.imageBlocks(ctx, img, n)
+		if err != nil {
+			return "", err
+		}
+		question = append(question, blocks...)
+	}
+	messages = append(messages, anthropic.NewBetaUserMessage(question...))
+
+	betas := []anthropic.AnthropicBeta{"structured-outputs-2025-11-13"}
+	if c.files != nil {
+		betas = append(betas, filesBeta)
+	}
+	count := option.WithMiddleware(func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		entry.Attempts++
+		entry.URL = r.URL.String()
+		return next(r)
 	})
+
+	start := time.Now()
+	msg, model, err := c.send(ctx, anthropic.BetaMessageNewParams{
+		MaxTokens:    entry.MaxTokens,
+		Temperature:  c.temperature,
+		System:       system,
+		Messages:     messages,
+		OutputFormat: anthropic.BetaJSONSchemaOutputFormat(schemaMap),
+		Betas:        betas,
+	}, count)
+	entry.Model = string(model)
+	entry.LatencyMS = time.Since(start).Milliseconds()
 	if err != nil {
-		return nil, fmt.Errorf("API call failed: %w", err)
+		entry.Outcome, entry.Error = "error", err.Error()
+		c.audit.record(entry)
+		return "", fmt.Errorf("API call failed: %w", err)
 	}
+	c.usage.add(model, msg.Usage)
+	c.limit.used(msg.Usage)
+	entry.Tokens.Input, entry.Tokens.Output = msg.Usage.InputTokens, msg.Usage.OutputTokens
+	entry.Tokens.CacheRead, entry.Tokens.CacheWrite = msg.Usage.CacheReadInputTokens, msg.Usage.CacheCreationInputTokens
 
-	// Parse the structured JSON from the response
 	for _, block := range msg.Content {
 		if textBlock, ok := block.AsAny().(anthropic.BetaTextBlock); ok {
-			var log ReadingLog
-			if err := json.Unmarshal([]byte(textBlock.Text), &log); err != nil {
-				return nil, fmt.Errorf("failed to parse response JSON: %w\nraw: %s", err, textBlock.Text)
+			entry.Response, entry.Outcome = textBlock.Text, "ok"
+			if !json.Valid([]byte(textBlock.Text)) {
+				entry.Outcome = "invalid-json"
+			}
+			c.audit.record(entry)
+			if c.recordings != nil {
+				if err := c.recordings.save(key, entry.Image, textBlock.Text); err != nil {
+					logger.Warn("could not save recording", "image", entry.Image, "err", err)
+				}
 			}
-			return &log, nil
+			return textBlock.Text, nil
 		}
 	}
+	entry.Outcome, entry.Error = "error", "no text content in API response"
+	c.audit.record(entry)
+	return "", fmt.Errorf("no text content in API response")
+}
 
-	return nil, fmt.Errorf("no text content in API response")
+// imageBlocks returns the content blocks for an image and its close-ups.
+// In a batch, n is the image's number, which labels it; otherwise it is 0.
+func (c *anthropicClient) imageBlocks(ctx context.Context, img batchImage, n int) ([]anthropic.BetaContentBlockParamUnion, error) {
+	image, err := c.files.image(ctx, img.MediaType, img.Data)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		if len(img.Crops) == 0 {
+			return []anthropic.BetaContentBlockParamUnion{image}, nil
+		}
+		blocks := []anthropic.BetaContentBlockParamUnion{image, anthropic.NewBetaTextBlock(cropIntro)}
+		for _, cr := range img.Crops {
+			blocks = append(blocks, anthropic.NewBetaTextBlock("Close-up of "+cr.Label+":"), cropBlock(cr))
+		}
+		return blocks, nil
+	}
+	blocks := []anthropic.BetaContentBlockParamUnion{anthropic.NewBetaTextBlock(fmt.Sprintf("Photo %d:", n)), image}
+	if len(img.Crops) > 0 {
+		blocks = append(blocks, anthropic.NewBetaTextBlock(fmt.Sprintf("%s These are of photo %d.", cropIntro, n)))
+	}
+	for _, cr := range img.Crops {
+		blocks = append(blocks, anthropic.NewBetaTextBlock(fmt.Sprintf("Close-up of %s in photo %d:", cr.Label, n)), cropBlock(cr))
+	}
+	return blocks, nil
 }
 
 // --- csv output ---------------------------------------------------------
 
-// writeCSV writes the parsed reading logs to a CSV file.
+// writeCSV writes the parsed reading logs to a CSV file in the store.
 func writeCSV(filename string, logs []ReadingLog) error {
-	file, err := os.Create(filename)
+	file, err := createStoreFile(store, filename)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := encodeCSV(file, logs); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
 
-	// Header row
-	header := []string{
-		"Full Name", "Grade", "Homeroom Teacher",
-		"Friday 1/30", "Saturday 1/31", "Sunday 2/1",
-		"Monday 2/2", "Tuesday 2/3", "Wednesday 2/4", "Thursday 2/5",
-		"Total Minutes",
+// encodeCSV writes logs to w as CSV, with a header row, and with summary
+// rows after them if configured.
+func encodeCSV(w io.Writer, logs []ReadingLog) error {
+	cols := csvColumns(logs)
+	writer, err := newCSVWriter(w)
+	if err != nil {
+		return err
 	}
-	if err := writer.Write(header); err != nil {
+	if err := writer.Write(csvHeader(cols)); err != nil {
 		return err
 	}
-
-	// Data rows
 	for _, log := range logs {
-		total := 0
-		for _, e := range log.ReadingEntries {
-			total += e.Minutes
-		}
-		row := []string{
-			log.FullName,
-			log.Grade,
-			log.HomeroomTeacher,
-			formatMinutes(log.ReadingEntries, "1/30"),
-			formatMinutes(log.ReadingEntries, "1/31"),
-			formatMinutes(log.ReadingEntries, "2/1"),
-			formatMinutes(log.ReadingEntries, "2/2"),
-			formatMinutes(log.ReadingEntries, "2/3"),
-			formatMinutes(log.ReadingEntries, "2/4"),
-			formatMinutes(log.ReadingEntries, "2/5"),
-			fmt.Sprintf("%d", total),
-		}
-		if err := writer.Write(row); err != nil {
+		if err := writer.Write(csvRow(log, cols)); err != nil {
 			return err
 		}
 	}
+	if cfg.Summary {
+		return writer.WriteAll(summaryRows(logs, cols))
+	}
+	writer.Flush()
+	return writer.Error()
+}
 
-	return nil
+// csvHeader returns the header row shared by all CSV exports, with one
+// column per day in cols.
+func csvHeader(cols []DayConfig) []string {
+	header := []string{"Full Name", "Grade", "Homeroom Teacher"}
+	for _, d := range cols {
+		header = append(header, d.Day+" "+d.Date)
+	}
+	header = append(header, "Total Minutes")
+	if cfg.hasGoals() {
+		header = append(header, "Goal", "Goal Met")
+	}
+	header = append(header, "Books", "Notes")
+	for _, f := range allFields() {
+		header = append(header, f.Name)
+	}
+	return append(header, "Flags", "Source File", "Photo Taken")
+}
+
+// csvRow flattens a single reading log into a CSV row matching csvHeader.
+func csvRow(log ReadingLog, cols []DayConfig) []string {
+	total := 0
+	for _, e := range log.ReadingEntries {
+		total += e.Minutes
+	}
+	row := []string{log.FullName, log.Grade, log.HomeroomTeacher}
+	for _, d := range cols {
+		row = append(row, formatMinutes(log.ReadingEntries, d.Date))
+	}
+	row = append(row, fmt.Sprintf("%d", total))
+	if cfg.hasGoals() {
+		goal, met := goalColumns(log.Grade, total)
+		row = append(row, goal, met)
+	}
+	row = append(row, bookTitles(log), log.Notes)
+	for _, f := range allFields() {
+		row = append(row, fieldColumn(log, f))
+	}
+	return append(row, formatFlags(log.Flags), log.Source, log.PhotoTaken)
+}
+
+// formatFlags renders review flags for a single CSV cell.
+func formatFlags(flags []Flag) string {
+	parts := make([]string, len(flags))
+	for i, f := range flags {
+		parts[i] = f.Check + ": " + f.Detail
+	}
+	return strings.Join(parts, "; ")
+}
+
+// csvColumns returns the day columns for logs: every date that appears in
+// any entry, in chronological order. With no entries at all, the configured
+// days are used so the header still matches the form.
+func csvColumns(logs []ReadingLog) []DayConfig {
+	var cs columnSet
+	for _, log := range logs {
+		cs.add(log)
+	}
+	return cs.sorted()
+}
+
+// columnSet accumulates the distinct dates seen across reading logs.
+type columnSet struct {
+	seen map[string]bool
+	cols []DayConfig
+}
+
+func (c *columnSet) add(log ReadingLog) {
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	for _, e := range log.ReadingEntries {
+		key := dateKey(e.Date)
+		if !c.seen[key] {
+			c.seen[key] = true
+			c.cols = append(c.cols, DayConfig{Day: e.Day, Date: e.Date})
+		}
+	}
+}
+
+// sorted returns the columns in chronological order. Dates without a year
+// are ordered by month and day, starting after the largest gap so a week
+// spanning New Year reads 12/30, 12/31, 1/1. Unparseable dates go last.
+func (c *columnSet) sorted() []DayConfig {
+	if len(c.cols) == 0 {
+		return cfg.Days
+	}
+	type dated struct {
+		col DayConfig
+		ord int
+		ok  bool
+	}
+	ds := make([]dated, len(c.cols))
+	hasYear := false
+	for i, col := range c.cols {
+		year, month, day, ok := parseDate(col.Date)
+		ds[i] = dated{col, year*372 + month*31 + day, ok}
+		hasYear = hasYear || year > 0
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		if ds[i].ok != ds[j].ok {
+			return ds[i].ok
+		}
+		if !ds[i].ok {
+			return ds[i].col.Date < ds[j].col.Date
+		}
+		return ds[i].ord < ds[j].ord
+	})
+
+	n := 0 // parseable dates, all at the front
+	for n < len(ds) && ds[n].ok {
+		n++
+	}
+	start := 0
+	if !hasYear && n > 1 {
+		gap := ds[0].ord + 372 - ds[n-1].ord // wrapping from the last date to the first
+		for i := 1; i < n; i++ {
+			if g := ds[i].ord - ds[i-1].ord; g > gap {
+				gap, start = g, i
+			}
+		}
+	}
+
+	cols := make([]DayConfig, 0, len(ds))
+	for i := range n {
+		col := ds[(start+i)%n].col
+		col.Date = shortDate(col.Date)
+		cols = append(cols, col)
+	}
+	for _, d := range ds[n:] {
+		cols = append(cols, d.col)
+	}
+	return cols
+}
+
+// parseDate reads a date as written on the form (M/D or M/D/YYYY, a month
+// name and day such as "Jan 30" or "30 January 2026") or as stored
+// (YYYY-MM-DD). The year is 0 when absent.
+func parseDate(s string) (year, month, day int, ok bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Year(), int(t.Month()), t.Day(), true
+	}
+	if !strings.Contains(s, "/") {
+		return parseMonthName(s)
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, 0, false
+	}
+	month, err1 := strconv.Atoi(parts[0])
+	day, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || month < 1 || month > 12 || day < 1 || day > 31 {
+		return 0, 0, 0, false
+	}
+	if len(parts) == 3 {
+		y, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		if y < 100 {
+			y += 2000
+		}
+		year = y
+	}
+	return year, month, day, true
+}
+
+// parseMonthName reads a date written with the month's name, in English or
+// Spanish, such as "Jan 30", "Jan. 30th", "30 January 2026", or "30 de
+// enero".
+func parseMonthName(s string) (year, month, day int, ok bool) {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == ',' || r == '.' || r == '-'
+	})
+	words = slices.DeleteFunc(words, func(w string) bool { return w == "de" || w == "del" })
+	if len(words) < 2 || len(words) > 3 {
+		return 0, 0, 0, false
+	}
+	for _, w := range words {
+		if m := monthOf(w); m != 0 && month == 0 {
+			month = m
+			continue
+		}
+		digits := strings.TrimRight(w, "stndrh") // 30th, 1st
+		n, err := strconv.Atoi(digits)
+		switch {
+		case err != nil:
+			return 0, 0, 0, false
+		case len(digits) == 4 && year == 0:
+			year = n
+		case n >= 1 && n <= 31 && day == 0:
+			day = n
+		default:
+			return 0, 0, 0, false
+		}
+	}
+	return year, month, day, month != 0 && day != 0
+}
+
+// spanishMonths are the months' names in Spanish, from January.
+var spanishMonths = []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}
+
+// monthOf returns the number of the month a word names in English or
+// Spanish, in full or cut short to at least three letters ("Jan", "Sept",
+// "ene"), or 0.
+func monthOf(w string) int {
+	if len(w) < 3 {
+		return 0
+	}
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), w) || strings.HasPrefix(spanishMonths[m-1], w) || (m == time.September && w == "setiembre") {
+			return int(m)
+		}
+	}
+	return 0
+}
+
+// dateKey normalizes a date for comparison, so "1/30", "01/30", "Jan 30",
+// and "2026-01-30" match. The year is left out, so that a date stored with
+// its year matches the same day written without one; a season never has
+// the same day twice.
+func dateKey(s string) string {
+	_, month, day, ok := parseDate(s)
+	if !ok {
+		return strings.TrimSpace(s)
+	}
+	return fmt.Sprintf("%d/%d", month, day)
+}
+
+// shortDate returns a date as M/D, as it is written on the form and in
+// column headings, or as it is if it isn't a date.
+func shortDate(s string) string {
+	_, month, day, ok := parseDate(s)
+	if !ok {
+		return s
+	}
+	return fmt.Sprintf("%d/%d", month, day)
 }
 
 // formatMinutes looks up the reading minutes for a given date and returns it as a string.
 func formatMinutes(entries []ReadingEntry, date string) string {
+	key := dateKey(date)
 	for _, e := range entries {
-		if e.Date == date {
+		if dateKey(e.Date) == key {
 			if e.Minutes > 0 {
 				return fmt.Sprintf("%d", e.Minutes)
 			}
@@ -471,13 +1947,28 @@ func formatMinutes(entries []ReadingEntry, date string) string {
 
 // --- json schema --------------------------------------------------------
 
-// generateJSONSchema produces a JSON Schema map from a Go struct using invopop/jsonschema.
-func generateJSONSchema(t any) map[string]any {
+// reflectSchema produces a JSON Schema from a Go struct using invopop/jsonschema.
+func reflectSchema(t any) *jsonschema.Schema {
 	reflector := jsonschema.Reflector{
 		AllowAdditionalProperties: false,
 		DoNotReference:            true,
 	}
-	schema := reflector.Reflect(t)
+	return reflector.Reflect(t)
+}
+
+// extractionSchema is the schema the model is asked to fill in. schema print
+// documents the same value, so the two can never drift apart.
+func extractionSchema(form FormTemplate) *jsonschema.Schema {
+	schema := reflectSchema(&ReadingLog{})
+	// Blank is omitted from stored results, but the model must always answer.
+	schema.Required = append([]string{"blank"}, schema.Required...)
+	addExtraFields(schema, form.fields())
+	return schema
+}
+
+// generateJSONSchema converts a schema to the map form the structured outputs
+// API expects.
+func generateJSONSchema(schema *jsonschema.Schema) map[string]any {
 	schemaBytes, err := json.Marshal(schema)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected end-to-end output in testdata/e2e/expected")
+
+// TestMain runs the program itself instead of the tests when the end-to-end
+// test starts the test binary as a subprocess, so each run gets fresh
+// globals and can exit as the real binary would.
+func TestMain(m *testing.M) {
+	if os.Getenv("READING_LOGS_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// e2eDir holds the end-to-end test's images, configuration, and expected
+// output.
+const e2eDir = "testdata/e2e"
+
+// TestE2E runs the whole pipeline — finding, encoding, parsing, progress, and
+// CSV — over the images in testdata/e2e/images with the fake client, and
+// compares the CSV and progress file with testdata/e2e/expected. After a
+// change that is meant to alter the output, run it with -update and review
+// the expected files in the diff.
+func TestE2E(t *testing.T) {
+	dir := t.TempDir()
+	copyE2EImages(t, dir, 1)
+	out := runE2E(t, dir, "--fake", "--verify")
+
+	for _, name := range []string{"reading_logs.csv", ".progress.json"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("run wrote no %s: %v\n%s", name, err, out)
+		}
+		path := filepath.Join(e2eDir, "expected", name)
+		if *update {
+			if err := os.WriteFile(path, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from %s; if the change is intended, rerun with -update\n--- got ---\n%s", name, path, got)
+		}
+	}
+}
+
+// TestE2EConcurrent reads several copies of the end-to-end images with
+// several workers at once and checks the output is the same as reading them
+// one at a time. Run with -race (make race), it also catches workers and
+// the loop that starts them touching progress together.
+func TestE2EConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("reads the images twice")
+	}
+	serial, concurrent := t.TempDir(), t.TempDir()
+	copyE2EImages(t, serial, 5)
+	copyE2EImages(t, concurrent, 5)
+	runE2E(t, serial, "--fake", "--verify", "--concurrency", "1")
+	out := runE2E(t, concurrent, "--fake", "--verify", "--concurrency", "4")
+
+	for _, name := range []string{"reading_logs.csv", ".progress.json"} {
+		want, err := os.ReadFile(filepath.Join(serial, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(concurrent, name))
+		if err != nil {
+			t.Fatalf("run wrote no %s: %v\n%s", name, err, out)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s with --concurrency 4 differs from --concurrency 1\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+		}
+	}
+}
+
+// copyE2EImages copies the end-to-end images into dir copies times over.
+// Each copy after the first gets a different name and a few bytes after the
+// end of the image, so it is read as an image of its own.
+func copyE2EImages(t *testing.T, dir string, copies int) {
+	t.Helper()
+	images, err := filepath.Glob(filepath.Join(e2eDir, "images", "*"))
+	if err != nil || len(images) == 0 {
+		t.Fatalf("no images in %s/images: %v", e2eDir, err)
+	}
+	// The photo dates come from the files' modification times.
+	taken := time.Date(2026, time.January, 5, 8, 0, 0, 0, time.UTC)
+	for c := range copies {
+		for _, src := range images {
+			data, err := os.ReadFile(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := filepath.Base(src)
+			if c > 0 {
+				ext := filepath.Ext(name)
+				name = fmt.Sprintf("%s-copy%d%s", name[:len(name)-len(ext)], c, ext)
+				data = append(data, fmt.Sprintf("copy %d", c)...)
+			}
+			dst := filepath.Join(dir, name)
+			if err := os.WriteFile(dst, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(dst, taken, taken); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// runE2E runs the program with args in dir, using the end-to-end
+// configuration, and returns what it printed.
+func runE2E(t *testing.T, dir string, args ...string) []byte {
+	t.Helper()
+	config, err := filepath.Abs(filepath.Join(e2eDir, "readinglogs.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "READING_LOGS_TEST_MAIN=1", "READING_LOGS_CONFIG="+config, "READING_LOGS_PASSPHRASE=", "TZ=UTC", "LC_ALL=C")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run %v failed: %v\n%s", args, err, out)
+	}
+	return out
+}
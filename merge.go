@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runMerge combines progress files from other machines, such as two
+// volunteers who each read half the photos on their own laptop, into the
+// progress file here. Where two files read an image differently, the result
+// already here (or from the earlier file) is kept and flagged for review
+// with how the other file differs.
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	forceUnlock := lockFlag(fs)
+	outputFlags(fs)
+	files := parseArgs(fs, args)
+	if len(files) == 0 {
+		return fmt.Errorf("usage: merge <progress file>...")
+	}
+	if err := lockProgress(*forceUnlock); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	here, _ := os.Stat(progressFile)
+	conflicts := 0
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if here != nil && os.SameFile(info, here) {
+			continue // already merged into
+		}
+		data, err := readLocalFile(file)
+		if err != nil {
+			return err
+		}
+		other, _, err := decodeProgress(data)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", file, err)
+		}
+
+		added, differ := progress.merge(other)
+		green.Printf("  ✓ %s", file)
+		dim.Printf(" | %d new result(s), %d conflict(s)\n", added, len(differ))
+		keys := make([]string, 0, len(differ))
+		for key := range differ {
+			keys = append(keys, key)
+		}
+		for _, key := range progress.sortedByName(keys) {
+			yellow.Printf("    ⚑ %s\n", progress.nameOf(key))
+			for _, d := range differ[key] {
+				fmt.Printf("      %s %s\n", dim.Sprint("│"), d)
+				progress.Review[key] = append(progress.Review[key], Flag{Check: "merge", Detail: fmt.Sprintf("%s (vs %s)", d, file)})
+			}
+		}
+		conflicts += len(differ)
+	}
+
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf("could not save progress: %w", err)
+	}
+	logs := completedLogs(progress)
+	paths, err := writeOutput(logs)
+	if err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	if err := updateHistory(logs); err != nil {
+		return fmt.Errorf("could not update history: %w", err)
+	}
+	dim.Printf("  Refreshed %s with %d result(s)\n", strings.Join(paths, ", "), len(logs))
+	if conflicts > 0 {
+		yellow.Printf("  %d conflicting result(s) kept as first read and sent to the review queue\n", conflicts)
+	}
+	return nil
+}
+
+// merge adds the results in other that p doesn't have. For images both
+// read, p's results are kept; merge returns how other's differ, by record
+// key, along with the number of results added. Errors are kept only for
+// images neither read successfully.
+func (p *Progress) merge(other *Progress) (added int, conflicts map[string][]string) {
+	conflicts = make(map[string][]string)
+	mine, theirs := p.keysByImage(), other.keysByImage()
+	for id, name := range other.Images {
+		if _, ok := p.Images[id]; !ok {
+			p.Images[id] = name
+		}
+	}
+	// Stamps are left behind: modification times from another machine
+	// would only cause the images to be hashed again.
+
+	for id, keys := range theirs {
+		if _, ok := mine[id]; !ok {
+			for _, key := range keys {
+				p.Completed[key] = other.Completed[key]
+				if flags, ok := other.Review[key]; ok {
+					p.Review[key] = flags
+				}
+			}
+			delete(p.Blank, id)
+			added += len(keys)
+			continue
+		}
+
+		for _, key := range keys {
+			log, ok := p.Completed[key]
+			if !ok {
+				conflicts[id] = append(conflicts[id], fmt.Sprintf("%s was read only by the other file", p.nameOf(key)))
+				continue
+			}
+			if diffs := compareLogs(normalized(log), normalized(other.Completed[key])); len(diffs) > 0 {
+				conflicts[key] = append(conflicts[key], diffs...)
+			}
+			for _, f := range other.Review[key] {
+				if !hasFlag(p.Review[key], f) {
+					p.Review[key] = append(p.Review[key], f)
+				}
+			}
+		}
+		for _, key := range mine[id] {
+			if _, ok := other.Completed[key]; !ok {
+				conflicts[key] = append(conflicts[key], fmt.Sprintf("%s was not read by the other file", p.nameOf(key)))
+			}
+		}
+	}
+
+	for id := range other.Blank {
+		if _, ok := mine[id]; !ok {
+			p.Blank[id] = true
+		}
+	}
+	for name, sender := range other.Senders {
+		if _, ok := p.Senders[name]; !ok {
+			p.Senders[name] = sender
+		}
+	}
+	for guid, id := range other.Album {
+		if _, ok := p.Album[guid]; !ok {
+			p.Album[guid] = id
+		}
+	}
+	for name, err := range other.Errors {
+		if _, ok := p.Errors[name]; !ok {
+			p.Errors[name] = err
+			if kind, ok := other.Failures[name]; ok {
+				p.Failures[name] = kind
+			}
+		}
+	}
+	ids := p.idsByName()
+	for name := range p.Errors {
+		id := ids[name]
+		if _, ok := p.Completed[id]; ok || p.Blank[id] {
+			delete(p.Errors, name)
+			delete(p.Failures, name)
+		}
+	}
+	return added, conflicts
+}
+
+// keysByImage returns the record keys of the completed results for each
+// image ID.
+func (p *Progress) keysByImage() map[string][]string {
+	keys := make(map[string][]string)
+	for key := range p.Completed {
+		keys[imageOf(key)] = append(keys[imageOf(key)], key)
+	}
+	return keys
+}
+
+// normalized returns a normalized copy of log, leaving the original's
+// entries untouched.
+func normalized(log ReadingLog) ReadingLog {
+	log.ReadingEntries = append([]ReadingEntry(nil), log.ReadingEntries...)
+	normalizeLog(&log)
+	return log
+}
+
+// hasFlag reports whether flags already include f.
+func hasFlag(flags []Flag, f Flag) bool {
+	for _, g := range flags {
+		if g == f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package main
+
+// spanishMessages are the messages shown with --lang es, keyed by their
+// English text.
+var spanishMessages = map[string]string{
+	// Errors and warnings
+	"Warning: %s": "Aviso: %s",
+
+	"could not save progress":                          "no se pudo guardar el progreso",
+	"could not update history":                         "no se pudo actualizar el historial",
+	"could not archive images":                         "no se pudieron archivar las imágenes",
+	"could not write audit log":                        "no se pudo escribir el registro de auditoría",
+	"could not write the report":                       "no se pudo escribir el informe",
+	"could not keep a copy of this run":                "no se pudo guardar una copia de esta ejecución",
+	"could not save recording":                         "no se pudo guardar la grabación",
+	"on_record hook failed":                            "falló el hook on_record",
+	"on_complete hook failed":                          "falló el hook on_complete",
+	"live display failed":                              "falló la pantalla en vivo",
+	"writing output":                                   "al escribir la salida",
+	"finding images":                                   "al buscar las imágenes",
+	"--dry-run can't be used with --json":              "--dry-run no se puede usar con --json",
+	"could not save progress: %w":                      "no se pudo guardar el progreso: %w",
+	"could not write output: %w":                       "no se pudo escribir la salida: %w",
+	"could not update history: %w":                     "no se pudo actualizar el historial: %w",
+	"could not keep %s as an example: %w":              "no se pudo guardar %s como ejemplo: %w",
+	"corrected result: %w":                             "resultado corregido: %w",
+	"no completed result for %s":                       "no hay un resultado completo para %s",
+	"editing needs a terminal; run without --headless": "para editar se necesita una terminal; ejecútelo sin --headless",
+
+	// Runs
+	"Resuming: %d of %d already completed": "Reanudando: %d de %d ya completadas",
+	"%s images to process":                 "%s imágenes por procesar",
+	"Wrote %d reading log(s) to %s":        "Se escribieron %d registro(s) de lectura en %s",
+	"in %s":                                "en %s",
+
+	// Summaries
+	"Summary":                   "Resumen",
+	"Images found":              "Imágenes encontradas",
+	"Already done":              "Ya hechas",
+	"Newly processed":           "Procesadas ahora",
+	"Blank forms":               "Formularios en blanco",
+	"(no result recorded)":      "(no se guardó resultado)",
+	"Failed":                    "Con error",
+	"Flagged":                   "Marcadas",
+	"(run `review` to see why)": "(ejecute `review` para ver por qué)",
+	"API usage":                 "Uso de la API",
+	"Requests":                  "Solicitudes",
+	"Input tokens":              "Tokens de entrada",
+	"Cache reads":               "Lecturas de caché",
+	"(%.0f%% of input)":         "(%.0f%% de la entrada)",
+	"Cache writes":              "Escrituras de caché",
+	"Output tokens":             "Tokens de salida",
+	"Estimated cost":            "Costo estimado",
+
+	// Review
+	"Nothing to review":                "Nada que revisar",
+	"%d result(s) flagged for review":  "%d resultado(s) marcado(s) para revisión",
+	"| %s | verified":                  "| %s | verificado",
+	"| %s | corrected":                 "| %s | corregido",
+	"No changes":                       "Sin cambios",
+	"Kept as an example in %s":         "Guardado como ejemplo en %s",
+	"Editing %s in %s":                 "Editando %s en %s",
+	"usage: review approve <image>...": "uso: review approve <imagen>...",
+	"Not kept as an example: the image has more than one student's log":              "No se guardó como ejemplo: la imagen tiene el registro de más de un estudiante",
+	"usage: review [--preview] | review approve <image>... | review correct <image>": "uso: review [--preview] | review approve <imagen>... | review correct <imagen>",
+	"usage: review correct <image> [--from file.json] [--no-example]":                "uso: review correct <imagen> [--from archivo.json] [--no-example]",
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"time"
+)
+
+// errMalformed is returned by the metadata strippers for an image whose
+// structure they can't follow.
+var errMalformed = errors.New("malformed image")
+
+// EXIF tags read from photos.
+const (
+	exifOrientation        = 0x0112 // which way up the photo was taken
+	exifPointer            = 0x8769 // where the EXIF directory is
+	exifDateTimeOriginal   = 0x9003 // when the photo was taken, in the camera's time
+	exifOffsetTimeOriginal = 0x9011 // and that time's UTC offset, e.g. -08:00
+)
+
+// stripMetadata returns an image's bytes without the metadata phones and
+// cameras write into it: EXIF, with the GPS position and device details,
+// XMP, IPTC, comments, and PNG text chunks. Only the EXIF orientation is
+// kept, so the image still shows the right way up. JPEG, PNG, and WebP are
+// rewritten without re-encoding the picture; any other format, such as GIF,
+// is returned as it is. An image whose structure can't be followed is
+// decoded and encoded again, which drops everything.
+func stripMetadata(data []byte) ([]byte, error) {
+	var stripped []byte
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		stripped, err = stripJPEG(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		stripped, err = stripPNG(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		stripped, err = stripWebP(data)
+	default:
+		return data, nil
+	}
+	if errors.Is(err, errMalformed) {
+		return reencode(data)
+	}
+	return stripped, err
+}
+
+// reencode decodes an image and encodes it again in the same format, which
+// leaves all metadata behind.
+func reencode(data []byte) ([]byte, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not remove metadata: %w", err)
+	}
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	case "png":
+		err = png.Encode(&buf, img)
+	default:
+		return nil, fmt.Errorf("could not remove metadata from a %s image", format)
+	}
+	return buf.Bytes(), err
+}
+
+// stripJPEG keeps the JFIF and Adobe segments and any ICC color profile,
+// which affect how the picture is drawn, and drops every other application
+// segment and comment before the image data.
+func stripJPEG(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 0
+	var kept [][]byte
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, errMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++ // fill byte
+			continue
+		}
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			kept = append(kept, data[i:i+2]) // no length
+			i += 2
+			continue
+		}
+		if marker == 0xDA { // start of scan: the rest is image data
+			kept = append(kept, data[i:])
+			break
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return nil, errMalformed
+		}
+		segment, payload := data[i:i+2+n], data[i+4:i+2+n]
+		i += 2 + n
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+			if o := exifOrientationOf(payload[6:]); o != 0 {
+				orientation = o
+			}
+		case marker == 0xE0, marker == 0xEE,
+			marker == 0xE2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")):
+			kept = append(kept, segment)
+		case marker >= 0xE0 && marker <= 0xEF, marker == 0xFE:
+			// other application data, and comments
+		default:
+			kept = append(kept, segment)
+		}
+	}
+
+	// EXIF goes after a JFIF segment, if there is one.
+	if len(kept) > 0 && kept[0][1] == 0xE0 {
+		out.Write(kept[0])
+		kept = kept[1:]
+	}
+	if orientation > 1 {
+		exif := append([]byte("Exif\x00\x00"), orientationTIFF(orientation)...)
+		out.Write([]byte{0xFF, 0xE1})
+		binary.Write(out, binary.BigEndian, uint16(len(exif)+2))
+		out.Write(exif)
+	}
+	for _, segment := range kept {
+		out.Write(segment)
+	}
+	return out.Bytes(), nil
+}
+
+// stripPNG drops the text, time, and EXIF chunks, putting back an EXIF
+// chunk with only the orientation if one was given.
+func stripPNG(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8])
+	for i := 8; i < len(data); {
+		if i+12 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		if n < 0 || i+12+n > len(data) {
+			return nil, errMalformed
+		}
+		kind, chunk := string(data[i+4:i+8]), data[i:i+12+n]
+		i += 12 + n
+		switch kind {
+		case "tEXt", "zTXt", "iTXt", "tIME":
+		case "eXIf":
+			if o := exifOrientationOf(chunk[8 : 8+n]); o > 1 {
+				writePNGChunk(out, "eXIf", orientationTIFF(o))
+			}
+		default:
+			out.Write(chunk)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// writePNGChunk writes a PNG chunk with its length and checksum.
+func writePNGChunk(out *bytes.Buffer, kind string, payload []byte) {
+	binary.Write(out, binary.BigEndian, uint32(len(payload)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(payload)
+	out.WriteString(kind)
+	out.Write(payload)
+	binary.Write(out, binary.BigEndian, crc.Sum32())
+}
+
+// stripWebP drops the EXIF and XMP chunks and clears the flags that
+// announce them. WebP viewers ignore the EXIF orientation, so none is kept.
+func stripWebP(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + n + n%2 // chunks are padded to an even length
+		if n < 0 || end > len(data) {
+			return nil, errMalformed
+		}
+		kind, chunk := string(data[i:i+4]), data[i:end]
+		i = end
+		switch kind {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk = bytes.Clone(chunk)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present
+			}
+			out.Write(chunk)
+		default:
+			out.Write(chunk)
+		}
+	}
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
+
+// exifOrientationOf returns the orientation recorded in EXIF data, or 0 if
+// there is none.
+func exifOrientationOf(exif []byte) int {
+	t, ok := parseTIFF(exif)
+	if !ok {
+		return 0
+	}
+	e, ok := t.find(t.first(), exifOrientation)
+	if !ok {
+		return 0
+	}
+	if o := int(t.order.Uint16(e.value)); o >= 1 && o <= 8 {
+		return o
+	}
+	return 0
+}
+
+// exifTaken returns when a photo was taken, from the DateTimeOriginal in
+// its EXIF data, as 2006-01-02T15:04:05 with the UTC offset added if the
+// camera recorded one. It is empty if the photo doesn't say.
+func exifTaken(exif []byte) string {
+	t, ok := parseTIFF(exif)
+	if !ok {
+		return ""
+	}
+	ptr, ok := t.find(t.first(), exifPointer)
+	if !ok {
+		return ""
+	}
+	sub := int(t.order.Uint32(ptr.value))
+	taken, err := time.Parse("2006:01:02 15:04:05", t.text(sub, exifDateTimeOriginal))
+	if err != nil || taken.Year() < 1990 {
+		return "" // missing, or a camera whose clock was never set
+	}
+	stamp := taken.Format("2006-01-02T15:04:05")
+	if offset := t.text(sub, exifOffsetTimeOriginal); len(offset) == 6 && (offset[0] == '+' || offset[0] == '-') {
+		stamp += offset
+	}
+	return stamp
+}
+
+// photoTaken returns when the photo in an image was taken, as exifTaken
+// does.
+func photoTaken(data []byte) string {
+	return exifTaken(exifOf(data))
+}
+
+// exifOf returns the EXIF data of a JPEG, PNG, WebP, HEIC, or TIFF image,
+// or nil.
+func exifOf(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte("MM\x00*")) || bytes.HasPrefix(data, []byte("II*\x00")):
+		// A TIFF file is laid out as EXIF data is; its first directory
+		// holds the same tags.
+		return data
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		// HEIC keeps EXIF as an item whose data starts "Exif\0\0"; rather
+		// than follow the item tables, look for it followed by a TIFF header.
+		for rest := data; ; {
+			i := bytes.Index(rest, []byte("Exif\x00\x00"))
+			if i < 0 {
+				break
+			}
+			rest = rest[i+6:]
+			if bytes.HasPrefix(rest, []byte("MM\x00*")) || bytes.HasPrefix(rest, []byte("II*\x00")) {
+				return rest
+			}
+		}
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		for i := 2; i+4 <= len(data) && data[i] == 0xFF && data[i+1] != 0xDA; {
+			n := int(binary.BigEndian.Uint16(data[i+2:]))
+			if n < 2 || i+2+n > len(data) {
+				break
+			}
+			if payload := data[i+4 : i+2+n]; data[i+1] == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				return payload[6:]
+			}
+			i += 2 + n
+		}
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		for i := 8; i+12 <= len(data); {
+			n := int(binary.BigEndian.Uint32(data[i:]))
+			if n < 0 || i+12+n > len(data) {
+				break
+			}
+			if string(data[i+4:i+8]) == "eXIf" {
+				return data[i+8 : i+8+n]
+			}
+			i += 12 + n
+		}
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		for i := 12; i+8 <= len(data); {
+			n := int(binary.LittleEndian.Uint32(data[i+4:]))
+			if n < 0 || i+8+n > len(data) {
+				break
+			}
+			if string(data[i:i+4]) == "EXIF" {
+				return bytes.TrimPrefix(data[i+8:i+8+n], []byte("Exif\x00\x00"))
+			}
+			i += 8 + n + n%2
+		}
+	}
+	return nil
+}
+
+// tiff is EXIF data, which is laid out as a TIFF file: directories of
+// tagged entries, with values too long for an entry stored elsewhere.
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// tiffEntry is one entry in a TIFF directory. value holds the value itself
+// if it fits in four bytes, and otherwise where it is.
+type tiffEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// parseTIFF reads the header of EXIF data.
+func parseTIFF(data []byte) (tiff, bool) {
+	if len(data) < 8 {
+		return tiff{}, false
+	}
+	switch string(data[:2]) {
+	case "II":
+		return tiff{data, binary.LittleEndian}, true
+	case "MM":
+		return tiff{data, binary.BigEndian}, true
+	}
+	return tiff{}, false
+}
+
+// first returns where the first directory is.
+func (t tiff) first() int {
+	return int(t.order.Uint32(t.data[4:]))
+}
+
+// find returns the entry for tag in the directory at offset.
+func (t tiff) find(offset int, tag uint16) (tiffEntry, bool) {
+	if offset < 8 || offset+2 > len(t.data) {
+		return tiffEntry{}, false
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	for e := offset + 2; e+12 <= len(t.data) && count > 0; e, count = e+12, count-1 {
+		if t.order.Uint16(t.data[e:]) == tag {
+			return tiffEntry{kind: t.order.Uint16(t.data[e+2:]), count: t.order.Uint32(t.data[e+4:]), value: t.data[e+8 : e+12]}, true
+		}
+	}
+	return tiffEntry{}, false
+}
+
+// text returns the ASCII value of tag in the directory at offset, or "".
+func (t tiff) text(offset int, tag uint16) string {
+	e, ok := t.find(offset, tag)
+	if !ok || e.kind != 2 {
+		return ""
+	}
+	value := e.value[:min(int(e.count), 4)]
+	if e.count > 4 {
+		at := int(t.order.Uint32(e.value))
+		if at < 0 || at+int(e.count) > len(t.data) {
+			return ""
+		}
+		value = t.data[at : at+int(e.count)]
+	}
+	return strings.TrimRight(string(value), "\x00 ")
+}
+
+// orientationTIFF returns EXIF data, laid out as a TIFF file, holding only
+// an orientation.
+func orientationTIFF(orientation int) []byte {
+	var b bytes.Buffer
+	b.WriteString("MM\x00\x2a")
+	binary.Write(&b, binary.BigEndian, uint32(8)) // first directory
+	binary.Write(&b, binary.BigEndian, uint16(1)) // one entry
+	binary.Write(&b, binary.BigEndian, []uint16{exifOrientation, 3})
+	binary.Write(&b, binary.BigEndian, uint32(1)) // one SHORT
+	binary.Write(&b, binary.BigEndian, []uint16{uint16(orientation), 0})
+	binary.Write(&b, binary.BigEndian, uint32(0)) // no further directories
+	return b.Bytes()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// modelAliases are the short names --model accepts for the current models.
+var modelAliases = map[string]anthropic.Model{
+	"fast":     anthropic.ModelClaudeHaiku4_5_20251001,
+	"balanced": anthropic.ModelClaudeSonnet4_5_20250929,
+	"accurate": anthropic.ModelClaudeOpus4_5_20251101,
+}
+
+// overloadLimit is how many requests may fail as overloaded, each retried
+// on the fallback model, before the fallback is used for the rest of the run.
+const overloadLimit = 3
+
+// resolveModel returns the model an alias stands for, or name unchanged.
+func resolveModel(name string) anthropic.Model {
+	if m, ok := modelAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return m
+	}
+	return anthropic.Model(name)
+}
+
+// fallbackModel returns the model to use when model is overloaded: the
+// configured one, "none" for no fallback, or by default the balanced model
+// (the accurate one if model is already the balanced one).
+func fallbackModel(configured string, model anthropic.Model) anthropic.Model {
+	switch {
+	case strings.EqualFold(configured, "none"):
+		return ""
+	case configured != "":
+		return resolveModel(configured)
+	case model == modelAliases["balanced"]:
+		return modelAliases["accurate"]
+	default:
+		return modelAliases["balanced"]
+	}
+}
+
+// isOverloaded reports whether a request failed because the API is
+// overloaded, after the SDK's own retries.
+func isOverloaded(err error) bool {
+	var apiErr *anthropic.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == 529
+}
+
+// send makes a request on the client's model. A request that fails as
+// overloaded is tried again on the fallback model, and once overloadLimit
+// requests have, the client switches to the fallback for good. It returns
+// the model that answered. Requests are paced by the client's rate limiter.
+func (c *anthropicClient) send(ctx context.Context, params anthropic.BetaMessageNewParams, opts ...option.RequestOption) (*anthropic.BetaMessage, anthropic.Model, error) {
+	c.mu.Lock()
+	params.Model = c.model
+	c.mu.Unlock()
+	opts = append(opts, option.WithMiddleware(c.limit.middleware))
+	msg, err := c.client.Beta.Messages.New(ctx, params, opts...)
+	if !isOverloaded(err) || c.fallback == "" || params.Model == c.fallback {
+		return msg, params.Model, err
+	}
+
+	c.mu.Lock()
+	c.overloads++
+	if c.overloads == overloadLimit {
+		c.model = c.fallback
+		logger.Warn(fmt.Sprintf("%s is overloaded, switching to %s", params.Model, c.fallback), "model", params.Model, "fallback", c.fallback)
+	}
+	c.mu.Unlock()
+	params.Model = c.fallback
+	msg, err = c.client.Beta.Messages.New(ctx, params, opts...)
+	return msg, c.fallback, err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// multiPrompt is added to the prompt when images may show several students.
+const multiPrompt = `
+
+This photo may show more than one student's reading log, for example two forms side by side. Return one entry in logs for each form, in order from left to right and top to bottom.`
+
+// recordKey is the progress key for the i'th student's log on an image. The
+// first keeps the image's own name, so single-student images are unchanged.
+func recordKey(image string, i int) string {
+	if i == 0 {
+		return image
+	}
+	return fmt.Sprintf("%s#%d", image, i+1)
+}
+
+// imageOf returns the image a progress key's log was read from.
+func imageOf(key string) string {
+	if i := strings.LastIndex(key, "#"); i >= 0 {
+		if _, err := strconv.Atoi(key[i+1:]); err == nil {
+			return key[:i]
+		}
+	}
+	return key
+}
+
+// read asks client for the logs on an image: just one, or with --multi as
+// many as the image shows. With --batch-size, the pipeline's own client
+// reads it along with other images.
+func (p *pipeline) read(ctx context.Context, client Client, mediaType, encoded string, form FormTemplate) ([]*ReadingLog, error) {
+	if p.multi {
+		return client.ParseReadingLogs(ctx, mediaType, encoded, form)
+	}
+	parse := client.ParseReadingLog
+	if p.batch != nil && client == p.client {
+		parse = p.batch.parse
+	}
+	log, err := parse(ctx, mediaType, encoded, form)
+	if err != nil {
+		return nil, err
+	}
+	return []*ReadingLog{log}, nil
+}
+
+// recordsOf returns the keys of the completed records a key refers to: just
+// that one for a further student such as "#2", otherwise every student's
+// record read from the image.
+func (p *Progress) recordsOf(key string) []string {
+	if imageOf(key) != key {
+		if _, ok := p.Completed[key]; ok {
+			return []string{key}
+		}
+		return nil
+	}
+	var keys []string
+	for k := range p.Completed {
+		if imageOf(k) == key {
+			keys = append(keys, k)
+		}
+	}
+	return p.sortedByName(keys)
+}
+
+// ParseReadingLogs asks Claude for every reading log on an image.
+func (c *anthropicClient) ParseReadingLogs(ctx context.Context, mediaType, encodedImage string, form FormTemplate) ([]*ReadingLog, error) {
+	props := jsonschema.NewProperties()
+	props.Set("logs", &jsonschema.Schema{
+		Type:        "array",
+		Items:       extractionSchema(form),
+		Description: "One entry per reading log form in the image",
+	})
+	schema := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           props,
+		Required:             []string{"logs"},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+
+	prompt, err := renderPrompt(c.prompt, form)
+	if err != nil {
+		return nil, err
+	}
+	text, err := c.ask(ctx, "parse-multi", mediaType, encodedImage, prompt+multiPrompt, generateJSONSchema(schema))
+	if err != nil {
+		return nil, err
+	}
+	var answer struct {
+		Logs []*ReadingLog `json:"logs"`
+	}
+	if err := json.Unmarshal([]byte(text), &answer); err != nil {
+		return nil, fmt.Errorf("%w: %w\nraw: %s", errParse, err, text)
+	}
+	if len(answer.Logs) == 0 {
+		answer.Logs = []*ReadingLog{{Blank: true}}
+	}
+	answer.Logs[0].Raw = text
+	return answer.Logs, nil
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// normalizeLog canonicalizes the fields the model returns inconsistently, so
+// grouping and reports treat variants as the same value. The value as read
+// is kept alongside. It is safe to apply more than once.
+func normalizeLog(log *ReadingLog) {
+	if log.GradeRaw == "" {
+		log.GradeRaw = log.Grade
+	}
+	log.Grade = normalizeGrade(log.GradeRaw)
+	if log.GradeRaw == log.Grade {
+		log.GradeRaw = ""
+	}
+
+	if log.TeacherRaw == "" {
+		log.TeacherRaw = log.HomeroomTeacher
+	}
+	log.HomeroomTeacher = normalizeTeacher(log.TeacherRaw)
+	if log.TeacherRaw == log.HomeroomTeacher {
+		log.TeacherRaw = ""
+	}
+
+	// Trust our own reading of the written time over the model's arithmetic.
+	for i, e := range log.ReadingEntries {
+		if m, ok := parseDuration(e.Written); ok {
+			log.ReadingEntries[i].Minutes = m
+		}
+	}
+
+	days := formOf(*log).days()
+	near := weekOfForm(*log, days)
+	for i := range log.ReadingEntries {
+		e := &log.ReadingEntries[i]
+		if e.DateRaw == "" {
+			e.DateRaw = e.Date
+		}
+		if d, ok := dateNear(e.DateRaw, near); ok {
+			e.Date = d.Format("2006-01-02")
+		}
+		if e.DateRaw == e.Date {
+			e.DateRaw = ""
+		}
+		e.Day = normalizeDay(e.Day, e.Date, days)
+	}
+	for _, n := range normalizers {
+		n(log)
+	}
+}
+
+// flagWritten flags each day whose minutes, as the model gave them, differ
+// from the time it copied from the form, or whose time as written can't be
+// read as a duration. Either is a sign of a misread, such as 70 for a
+// written 20. It is run on a log as it comes back from the model, before
+// normalizeLog replaces the minutes with those of the time as written.
+func flagWritten(log *ReadingLog) {
+	for _, e := range log.ReadingEntries {
+		if strings.TrimSpace(e.Written) == "" {
+			continue
+		}
+		m, ok := parseDuration(e.Written)
+		switch {
+		case !ok:
+			log.Flags = append(log.Flags, Flag{Check: "written", Detail: fmt.Sprintf("%s %s: %q isn't a time; kept %d min", e.Day, shortDate(e.Date), e.Written, e.Minutes)})
+		case m != e.Minutes:
+			log.Flags = append(log.Flags, Flag{Check: "written", Detail: fmt.Sprintf("%s %s: written %q but read as %d min", e.Day, shortDate(e.Date), e.Written, e.Minutes)})
+		}
+	}
+}
+
+// weekOfForm returns when the week a log covers began, for giving a year to
+// dates written without one: the first of the form's days, in the year
+// it has or the configured year, or else the day the photo was taken, or
+// else six months ago. Each date takes the year that puts it closest, so a
+// week from 12/29 to 1/4 runs from one year into the next.
+func weekOfForm(log ReadingLog, days []DayConfig) time.Time {
+	if len(days) > 0 {
+		if year, month, day, ok := parseDate(days[0].Date); ok && (year != 0 || cfg.Year != 0) {
+			if year == 0 {
+				year = cfg.Year
+			}
+			return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		}
+	}
+	if len(log.PhotoTaken) >= 10 {
+		if taken, err := time.Parse("2006-01-02", log.PhotoTaken[:10]); err == nil {
+			return taken
+		}
+	}
+	// Logs are read after their week, so a date goes in the year before
+	// today rather than the next one.
+	return time.Now().AddDate(0, -6, 0)
+}
+
+// weekdays maps day names in English and Spanish, and their usual
+// abbreviations, to the English name.
+var weekdays = map[string]string{
+	"monday": "Monday", "mon": "Monday", "lunes": "Monday", "lun": "Monday",
+	"tuesday": "Tuesday", "tue": "Tuesday", "tues": "Tuesday", "martes": "Tuesday", "mar": "Tuesday",
+	"wednesday": "Wednesday", "wed": "Wednesday", "miércoles": "Wednesday", "miercoles": "Wednesday", "mié": "Wednesday", "mie": "Wednesday",
+	"thursday": "Thursday", "thu": "Thursday", "thur": "Thursday", "thurs": "Thursday", "jueves": "Thursday", "jue": "Thursday",
+	"friday": "Friday", "fri": "Friday", "viernes": "Friday", "vie": "Friday",
+	"saturday": "Saturday", "sat": "Saturday", "sábado": "Saturday", "sabado": "Saturday", "sáb": "Saturday", "sab": "Saturday",
+	"sunday": "Sunday", "sun": "Sunday", "domingo": "Sunday", "dom": "Sunday",
+}
+
+// weekdayOf returns the English name of a day of the week written in
+// English or Spanish ("Mon.", "lunes"), or "" if it isn't one.
+func weekdayOf(s string) string {
+	return weekdays[strings.TrimRight(strings.ToLower(strings.TrimSpace(s)), ".")]
+}
+
+// normalizeDay returns the name for an entry's day: the form's own name for
+// the day on its date, so a Spanish form's "lunes" is the Monday of an
+// English one, or else the English name of the day as read, or else the
+// day as read.
+func normalizeDay(day, date string, days []DayConfig) string {
+	for _, d := range days {
+		if dateKey(d.Date) == dateKey(date) {
+			return d.Day
+		}
+	}
+	if name := weekdayOf(day); name != "" {
+		return name
+	}
+	return day
+}
+
+// gradeWords maps spelled-out and abbreviated grades to their canonical form.
+var gradeWords = map[string]string{
+	"k": "K", "kg": "K", "kdg": "K", "kinder": "K", "kindergarten": "K", "kindergarden": "K",
+	"tk": "TK", "transitional kindergarten": "TK", "transitional k": "TK",
+	"pk": "PK", "prek": "PK", "pre k": "PK", "prekindergarten": "PK", "pre kindergarten": "PK", "preschool": "PK",
+	"first": "1", "second": "2", "third": "3", "fourth": "4", "fifth": "5", "sixth": "6",
+	"seventh": "7", "eighth": "8", "ninth": "9", "tenth": "10", "eleventh": "11", "twelfth": "12",
+	"one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "ten": "10", "eleven": "11", "twelve": "12",
+	// Spanish
+	"kínder": "K", "preescolar": "PK", "prekinder": "PK", "prekínder": "PK", "pre kinder": "PK", "pre kínder": "PK",
+	"primero": "1", "primer": "1", "segundo": "2", "tercero": "3", "tercer": "3", "cuarto": "4", "quinto": "5",
+	"sexto": "6", "séptimo": "7", "septimo": "7", "octavo": "8",
+}
+
+// normalizeGrade returns the canonical grade (PK, TK, K, 1, 2, ...) for a
+// grade as written. The configured grades mapping is consulted first; a
+// value that can't be recognized is returned trimmed but otherwise as-is.
+func normalizeGrade(raw string) string {
+	raw = strings.TrimSpace(raw)
+	for from, to := range cfg.Grades {
+		if strings.EqualFold(strings.TrimSpace(from), raw) {
+			return to
+		}
+	}
+
+	// Reduce "Gr. 1st", "1st grade", "Kinder." to their core words.
+	words := strings.FieldsFunc(strings.ToLower(raw), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var kept []string
+	for _, w := range words {
+		if w != "grade" && w != "gr" && w != "grd" && w != "grado" && w != "de" && w != "primaria" {
+			kept = append(kept, w)
+		}
+	}
+	key := strings.Join(kept, " ")
+	if g, ok := gradeWords[key]; ok {
+		return g
+	}
+
+	// Ordinals and plain numbers: "1st", "2nd", "3rd", "4th", "5", and in
+	// Spanish "1ro", "2do", "3er", "4º".
+	num := strings.TrimRight(key, "stndrhoeº°")
+	if n, err := strconv.Atoi(num); err == nil && n >= 1 && n <= 12 {
+		return strconv.Itoa(n)
+	}
+	return raw
+}
+
+// fractionGlyphs are the vulgar fractions parents write, as plain fractions.
+var fractionGlyphs = strings.NewReplacer("½", " 1/2", "¼", " 1/4", "¾", " 3/4", "⅓", " 1/3", "⅔", " 2/3", "⁄", "/")
+
+// durationPhrases rewrites common spelled-out durations, in English and
+// Spanish, into numbers. At
+// each position the first matching phrase wins, so longer ones come first.
+var durationPhrases = strings.NewReplacer(
+	"an hour and a half", "90 min", "hours and a half", "1/2 hours", "hour and a half", "1/2 hour", "and a half", "1/2",
+	"half an hour", "30 min", "half a hour", "30 min", "half hour", "30 min", "half hr", "30 min",
+	"quarter of an hour", "15 min", "quarter hour", "15 min",
+	"an hour", "1 hour", "a hour", "1 hour",
+	"una hora y media", "90 min", "horas y media", "1/2 hours", "hora y media", "1/2 hour", "y media", "1/2",
+	"media hora", "30 min", "un cuarto de hora", "15 min", "cuarto de hora", "15 min",
+	"una hora y cuarto", "75 min", "horas y cuarto", "1/4 hours", "hora y cuarto", "1/4 hour",
+	"una hora", "1 hour",
+)
+
+// durationWords are spelled-out amounts that appear in written times.
+var durationWords = map[string]float64{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+	"ten": 10, "eleven": 11, "twelve": 12, "fifteen": 15, "twenty": 20, "thirty": 30, "forty": 40,
+	"fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90, "half": 0.5,
+	"un": 1, "uno": 1, "dos": 2, "tres": 3, "cuatro": 4, "cinco": 5, "seis": 6, "siete": 7, "ocho": 8,
+	"nueve": 9, "diez": 10, "once": 11, "doce": 12, "quince": 15, "veinte": 20, "veinticinco": 25,
+	"treinta": 30, "cuarenta": 40, "cincuenta": 50, "sesenta": 60, "setenta": 70, "ochenta": 80, "noventa": 90,
+}
+
+// wordAmount returns the amount a spelled-out number stands for,
+// including hyphenated ones such as "forty-five".
+func wordAmount(t string) (float64, bool) {
+	var total float64
+	for _, w := range strings.Split(t, "-") {
+		v, ok := durationWords[w]
+		if !ok {
+			return 0, false
+		}
+		total += v
+	}
+	return total, true
+}
+
+// parseDuration reads a reading time as written in English or Spanish
+// ("20", "20 min", "1.5 hrs", "1 ½ hours", "1h 30m", "1:15", "half an
+// hour", "media hora", "veinte minutos") and returns it in whole minutes. A bare number is taken as minutes. It reports false if the text
+// isn't a recognizable duration.
+func parseDuration(written string) (int, bool) {
+	s := strings.ToLower(strings.TrimSpace(fractionGlyphs.Replace(written)))
+	s = durationPhrases.Replace(s)
+	if s == "" {
+		return 0, false
+	}
+	if h, m, ok := strings.Cut(s, ":"); ok {
+		hours, err1 := strconv.Atoi(strings.TrimSpace(h))
+		mins, err2 := strconv.Atoi(strings.TrimSpace(strings.TrimRight(m, "hrsminute .")))
+		if err1 == nil && err2 == nil && mins < 60 {
+			return hours*60 + mins, true
+		}
+		return 0, false
+	}
+
+	// Split into numbers and unit words: "1.5hrs" → "1.5", "hrs".
+	var tokens []string
+	var cur strings.Builder
+	var curDigit bool
+	for _, r := range s {
+		isNum := unicode.IsDigit(r) || r == '.' || r == '/'
+		if unicode.IsSpace(r) || r == ',' || r == '+' || r == '&' {
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+		if cur.Len() > 0 && isNum != curDigit {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+		cur.WriteRune(r)
+		curDigit = isNum
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+
+	total, amount, haveAmount, sawAny := 0.0, 0.0, false, false
+	for _, t := range tokens {
+		if v, ok := parseAmount(t); ok {
+			amount += v // "1 1/2" accumulates to 1.5
+			haveAmount = true
+			continue
+		}
+		switch t {
+		case "h", "hr", "hrs", "hour", "hours", "hora", "horas":
+			if !haveAmount {
+				amount = 1
+			}
+			total += amount * 60
+		case "m", "mn", "mi", "min", "mins", "minute", "minutes", "minuto", "minutos":
+			if !haveAmount {
+				return 0, false
+			}
+			total += amount
+		case "and", "y":
+			continue
+		default:
+			if v, ok := wordAmount(t); ok {
+				amount += v
+				haveAmount = true
+				continue
+			}
+			return 0, false
+		}
+		amount, haveAmount, sawAny = 0, false, true
+	}
+	if haveAmount {
+		total += amount // a trailing bare number is minutes
+		sawAny = true
+	}
+	if !sawAny {
+		return 0, false
+	}
+	return int(total + 0.5), true
+}
+
+// parseAmount reads a whole number, decimal, or fraction such as "3/4".
+func parseAmount(s string) (float64, bool) {
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, ok1 := parseDecimal(num)
+		d, ok2 := parseDecimal(den)
+		if !ok1 || !ok2 || d == 0 {
+			return 0, false
+		}
+		return n / d, true
+	}
+	return parseDecimal(s)
+}
+
+// parseDecimal reads digits with at most one decimal point, such as "20",
+// "1.5", or ".5". Unlike ParseFloat alone, it rejects "inf", "nan", signs,
+// exponents, and hex, none of which are written times.
+func parseDecimal(s string) (float64, bool) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || whole != "" && !allDigits(whole) || frac != "" && !allDigits(frac) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// honorifics are dropped from teacher names before matching.
+var honorifics = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "miss": true, "mx": true, "dr": true, "teacher": true,
+	"sr": true, "sra": true, "srta": true, "maestra": true, "maestro": true, "mtra": true, "mtro": true, "profe": true, "profesora": true, "profesor": true,
+}
+
+// teacherWords splits a teacher name into words without honorifics,
+// rejoining letters written spaced out ("S m i t h").
+func teacherWords(name string) []string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-' && r != '\''
+	})
+	var kept []string
+	var letters strings.Builder
+	flush := func() {
+		if letters.Len() > 0 {
+			kept = append(kept, letters.String())
+			letters.Reset()
+		}
+	}
+	for _, w := range words {
+		switch {
+		case honorifics[strings.ToLower(w)] && len(kept) == 0 && letters.Len() == 0:
+		case len([]rune(w)) == 1:
+			letters.WriteString(w)
+		default:
+			flush()
+			kept = append(kept, w)
+		}
+	}
+	flush()
+	return kept
+}
+
+// teacherKey is the form teacher names are compared in.
+func teacherKey(name string) string {
+	return strings.ToLower(strings.Join(teacherWords(name), " "))
+}
+
+// normalizeTeacher maps a teacher name as written to its canonical name.
+// Configured aliases are matched exactly (ignoring case, honorifics, and
+// spacing), then canonical names are matched allowing a small number of
+// misread letters. Names that match nothing are tidied up but kept.
+func normalizeTeacher(raw string) string {
+	key := teacherKey(raw)
+	if key == "" {
+		return strings.TrimSpace(raw)
+	}
+	for canonical, aliases := range cfg.Teachers {
+		if teacherKey(canonical) == key {
+			return canonical
+		}
+		for _, a := range aliases {
+			if teacherKey(a) == key {
+				return canonical
+			}
+		}
+	}
+
+	best, bestDist, tied := "", 0, false
+	for canonical := range cfg.Teachers {
+		d := editDistance(key, teacherKey(canonical))
+		if d > fuzzyLimit(key) {
+			continue
+		}
+		switch {
+		case best == "" || d < bestDist:
+			best, bestDist, tied = canonical, d, false
+		case d == bestDist:
+			tied = true
+		}
+	}
+	if best != "" && !tied {
+		return best
+	}
+
+	words := teacherWords(raw)
+	for i, w := range words {
+		if w == strings.ToLower(w) {
+			r := []rune(w)
+			words[i] = string(unicode.ToUpper(r[0])) + string(r[1:])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// fuzzyLimit is how many edits a name of this length may be from a
+// canonical name and still match it.
+func fuzzyLimit(key string) int {
+	switch n := len([]rune(key)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance counts the single-letter insertions, deletions,
+// substitutions, and swaps of adjacent letters needed to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
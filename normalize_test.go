@@ -0,0 +1,423 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// withConfig runs a test with a copy of the default configuration that
+// edit has changed, restoring the previous one afterwards.
+func withConfig(t *testing.T, edit func(c *Config)) {
+	t.Helper()
+	old := cfg
+	cfg = defaultConfig()
+	edit(cfg)
+	t.Cleanup(func() { cfg = old })
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"20", 20, true},
+		{"20 min", 20, true},
+		{"20min", 20, true},
+		{"45 minutes", 45, true},
+		{"1.5 hrs", 90, true},
+		{"1.5hrs", 90, true},
+		{".5 hr", 30, true},
+		{"1 ½ hours", 90, true},
+		{"1½ hr", 90, true},
+		{"3/4 hour", 45, true},
+		{"1h 30m", 90, true},
+		{"1 hr 15 min", 75, true},
+		{"1:15", 75, true},
+		{"0:45", 45, true},
+		{"an hour", 60, true},
+		{"an hour and a half", 90, true},
+		{"half an hour", 30, true},
+		{"quarter hour", 15, true},
+		{"twenty", 20, true},
+		{"forty-five minutes", 45, true},
+		{"two hours", 120, true},
+		{"1 hour and 10 minutes", 70, true},
+
+		// Spanish
+		{"media hora", 30, true},
+		{"una hora", 60, true},
+		{"una hora y media", 90, true},
+		{"una hora y cuarto", 75, true},
+		{"2 horas y cuarto", 135, true},
+		{"1 hora y media", 90, true},
+		{"veinte minutos", 20, true},
+		{"2 horas", 120, true},
+
+		// Not durations
+		{"", 0, false},
+		{"   ", 0, false},
+		{"lots", 0, false},
+		{"min", 0, false},
+		{"1:75", 0, false},
+		{"inf", 0, false},
+		{"Inf min", 0, false},
+		{"nan", 0, false},
+		{"NaN hours", 0, false},
+		{"infinity", 0, false},
+		{"1e3", 0, false},
+		{"0x1p4", 0, false},
+		{"1/0 hour", 0, false},
+		{"1.2.3", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDuration(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseDuration(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"20", 20, true},
+		{"1.5", 1.5, true},
+		{".5", 0.5, true},
+		{"3/4", 0.75, true},
+		{"", 0, false},
+		{".", 0, false},
+		{"-1", 0, false},
+		{"+1", 0, false},
+		{"inf", 0, false},
+		{"nan", 0, false},
+		{"1_000", 0, false},
+		{"1/inf", 0, false},
+		{"3/0", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseAmount(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseAmount(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeGrade(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Grades = map[string]string{"Room 12": "3"}
+	})
+	tests := []struct{ in, want string }{
+		{"1", "1"},
+		{" 2 ", "2"},
+		{"1st", "1"},
+		{"2nd grade", "2"},
+		{"Gr. 3rd", "3"},
+		{"4th Grade", "4"},
+		{"Fifth", "5"},
+		{"twelve", "12"},
+		{"K", "K"},
+		{"Kinder.", "K"},
+		{"kindergarten", "K"},
+		{"TK", "TK"},
+		{"Pre-K", "PK"},
+		{"room 12", "3"},
+
+		// Spanish
+		{"primero", "1"},
+		{"2do", "2"},
+		{"3er grado", "3"},
+		{"4º", "4"},
+		{"quinto de primaria", "5"},
+		{"kínder", "K"},
+		{"preescolar", "PK"},
+
+		// Unrecognized values are kept as written.
+		{"13", "13"},
+		{"Mixed", "Mixed"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeGrade(tt.in); got != tt.want {
+			t.Errorf("normalizeGrade(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTeacher(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Teachers = map[string][]string{
+			"Ms. Johnson":  {"Mrs J", "Johnson"},
+			"Mr. Alvarez":  nil,
+			"Ms. Lee":      nil,
+			"Ms. Rodrigo":  nil,
+			"Ms. Rodrigue": nil,
+		}
+	})
+	tests := []struct{ in, want string }{
+		// Exact matches ignore case, honorifics, and spacing.
+		{"Ms. Johnson", "Ms. Johnson"},
+		{"johnson", "Ms. Johnson"},
+		{"Mrs. J", "Ms. Johnson"},
+		{"J o h n s o n", "Ms. Johnson"},
+		{"Sra. Johnson", "Ms. Johnson"},
+		{"Maestro Alvarez", "Mr. Alvarez"},
+
+		// A misread letter or two still matches a long enough name.
+		{"Jonhson", "Ms. Johnson"},
+		{"Alvares", "Mr. Alvarez"},
+		{"Alvrz", "Alvrz"},
+
+		// Short names must match exactly.
+		{"Lea", "Lea"},
+		{"lee", "Ms. Lee"},
+
+		// A name as close to two teachers matches neither.
+		{"Rodrigu", "Rodrigu"},
+
+		// Anything else is tidied up but kept.
+		{"mr. smith", "Smith"},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeTeacher(tt.in); got != tt.want {
+			t.Errorf("normalizeTeacher(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"johnson", "johnson", 0},
+		{"johnson", "jonhson", 1}, // swapped letters
+		{"johnson", "johnsen", 1},
+		{"johnson", "johnsn", 1},
+		{"johnson", "johnsonn", 1},
+		{"kitten", "sitting", 3},
+		{"núñez", "nunez", 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestDateNear(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		in   string
+		near time.Time
+		want time.Time
+		ok   bool
+	}{
+		{"1/30", day(2026, time.January, 26), day(2026, time.January, 30), true},
+		{"2026-01-30", day(2020, time.June, 1), day(2026, time.January, 30), true},
+		{"1/30/25", day(2026, time.January, 26), day(2025, time.January, 30), true},
+		{"Jan 30", day(2026, time.January, 26), day(2026, time.January, 30), true},
+		{"30 de enero", day(2026, time.January, 26), day(2026, time.January, 30), true},
+
+		// A week that runs from one year into the next.
+		{"12/31", day(2025, time.December, 29), day(2025, time.December, 31), true},
+		{"1/2", day(2025, time.December, 29), day(2026, time.January, 2), true},
+		{"12/30", day(2026, time.January, 3), day(2025, time.December, 30), true},
+
+		{"", day(2026, time.January, 1), time.Time{}, false},
+		{"13/1", day(2026, time.January, 1), time.Time{}, false},
+		{"someday", day(2026, time.January, 1), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := dateNear(tt.in, tt.near)
+		if !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("dateNear(%q, %s) = %s, %v, want %s, %v", tt.in, tt.near.Format("2006-01-02"), got.Format("2006-01-02"), ok, tt.want.Format("2006-01-02"), tt.ok)
+		}
+	}
+}
+
+func TestWeekOfForm(t *testing.T) {
+	withConfig(t, func(c *Config) { c.Year = 2025 })
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	week := []DayConfig{{Day: "Monday", Date: "12/29"}, {Day: "Friday", Date: "1/2"}}
+
+	tests := []struct {
+		name string
+		log  ReadingLog
+		days []DayConfig
+		year int
+		want time.Time
+	}{
+		{"configured year", ReadingLog{}, week, 2025, day(2025, time.December, 29)},
+		{"year on the form", ReadingLog{}, []DayConfig{{Day: "Monday", Date: "1/5/2026"}}, 2025, day(2026, time.January, 5)},
+		{"photo taken", ReadingLog{PhotoTaken: "2026-01-03T08:15:00"}, week, 0, day(2026, time.January, 3)},
+		{"photo taken, no days", ReadingLog{PhotoTaken: "2026-01-03"}, nil, 2025, day(2026, time.January, 3)},
+	}
+	for _, tt := range tests {
+		cfg.Year = tt.year
+		if got := weekOfForm(tt.log, tt.days); !got.Equal(tt.want) {
+			t.Errorf("%s: weekOfForm = %s, want %s", tt.name, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+		}
+	}
+
+	// Without a year or a photo date, the week is placed in the past.
+	cfg.Year = 0
+	if got := weekOfForm(ReadingLog{}, week); !got.Before(time.Now()) {
+		t.Errorf("weekOfForm with nothing to go by = %s, want a date in the past", got.Format("2006-01-02"))
+	}
+}
+
+func TestNormalizeLogYearRollover(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Year = 2025
+		c.Days = []DayConfig{{Day: "Monday", Date: "12/29"}, {Day: "Thursday", Date: "1/1"}, {Day: "Friday", Date: "1/2"}}
+	})
+	log := ReadingLog{
+		PhotoTaken: "2026-01-04",
+		ReadingEntries: []ReadingEntry{
+			{Day: "Mon", Date: "12/29", Minutes: 20},
+			{Day: "jueves", Date: "1/1", Minutes: 15},
+			{Day: "Fri", Date: "Jan 2", Written: "½ hr", Minutes: 20},
+		},
+	}
+	normalizeLog(&log)
+
+	want := []struct {
+		day, date, raw string
+		minutes        int
+	}{
+		{"Monday", "2025-12-29", "12/29", 20},
+		{"Thursday", "2026-01-01", "1/1", 15},
+		{"Friday", "2026-01-02", "Jan 2", 30},
+	}
+	for i, w := range want {
+		e := log.ReadingEntries[i]
+		if e.Day != w.day || e.Date != w.date || e.DateRaw != w.raw || e.Minutes != w.minutes {
+			t.Errorf("entry %d = %s %s (%s) %d min, want %s %s (%s) %d min", i, e.Day, e.Date, e.DateRaw, e.Minutes, w.day, w.date, w.raw, w.minutes)
+		}
+	}
+
+	// Normalizing again changes nothing.
+	again := log
+	again.ReadingEntries = append([]ReadingEntry(nil), log.ReadingEntries...)
+	normalizeLog(&again)
+	for i, w := range want {
+		e := again.ReadingEntries[i]
+		if e.Day != w.day || e.Date != w.date || e.DateRaw != w.raw || e.Minutes != w.minutes {
+			t.Errorf("entry %d normalized again = %s %s (%s) %d min, want %s %s (%s) %d min", i, e.Day, e.Date, e.DateRaw, e.Minutes, w.day, w.date, w.raw, w.minutes)
+		}
+	}
+}
+
+func TestWeekdayOf(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Monday", "Monday"},
+		{"mon", "Monday"},
+		{"Tues.", "Tuesday"},
+		{" THURS ", "Thursday"},
+		{"lunes", "Monday"},
+		{"Miércoles", "Wednesday"},
+		{"miercoles", "Wednesday"},
+		{"mié.", "Wednesday"},
+		{"jue", "Thursday"},
+		{"Sábado", "Saturday"},
+		{"dom.", "Sunday"},
+		{"someday", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := weekdayOf(tt.in); got != tt.want {
+			t.Errorf("weekdayOf(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeDay(t *testing.T) {
+	spanish := []DayConfig{{Day: "lunes", Date: "1/26"}, {Day: "martes", Date: "1/27"}}
+	tests := []struct {
+		day, date string
+		days      []DayConfig
+		want      string
+	}{
+		// The form's own name for the day on that date wins.
+		{"Mon", "2026-01-26", spanish, "lunes"},
+		{"Tuesday", "1/27", spanish, "martes"},
+		// Otherwise the day as read, in English.
+		{"miércoles", "1/28", spanish, "Wednesday"},
+		{"jue", "", nil, "Thursday"},
+		// Or as read, when it isn't a day name.
+		{"Day 3", "", nil, "Day 3"},
+	}
+	for _, tt := range tests {
+		if got := normalizeDay(tt.day, tt.date, tt.days); got != tt.want {
+			t.Errorf("normalizeDay(%q, %q) = %q, want %q", tt.day, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestParseMonthName(t *testing.T) {
+	tests := []struct {
+		in               string
+		year, month, day int
+		ok               bool
+	}{
+		{"Jan 30", 0, 1, 30, true},
+		{"Jan. 30th", 0, 1, 30, true},
+		{"30 January 2026", 2026, 1, 30, true},
+		{"Sept 2", 0, 9, 2, true},
+		{"30 de enero", 0, 1, 30, true},
+		{"2 de febrero del 2026", 2026, 2, 2, true},
+		{"1 setiembre", 0, 9, 1, true},
+		{"ene 5", 0, 1, 5, true},
+		{"Ja 30", 0, 0, 0, false},
+		{"January", 0, 0, 0, false},
+		{"Jan 32", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		year, month, day, ok := parseMonthName(tt.in)
+		if year != tt.year || month != tt.month || day != tt.day || ok != tt.ok {
+			t.Errorf("parseMonthName(%q) = %d, %d, %d, %v, want %d, %d, %d, %v", tt.in, year, month, day, ok, tt.year, tt.month, tt.day, tt.ok)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in               string
+		year, month, day int
+		ok               bool
+	}{
+		{"1/30", 0, 1, 30, true},
+		{" 2/2 ", 0, 2, 2, true},
+		{"01/05", 0, 1, 5, true},
+		{"2/3/26", 2026, 2, 3, true},
+		{"2/3/2026", 2026, 2, 3, true},
+		{"2026-02-03", 2026, 2, 3, true},
+		{"Feb 3", 0, 2, 3, true},
+		{"13/1", 0, 0, 0, false},
+		{"1/32", 0, 0, 0, false},
+		{"0/5", 0, 0, 0, false},
+		{"1/", 0, 0, 0, false},
+		{"1/2/3/4", 0, 0, 0, false},
+		{"2/3/xx", 0, 0, 0, false},
+		{"2026-13-01", 0, 0, 0, false},
+		{"", 0, 0, 0, false},
+		{"Monday", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		year, month, day, ok := parseDate(tt.in)
+		if year != tt.year || month != tt.month || day != tt.day || ok != tt.ok {
+			t.Errorf("parseDate(%q) = %d, %d, %d, %v, want %d, %d, %d, %v", tt.in, year, month, day, ok, tt.year, tt.month, tt.day, tt.ok)
+		}
+	}
+}
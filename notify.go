@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runNotice is the summary of a finished run posted to the configured chat
+// webhooks.
+type runNotice struct {
+	Processed int
+	Blank     int
+	Failed    int
+	Flagged   int
+	Results   int
+	Minutes   int    // across every result in the output
+	Output    string // where the output is, as a path or bucket URL
+}
+
+// title is the notice's first line.
+func (n runNotice) title() string {
+	where := ""
+	if host, err := os.Hostname(); err == nil {
+		where = " on " + host
+	}
+	if n.Failed > 0 {
+		return fmt.Sprintf("Reading logs run finished%s with %d failure(s)", where, n.Failed)
+	}
+	return "Reading logs run finished" + where
+}
+
+// facts are the notice's figures, as label and value.
+func (n runNotice) facts() [][2]string {
+	facts := [][2]string{{"Processed", fmt.Sprint(n.Processed)}}
+	if n.Blank > 0 {
+		facts = append(facts, [2]string{"Blank", fmt.Sprint(n.Blank)})
+	}
+	facts = append(facts, [2]string{"Failed", fmt.Sprint(n.Failed)})
+	if n.Flagged > 0 {
+		facts = append(facts, [2]string{"Flagged for review", fmt.Sprint(n.Flagged)})
+	}
+	return append(facts,
+		[2]string{"Results", fmt.Sprint(n.Results)},
+		[2]string{"Total minutes", fmt.Sprint(n.Minutes)},
+		[2]string{"Output", n.Output},
+	)
+}
+
+// slackMessage is the notice as a Slack incoming webhook message.
+func (n runNotice) slackMessage() any {
+	text := "*" + n.title() + "*"
+	for _, f := range n.facts() {
+		text += fmt.Sprintf("\n%s: %s", f[0], f[1])
+	}
+	return map[string]string{"text": text}
+}
+
+// teamsMessage is the notice as an Adaptive Card, which both Teams
+// Workflows webhooks and the older incoming webhooks accept.
+func (n runNotice) teamsMessage() any {
+	var facts []map[string]string
+	for _, f := range n.facts() {
+		facts = append(facts, map[string]string{"title": f[0], "value": f[1]})
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []any{
+					map[string]any{"type": "TextBlock", "text": n.title(), "weight": "Bolder", "size": "Medium", "wrap": true},
+					map[string]any{"type": "FactSet", "facts": facts},
+				},
+			},
+		}},
+	}
+}
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// postWebhook posts a message as JSON.
+func postWebhook(hook string, message any) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(hook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &webhookStatus{code: resp.StatusCode, status: resp.Status}
+	}
+	return nil
+}
+
+// webhookStatus is the error for a webhook that answered with a status
+// other than success.
+type webhookStatus struct {
+	code   int
+	status string
+}
+
+func (e *webhookStatus) Error() string { return e.status }
+
+// retryable reports whether posting again might succeed: the endpoint was
+// rate limited or had an error of its own.
+func (e *webhookStatus) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// notifyRun posts the notice to the Slack and Teams webhooks that are set.
+// A webhook that fails is warned about; the run has already finished.
+func notifyRun(n runNotice) {
+	hooks := []struct {
+		name, url string
+		message   any
+	}{
+		{"Slack", cfg.SlackWebhook, n.slackMessage()},
+		{"Teams", cfg.TeamsWebhook, n.teamsMessage()},
+	}
+	for _, h := range hooks {
+		if h.url == "" {
+			continue
+		}
+		if err := postWebhook(h.url, h.message); err != nil {
+			logger.Warn("could not notify "+h.name, "err", err)
+		}
+	}
+}
+
+// storeLocation is the full location of a file in the store: an absolute
+// path, or a bucket URL.
+func storeLocation(name string) string {
+	if d, ok := store.(dirStore); ok {
+		if abs, err := filepath.Abs(d.path(name)); err == nil {
+			return abs
+		}
+		return d.path(name)
+	}
+	return store.String() + name
+}
+
+// storeLocations returns where each named file is, separated by commas.
+func storeLocations(names []string) string {
+	locations := make([]string, len(names))
+	for i, name := range names {
+		locations[i] = storeLocation(name)
+	}
+	return strings.Join(locations, ", ")
+}
+
+// validateWebhook checks that a webhook setting is an http or https URL.
+func validateWebhook(hook string) error {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("%q is not an http or https URL", hook)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// outlierThreshold is the modified z-score above which a day's minutes are
+	// flagged. 3.5 is the conventional cut-off for median/MAD based scores.
+	outlierThreshold = 3.5
+	// minCohortSize is the fewest non-zero entries a classroom needs before
+	// its own statistics are used; smaller classes are compared school-wide.
+	minCohortSize = 8
+)
+
+// cohortStats summarizes the distribution of non-zero daily minutes.
+type cohortStats struct {
+	name   string
+	median float64
+	scale  float64 // robust standard deviation estimate
+	n      int
+}
+
+// detectOutliers flags entries whose minutes are far above what the rest of
+// the cohort reports, using each classroom's median and median absolute
+// deviation. If classrooms is non-nil, only results in those classrooms are
+// re-evaluated. It returns the number of results flagged.
+func detectOutliers(p *Progress, threshold float64, classrooms map[string]bool) int {
+	byClass := make(map[string][]float64)
+	var all []float64
+	for _, log := range p.Completed {
+		for _, e := range log.ReadingEntries {
+			if e.Minutes > 0 {
+				byClass[classroomOf(log)] = append(byClass[classroomOf(log)], float64(e.Minutes))
+				all = append(all, float64(e.Minutes))
+			}
+		}
+	}
+	school := newCohortStats("school", all)
+
+	details := make(map[string][]string)
+	var only map[string]bool
+	if classrooms != nil {
+		only = make(map[string]bool)
+	}
+	for name, log := range p.Completed {
+		class := classroomOf(log)
+		if classrooms != nil {
+			if !classrooms[class] {
+				continue
+			}
+			only[name] = true
+		}
+		if log.Verified {
+			continue // a person has already checked it
+		}
+		stats := newCohortStats("class", byClass[class])
+		if stats.n < minCohortSize {
+			stats = school
+		}
+		if stats.n < minCohortSize {
+			continue // not enough data anywhere to judge
+		}
+		for _, e := range log.ReadingEntries {
+			if e.Minutes <= 0 {
+				continue
+			}
+			if reason, ok := stats.outlier(float64(e.Minutes), threshold); ok {
+				details[name] = append(details[name], fmt.Sprintf("%s %s: %d min %s", e.Day, shortDate(e.Date), e.Minutes, reason))
+			}
+		}
+	}
+
+	setFlags(p, "outlier", details, only)
+	return len(details)
+}
+
+func newCohortStats(name string, values []float64) cohortStats {
+	s := cohortStats{name: name, n: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+	s.median = median(values)
+
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - s.median)
+	}
+	// 1.4826 scales the MAD to a standard deviation for normal data. When
+	// more than half the cohort reports the same value the MAD is zero, so
+	// fall back to the mean absolute deviation.
+	s.scale = 1.4826 * median(deviations)
+	if s.scale == 0 {
+		sum := 0.0
+		for _, d := range deviations {
+			sum += d
+		}
+		s.scale = 1.2533 * sum / float64(len(deviations))
+	}
+	return s
+}
+
+// outlier reports whether v is unusually high for the cohort, with a
+// human-readable explanation.
+func (s cohortStats) outlier(v, threshold float64) (string, bool) {
+	if v <= s.median {
+		return "", false
+	}
+	if s.scale == 0 {
+		// Everyone reported the same value; anything far above it stands out.
+		if v > 3*s.median {
+			return fmt.Sprintf("vs %s median %.0f (every other entry is %.0f)", s.name, s.median, s.median), true
+		}
+		return "", false
+	}
+	z := (v - s.median) / s.scale
+	if z <= threshold {
+		return "", false
+	}
+	return fmt.Sprintf("vs %s median %.0f (robust z-score %.1f, n=%d)", s.name, s.median, z, s.n), true
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
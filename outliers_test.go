@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		in   []float64
+		want float64
+	}{
+		{[]float64{7}, 7},
+		{[]float64{3, 1, 2}, 2},
+		{[]float64{4, 1, 3, 2}, 2.5},
+		{[]float64{20, 20, 20, 90}, 20},
+	}
+	for _, tt := range tests {
+		if got := median(tt.in); got != tt.want {
+			t.Errorf("median(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	values := []float64{3, 1, 2}
+	median(values)
+	if values[0] != 3 || values[1] != 1 || values[2] != 2 {
+		t.Errorf("median sorted its argument: %v", values)
+	}
+}
+
+func TestCohortStats(t *testing.T) {
+	tests := []struct {
+		name          string
+		values        []float64
+		median, scale float64
+	}{
+		{"empty", nil, 0, 0},
+		{"spread", []float64{10, 20, 20, 30, 100}, 20, 1.4826 * 10},
+		// More than half report 20, so the MAD is zero and the mean
+		// absolute deviation, 6, is used instead.
+		{"mostly the same", []float64{20, 20, 20, 30, 40}, 20, 1.2533 * 6},
+		{"all the same", []float64{20, 20, 20, 20}, 20, 0},
+	}
+	for _, tt := range tests {
+		s := newCohortStats(tt.name, tt.values)
+		if s.n != len(tt.values) || s.median != tt.median || math.Abs(s.scale-tt.scale) > 1e-9 {
+			t.Errorf("%s: n, median, scale = %d, %v, %v, want %d, %v, %v", tt.name, s.n, s.median, s.scale, len(tt.values), tt.median, tt.scale)
+		}
+	}
+}
+
+func TestCohortOutlier(t *testing.T) {
+	spread := newCohortStats("Baker", []float64{10, 20, 20, 30, 100})
+	same := newCohortStats("Baker", []float64{20, 20, 20, 20})
+	tests := []struct {
+		stats  cohortStats
+		v      float64
+		want   bool
+		reason string
+	}{
+		{spread, 100, true, "vs Baker median 20 (robust z-score 5.4, n=5)"},
+		{spread, 60, false, ""},
+		{spread, 15, false, ""},
+		{same, 61, true, "vs Baker median 20 (every other entry is 20)"},
+		{same, 60, false, ""},
+	}
+	for _, tt := range tests {
+		reason, ok := tt.stats.outlier(tt.v, 3.5)
+		if ok != tt.want || reason != tt.reason {
+			t.Errorf("outlier(%v) with median %v, scale %v = %q, %v, want %q, %v", tt.v, tt.stats.median, tt.stats.scale, reason, ok, tt.reason, tt.want)
+		}
+	}
+}
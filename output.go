@@ -0,0 +1,464 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// An Exporter writes results to a file in the store in one format. The
+// logs arrive in the configured order.
+type Exporter interface {
+	Export(name string, logs []ReadingLog, now time.Time) error
+}
+
+// exportFunc adapts a function to an Exporter.
+type exportFunc func(name string, logs []ReadingLog, now time.Time) error
+
+func (f exportFunc) Export(name string, logs []ReadingLog, now time.Time) error {
+	return f(name, logs, now)
+}
+
+// outputFormat is a --format: how it is written, and the file extensions
+// that choose it. The first extension names its file when a run writes
+// several formats.
+type outputFormat struct {
+	Exporter
+	exts []string
+}
+
+// formats maps each --format to how it is written. registerFormat adds to
+// it.
+var formats = map[string]outputFormat{
+	"csv": {exportFunc(func(name string, logs []ReadingLog, _ time.Time) error {
+		return writeCSV(name, logs)
+	}), []string{".csv"}},
+	"json": {exportFunc(func(name string, logs []ReadingLog, _ time.Time) error {
+		return writeJSON(name, logs)
+	}), []string{".json"}},
+	"ndjson": {exportFunc(func(name string, logs []ReadingLog, _ time.Time) error {
+		return writeNDJSON(name, logs)
+	}), []string{".ndjson", ".jsonl"}},
+	"xlsx": {exportFunc(func(name string, logs []ReadingLog, _ time.Time) error {
+		return writeXLSX(name, logs)
+	}), []string{".xlsx"}},
+	"parquet": {exportFunc(writeParquet), []string{".parquet"}},
+}
+
+// registerFormat adds an output format, so that --format name, or an
+// output file ending in one of exts, writes results with e.
+func registerFormat(name string, exts []string, e Exporter) {
+	if _, ok := formats[name]; ok {
+		panic("format " + name + " registered twice")
+	}
+	formats[name] = outputFormat{e, exts}
+}
+
+// formatNames returns the output formats' names in order.
+func formatNames() []string {
+	return slices.Sorted(maps.Keys(formats))
+}
+
+// outputFlags registers --output and --format, and the CSV options, bound
+// directly to cfg so the flags override the config file and environment.
+func outputFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Output, "output", cfg.Output, "output file; {date} and {time} are replaced with the current date and time")
+	fs.StringVar(&cfg.Format, "format", cfg.Format, "output format, or comma-separated formats to write several at once: "+strings.Join(formatNames(), ", ")+" (default: from the output file extension)")
+	fs.Func("delimiter", "CSV field separator: comma, semicolon, tab, or pipe (default comma)", func(v string) error {
+		if _, err := csvDelimiter(v); err != nil {
+			return err
+		}
+		cfg.CSVDelimiter = v
+		return nil
+	})
+	fs.BoolVar(&cfg.CSVBOM, "bom", cfg.CSVBOM, "start CSV files with a UTF-8 byte-order mark, so Excel reads accented names correctly")
+	fs.BoolVar(&cfg.CSVCRLF, "crlf", cfg.CSVCRLF, "end CSV lines with CRLF, as Windows programs expect")
+	fs.BoolVar(&cfg.Summary, "summary", cfg.Summary, "add a subtotal row for each teacher, a total row, and the mean and median minutes to CSV and XLSX files")
+	fs.Func("sort-by", "comma-separated row order: "+strings.Join(sortKeys, ", ")+"; -KEY for descending (default "+strings.Join(defaultConfig().SortBy, ",")+")", func(v string) error {
+		keys := strings.Split(v, ",")
+		if err := checkSortKeys(keys); err != nil {
+			return err
+		}
+		cfg.SortBy = keys
+		return nil
+	})
+}
+
+// outputFile is a file a run writes and its format.
+type outputFile struct {
+	path, format string
+}
+
+// resolveOutput expands the placeholders in an output path and returns the
+// file to write for each of the comma-separated formats. With no format,
+// the extension chooses one, and CSV is the default. With several, each
+// file is named after the path with its format's extension, so
+// --format csv,xlsx writes reading_logs.csv and reading_logs.xlsx.
+func resolveOutput(path, format string, now time.Time) ([]outputFile, error) {
+	path = strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+	).Replace(path)
+	ext := strings.ToLower(filepath.Ext(path))
+
+	if format == "" {
+		format = "csv"
+		for name, f := range formats {
+			if slices.Contains(f.exts, ext) {
+				format = name
+			}
+		}
+	}
+	names := strings.Split(format, ",")
+	var files []outputFile
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		f, ok := formats[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown output format %q (want %s)", name, strings.Join(formatNames(), ", "))
+		}
+		if slices.ContainsFunc(files, func(o outputFile) bool { return o.format == name }) {
+			continue
+		}
+		p := path
+		if len(names) > 1 {
+			p = strings.TrimSuffix(path, filepath.Ext(path)) + f.exts[0]
+		}
+		files = append(files, outputFile{p, name})
+	}
+	return files, nil
+}
+
+// writeOutput writes logs to the configured output in each configured
+// format and returns the paths written.
+func writeOutput(logs []ReadingLog) ([]string, error) {
+	now := time.Now()
+	files, err := resolveOutput(cfg.Output, cfg.Format, now)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, f := range files {
+		if err := writeLogs(f.path, f.format, logs, now); err != nil {
+			return paths, err
+		}
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+// writeLogs writes logs to path in the given format, in the configured
+// order.
+func writeLogs(path, format string, logs []ReadingLog, now time.Time) error {
+	return formats[format].Export(path, sortLogs(logs), now)
+}
+
+// writeJSON writes the logs as a single indented JSON array.
+func writeJSON(filename string, logs []ReadingLog) error {
+	data, err := json.MarshalIndent(logs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStoreFile(store, filename, append(data, '\n'))
+}
+
+// writeNDJSON writes one JSON record per line, for streaming consumers.
+func writeNDJSON(filename string, logs []ReadingLog) error {
+	file, err := createStoreFile(store, filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, log := range logs {
+		if err := enc.Encode(log); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// parquetRow is one day's entry in the Parquet export. The column names and
+// types are a stable contract for analytics tools, so only add to them.
+type parquetRow struct {
+	Student      string    `parquet:"student,dict"`
+	Grade        string    `parquet:"grade,dict"`
+	Teacher      string    `parquet:"teacher,dict"`
+	Classroom    string    `parquet:"classroom,dict"`
+	Week         string    `parquet:"week,dict"`
+	Day          string    `parquet:"day,dict"`
+	Date         string    `parquet:"date,dict"`
+	Minutes      int32     `parquet:"minutes"`
+	BookTitle    string    `parquet:"book_title"`
+	SourceFile   string    `parquet:"source_file"`
+	RunTimestamp time.Time `parquet:"run_timestamp,timestamp"`
+	PhotoTaken   string    `parquet:"photo_taken"`
+	WeeklyGoal   int32     `parquet:"weekly_goal"` // 0 if the grade has none
+	GoalMet      bool      `parquet:"goal_met"`
+}
+
+// writeParquet writes the logs in long form, one row per student per day,
+// so they load straight into DuckDB or pandas.
+func writeParquet(filename string, logs []ReadingLog, runAt time.Time) error {
+	var rows []parquetRow
+	for _, log := range logs {
+		goal := cfg.goalFor(log.Grade)
+		met := goal > 0 && totalMinutes(log) >= goal
+		for _, e := range log.ReadingEntries {
+			rows = append(rows, parquetRow{
+				Student:      log.FullName,
+				Grade:        log.Grade,
+				Teacher:      log.HomeroomTeacher,
+				Classroom:    classroomOf(log),
+				Week:         weekOf(log),
+				Day:          e.Day,
+				Date:         e.Date,
+				Minutes:      int32(e.Minutes),
+				BookTitle:    e.BookTitle,
+				SourceFile:   log.Source,
+				RunTimestamp: runAt.UTC(),
+				PhotoTaken:   log.PhotoTaken,
+				WeeklyGoal:   int32(goal),
+				GoalMet:      met,
+			})
+		}
+	}
+
+	file, err := createStoreFile(store, filename)
+	if err != nil {
+		return err
+	}
+	if err := parquet.Write(file, rows, parquet.Compression(&parquet.Zstd)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeXLSX writes the same table as the CSV as a minimal single-sheet Excel
+// workbook. Numeric cells are stored as numbers so totals can be summed.
+func writeXLSX(filename string, logs []ReadingLog) error {
+	cols := csvColumns(logs)
+	rows := [][]string{csvHeader(cols)}
+	for _, log := range logs {
+		rows = append(rows, csvRow(log, cols))
+	}
+	if cfg.Summary {
+		rows = append(rows, summaryRows(logs, cols)...)
+	}
+
+	file, err := createStoreFile(store, filename)
+	if err != nil {
+		return err
+	}
+	if err := writeWorkbook(file, "Reading Logs", rows); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeWorkbook writes an XLSX package with one sheet containing rows.
+func writeWorkbook(w io.Writer, sheetName string, rows [][]string) error {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + xmlEscape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`},
+		// Style 1 is quote-prefixed text, which Excel keeps as text even
+		// when the cell is edited.
+		{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" quotePrefix="1"/></cellXfs>
+</styleSheet>`},
+		{"xl/worksheets/sheet1.xml", sheetXML(rows)},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// sheetXML renders rows as worksheet XML using inline strings, so no shared
+// string table is needed. Inline strings are never evaluated, and text that
+// looks like a formula is also quote-prefixed, so it stays text if edited.
+func sheetXML(rows [][]string) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range rows {
+		fmt.Fprintf(&sb, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := columnName(c) + strconv.Itoa(r+1)
+			if isNumber(cell) && r > 0 {
+				fmt.Fprintf(&sb, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			} else if isFormula(cell) {
+				fmt.Fprintf(&sb, `<c r="%s" s="1" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(cell))
+			} else if cell != "" {
+				fmt.Fprintf(&sb, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(cell))
+			}
+		}
+		sb.WriteString(`</row>`)
+	}
+	sb.WriteString(`</sheetData></worksheet>`)
+	return sb.String()
+}
+
+// isNumber reports whether s is a plain decimal number, such as "45", "-3",
+// or "1.5". ParseFloat alone would also accept "NaN", "Inf", "0x1p4", and
+// "1_000", which aren't valid cell values.
+func isNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	return allDigits(whole) && (!hasFrac || allDigits(frac))
+}
+
+// allDigits reports whether s is one or more ASCII digits.
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// columnName converts a zero-based column index to a spreadsheet column
+// name (0 → A, 26 → AA).
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// sortKeys are the row orders --sort-by accepts.
+var sortKeys = []string{"teacher", "grade", "student", "week", "total", "file"}
+
+// checkSortKeys returns an error for a sort key that isn't one of sortKeys.
+func checkSortKeys(keys []string) error {
+	for _, k := range keys {
+		if !slices.Contains(sortKeys, strings.TrimPrefix(strings.TrimSpace(k), "-")) {
+			return fmt.Errorf("unknown sort key %q (want %s)", k, strings.Join(sortKeys, ", "))
+		}
+	}
+	return nil
+}
+
+// sortLogs returns logs in the order set by sort_by, with the image
+// filename breaking ties, so repeated exports of the same results are
+// identical and can be diffed.
+func sortLogs(logs []ReadingLog) []ReadingLog {
+	sorted := slices.Clone(logs)
+	slices.SortStableFunc(sorted, func(a, b ReadingLog) int {
+		for _, key := range cfg.SortBy {
+			key = strings.TrimSpace(key)
+			desc := strings.HasPrefix(key, "-")
+			c := compareBy(strings.TrimPrefix(key, "-"), a, b)
+			if desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a.Source, b.Source)
+	})
+	return sorted
+}
+
+// compareBy compares two logs by one sort key. Names are compared without
+// regard to case, and grades in school order.
+func compareBy(key string, a, b ReadingLog) int {
+	switch key {
+	case "teacher":
+		return compareFold(a.HomeroomTeacher, b.HomeroomTeacher)
+	case "grade":
+		return cmp.Or(cmp.Compare(gradeRank(a.Grade), gradeRank(b.Grade)), compareFold(a.Grade, b.Grade))
+	case "student":
+		return compareFold(a.FullName, b.FullName)
+	case "week":
+		return strings.Compare(weekOf(a), weekOf(b))
+	case "total":
+		return cmp.Compare(totalMinutes(a), totalMinutes(b))
+	case "file":
+		return strings.Compare(a.Source, b.Source)
+	}
+	return 0
+}
+
+func compareFold(a, b string) int {
+	return cmp.Or(strings.Compare(strings.ToLower(a), strings.ToLower(b)), strings.Compare(a, b))
+}
+
+// gradeRank orders grades PK, TK, K, 1 through 12, then anything else.
+func gradeRank(grade string) int {
+	switch grade {
+	case "PK":
+		return -2
+	case "TK":
+		return -1
+	case "K":
+		return 0
+	}
+	if n, err := strconv.Atoi(grade); err == nil && n >= 1 && n <= 12 {
+		return n
+	}
+	return 13
+}
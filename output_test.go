@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"45", true},
+		{"0", true},
+		{"-3", true},
+		{"1.5", true},
+		{"", false},
+		{"-", false},
+		{".5", false},
+		{"5.", false},
+		{"1.2.3", false},
+		{"Nan", false},
+		{"NaN", false},
+		{"Inf", false},
+		{"-inf", false},
+		{"infinity", false},
+		{"0x1p4", false},
+		{"1_000", false},
+		{"1e3", false},
+		{"+4", false},
+		{" 4", false},
+	}
+	for _, tt := range tests {
+		if got := isNumber(tt.in); got != tt.want {
+			t.Errorf("isNumber(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSheetXMLCells(t *testing.T) {
+	rows := [][]string{
+		{"Student", "Minutes"},
+		{"Nan", "30"},
+		{"Inf", "1.5"},
+		{"=SUM(A1)", ""},
+	}
+	got := sheetXML(rows)
+
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t>Student</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t>Nan</t></is></c>`,
+		`<c r="B2"><v>30</v></c>`,
+		`<c r="A3" t="inlineStr"><is><t>Inf</t></is></c>`,
+		`<c r="B3"><v>1.5</v></c>`,
+		`<c r="A4" s="1" t="inlineStr"><is><t>=SUM(A1)</t></is></c>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("sheetXML is missing %s\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `r="B4"`) {
+		t.Errorf("sheetXML wrote an empty cell\n%s", got)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// backSuffixes mark an image as the back page of a two-sided form. The
+// back of IMG_0912.heic is IMG_0912-back.heic (or _back, or " back"); the
+// extension doesn't have to match.
+var backSuffixes = []string{"-back", "_back", " back"}
+
+// pageOf returns the form a progress key belongs to, the same for both
+// sides of a two-sided form, and whether the key is for the back page.
+func pageOf(key string) (form string, back bool) {
+	image := imageOf(key)
+	student := key[len(image):] // "#2" for a further student, or ""
+	stem := strings.TrimSuffix(image, filepath.Ext(image))
+	for _, s := range backSuffixes {
+		if len(stem) > len(s) && strings.EqualFold(stem[len(stem)-len(s):], s) {
+			return stem[:len(stem)-len(s)] + student, true
+		}
+	}
+	return stem + student, false
+}
+
+// isBackPage reports whether an image is the back page of a two-sided form.
+func isBackPage(name string) bool {
+	_, back := pageOf(name)
+	return back
+}
+
+// mergePages combines the results read from the two sides of a form into
+// one. Days are taken from whichever side has them filled in; the student,
+// grade, and teacher come from the front unless only the back has them.
+func mergePages(front, back ReadingLog) ReadingLog {
+	merged := front
+	if strings.TrimSpace(merged.FullName) == "" {
+		merged.FullName = back.FullName
+	}
+	if strings.TrimSpace(merged.Grade) == "" {
+		merged.Grade, merged.GradeRaw = back.Grade, back.GradeRaw
+	}
+	if strings.TrimSpace(merged.HomeroomTeacher) == "" {
+		merged.HomeroomTeacher, merged.TeacherRaw = back.HomeroomTeacher, back.TeacherRaw
+	}
+	merged.Notes = joinDistinct([]string{front.Notes, back.Notes})
+
+	merged.ReadingEntries = append([]ReadingEntry(nil), front.ReadingEntries...)
+	index := make(map[string]int)
+	for i, e := range merged.ReadingEntries {
+		index[dateKey(e.Date)] = i
+	}
+	for _, e := range back.ReadingEntries {
+		i, ok := index[dateKey(e.Date)]
+		switch {
+		case !ok:
+			index[dateKey(e.Date)] = len(merged.ReadingEntries)
+			merged.ReadingEntries = append(merged.ReadingEntries, e)
+		case !filledIn(merged.ReadingEntries[i]):
+			merged.ReadingEntries[i] = e
+		}
+	}
+
+	if len(back.Extra) > 0 {
+		merged.Extra = make(map[string]any)
+		for k, v := range back.Extra {
+			merged.Extra[k] = v
+		}
+		for k, v := range front.Extra {
+			merged.Extra[k] = v
+		}
+	}
+	merged.Flags = append(append([]Flag(nil), front.Flags...), back.Flags...)
+	merged.Back = back.Source
+	return merged
+}
+
+// filledIn reports whether anything was read for a day.
+func filledIn(e ReadingEntry) bool {
+	return e.Minutes > 0 || strings.TrimSpace(e.Written) != ""
+}
+
+// pairPages merges each back page into the result for its front page. A
+// back page whose front hasn't been read yet is kept as a result of its own.
+func pairPages(logs []ReadingLog) []ReadingLog {
+	backs := make(map[string]ReadingLog)
+	fronts := make(map[string]bool)
+	for _, log := range logs {
+		if form, back := pageOf(log.Source); back {
+			backs[form] = log
+		} else {
+			fronts[form] = true
+		}
+	}
+
+	paired := logs[:0:0]
+	for _, log := range logs {
+		form, back := pageOf(log.Source)
+		switch {
+		case back && fronts[form]:
+			continue // merged into the front
+		case !back:
+			if b, ok := backs[form]; ok {
+				log = mergePages(log, b)
+			}
+		}
+		paired = append(paired, log)
+	}
+	return paired
+}
+
+// recordsFor returns the completed results that include any of the record
+// keys, with two-sided forms merged, for updating the history after a
+// change to them.
+func recordsFor(p *Progress, keys []string) []ReadingLog {
+	want := make(map[string]bool)
+	for _, k := range keys {
+		want[p.nameOf(k)] = true
+	}
+	var logs []ReadingLog
+	for _, log := range completedLogs(p) {
+		if want[log.Source] || want[log.Back] {
+			logs = append(logs, log)
+		}
+	}
+	return logs
+}
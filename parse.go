@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// runParseImage reads a single image, from a file or stdin, and prints what
+// was read as JSON, without touching the progress file or the CSV. It is
+// for spot checks and scripts.
+func runParseImage(args []string) error {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	stdin := fs.Bool("stdin", false, "read the image from stdin instead of a file")
+	mediaType := fs.String("media-type", "", "media type of the image on stdin, e.g. image/jpeg (default: detected from its bytes)")
+	fake := fs.Bool("fake", false, "use the built-in fake client instead of the Anthropic API (no API key needed)")
+	model := fs.String("model", cfg.Model, "model to read the image with: a model ID or fast, balanced, or accurate")
+	form := fs.String("template", "", "form template to read the image with (default: detect it)")
+	promptFile := fs.String("prompt-file", cfg.PromptFile, "Go template to use as the extraction prompt (default: built in)")
+	multi := fs.Bool("multi", cfg.MultiStudent, "the image may show several students' logs; prints an array of results")
+	flatten := fs.Bool("flatten", cfg.FlattenPages, "find the page in the photo and straighten it if it was taken at an angle")
+	collages := fs.Bool("split-collages", cfg.SplitCollages, "if the image is a collage of several photos, read each on its own")
+	configured, _ := cfg.imageTimeout()
+	timeout := fs.Duration("timeout", configured, "give up if the image takes longer than this to read (0 for no limit)")
+	files := parseArgs(fs, args)
+
+	var name string
+	var data []byte
+	var err error
+	switch {
+	case *stdin && len(files) == 0:
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("reading stdin: %w", err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("no image on stdin")
+		}
+		if *mediaType == "" {
+			*mediaType = http.DetectContentType(data)
+		}
+		ext := imageExt(*mediaType)
+		if ext == "" {
+			return configErrorf("unsupported media type %q; give it with --media-type", *mediaType)
+		}
+		name = "stdin" + ext
+	case !*stdin && len(files) == 1:
+		name = files[0]
+		if !isImageFile(name) {
+			return configErrorf("%s is not a supported image", name)
+		}
+		if data, err = readLocalFile(name); err != nil {
+			return imageError{err}
+		}
+	default:
+		return configErrorf("usage: parse <image> | parse --stdin [--media-type TYPE]")
+	}
+
+	if *form != "" {
+		if _, err := lookupTemplate(*form); err != nil {
+			return configErrorf("--template: %w", err)
+		}
+	}
+	prompt, err := loadPrompt(*promptFile)
+	if err != nil {
+		return configErrorf("--prompt-file: %w", err)
+	}
+	client := newAnthropicClient(prompt)
+	client.model = resolveModel(*model)
+	client.fallback = fallbackModel(cfg.FallbackModel, client.model)
+	client.limit = newRateLimiter(cfg.RequestsPerMinute, cfg.TokensPerMinute)
+	pipe := &pipeline{client: client, template: *form, multi: *multi, flatten: *flatten, splitCollages: *collages}
+	if *fake {
+		pipe.client = &fakeClient{}
+	} else {
+		useSavedKey()
+		if err := pipe.checkAPIKey(); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	logs, err := pipe.extractData(ctx, name, data)
+	if err != nil {
+		return err
+	}
+	for _, log := range logs {
+		normalizeLog(log)
+		if !*stdin {
+			log.Source = filepath.Base(name)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if *multi || len(logs) > 1 {
+		return enc.Encode(logs)
+	}
+	return enc.Encode(logs[0])
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// previewModes are the ways an image can be shown while reviewing: drawn in
+// the terminal with the kitty, iTerm2, or sixel graphics protocol, or opened
+// in the system's image viewer.
+var previewModes = []string{"auto", "kitty", "iterm", "sixel", "open", "none"}
+
+// previewSize is the largest width or height, in pixels, of an image drawn
+// in the terminal: enough to read handwriting without filling the screen.
+const previewSize = 800
+
+// inlinePreview returns the graphics protocol the terminal speaks, or "" if
+// it isn't known to draw images.
+func inlinePreview() string {
+	if cfg.ImagePreview != "auto" {
+		switch cfg.ImagePreview {
+		case "kitty", "iterm", "sixel":
+			return cfg.ImagePreview
+		}
+		return ""
+	}
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || program == "ghostty":
+		return "kitty"
+	case program == "iTerm.app" || program == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return "iterm"
+	case term == "foot" || strings.HasPrefix(term, "mlterm") || strings.Contains(term, "sixel"):
+		return "sixel"
+	}
+	return ""
+}
+
+// previewImage shows an image so its handwriting can be checked against
+// the result: in the terminal if it can draw images, otherwise, when open is
+// set, in the system's image viewer.
+func previewImage(name string, open bool) error {
+	if cfg.ImagePreview == "none" || headless {
+		return nil
+	}
+	protocol := inlinePreview()
+	if protocol == "" || cfg.ImagePreview == "open" {
+		if open || cfg.ImagePreview == "open" {
+			return openImage(name)
+		}
+		return nil
+	}
+
+	img, err := decodeImage(name)
+	if err != nil {
+		if open {
+			return openImage(name)
+		}
+		return fmt.Errorf("can't draw %s in the terminal: %w", name, err)
+	}
+	img = thumbnail(img, previewSize)
+
+	w := bufio.NewWriter(os.Stdout)
+	switch protocol {
+	case "kitty":
+		err = writeKitty(w, img)
+	case "iterm":
+		err = writeITerm(w, img)
+	case "sixel":
+		writeSixel(w, img)
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteString("\n")
+	return w.Flush()
+}
+
+// decodeImage loads an image from the store, converting it from HEIC, TIFF,
+// or BMP if need be, and decodes it.
+func decodeImage(name string) (image.Image, error) {
+	_, encoded, err := loadImage(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// openImage opens an image in the system's viewer without waiting for it.
+// Images in a bucket are downloaded to a temporary file first.
+func openImage(name string) error {
+	path := ""
+	if d, ok := store.(dirStore); ok {
+		path = d.path(name)
+	} else {
+		data, err := readStoreFile(store, name)
+		if err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp("", "reading-log-*"+filepath.Ext(name))
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(data)
+		tmp.Close()
+		if err != nil {
+			return err
+		}
+		path = tmp.Name() // left for the viewer, which may read it after we exit
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not open %s: %w", name, err)
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// thumbnail scales img down, averaging each block of pixels, so that
+// neither side is longer than size.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	scale := max(b.Dx(), b.Dy())
+	if scale <= size {
+		return img
+	}
+	w, h := max(b.Dx()*size/scale, 1), max(b.Dy()*size/scale, 1)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := range w {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			var r, g, bl, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					pr, pg, pb, _ := img.At(sx, sy).RGBA()
+					r, g, bl, n = r+uint64(pr), g+uint64(pg), bl+uint64(pb), n+1
+				}
+			}
+			i := out.PixOffset(x, y)
+			out.Pix[i], out.Pix[i+1], out.Pix[i+2], out.Pix[i+3] = uint8(r/n>>8), uint8(g/n>>8), uint8(bl/n>>8), 0xff
+		}
+	}
+	return out
+}
+
+// writeKitty draws img with the kitty graphics protocol, which takes a PNG
+// in base64 chunks of at most 4096 bytes.
+func writeKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	for first := true; len(data) > 0; first = false {
+		chunk := data[:min(len(data), 4096)]
+		data = data[len(chunk):]
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(w, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return nil
+}
+
+// writeITerm draws img with iTerm2's inline image escape, also understood
+// by WezTerm.
+func writeITerm(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a", buf.Len(), base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return nil
+}
+
+// writeSixel draws img as sixels, in a palette of 216 colors (six levels
+// each of red, green, and blue), which keeps ink and paper apart.
+func writeSixel(w *bufio.Writer, img image.Image) {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	pixels := make([]uint8, width*height)
+	for y := range height {
+		for x := range width {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			level := func(v uint32) uint8 { return uint8((v*5 + 0x7fff) / 0xffff) }
+			pixels[y*width+x] = level(r)*36 + level(g)*6 + level(bl)
+		}
+	}
+
+	fmt.Fprintf(w, "\x1bPq\"1;1;%d;%d", width, height)
+	for c := range 216 {
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", c, c/36*20, c/6%6*20, c%6*20)
+	}
+	for top := 0; top < height; top += 6 {
+		used := make(map[uint8]bool)
+		for y := top; y < min(top+6, height); y++ {
+			for _, c := range pixels[y*width : (y+1)*width] {
+				used[c] = true
+			}
+		}
+		for c := range 216 {
+			if !used[uint8(c)] {
+				continue
+			}
+			fmt.Fprintf(w, "#%d", c)
+			run, last := 0, byte(0)
+			flush := func() {
+				if run > 3 {
+					fmt.Fprintf(w, "!%d%c", run, last)
+					return
+				}
+				for range run {
+					w.WriteByte(last)
+				}
+			}
+			for x := range width {
+				bits := byte(0)
+				for row := range min(6, height-top) {
+					if pixels[(top+row)*width+x] == uint8(c) {
+						bits |= 1 << row
+					}
+				}
+				ch := 63 + bits
+				if ch != last || run == 0 {
+					flush()
+					run, last = 0, ch
+				}
+				run++
+			}
+			flush()
+			w.WriteByte('$')
+		}
+		w.WriteByte('-')
+	}
+	w.WriteString("\x1b\\")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// profileOption takes --profile NAME (or --profile=NAME) out of args,
+// wherever it appears, and returns the name and the other arguments. It is
+// read before the config is loaded, since the profile is part of it.
+func profileOption(args []string) (name string, rest []string, err error) {
+	rest = args[:0:0]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--profile" || arg == "-profile":
+			if i+1 == len(args) {
+				return "", nil, fmt.Errorf("--profile needs a profile name")
+			}
+			i++
+			name = args[i]
+		case strings.HasPrefix(arg, "--profile="), strings.HasPrefix(arg, "-profile="):
+			_, name, _ = strings.Cut(arg, "=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return name, rest, nil
+}
+
+// useProfile applies the named profile from the config file at path over
+// the settings already read from it, and moves into the profile's folder,
+// so the profile's progress, history, outputs, and relative paths are kept
+// apart from every other profile's.
+//
+// The profile's folder is its dir setting, relative to the config file, or
+// a folder named after the profile next to it. READING_LOGS_CONFIG and
+// READING_LOGS_PROFILE are set so commands run from here, like the daemon's
+// jobs, use the same profile.
+func (c *Config) useProfile(path, name string) error {
+	if path == "" {
+		return fmt.Errorf("profile %q: there is no config file to find it in", name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var folder struct {
+		Dir string `yaml:"dir" toml:"dir"`
+	}
+	var names []string
+	if strings.HasSuffix(path, ".toml") {
+		var file struct {
+			Profiles map[string]toml.Primitive `toml:"profiles"`
+		}
+		md, err := toml.Decode(string(data), &file)
+		if err != nil {
+			return err
+		}
+		p, ok := file.Profiles[name]
+		for n := range file.Profiles {
+			names = append(names, n)
+		}
+		if ok {
+			if err := md.PrimitiveDecode(p, c); err != nil {
+				return fmt.Errorf("profile %q: %w", name, err)
+			}
+			md.PrimitiveDecode(p, &folder)
+		}
+	} else {
+		var file struct {
+			Profiles map[string]yaml.Node `yaml:"profiles"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return err
+		}
+		p, ok := file.Profiles[name]
+		for n := range file.Profiles {
+			names = append(names, n)
+		}
+		if ok {
+			if err := p.Decode(c); err != nil {
+				return fmt.Errorf("profile %q: %w", name, err)
+			}
+			p.Decode(&folder)
+		}
+	}
+	if !slices.Contains(names, name) {
+		if len(names) == 0 {
+			return fmt.Errorf("no profile %q; there are no profiles in %s", name, path)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("no profile %q in %s (there are %s)", name, path, strings.Join(names, ", "))
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dir := folder.Dir
+	if dir == "" {
+		dir = name
+	}
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(home, rest)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(abs), dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	os.Setenv("READING_LOGS_CONFIG", abs)
+	os.Setenv("READING_LOGS_PROFILE", name)
+	c.profile, c.profileDir = name, dir
+	return nil
+}
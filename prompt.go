@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// defaultPrompt is the extraction prompt. --prompt-file replaces it with a
+// template of the same shape; see promptData for the placeholders.
+const defaultPrompt = `Analyze this reading log image carefully. Extract the following information exactly as written:
+
+1. The student's full name
+2. The grade level
+3. The homeroom teacher's name
+
+Then for each day listed on the reading log ({{.DayList}}), extract the reading time as a number of minutes (integer only, e.g. if it says "10 min" or "10mi" return 10). If the time is written in hours or fractions, convert it to minutes (e.g. "1.5 hrs" is 90 and "½ hour" is 30). Also copy the reading time letter for letter as written, with its units or words (e.g. "20m" or "twenty min"), without converting or correcting it. If a day has no reading time filled in, use 0 and leave the written time empty. If a book title is written for a day, copy it as well. Note whether each day has a parent signature or initials.
+
+The form may be printed or filled in in Spanish (for example "lunes" for Monday, "minutos" for minutes, or "firma" for signature). Read it the same way: give each day by its name in the list above, and copy the written time as it is, in Spanish if that is how it is written.
+
+Copy any free-text comments on the form into the notes.{{.FieldList}}{{.FormPrompt}}
+
+Return all information in the structured JSON format requested.`
+
+// promptData is what a prompt template can refer to.
+type promptData struct {
+	Days       []DayConfig
+	DayList    string // "Friday 1/30, Saturday 1/31, ..."
+	Fields     []FieldConfig
+	FieldList  string // instructions for the extra fields, or ""
+	Template   string // form template name
+	FormPrompt string // the form template's own instructions, or ""
+}
+
+// loadPrompt parses the prompt template in path, or the registered prompt
+// it names, or the built-in prompt if path is empty. The template is tried
+// against the default form so mistakes surface before any image is sent.
+func loadPrompt(path string) (*template.Template, error) {
+	text := defaultPrompt
+	if registered, ok := prompts[path]; ok {
+		text = registered
+	} else if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("prompt template: %w", err)
+	}
+	if _, err := renderPrompt(tmpl, defaultTemplate()); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderPrompt fills in the prompt for one form.
+func renderPrompt(tmpl *template.Template, form FormTemplate) (string, error) {
+	fields := form.fields()
+	data := promptData{
+		Days:       form.days(),
+		DayList:    form.dayList(),
+		Fields:     fields,
+		FieldList:  fieldPrompt(fields),
+		Template:   form.Name,
+		FormPrompt: formPrompt(form),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("prompt template: %w", err)
+	}
+	return buf.String(), nil
+}
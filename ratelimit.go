@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// rateLimiter keeps a run's API requests under the account's per-minute
+// limits, so concurrent reads wait their turn instead of failing with 429s.
+// It is a pair of token buckets, one for requests and one for tokens, each
+// holding a minute's worth and refilling continuously. Limits set with --rpm
+// and --tpm are fixed; otherwise they are learned from the rate limit
+// headers on each response, which also report what other runs on the same
+// account have used.
+type rateLimiter struct {
+	mu       sync.Mutex
+	rpm, tpm float64 // limits per minute; 0 for none (or not yet known)
+	fixedRPM bool    // set with --rpm, so not taken from responses
+	fixedTPM bool    // likewise --tpm
+	requests float64 // left in each bucket
+	tokens   float64
+	refilled time.Time
+	estimate float64   // tokens a request is expected to use, from recent ones
+	paused   time.Time // no requests until then, after a 429 with retry-after
+	waiting  int       // requests waiting their turn
+}
+
+// newRateLimiter returns a limiter for the given limits, either of which is
+// 0 to learn it from the API.
+func newRateLimiter(rpm, tpm int) *rateLimiter {
+	return &rateLimiter{
+		rpm: float64(rpm), tpm: float64(tpm),
+		fixedRPM: rpm > 0, fixedTPM: tpm > 0,
+		requests: float64(rpm), tokens: float64(tpm),
+		refilled: time.Now(),
+	}
+}
+
+// middleware waits for the request's turn, then sends it and notes the
+// limits its response reports. It sees every attempt, so the SDK's own
+// retries are paced too.
+func (l *rateLimiter) middleware(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if l == nil {
+		return next(r)
+	}
+	reserved, err := l.wait(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := next(r)
+	l.observe(resp, reserved)
+	return resp, err
+}
+
+// wait blocks until a request may be sent, taking one request and the
+// expected tokens from the buckets, which it returns.
+func (l *rateLimiter) wait(ctx context.Context) (float64, error) {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		delay, reserved := l.take(now)
+		if delay == 0 {
+			l.mu.Unlock()
+			return reserved, nil
+		}
+		l.waiting++
+		if l.waiting == 1 {
+			dim.Println(glyphs(fmt.Sprintf("    ⏸ Waiting for the rate limit (%s)", l.limits())))
+		}
+		l.mu.Unlock()
+		logger.Debug("rate limited", "wait", delay.Round(time.Millisecond))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// take refills the buckets and, if there is room, takes a request from
+// them. Otherwise it returns how long until there will be.
+func (l *rateLimiter) take(now time.Time) (time.Duration, float64) {
+	minutes := now.Sub(l.refilled).Minutes()
+	l.refilled = now
+	l.requests = min(l.requests+minutes*l.rpm, l.rpm)
+	l.tokens = min(l.tokens+minutes*l.tpm, l.tpm)
+	if now.Before(l.paused) {
+		return l.paused.Sub(now), 0
+	}
+
+	need := min(l.estimate, l.tpm)
+	var delay float64 // minutes
+	if l.rpm > 0 && l.requests < 1 {
+		delay = (1 - l.requests) / l.rpm
+	}
+	if l.tpm > 0 && l.tokens < need {
+		delay = max(delay, (need-l.tokens)/l.tpm)
+	}
+	if delay > 0 {
+		return max(time.Duration(delay*float64(time.Minute)), 10*time.Millisecond), 0
+	}
+	if l.rpm > 0 {
+		l.requests--
+	}
+	if l.tpm > 0 {
+		l.tokens -= need
+		return 0, need
+	}
+	return 0, 0
+}
+
+// observe returns the tokens reserved for a request, which used records
+// once the response's usage is known, and takes any limits the response
+// reports: the account's limits, what is left of them, and after a 429, how
+// long to hold off.
+func (l *rateLimiter) observe(resp *http.Response, reserved float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.tokens+reserved, l.tpm)
+	if resp == nil {
+		return
+	}
+	header := func(name string) (float64, bool) {
+		v, err := strconv.ParseFloat(resp.Header.Get("anthropic-ratelimit-"+name), 64)
+		return v, err == nil
+	}
+	if limit, ok := header("requests-limit"); ok && !l.fixedRPM {
+		if l.rpm == 0 {
+			l.requests = limit
+		}
+		l.rpm = limit
+	}
+	if limit, ok := header("tokens-limit"); ok && !l.fixedTPM {
+		if l.tpm == 0 {
+			l.tokens = limit
+		}
+		l.tpm = limit
+	}
+	if left, ok := header("requests-remaining"); ok && l.rpm > 0 {
+		l.requests = min(l.requests, left)
+	}
+	if left, ok := header("tokens-remaining"); ok && l.tpm > 0 {
+		l.tokens = min(l.tokens, left)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		after, err := strconv.Atoi(resp.Header.Get("retry-after"))
+		if err != nil {
+			after = 10
+		}
+		l.paused = time.Now().Add(time.Duration(after) * time.Second)
+		logger.Debug("rate limit reached", "retry_after", after)
+	}
+}
+
+// used charges a response's tokens to the bucket and updates the estimate
+// for the next request. Reads from the prompt cache don't count toward the
+// limit.
+func (l *rateLimiter) used(u anthropic.BetaUsage) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tokens := float64(u.InputTokens + u.CacheCreationInputTokens + u.OutputTokens)
+	if l.tpm > 0 {
+		l.tokens -= tokens
+	}
+	if l.estimate == 0 {
+		l.estimate = tokens
+	} else {
+		l.estimate = (3*l.estimate + tokens) / 4
+	}
+}
+
+// status describes the throttle for the live display: empty unless
+// requests are waiting.
+func (l *rateLimiter) status() string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.waiting == 0 {
+		return ""
+	}
+	return fmt.Sprintf("rate limited, %d waiting (%s)", l.waiting, l.limits())
+}
+
+// limits describes the limits in force, e.g. "50 requests/min, 30000
+// tokens/min".
+func (l *rateLimiter) limits() string {
+	var parts []string
+	if l.rpm > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f requests/min", l.rpm))
+	}
+	if l.tpm > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f tokens/min", l.tpm))
+	}
+	if !l.paused.IsZero() && time.Now().Before(l.paused) {
+		parts = append(parts, "told to retry later")
+	}
+	return strings.Join(parts, ", ")
+}
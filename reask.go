@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// reaskFields are the fields worth a second request when they come back
+// blank, with the description the model is given for each.
+var reaskFields = []struct {
+	Name        string
+	Description string
+	value       func(*ReadingLog) *string
+}{
+	{"full_name", "The student's full name as written on the form", func(l *ReadingLog) *string { return &l.FullName }},
+	{"grade", "The student's grade level as written on the form", func(l *ReadingLog) *string { return &l.Grade }},
+}
+
+// hasReading reports whether anything was read from the form's days, which
+// means a blank name or grade is a misread rather than an empty form.
+func hasReading(log ReadingLog) bool {
+	for _, e := range log.ReadingEntries {
+		if filledIn(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlank reports whether a log is of an unfilled form: the model says so,
+// or there is neither a name nor any reading time.
+func isBlank(log ReadingLog) bool {
+	return log.Blank || (strings.TrimSpace(log.FullName) == "" && !hasReading(log))
+}
+
+// fillMissing asks the model again for just the fields that came back
+// blank on a form that clearly isn't empty. Fields still blank after that
+// are flagged as incomplete.
+func (p *pipeline) fillMissing(ctx context.Context, mediaType, encoded string, log *ReadingLog) error {
+	if !hasReading(*log) {
+		return nil
+	}
+	var missing []string
+	for _, f := range reaskFields {
+		if strings.TrimSpace(*f.value(log)) == "" {
+			missing = append(missing, f.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	values, err := p.client.AskFields(ctx, mediaType, encoded, missing)
+	if err != nil {
+		return fmt.Errorf("asking again for %s: %w", strings.Join(missing, ", "), err)
+	}
+	for _, f := range reaskFields {
+		if v := f.value(log); strings.TrimSpace(*v) == "" {
+			*v = strings.TrimSpace(values[f.Name])
+		}
+	}
+	flagMissing(log)
+	return nil
+}
+
+// flagMissing flags each re-askable field that is still blank.
+func flagMissing(log *ReadingLog) {
+	for _, f := range reaskFields {
+		if strings.TrimSpace(*f.value(log)) == "" {
+			log.Flags = append(log.Flags, Flag{Check: "incomplete", Detail: "no " + strings.ReplaceAll(f.Name, "_", " ") + " could be read"})
+		}
+	}
+}
+
+// AskFields asks Claude to look again for specific fields that came back
+// blank, returning what it finds (empty if they really aren't written).
+func (c *anthropicClient) AskFields(ctx context.Context, mediaType, encodedImage string, fields []string) (map[string]string, error) {
+	props := jsonschema.NewProperties()
+	var list []string
+	for _, name := range fields {
+		for _, f := range reaskFields {
+			if f.Name == name {
+				props.Set(name, &jsonschema.Schema{Type: "string", Description: f.Description})
+				list = append(list, strings.ReplaceAll(name, "_", " "))
+			}
+		}
+	}
+	schema := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           props,
+		Required:             fields,
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+
+	prompt := fmt.Sprintf(`This reading log has been filled in, but the student's %s could not be read on a first pass. Look carefully at the whole form again, including the header, margins, and any handwriting at an angle, and copy exactly what is written. Only return an empty value if it truly isn't written anywhere on the form.`, strings.Join(list, " and "))
+
+	text, err := c.ask(ctx, "ask-fields", mediaType, encodedImage, prompt, generateJSONSchema(schema))
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if err := json.Unmarshal([]byte(text), &values); err != nil {
+		return nil, fmt.Errorf("%w: %w\nraw: %s", errParse, err, text)
+	}
+	return values, nil
+}
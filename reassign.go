@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runReassign moves a completed result to a different classroom and/or week,
+// for images that were processed under the wrong attribution, and then
+// refreshes only the exports that the move affects.
+func runReassign(args []string) error {
+	fs := flag.NewFlagSet("reassign", flag.ContinueOnError)
+	week := fs.String("week", "", "week to move the result to")
+	classroom := fs.String("classroom", "", "classroom to move the result to")
+	dir := fs.String("dir", "exports", "chunked export directory to refresh, if present")
+	forceUnlock := lockFlag(fs)
+	outputFlags(fs)
+	files := parseArgs(fs, args)
+
+	if len(files) != 1 || (*week == "" && *classroom == "") {
+		return fmt.Errorf("usage: reassign <image> [--week W] [--classroom C]")
+	}
+	name := filepath.Base(files[0])
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+	if err := lockProgress(*forceUnlock); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	keys := progress.recordsOf(progress.keyOf(name))
+	if len(keys) == 0 {
+		return fmt.Errorf("no completed result for %s", name)
+	}
+
+	// A --multi image holds several students' records, which all move.
+	weeks := make(map[string]bool)
+	classrooms := make(map[string]bool)
+	moved := make([]ReadingLog, 0, len(keys))
+	var removed [][2]string // week and filename of each earlier history entry
+	for _, key := range keys {
+		log := progress.Completed[key]
+		oldWeek, oldClassroom := weekOf(log), classroomOf(log)
+		if *week != "" {
+			log.Week = *week
+		}
+		if *classroom != "" {
+			log.Classroom = *classroom
+		}
+		newWeek, newClassroom := weekOf(log), classroomOf(log)
+		progress.Completed[key] = log
+		weeks[oldWeek], weeks[newWeek] = true, true
+		classrooms[oldClassroom], classrooms[newClassroom] = true, true
+
+		source := progress.nameOf(key)
+		moved = append(moved, withSource(log, source))
+		green.Printf("  ✓ %s", source)
+		dim.Printf(" | week %s → %s | classroom %s → %s\n", oldWeek, newWeek, oldClassroom, newClassroom)
+		removed = append(removed, [2]string{oldWeek, source})
+	}
+
+	// Re-check the moved results against their new week, and the two
+	// classrooms whose baselines change.
+	only := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		only[key] = true
+	}
+	validateLogs(progress, only)
+	detectOutliers(progress, cfg.OutlierThreshold, classrooms)
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf("could not save progress: %w", err)
+	}
+
+	// Refresh the exports: the combined CSV always, and only the week
+	// chunks touched by the move when a chunked export exists.
+	allLogs := completedLogs(progress)
+	paths, err := writeOutput(allLogs)
+	if err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	dim.Printf("  Refreshed %s\n", strings.Join(paths, ", "))
+	err = editHistory(func(h *History) {
+		for _, r := range removed {
+			h.remove(r[0], r[1])
+		}
+		h.record(moved)
+	})
+	if err != nil {
+		return fmt.Errorf("could not update history: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(*dir, "index.json")); err == nil {
+		if _, err := exportChunked(*dir, newWeekLogs(progress), weeks); err != nil {
+			return fmt.Errorf("could not refresh chunked export: %w", err)
+		}
+		dim.Printf("  Refreshed %d week chunk(s) in %s\n", len(weeks), *dir)
+	}
+	return nil
+}
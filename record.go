@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// recording is one model response saved by --record.
+type recording struct {
+	Image    string `json:"image,omitempty"` // filename, for reading the file; not part of the key
+	Response string `json:"response"`
+}
+
+// recordings holds the responses saved with --record, keyed by request
+// kind, model, temperature, and image hash, so --replay can run the whole
+// pipeline again without calling the API.
+type recordings struct {
+	replay bool // answer from the recordings instead of the API
+
+	mu        sync.Mutex
+	responses map[string]recording
+}
+
+// openRecordings loads the saved responses. A missing file is an error
+// when replaying, since nothing could be answered.
+func openRecordings(replay bool) (*recordings, error) {
+	r := &recordings{replay: replay, responses: make(map[string]recording)}
+	data, err := readStoreFile(store, cfg.Recordings)
+	if errors.Is(err, os.ErrNotExist) && !replay {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.responses); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", cfg.Recordings, err)
+	}
+	return r, nil
+}
+
+// recordingKey identifies a request for --record and --replay. The prompt
+// isn't part of it, so replaying after editing the prompt returns the
+// responses to the old one.
+func recordingKey(request, model string, temperature *float64, imageSHA256 string) string {
+	t := "default"
+	if temperature != nil {
+		t = fmt.Sprint(*temperature)
+	}
+	return fmt.Sprintf("%s %s %s %s", request, model, t, imageSHA256)
+}
+
+// lookup returns the recorded response for key.
+func (r *recordings) lookup(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.responses[key]
+	return rec.Response, ok
+}
+
+// save records a response and writes the recordings file.
+func (r *recordings) save(key, image, response string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses[key] = recording{Image: image, Response: response}
+	data, err := json.MarshalIndent(r.responses, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStoreFile(store, cfg.Recordings, data)
+}
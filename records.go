@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// findResult returns the completed result, with two-sided forms merged, for
+// an image filename ("IMG_0912.heic", "IMG_0912.heic#2") or a student's name.
+func findResult(p *Progress, arg string) (ReadingLog, error) {
+	logs := completedLogs(p)
+	name := p.nameOf(p.keyOf(arg))
+	for _, log := range logs {
+		if log.Source == name || log.Back == name {
+			return log, nil
+		}
+	}
+
+	var found []ReadingLog
+	for _, log := range logs {
+		if sameText(log.FullName, arg) {
+			found = append(found, log)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return ReadingLog{}, fmt.Errorf("no result for %s", arg)
+	case 1:
+		return found[0], nil
+	default:
+		sources := make([]string, len(found))
+		for i, log := range found {
+			sources[i] = log.Source
+		}
+		return ReadingLog{}, fmt.Errorf("%d results for %q; name the image instead: %s", len(found), arg, strings.Join(sources, ", "))
+	}
+}
+
+// runShow prints one result in full.
+func runShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	targets := parseArgs(fs, args)
+	if len(targets) != 1 {
+		return fmt.Errorf("usage: show <image|student> [--json]")
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	log, err := findResult(progress, targets[0])
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(log)
+	}
+
+	bold.Printf("  %s", log.Source)
+	if log.Back != "" {
+		dim.Printf(" + %s", log.Back)
+	}
+	fmt.Println()
+	field := func(label, value, raw string) {
+		fmt.Printf("    %-10s %s", dim.Sprint(label), value)
+		if raw != "" {
+			dim.Printf("  (read as %q)", raw)
+		}
+		fmt.Println()
+	}
+	field("Student", log.FullName, "")
+	field("Grade", log.Grade, log.GradeRaw)
+	field("Teacher", log.HomeroomTeacher, log.TeacherRaw)
+	field("Week", weekOf(log), "")
+	field("Classroom", classroomOf(log), "")
+	if log.Template != "" {
+		field("Template", log.Template, "")
+	}
+	if log.Sender != "" {
+		field("Sender", log.Sender, "")
+	}
+	if log.PhotoTaken != "" {
+		field("Taken", log.PhotoTaken, "")
+	}
+	var state []string
+	if log.Verified {
+		state = append(state, "verified")
+	}
+	if log.Locked {
+		state = append(state, "finalized")
+	}
+	if len(state) > 0 {
+		field("Status", strings.Join(state, ", "), "")
+	}
+
+	total := 0
+	for _, e := range log.ReadingEntries {
+		total += e.Minutes
+		line := fmt.Sprintf("%4d min", e.Minutes)
+		if e.Written != "" && e.Written != fmt.Sprint(e.Minutes) {
+			line += dim.Sprintf("  (written %q)", e.Written)
+		}
+		if e.BookTitle != "" {
+			line += "  " + e.BookTitle
+		}
+		if e.Signed != nil && *e.Signed {
+			line += green.Sprint("  ✓ signed")
+		}
+		fmt.Printf("    %s %-14s %s\n", dim.Sprint("│"), dim.Sprintf("%s %s", e.Day, shortDate(e.Date)), line)
+	}
+	fmt.Printf("    %s %s\n", dim.Sprint("└"), boldGrn.Sprintf("Total: %d min", total))
+	if log.Notes != "" {
+		field("Notes", log.Notes, "")
+	}
+	extra := make([]string, 0, len(log.Extra))
+	for k := range log.Extra {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		field(k, formatValue(log.Extra[k]), "")
+	}
+	for _, f := range log.Flags {
+		fmt.Printf("    %s %s %s\n", yellow.Sprint("⚑"), dim.Sprintf("[%s]", f.Check), f.Detail)
+	}
+	return nil
+}
+
+// editColumns maps the field names edit accepts to the CSV columns they
+// correct.
+var editColumns = map[string]string{
+	"name":    "Full Name",
+	"grade":   "Grade",
+	"teacher": "Homeroom Teacher",
+	"notes":   "Notes",
+}
+
+// runEdit changes single fields of a result, such as a misspelled name,
+// without opening the whole result in an editor. Edited results are marked
+// as verified, like corrections made with review correct or import.
+func runEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	forceUnlock := lockFlag(fs)
+	outputFlags(fs)
+	positional := parseArgs(fs, args)
+	if len(positional) < 2 {
+		return fmt.Errorf("usage: edit <image|student> <field>=<value>... (fields: name, grade, teacher, notes, a date such as 1/30 for its minutes, or an extra field)")
+	}
+	if err := lockProgress(*forceUnlock); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	log, err := findResult(progress, positional[0])
+	if err != nil {
+		return err
+	}
+
+	// Express the edits as a row of the CSV the result would export to, so
+	// they are applied the same way as an imported correction.
+	var header []string
+	cells := make(map[string]string)
+	for _, assignment := range positional[1:] {
+		field, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("%q: expected <field>=<value>", assignment)
+		}
+		col, err := editColumn(log, strings.TrimSpace(field))
+		if err != nil {
+			return err
+		}
+		header = append(header, col)
+		cells[col] = value
+	}
+
+	changes, keys, err := correctFromRow(progress, log, header, cells)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		dim.Println("  No changes")
+		return nil
+	}
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf("could not save progress: %w", err)
+	}
+	green.Printf("  ✓ %s\n", log.Source)
+	for _, c := range changes {
+		fmt.Printf("    %s %s\n", dim.Sprint("│"), c)
+	}
+	if _, err := writeOutput(completedLogs(progress)); err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	if err := updateHistory(recordsFor(progress, keys)); err != nil {
+		return fmt.Errorf("could not update history: %w", err)
+	}
+	return nil
+}
+
+// editColumn returns the CSV column an edit's field name stands for.
+func editColumn(log ReadingLog, field string) (string, error) {
+	if col, ok := editColumns[strings.ToLower(field)]; ok {
+		return col, nil
+	}
+	if _, _, _, ok := parseDate(field); ok {
+		for _, d := range csvColumns([]ReadingLog{log}) {
+			if dateKey(d.Date) == dateKey(field) {
+				return d.Day + " " + d.Date, nil
+			}
+		}
+		for _, d := range cfg.Days {
+			if dateKey(d.Date) == dateKey(field) {
+				return d.Day + " " + d.Date, nil
+			}
+		}
+		return "", fmt.Errorf("%s: no such day on the form", field)
+	}
+	for _, f := range allFields() {
+		if f.Name == field && !f.perEntry() {
+			return f.Name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown field %q (want name, grade, teacher, notes, a date, or an extra field)", field)
+}
+
+// runDelete removes a result, for a photo that isn't a reading log or was
+// read so badly it is easier to start over. A named image is read again on
+// the next run unless it is removed too.
+func runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	forceUnlock := lockFlag(fs)
+	outputFlags(fs)
+	targets := parseArgs(fs, args)
+	if len(targets) != 1 {
+		return fmt.Errorf("usage: delete <image|student>")
+	}
+	if err := lockProgress(*forceUnlock); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	log, err := findResult(progress, targets[0])
+	if err != nil {
+		return err
+	}
+	if log.Locked {
+		return fmt.Errorf("%s is in finalized week %s; run finalize %s --undo first", log.Source, weekOf(log), weekOf(log))
+	}
+	for _, name := range []string{log.Source, log.Back} {
+		if name == "" {
+			continue
+		}
+		key := progress.keyOf(name)
+		delete(progress.Completed, key)
+		delete(progress.Review, key)
+	}
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf("could not save progress: %w", err)
+	}
+	green.Printf("  ✓ Deleted %s", log.Source)
+	dim.Printf(" | %s | %s\n", log.FullName, classroomOf(log))
+
+	if _, err := writeOutput(completedLogs(progress)); err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	err = editHistory(func(h *History) { h.remove(weekOf(log), log.Source) })
+	if err != nil {
+		return fmt.Errorf("could not update history: %w", err)
+	}
+	return nil
+}
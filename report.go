@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// runReport summarizes results across the season's history.
+func runReport(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: report cumulative|leaderboard|goals|trends|missing|html|pdf|contact-sheet|unsigned [flags]")
+	}
+	switch args[0] {
+	case "cumulative":
+		return runReportCumulative(args[1:])
+	case "leaderboard":
+		return runReportLeaderboard(args[1:])
+	case "goals":
+		return runReportGoals(args[1:])
+	case "trends":
+		return runReportTrends(args[1:])
+	case "missing":
+		return runReportMissing(args[1:])
+	case "html":
+		return runReportHTML(args[1:])
+	case "pdf":
+		return runReportPDF(args[1:])
+	case "contact-sheet":
+		return runReportContactSheet(args[1:])
+	case "unsigned":
+		return runReportUnsigned(args[1:])
+	default:
+		return fmt.Errorf("unknown report %q", args[0])
+	}
+}
+
+// seasonLogs returns every result in the history plus the current progress
+// file, which may hold results not yet recorded.
+func seasonLogs() ([]ReadingLog, error) {
+	h, err := loadHistory()
+	if err != nil {
+		return nil, err
+	}
+	progress, err := loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	h.record(completedLogs(progress))
+	logs := h.logs()
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no results in %s or %s", cfg.History, progressFile)
+	}
+	return logs, nil
+}
+
+// studentTotal is one student's reading across the weeks they appear in.
+type studentTotal struct {
+	Name      string
+	Grade     string
+	Classroom string
+	Minutes   int
+	Days      int // days on their forms, read or not
+	weeks     map[string]bool
+}
+
+// Weeks is the number of weeks the student turned in a log.
+func (s *studentTotal) Weeks() int { return len(s.weeks) }
+
+// AvgPerDay is the average minutes per day on the student's forms.
+func (s *studentTotal) AvgPerDay() float64 {
+	if s.Days == 0 {
+		return 0
+	}
+	return float64(s.Minutes) / float64(s.Days)
+}
+
+// studentKey identifies a student across weeks by name and classroom,
+// ignoring case and spacing differences in how the name was written.
+func studentKey(log ReadingLog) string {
+	return strings.ToLower(strings.Join(strings.Fields(log.FullName), " ") + "|" + classroomOf(log))
+}
+
+// aggregateStudents totals logs per student, ordered by classroom and name.
+func aggregateStudents(logs []ReadingLog) []*studentTotal {
+	byKey := make(map[string]*studentTotal)
+	var totals []*studentTotal
+	for _, log := range logs {
+		key := studentKey(log)
+		t, ok := byKey[key]
+		if !ok {
+			t = &studentTotal{Name: log.FullName, Classroom: classroomOf(log), weeks: make(map[string]bool)}
+			byKey[key] = t
+			totals = append(totals, t)
+		}
+		t.Grade = log.Grade // the latest week wins, in case a grade was misread
+		t.weeks[weekOf(log)] = true
+		for _, e := range log.ReadingEntries {
+			t.Minutes += e.Minutes
+			t.Days++
+		}
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		if totals[i].Classroom != totals[j].Classroom {
+			return totals[i].Classroom < totals[j].Classroom
+		}
+		return totals[i].Name < totals[j].Name
+	})
+	return totals
+}
+
+func runReportCumulative(args []string) error {
+	fs := flag.NewFlagSet("report cumulative", flag.ContinueOnError)
+	csvPath := fs.String("csv", "", "also write the report to this CSV file")
+	parseFlags(fs, args)
+
+	logs, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	totals := aggregateStudents(logs)
+
+	header := []string{"Student", "Grade", "Classroom", "Total Minutes", "Weeks", "Avg Minutes/Day"}
+	rows := make([][]string, len(totals))
+	for i, t := range totals {
+		rows[i] = []string{t.Name, t.Grade, t.Classroom, fmt.Sprint(t.Minutes), fmt.Sprint(t.Weeks()), fmt.Sprintf("%.1f", t.AvgPerDay())}
+	}
+
+	printTable(header, rows)
+	if *csvPath != "" {
+		if err := writeTableCSV(*csvPath, header, rows); err != nil {
+			return err
+		}
+		boldGrn.Printf("\n  Wrote %d student(s) to %s\n", len(rows), *csvPath)
+	}
+	return nil
+}
+
+// printTable prints rows under a bold header, with columns padded to fit.
+func printTable(header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len([]rune(h))
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+	line := func(cells []string) string {
+		parts := make([]string, len(cells))
+		for i, cell := range cells {
+			parts[i] = cell + strings.Repeat(" ", widths[i]-len([]rune(cell)))
+		}
+		return strings.TrimRight("  "+strings.Join(parts, "  "), " ")
+	}
+	bold.Println(line(header))
+	for _, row := range rows {
+		fmt.Println(line(row))
+	}
+}
+
+// writeTableCSV writes a report table to a CSV file in the store.
+func writeTableCSV(filename string, header []string, rows [][]string) error {
+	file, err := createStoreFile(store, filename)
+	if err != nil {
+		return err
+	}
+	w, err := newCSVWriter(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.Write(header)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
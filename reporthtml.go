@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+)
+
+// htmlReport is the data behind report html.
+type htmlReport struct {
+	Title     string
+	Generated string
+	Classes   []classTotal
+	Students  []*studentTotal
+	MaxMins   int
+	Trend     *trendChart
+	Flagged   []flaggedRow
+	Failed    []flaggedRow
+}
+
+type classTotal struct {
+	Name     string
+	Students int
+	Minutes  int
+}
+
+// PerStudent is the class's average minutes per student.
+func (c classTotal) PerStudent() float64 { return float64(c.Minutes) / float64(c.Students) }
+
+type flaggedRow struct {
+	File    string
+	Student string
+	Reason  string
+}
+
+// trendChart is a line chart of minutes per class per week.
+type trendChart struct {
+	Weeks  []string
+	Series []trendSeries
+	Max    int
+}
+
+type trendSeries struct {
+	Class   string
+	Color   string
+	Minutes []int // one per week
+}
+
+// chartColors are used in turn for the trend lines.
+var chartColors = []string{"#2563eb", "#dc2626", "#16a34a", "#d97706", "#7c3aed", "#0891b2", "#db2777", "#65a30d"}
+
+// Chart geometry, in SVG user units.
+const (
+	chartWidth  = 640
+	chartHeight = 240
+	barHeight   = 18
+	labelWidth  = 180
+)
+
+func runReportHTML(args []string) error {
+	fs := flag.NewFlagSet("report html", flag.ContinueOnError)
+	out := fs.String("out", "reading_report.html", "HTML file to write")
+	week := fs.String("week", "", "only report this week (default: the whole season)")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	season, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	logs := season
+	title := "Reading Logs — Season Report"
+	if *week != "" {
+		logs = nil
+		for _, log := range season {
+			if weekOf(log) == *week {
+				logs = append(logs, log)
+			}
+		}
+		if len(logs) == 0 {
+			return fmt.Errorf("no results for week %s", *week)
+		}
+		title = "Reading Logs — Week of " + *week
+	}
+
+	r := htmlReport{
+		Title:     title,
+		Generated: time.Now().Format("January 2, 2006 3:04 PM"),
+		Students:  aggregateStudents(logs),
+		Trend:     newTrendChart(season),
+	}
+	classes := make(map[string]*classTotal)
+	for _, s := range r.Students {
+		c, ok := classes[s.Classroom]
+		if !ok {
+			c = &classTotal{Name: s.Classroom}
+			classes[s.Classroom] = c
+		}
+		c.Students++
+		c.Minutes += s.Minutes
+		r.MaxMins = max(r.MaxMins, s.Minutes)
+	}
+	for _, c := range classes {
+		r.Classes = append(r.Classes, *c)
+	}
+	sort.Slice(r.Classes, func(i, j int) bool { return r.Classes[i].Name < r.Classes[j].Name })
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	for _, key := range progress.sortedByName(sortedKeys(progress.Review)) {
+		for _, f := range progress.Review[key] {
+			r.Flagged = append(r.Flagged, flaggedRow{progress.nameOf(key), progress.Completed[key].FullName, f.Detail})
+		}
+	}
+	for _, name := range sortedKeys(progress.Errors) {
+		r.Failed = append(r.Failed, flaggedRow{File: name, Reason: firstLine(progress.Errors[name])})
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, r); err != nil {
+		return err
+	}
+	if err := writeStoreFile(store, *out, buf.Bytes()); err != nil {
+		return err
+	}
+	boldGrn.Printf("  Wrote report for %d student(s) to %s\n", len(r.Students), *out)
+	return nil
+}
+
+// newTrendChart totals minutes per class per week, or returns nil when the
+// history covers fewer than two weeks.
+func newTrendChart(logs []ReadingLog) *trendChart {
+	totals := make(map[string]map[string]int) // class → week → minutes
+	weekSet := make(map[string]bool)
+	for _, log := range logs {
+		class, week := classroomOf(log), weekOf(log)
+		weekSet[week] = true
+		if totals[class] == nil {
+			totals[class] = make(map[string]int)
+		}
+		for _, e := range log.ReadingEntries {
+			totals[class][week] += e.Minutes
+		}
+	}
+	if len(weekSet) < 2 {
+		return nil
+	}
+
+	t := &trendChart{Weeks: sortedKeys(weekSet)}
+	for i, class := range sortedKeys(totals) {
+		s := trendSeries{Class: class, Color: chartColors[i%len(chartColors)]}
+		for _, w := range t.Weeks {
+			s.Minutes = append(s.Minutes, totals[class][w])
+			t.Max = max(t.Max, totals[class][w])
+		}
+		t.Series = append(t.Series, s)
+	}
+	return t
+}
+
+// Points renders a series as SVG polyline coordinates.
+func (t *trendChart) Points(s trendSeries) string {
+	var buf bytes.Buffer
+	for i, m := range s.Minutes {
+		fmt.Fprintf(&buf, "%.1f,%.1f ", t.X(i), t.Y(m))
+	}
+	return buf.String()
+}
+
+// X is the horizontal position of the i'th week.
+func (t *trendChart) X(i int) float64 {
+	return 40 + float64(i)*float64(chartWidth-60)/float64(len(t.Weeks)-1)
+}
+
+// Y is the vertical position of a minutes value.
+func (t *trendChart) Y(minutes int) float64 {
+	return float64(chartHeight-30) - float64(minutes)*float64(chartHeight-50)/float64(max(t.Max, 1))
+}
+
+// barWidth scales minutes to the width of the student bar chart.
+func barWidth(minutes, maxMinutes int) float64 {
+	return float64(minutes) * float64(chartWidth-labelWidth-50) / float64(max(maxMinutes, 1))
+}
+
+// sortedKeys returns the keys of a string-keyed map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"barWidth": barWidth,
+	"barEnd": func(minutes, maxMinutes int) float64 {
+		return labelWidth + 6 + barWidth(minutes, maxMinutes)
+	},
+	"barY":       func(i int) int { return i * (barHeight + 6) },
+	"barsHeight": func(n int) int { return n * (barHeight + 6) },
+	"add":        func(a, b int) int { return a + b },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2937; max-width: 760px; margin: 2em auto; padding: 0 1em; }
+  h1 { font-size: 1.6em; margin-bottom: 0; }
+  h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #e5e7eb; padding-bottom: .3em; }
+  .muted { color: #6b7280; font-size: .9em; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { text-align: left; padding: .35em .6em; border-bottom: 1px solid #f3f4f6; }
+  td.num, th.num { text-align: right; }
+  svg text { font-size: 12px; fill: #374151; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="muted">Generated {{.Generated}}</p>
+
+<h2>Classes</h2>
+<table>
+  <tr><th>Class</th><th class="num">Students</th><th class="num">Total minutes</th><th class="num">Per student</th></tr>
+  {{- range .Classes}}
+  <tr><td>{{.Name}}</td><td class="num">{{.Students}}</td><td class="num">{{.Minutes}}</td><td class="num">{{printf "%.0f" .PerStudent}}</td></tr>
+  {{- end}}
+</table>
+
+<h2>Minutes per student</h2>
+{{- $max := .MaxMins}}
+<svg width="640" height="{{barsHeight (len .Students)}}" role="img" aria-label="Minutes per student">
+  {{- range $i, $s := .Students}}
+  <text x="0" y="{{add (barY $i) 13}}">{{$s.Name}} ({{$s.Classroom}})</text>
+  <rect x="180" y="{{barY $i}}" width="{{printf "%.1f" (barWidth $s.Minutes $max)}}" height="18" fill="#2563eb" rx="2"></rect>
+  <text x="{{printf "%.1f" (barEnd $s.Minutes $max)}}" y="{{add (barY $i) 13}}">{{$s.Minutes}}</text>
+  {{- end}}
+</svg>
+
+{{- with .Trend}}
+<h2>Weekly trend</h2>
+<svg width="640" height="240" role="img" aria-label="Minutes per class per week">
+  <line x1="40" y1="210" x2="620" y2="210" stroke="#d1d5db"></line>
+  {{- $t := .}}
+  {{- range $i, $w := .Weeks}}
+  <text x="{{$t.X $i}}" y="228" text-anchor="middle">{{$w}}</text>
+  {{- end}}
+  {{- range .Series}}
+  <polyline points="{{$t.Points .}}" fill="none" stroke="{{.Color}}" stroke-width="2"></polyline>
+  {{- end}}
+</svg>
+<p>
+  {{- range .Series}}
+  <span style="color: {{.Color}}">&#9632;</span> {{.Class}}&nbsp;&nbsp;
+  {{- end}}
+</p>
+{{- end}}
+
+<h2>Needs attention</h2>
+{{- if or .Flagged .Failed}}
+<table>
+  <tr><th>Image</th><th>Student</th><th>Reason</th></tr>
+  {{- range .Flagged}}
+  <tr><td>{{.File}}</td><td>{{.Student}}</td><td>Flagged: {{.Reason}}</td></tr>
+  {{- end}}
+  {{- range .Failed}}
+  <tr><td>{{.File}}</td><td class="muted">—</td><td>Could not be read: {{.Reason}}</td></tr>
+  {{- end}}
+</table>
+{{- else}}
+<p class="muted">No flagged or failed images.</p>
+{{- end}}
+</body>
+</html>
+`))
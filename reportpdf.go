@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Page layout for printed summaries, in millimetres on US Letter.
+const (
+	pageMargin   = 12.0
+	maxRowHeight = 8.0
+	minRowHeight = 4.5
+)
+
+func runReportPDF(args []string) error {
+	fs := flag.NewFlagSet("report pdf", flag.ContinueOnError)
+	by := fs.String("by", "teacher", "one page per teacher or grade")
+	week := fs.String("week", "", "week to summarize (default: the most recent)")
+	dir := fs.String("dir", "reports", "directory to write the PDFs to")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	groupOf, err := groupBy(*by)
+	if err != nil {
+		return fmt.Errorf("--by: %w", err)
+	}
+	season, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	if *week == "" {
+		*week = latestWeek(season)
+	}
+
+	groups := make(map[string][]ReadingLog)
+	for _, log := range season {
+		if weekOf(log) == *week {
+			groups[groupOf(log)] = append(groups[groupOf(log)], log)
+		}
+	}
+	if len(groups) == 0 {
+		return fmt.Errorf("no results for week %s", *week)
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(groups) {
+		logs := groups[name]
+		sort.SliceStable(logs, func(i, j int) bool { return logs[i].FullName < logs[j].FullName })
+		path := filepath.Join(*dir, fileSafe(name)+"-"+fileSafe(*week)+".pdf")
+		if err := writeSummaryPDF(path, name, *week, logs); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("  %s %s\n", dim.Sprintf("%-20s", name), fmt.Sprintf("%d student(s) → %s", len(logs), path))
+	}
+	boldGrn.Printf("  Wrote %d summary page(s) to %s\n", len(groups), *dir)
+	return nil
+}
+
+// latestWeek returns the most recent week among logs.
+func latestWeek(logs []ReadingLog) string {
+	latest := ""
+	for _, log := range logs {
+		if w := weekOf(log); w > latest {
+			latest = w
+		}
+	}
+	return latest
+}
+
+// writeSummaryPDF writes a summaryPDF to path.
+func writeSummaryPDF(path, title, week string, logs []ReadingLog) error {
+	return savePDF(summaryPDF(title, week, logs), path)
+}
+
+// summaryPDF lays out a one-page table of each student's daily minutes and
+// weekly total. Rows shrink to keep large classes on a single page.
+func summaryPDF(title, week string, logs []ReadingLog) *fpdf.Fpdf {
+	cols := csvColumns(logs)
+
+	pdf := fpdf.New("P", "mm", "Letter", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(false, pageMargin)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // core fonts are cp1252
+
+	pageW, pageH := pdf.GetPageSize()
+	width := pageW - 2*pageMargin
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(width, 10, tr(title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.SetTextColor(100, 100, 100)
+	pdf.CellFormat(width, 6, tr("Reading minutes for the week of "+week), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(4)
+
+	rowH := (pageH - pdf.GetY() - pageMargin) / float64(len(logs)+2) // header and total rows
+	rowH = min(max(rowH, minRowHeight), maxRowHeight)
+	fontSize := min(rowH*2.8, 11)
+
+	totalW := 18.0
+	dayW := min(18.0, (width-60-totalW)/float64(len(cols)))
+	nameW := width - totalW - dayW*float64(len(cols))
+
+	pdf.SetFont("Helvetica", "B", fontSize)
+	pdf.SetFillColor(230, 236, 245)
+	pdf.CellFormat(nameW, rowH, "Student", "1", 0, "L", true, 0, "")
+	for _, c := range cols {
+		label := c.Date
+		if len(c.Day) >= 3 {
+			label = c.Day[:3] + " " + c.Date
+		}
+		pdf.CellFormat(dayW, rowH, tr(label), "1", 0, "C", true, 0, "")
+	}
+	pdf.CellFormat(totalW, rowH, "Total", "1", 1, "C", true, 0, "")
+
+	pdf.SetFont("Helvetica", "", fontSize)
+	classTotal := 0
+	for i, log := range logs {
+		fill := i%2 == 1
+		pdf.SetFillColor(247, 247, 247)
+		pdf.CellFormat(nameW, rowH, tr(log.FullName), "1", 0, "L", fill, 0, "")
+		total := 0
+		for _, e := range log.ReadingEntries {
+			total += e.Minutes
+		}
+		for _, c := range cols {
+			pdf.CellFormat(dayW, rowH, formatMinutes(log.ReadingEntries, c.Date), "1", 0, "C", fill, 0, "")
+		}
+		pdf.CellFormat(totalW, rowH, fmt.Sprint(total), "1", 1, "C", fill, 0, "")
+		classTotal += total
+	}
+
+	pdf.SetFont("Helvetica", "B", fontSize)
+	pdf.CellFormat(width-totalW, rowH, fmt.Sprintf("Class total (%d students)", len(logs)), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(totalW, rowH, fmt.Sprint(classTotal), "1", 1, "C", false, 0, "")
+	return pdf
+}
+
+// savePDF writes a finished PDF to a local file, encrypted if encryption is
+// on.
+func savePDF(pdf *fpdf.Fpdf, path string) error {
+	f, err := createLocalFile(path, 0644)
+	if err != nil {
+		pdf.Close()
+		return err
+	}
+	return pdf.OutputAndClose(f)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// runRetry reprocesses only the images recorded in Progress.Errors, optionally
+// with a different model or a larger token budget. Images that now succeed
+// move to Completed and their errors are cleared.
+func runRetry(args []string) error {
+	fs := flag.NewFlagSet("retry", flag.ContinueOnError)
+	maxTokens := fs.Int64("max-tokens", cfg.MaxTokens, "max output tokens per request")
+	var kinds stringList
+	fs.Var(&kinds, "kind", "only retry images that failed this way: "+strings.Join(failureKinds, ", ")+" (repeatable)")
+	newPipeline := pipelineFlags(fs)
+	outputFlags(fs)
+	displayFlags(fs)
+	parseFlags(fs, args)
+	for _, kind := range kinds {
+		if !slices.Contains(failureKinds, kind) {
+			return configErrorf("--kind must be one of %s", strings.Join(failureKinds, ", "))
+		}
+	}
+
+	printBanner()
+
+	pipe, err := newPipeline()
+	if err != nil {
+		return err
+	}
+	if err := pipe.checkAPIKey(); err != nil {
+		return err
+	}
+	if c, ok := pipe.client.(*anthropicClient); ok {
+		c.maxTokens = *maxTokens
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	if len(progress.Errors) == 0 {
+		green.Println("  No failed images to retry")
+		return nil
+	}
+
+	// Errors for images that have since been deleted can't be retried.
+	available := make(map[string]bool)
+	names, err := findImages(store)
+	if err != nil {
+		return fmt.Errorf("finding images: %w", err)
+	}
+	for _, name := range names {
+		available[name] = true
+	}
+	var images []string
+	for name := range progress.Errors {
+		switch {
+		case !available[name]:
+			yellow.Printf("  Skipping %s: image no longer present\n", name)
+		case progress.protected(progress.idOf(name)):
+			yellow.Printf("  Skipping %s: its result is verified or finalized\n", name)
+		case len(kinds) > 0 && !slices.Contains(kinds, progress.Failures[name]):
+			// failed some other way, or before kinds were recorded
+		default:
+			images = append(images, name)
+		}
+	}
+	sort.Strings(images)
+	if len(images) == 0 {
+		if len(kinds) > 0 {
+			green.Printf("  No images failed with %s\n", strings.Join(kinds, " or "))
+		}
+		return nil
+	}
+
+	cyan.Printf("  Retrying %d failed image(s) with %s (max %d tokens)\n\n", len(images), resolveModel(fs.Lookup("model").Value.String()), *maxTokens)
+	succeeded, blank, failed := pipe.runBatch(progress, images, func(string) bool { return false }, nil)
+
+	flagged, rows, paths := pipe.finishRun(progress, cfg.OutlierThreshold)
+	pipe.report(len(images), succeeded, blank, failed, 0, flagged, rows, paths)
+	if code := pipe.exitCode(); code != 0 {
+		exit(code)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Flag is a reason a result was routed to human review.
+type Flag struct {
+	Check  string `json:"check"` // the check that raised it, e.g. "outlier"
+	Detail string `json:"detail"`
+}
+
+// setFlags replaces every flag raised by check with the given per-file
+// details, leaving flags from other checks alone. If only is non-nil, just
+// those files are touched.
+func setFlags(p *Progress, check string, details map[string][]string, only map[string]bool) {
+	for name, flags := range p.Review {
+		if only != nil && !only[name] {
+			continue
+		}
+		kept := flags[:0]
+		for _, f := range flags {
+			if f.Check != check {
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.Review, name)
+		} else {
+			p.Review[name] = kept
+		}
+	}
+	for name, list := range details {
+		if only != nil && !only[name] {
+			continue
+		}
+		for _, d := range list {
+			p.Review[name] = append(p.Review[name], Flag{Check: check, Detail: d})
+		}
+	}
+}
+
+// printReviewQueue lists flagged results with the reasons they were flagged,
+// and with preview, the image each was read from.
+func printReviewQueue(p *Progress, preview bool) {
+	keys := make([]string, 0, len(p.Review))
+	for key := range p.Review {
+		keys = append(keys, key)
+	}
+
+	for _, key := range p.sortedByName(keys) {
+		log := p.Completed[key]
+		yellow.Printf("  ⚑ %s", p.nameOf(key))
+		dim.Printf(" | %s | %s\n", log.FullName, classroomOf(log))
+		for _, f := range p.Review[key] {
+			fmt.Printf("    %s %s %s\n", dim.Sprint("│"), dim.Sprintf("[%s]", f.Check), f.Detail)
+		}
+		if preview {
+			if err := previewImage(p.Images[imageOf(key)], true); err != nil {
+				logger.Warn("could not show "+p.nameOf(key), "err", err)
+			}
+		}
+	}
+}
+
+// runReview lists the results waiting for human review, or corrects one.
+func runReview(args []string) error {
+	if len(args) > 0 && args[0] == "correct" {
+		return runReviewCorrect(args[1:])
+	}
+	if len(args) > 0 && args[0] == "approve" {
+		return runReviewApprove(args[1:])
+	}
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	preview := fs.Bool("preview", false, "show each flagged image: in the terminal if it can draw images, otherwise in the image viewer")
+	if extra := parseArgs(fs, args); len(extra) > 0 {
+		return errors.New(msg("usage: review [--preview] | review approve <image>... | review correct <image>"))
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	if len(progress.Review) == 0 {
+		green.Println(msg("  Nothing to review"))
+		return nil
+	}
+	bold.Printf(msg("  %d result(s) flagged for review\n\n"), len(progress.Review))
+	printReviewQueue(progress, *preview)
+	return nil
+}
+
+// runReviewApprove marks results as checked by a person and correct as
+// read, clearing their review flags.
+func runReviewApprove(args []string) error {
+	fs := flag.NewFlagSet("review approve", flag.ContinueOnError)
+	forceUnlock := lockFlag(fs)
+	files := parseArgs(fs, args)
+	if len(files) == 0 {
+		return errors.New(msg("usage: review approve <image>..."))
+	}
+	if err := lockProgress(*forceUnlock); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		key := progress.keyOf(name)
+		log, ok := progress.Completed[key]
+		if !ok {
+			return fmt.Errorf(msg("no completed result for %s"), name)
+		}
+		log.Verified = true
+		progress.Completed[key] = log
+		delete(progress.Review, key)
+		green.Printf("  ✓ %s", name)
+		dim.Printf(msg(" | %s | verified\n"), log.FullName)
+	}
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf(msg("could not save progress: %w"), err)
+	}
+	if _, err := writeOutput(completedLogs(progress)); err != nil {
+		return fmt.Errorf(msg("could not write output: %w"), err)
+	}
+	return nil
+}
+
+// runReviewCorrect lets a person fix a misread result in their editor. The
+// corrected result replaces the original, clears its review flags, and is
+// kept with its image as an example for future prompts (see --examples).
+func runReviewCorrect(args []string) error {
+	fs := flag.NewFlagSet("review correct", flag.ContinueOnError)
+	from := fs.String("from", "", "read the corrected result from this JSON file instead of opening $EDITOR")
+	noExample := fs.Bool("no-example", false, "don't keep the correction as a prompt example")
+	preview := fs.Bool("preview", false, "open the image in the image viewer if the terminal can't draw it")
+	forceUnlock := lockFlag(fs)
+	files := parseArgs(fs, args)
+	if len(files) != 1 {
+		return errors.New(msg("usage: review correct <image> [--from file.json] [--no-example]"))
+	}
+	name := filepath.Base(files[0])
+	if err := lockProgress(*forceUnlock); err != nil {
+		return err
+	}
+
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	key := progress.keyOf(name)
+	log, ok := progress.Completed[key]
+	if !ok {
+		return fmt.Errorf(msg("no completed result for %s"), name)
+	}
+	before, err := json.MarshalIndent(modelFields(log), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var after []byte
+	if *from != "" {
+		after, err = os.ReadFile(*from)
+	} else {
+		// Show the handwriting next to the result being corrected.
+		if err := previewImage(progress.Images[imageOf(key)], *preview); err != nil {
+			logger.Warn("could not show "+name, "err", err)
+		}
+		after, err = editText(name, before)
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(bytes.TrimSpace(after), bytes.TrimSpace(before)) {
+		dim.Println(msg("  No changes"))
+		return nil
+	}
+	var fixed ReadingLog
+	dec := json.NewDecoder(bytes.NewReader(after))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fixed); err != nil {
+		return fmt.Errorf(msg("corrected result: %w"), err)
+	}
+
+	corrected := applyCorrection(log, fixed)
+	normalizeLog(&corrected)
+	corrected.Verified = true
+	progress.Completed[key] = corrected
+	delete(progress.Review, key)
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf(msg("could not save progress: %w"), err)
+	}
+	green.Printf("  ✓ %s", name)
+	dim.Printf(msg(" | %s | corrected\n"), corrected.FullName)
+
+	if _, err := writeOutput(completedLogs(progress)); err != nil {
+		return fmt.Errorf(msg("could not write output: %w"), err)
+	}
+	if err := updateHistory(recordsFor(progress, []string{key})); err != nil {
+		return fmt.Errorf(msg("could not update history: %w"), err)
+	}
+
+	if *noExample {
+		return nil
+	}
+	if _, several := progress.Completed[recordKey(imageOf(key), 1)]; several {
+		// The model would need every student on the image to learn from it.
+		dim.Println(msg("  Not kept as an example: the image has more than one student's log"))
+		return nil
+	}
+	mediaType, encoded, err := loadImage(name)
+	if err != nil {
+		return fmt.Errorf(msg("could not keep %s as an example: %w"), name, err)
+	}
+	err = saveExemplar(exemplar{
+		Image: name, Template: corrected.Template, MediaType: mediaType, Data: encoded,
+		Log: modelFields(corrected), Saved: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf(msg("could not keep %s as an example: %w"), name, err)
+	}
+	dim.Printf(msg("  Kept as an example in %s\n"), cfg.Exemplars)
+	return nil
+}
+
+// applyCorrection copies the fields a person corrected onto the original
+// result, keeping what the tool recorded alongside. Values as read are
+// dropped where they were corrected, so normalization doesn't undo the fix.
+func applyCorrection(log, fixed ReadingLog) ReadingLog {
+	if fixed.Grade != log.Grade {
+		log.GradeRaw = ""
+	}
+	if fixed.HomeroomTeacher != log.HomeroomTeacher {
+		log.TeacherRaw = ""
+	}
+	// Minutes are recomputed from the written time; if only the minutes were
+	// fixed, the written time is what was wrong.
+	for i, e := range fixed.ReadingEntries {
+		if m, ok := parseDuration(e.Written); ok && m != e.Minutes {
+			fixed.ReadingEntries[i].Written = fmt.Sprint(e.Minutes)
+		}
+	}
+	log.FullName = fixed.FullName
+	log.Grade = fixed.Grade
+	log.HomeroomTeacher = fixed.HomeroomTeacher
+	log.ReadingEntries = fixed.ReadingEntries
+	log.Notes = fixed.Notes
+	log.Extra = fixed.Extra
+	return log
+}
+
+// editText opens text in $EDITOR (vi by default) and returns what was saved.
+func editText(name string, text []byte) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "reading-log-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(text)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if headless {
+		return nil, errors.New(msg("editing needs a terminal; run without --headless"))
+	}
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	dim.Printf(msg("  Editing %s in %s\n"), name, editor)
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", tmp.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w", editor, err)
+	}
+	return os.ReadFile(tmp.Name())
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// rosterStudent is one student on the school's roster.
+type rosterStudent struct {
+	Name    string
+	Grade   string
+	Teacher string
+}
+
+// rosterColumns are the headers each roster field is read from, compared
+// ignoring case.
+var rosterColumns = map[string][]string{
+	"name":    {"student", "name", "full name", "student name"},
+	"grade":   {"grade"},
+	"teacher": {"teacher", "homeroom teacher", "homeroom", "classroom"},
+}
+
+// loadRoster reads a roster CSV with a student and a teacher column, and
+// optionally a grade. Names written "Last, First" are turned around, and
+// teachers and grades are normalized like those read from forms.
+func loadRoster(path string) ([]rosterStudent, error) {
+	data, err := readLocalFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("roster: %w", err)
+	}
+	rows, err := readCSV(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("%s has no students", path)
+	}
+	header := rows[0]
+
+	col := map[string]int{}
+	for field, names := range rosterColumns {
+		for i, h := range header {
+			if slices.Contains(names, strings.ToLower(strings.TrimSpace(h))) {
+				col[field] = i
+				break
+			}
+		}
+	}
+	for _, field := range []string{"name", "teacher"} {
+		if _, ok := col[field]; !ok {
+			return nil, fmt.Errorf("%s has no %s column (looked for %s)", path, field, strings.Join(rosterColumns[field], ", "))
+		}
+	}
+
+	cell := func(row []string, field string) string {
+		i, ok := col[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	var students []rosterStudent
+	for _, row := range rows[1:] {
+		name := cell(row, "name")
+		if name == "" {
+			continue
+		}
+		if last, first, ok := strings.Cut(name, ","); ok {
+			name = strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+		}
+		s := rosterStudent{Name: name, Teacher: normalizeTeacher(cell(row, "teacher"))}
+		if g := cell(row, "grade"); g != "" {
+			s.Grade = normalizeGrade(g)
+		}
+		students = append(students, s)
+	}
+	return students, nil
+}
+
+// nameKey is the form student names are compared in.
+func nameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// turnedIn reports whether a roster student has one of logs: one from their
+// own classroom whose name is at most a few misread letters off, or one
+// with exactly their name from any classroom, in case the teacher was
+// misread instead.
+func turnedIn(s rosterStudent, logs []ReadingLog) bool {
+	key := nameKey(s.Name)
+	for _, log := range logs {
+		k := nameKey(log.FullName)
+		if k == key {
+			return true
+		}
+		if teacherKey(classroomOf(log)) == teacherKey(s.Teacher) && editDistance(k, key) <= fuzzyLimit(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// runReportMissing lists the students on the roster with no log for a week,
+// by teacher, so teachers know who to remind.
+func runReportMissing(args []string) error {
+	fs := flag.NewFlagSet("report missing", flag.ContinueOnError)
+	rosterPath := fs.String("roster", cfg.Roster, "roster CSV of every student and their teacher")
+	week := fs.String("week", "", "week to check (default: the most recent)")
+	teacher := fs.String("teacher", "", "only list this teacher's students")
+	csvPath := fs.String("csv", "", "also write the list to this CSV file")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	if *rosterPath == "" {
+		return configErrorf("no roster; set roster in the config file or pass --roster")
+	}
+	roster, err := loadRoster(*rosterPath)
+	if err != nil {
+		return err
+	}
+	season, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	if *week == "" {
+		*week = latestWeek(season)
+	}
+	var logs []ReadingLog
+	for _, log := range season {
+		if weekOf(log) == *week {
+			logs = append(logs, log)
+		}
+	}
+	if len(logs) == 0 {
+		return fmt.Errorf("no results for week %s", *week)
+	}
+
+	byTeacher := make(map[string][]rosterStudent)
+	checked := 0
+	for _, s := range roster {
+		if *teacher != "" && teacherKey(s.Teacher) != teacherKey(normalizeTeacher(*teacher)) {
+			continue
+		}
+		checked++
+		if !turnedIn(s, logs) {
+			byTeacher[s.Teacher] = append(byTeacher[s.Teacher], s)
+		}
+	}
+	if checked == 0 {
+		return fmt.Errorf("no students on the roster for %s", *teacher)
+	}
+
+	header := []string{"Teacher", "Student", "Grade"}
+	var rows [][]string
+	for _, t := range sortedKeys(byTeacher) {
+		students := byTeacher[t]
+		slices.SortFunc(students, func(a, b rosterStudent) int { return strings.Compare(nameKey(a.Name), nameKey(b.Name)) })
+		for _, s := range students {
+			rows = append(rows, []string{t, s.Name, s.Grade})
+		}
+	}
+
+	bold.Printf("\n  Missing logs for the week of %s\n\n", *week)
+	if len(rows) == 0 {
+		green.Printf("  All %d students on the roster turned in a log\n", checked)
+		return nil
+	}
+	shown := make([][]string, len(rows))
+	for i, row := range rows {
+		shown[i] = slices.Clone(row)
+		if i > 0 && rows[i-1][0] == row[0] {
+			shown[i][0] = ""
+		}
+	}
+	printTable(header, shown)
+	yellow.Printf("\n  %d of %d students have no log\n", len(rows), checked)
+
+	if *csvPath != "" {
+		if err := writeTableCSV(*csvPath, header, rows); err != nil {
+			return err
+		}
+		boldGrn.Printf("\n  Wrote %d student(s) to %s\n", len(rows), *csvPath)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// runIDLayout names each run's directory. Colons aren't allowed in Windows
+// filenames, so the time uses dashes.
+const runIDLayout = "2006-01-02T15-04-05"
+
+// runLedger is the file in the runs directory that lists every run.
+const runLedger = "runs.jsonl"
+
+// runRecord is one run as listed in the ledger.
+type runRecord struct {
+	ID       string            `json:"id"` // also the name of the run's directory
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Command  string            `json:"command"`
+	Model    string            `json:"model"`
+	Images   map[string]string `json:"images"`  // image read → completed, blank, or failed
+	Results  int               `json:"results"` // results in the output
+	Output   string            `json:"output"`  // the run's copy of the output, the first with several formats
+}
+
+// runLog keeps a record of the run and a copy of what it wrote. A nil
+// *runLog keeps nothing.
+type runLog struct {
+	dir    string
+	mu     sync.Mutex
+	record runRecord
+}
+
+func newRunLog(dir, model string) *runLog {
+	if dir == "" {
+		return nil
+	}
+	now := time.Now()
+	return &runLog{dir: dir, record: runRecord{
+		ID:      now.Format(runIDLayout),
+		Started: now,
+		Command: commandLine(),
+		Model:   model,
+		Images:  make(map[string]string),
+	}}
+}
+
+// id is the run's ID, or "" if runs aren't kept.
+func (r *runLog) id() string {
+	if r == nil {
+		return ""
+	}
+	return r.record.ID
+}
+
+// note records what became of an image the run read.
+func (r *runLog) note(name, outcome string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record.Images[name] = outcome
+}
+
+// save writes the run's directory — a copy of each output and every result
+// as JSON, for comparing runs — adds the run to the ledger, and points the
+// latest link at it. It returns the directory.
+func (r *runLog) save(logs []ReadingLog, outputs []string) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dir := path.Join(r.dir, r.record.ID)
+
+	for i, output := range outputs {
+		data, err := readStoreFile(store, output)
+		if err != nil {
+			return "", err
+		}
+		copied := path.Join(dir, path.Base(output))
+		if err := writeStoreFile(store, copied, data); err != nil {
+			return "", err
+		}
+		if i == 0 {
+			r.record.Output = copied
+		}
+	}
+	results, err := json.MarshalIndent(logs, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeStoreFile(store, path.Join(dir, "results.json"), append(results, '\n')); err != nil {
+		return "", err
+	}
+
+	r.record.Finished = time.Now()
+	r.record.Results = len(logs)
+	line, err := json.Marshal(r.record)
+	if err != nil {
+		return "", err
+	}
+	ledger := path.Join(r.dir, runLedger)
+	existing, err := readStoreFile(store, ledger)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := writeStoreFile(store, ledger, append(existing, append(line, '\n')...)); err != nil {
+		return "", err
+	}
+
+	// Buckets have no links; the ledger's last line is the latest run there.
+	if d, ok := store.(dirStore); ok {
+		latest := d.path(path.Join(r.dir, "latest"))
+		os.Remove(latest)
+		if err := os.Symlink(r.record.ID, latest); err != nil {
+			dim.Printf("  Could not link %s to the latest run: %v\n", latest, err)
+		}
+	}
+	return dir, nil
+}
+
+// loadRuns reads the ledger, oldest run first.
+func loadRuns() ([]runRecord, error) {
+	data, err := readStoreFile(store, path.Join(cfg.RunsDir, runLedger))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []runRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var run runRecord
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", runLedger, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
+
+// runRuns lists the runs in the ledger.
+func runRuns(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: runs")
+	}
+	runs, err := loadRuns()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		dim.Printf("  No runs recorded in %s\n", cfg.RunsDir)
+		return nil
+	}
+	bold.Printf("  %-21s %9s %6s %6s %8s  %s\n", "Run", "Completed", "Blank", "Failed", "Results", "Model")
+	for _, run := range runs {
+		counts := make(map[string]int)
+		for _, outcome := range run.Images {
+			counts[outcome]++
+		}
+		fmt.Printf("  %-21s %9d %6d %6d %8d  %s\n", run.ID, counts["completed"], counts["blank"], counts["failed"], run.Results, dim.Sprint(run.Model))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+var signedYes, signedNo = true, false
+
+// exampleLog is the sample record used in generated documentation.
+var exampleLog = ReadingLog{
+	FullName:        "Flora Willoughby",
+	Grade:           "Kinder",
+	HomeroomTeacher: "Alm",
+	ReadingEntries: []ReadingEntry{
+		{Day: "Friday", Date: "1/30", Minutes: 10, Written: "10 min", BookTitle: "Frog and Toad", Signed: &signedYes},
+		{Day: "Saturday", Date: "1/31", Minutes: 10, Written: "10", BookTitle: "Frog and Toad", Signed: &signedYes},
+		{Day: "Sunday", Date: "2/1", Minutes: 10, Written: "10 min", Signed: &signedNo},
+		{Day: "Monday", Date: "2/2", Minutes: 90, Written: "1½ hrs", BookTitle: "Owl Moon", Signed: &signedYes},
+		{Day: "Tuesday", Date: "2/3", Minutes: 0, Signed: &signedNo},
+		{Day: "Wednesday", Date: "2/4", Minutes: 0, Signed: &signedNo},
+		{Day: "Thursday", Date: "2/5", Minutes: 0, Signed: &signedNo},
+	},
+	Notes: "Read with grandma on Monday",
+}
+
+// runSchema documents the data contract for integrators.
+func runSchema(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("usage: schema print [--format all|jsonschema|markdown|example|prompt] [--template name]")
+	}
+	fs := flag.NewFlagSet("schema print", flag.ContinueOnError)
+	format := fs.String("format", "all", "what to print: all, jsonschema, markdown, example, or prompt (the built-in prompt template)")
+	template := fs.String("template", "", "form template to document (default: the top-level days and fields)")
+	parseFlags(fs, args[1:])
+
+	form := defaultTemplate()
+	if *template != "" {
+		var err error
+		if form, err = lookupTemplate(*template); err != nil {
+			return err
+		}
+	}
+	schema := extractionSchema(form)
+	switch *format {
+	case "jsonschema":
+		return printJSON(schema)
+	case "markdown":
+		fmt.Print(schemaMarkdown(schema))
+		return nil
+	case "example":
+		return printExamples()
+	case "prompt":
+		fmt.Println(defaultPrompt)
+		return nil
+	case "all":
+		fmt.Println("## JSON Schema (model extraction)")
+		fmt.Println()
+		fmt.Println("```json")
+		if err := printJSON(schema); err != nil {
+			return err
+		}
+		fmt.Println("```")
+		fmt.Println()
+		fmt.Println("## Fields")
+		fmt.Println()
+		fmt.Print(schemaMarkdown(schema))
+		fmt.Println()
+		fmt.Println("## Examples")
+		fmt.Println()
+		return printExamples()
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+// schemaMarkdown renders a schema's properties as a Markdown table, with
+// nested array items flattened to dotted paths like reading_entries[].day.
+func schemaMarkdown(schema *jsonschema.Schema) string {
+	var sb strings.Builder
+	sb.WriteString("| Field | Type | Required | Description |\n")
+	sb.WriteString("|---|---|---|---|\n")
+	writeSchemaRows(&sb, schema, "")
+	return sb.String()
+}
+
+func writeSchemaRows(sb *strings.Builder, schema *jsonschema.Schema, prefix string) {
+	if schema.Properties == nil {
+		return
+	}
+	required := make(map[string]bool)
+	for _, r := range schema.Required {
+		required[r] = true
+	}
+	for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+		name, prop := prefix+pair.Key, pair.Value
+		typ := prop.Type
+		if typ == "array" && prop.Items != nil {
+			typ = prop.Items.Type + "[]"
+		}
+		req := ""
+		if required[pair.Key] {
+			req = "yes"
+		}
+		fmt.Fprintf(sb, "| `%s` | %s | %s | %s |\n", name, typ, req, prop.Description)
+
+		if prop.Type == "object" {
+			writeSchemaRows(sb, prop, name+".")
+		} else if prop.Type == "array" && prop.Items != nil && prop.Items.Type == "object" {
+			writeSchemaRows(sb, prop.Items, name+"[].")
+		}
+	}
+}
+
+// printExamples prints an example model response and the stored record it
+// becomes once the tool adds its metadata.
+func printExamples() error {
+	fmt.Println("Model response:")
+	fmt.Println()
+	fmt.Println("```json")
+	if err := printJSON(exampleLog); err != nil {
+		return err
+	}
+	fmt.Println("```")
+	fmt.Println()
+
+	stored := exampleLog
+	stored.ReadingEntries = slices.Clone(exampleLog.ReadingEntries)
+	stored.Sender = "parent@example.org"
+	stored.PhotoTaken = "2026-02-05T19:42:10-08:00"
+	stored.Week = "01-30"
+	stored.Classroom = "Alm"
+	normalizeLog(&stored)
+	fmt.Println("Stored record (.progress.json `completed` values):")
+	fmt.Println()
+	fmt.Println("```json")
+	if err := printJSON(stored); err != nil {
+		return err
+	}
+	fmt.Println("```")
+	return nil
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
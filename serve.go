@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxUploadBytes bounds the size of a single uploaded photo.
+const maxUploadBytes = 25 << 20
+
+// uploadServer accepts photos from an Apple Shortcut (or curl) and parses them
+// with the same pipeline as a batch run.
+type uploadServer struct {
+	token string
+	pipe  *pipeline
+
+	mu       sync.Mutex // guards progress and the progress file
+	progress *Progress
+}
+
+// runServe starts an HTTP endpoint for sharing photos straight from a phone.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	newPipeline := pipelineFlags(fs)
+	parseFlags(fs, args)
+
+	token := os.Getenv("SERVE_TOKEN")
+	if token == "" {
+		return configErrorf("set SERVE_TOKEN to the shared secret the Shortcut sends")
+	}
+
+	pipe, err := newPipeline()
+	if err != nil {
+		return err
+	}
+	if err := pipe.checkAPIKey(); err != nil {
+		return err
+	}
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	srv := &uploadServer{token: token, pipe: pipe, progress: progress}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /upload", srv.handleUpload)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	printBanner()
+	cyan.Printf("  Listening on %s (POST /upload)\n", *addr)
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return httpServer.ListenAndServe()
+}
+
+// handleUpload saves the posted image, parses it, and replies with a summary.
+// The body may be the raw image bytes (Shortcuts "File" body) or a multipart
+// form with a "file" field.
+func (s *uploadServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	data, filename, contentType, err := readUpload(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name, err := uploadName(filename, contentType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err := writeStoreFile(store, name, data); err != nil {
+		http.Error(w, "could not save upload", http.StatusInternalServerError)
+		return
+	}
+	printProgress(1, 1, 0, name)
+
+	logs, err := s.pipe.processImage(context.Background(), name)
+	s.pipe.artifacts.cleanup()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		printError(name, err)
+		storeFailure(s.progress, name, err)
+		saveProgress(s.progress)
+		http.Error(w, "could not read this reading log: "+firstLine(err.Error()), http.StatusUnprocessableEntity)
+		return
+	}
+	keys := storeResults(s.progress, name, logs)
+	if len(keys) == 0 {
+		dim.Println("    ○ blank form, no result recorded")
+		saveProgress(s.progress)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "This looks like a blank form, so nothing was recorded.")
+		return
+	}
+	only := make(map[string]bool)
+	results := make([]ReadingLog, len(keys))
+	for i := range keys {
+		only[keys[i]] = true
+		results[i] = s.progress.Completed[keys[i]]
+		printResult(&results[i])
+	}
+	validateLogs(s.progress, only)
+	detectOutliers(s.progress, cfg.OutlierThreshold, nil)
+	if err := saveProgress(s.progress); err != nil {
+		logger.Warn("could not save progress", "err", err)
+	}
+	if err := updateHistory(recordsFor(s.progress, keys)); err != nil {
+		logger.Warn("could not update history", "err", err)
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			File    string       `json:"file"`
+			Log     ReadingLog   `json:"log"`
+			Flagged []Flag       `json:"flagged,omitempty"`
+			Others  []ReadingLog `json:"others,omitempty"` // further students on the same image
+		}{name, results[0], s.progress.Review[keys[0]], results[1:]})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, summaryText(&results[i], s.progress.Review[key]))
+	}
+}
+
+// authorized checks the shared token from the Authorization header or, for
+// clients that can only set a URL, the token query parameter.
+func (s *uploadServer) authorized(r *http.Request) bool {
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if got == "" {
+		got = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+// readUpload returns the image bytes, original filename (if any), and
+// content type from either a raw or multipart request body.
+func readUpload(r *http.Request) ([]byte, string, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", "", fmt.Errorf("missing \"file\" form field: %w", err)
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		return data, header.Filename, header.Header.Get("Content-Type"), err
+	}
+	data, err := io.ReadAll(r.Body)
+	if err == nil && len(data) == 0 {
+		err = fmt.Errorf("empty request body")
+	}
+	return data, r.Header.Get("X-Filename"), mediaType, err
+}
+
+// imageExt returns the file extension for an image media type, or "" if it
+// isn't one that can be read.
+func imageExt(mediaType string) string {
+	switch mediaType {
+	case "image/heic":
+		return ".heic"
+	case "image/heif":
+		return ".heif"
+	case "image/tiff":
+		return ".tiff"
+	case "image/bmp":
+		return ".bmp"
+	}
+	exts, _ := mime.ExtensionsByType(mediaType)
+	for _, e := range exts {
+		if imageExts[e] {
+			return e
+		}
+	}
+	return ""
+}
+
+// uploadName picks a unique, timestamped filename for an uploaded image.
+func uploadName(filename, contentType string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		ext = imageExt(contentType)
+	}
+	if !imageExts[ext] {
+		return "", fmt.Errorf("unsupported image type %q", contentType)
+	}
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return fmt.Sprintf("upload-%s-%s%s", time.Now().Format("20060102-150405"), hex.EncodeToString(suffix), ext), nil
+}
+
+// summaryText renders a short plain-text summary suitable for display as a
+// Shortcut's output.
+func summaryText(log *ReadingLog, flags []Flag) string {
+	var sb strings.Builder
+	total := 0
+	fmt.Fprintf(&sb, "%s (%s, %s)\n", log.FullName, log.Grade, log.HomeroomTeacher)
+	for _, e := range log.ReadingEntries {
+		total += e.Minutes
+		if e.Minutes > 0 {
+			fmt.Fprintf(&sb, "%s %s: %d min\n", e.Day, shortDate(e.Date), e.Minutes)
+		} else {
+			fmt.Fprintf(&sb, "%s %s: —\n", e.Day, shortDate(e.Date))
+		}
+	}
+	fmt.Fprintf(&sb, "Total: %d min\n", total)
+	if len(flags) > 0 {
+		sb.WriteString("A volunteer will double-check this log.\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// An ImageSource is somewhere images come from other than the store, such
+// as a cloud folder or a mailbox. Before a run, each --source is synced:
+// Sync copies the images that are new since the last sync into the store
+// and returns their names. It can keep where it left off in
+// progress.Sources under its String().
+type ImageSource interface {
+	Sync(ctx context.Context, progress *Progress) ([]string, error)
+	String() string
+}
+
+// sourceKinds maps the scheme a --source starts with, the part before the
+// first colon, to a function that returns the source for the rest. The
+// functions only check the location; connecting and reading are left to
+// Sync, so a source can be checked when the config is loaded.
+var sourceKinds = map[string]func(location string) (ImageSource, error){
+	"dir":     newDirSource,
+	"zip":     newZipSource,
+	"dropbox": newDropboxSource,
+	"icloud":  newICloudSource,
+	"imap":    newIMAPSource,
+}
+
+// registerSource adds a kind of source, so that --source SCHEME:LOCATION
+// syncs from it.
+func registerSource(scheme string, open func(location string) (ImageSource, error)) {
+	if _, ok := sourceKinds[scheme]; ok {
+		panic("source " + scheme + " registered twice")
+	}
+	sourceKinds[scheme] = open
+}
+
+// openSource returns the source a --source names, such as
+// dropbox:/ReadingLogs or zip:photos.zip.
+func openSource(spec string) (ImageSource, error) {
+	scheme, location, _ := strings.Cut(spec, ":")
+	open, ok := sourceKinds[scheme]
+	if !ok {
+		return nil, configErrorf("unknown source %q; sources start with %s:", spec, strings.Join(slices.Sorted(maps.Keys(sourceKinds)), ":, "))
+	}
+	src, err := open(location)
+	if err != nil {
+		return nil, configErrorf("source %s: %w", spec, err)
+	}
+	return src, nil
+}
+
+// syncSources syncs each source in turn, stopping at the first that fails,
+// and returns the names of the images they saved.
+func syncSources(ctx context.Context, sources []ImageSource, progress *Progress) ([]string, error) {
+	var saved []string
+	for _, src := range sources {
+		cyan.Printf("  Syncing %s\n", src)
+		names, err := src.Sync(ctx, progress)
+		saved = append(saved, names...)
+		if err != nil {
+			return saved, fmt.Errorf("%s: %w", src, err)
+		}
+		fmt.Printf("  %s new image(s) from %s\n\n", bold.Sprintf("%d", len(names)), src)
+	}
+	return saved, nil
+}
+
+// ingest syncs one source into the store and then, unless noParse is set
+// or nothing new arrived, runs the parser.
+func ingest(src ImageSource, noParse, forceUnlock bool) error {
+	printBanner()
+	if err := lockProgress(forceUnlock); err != nil {
+		return err
+	}
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	saved, err := syncSources(context.Background(), []ImageSource{src}, progress)
+	if err := saveProgress(progress); err != nil {
+		return fmt.Errorf("could not save progress: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	if noParse || len(saved) == 0 {
+		return nil
+	}
+	runParse(nil)
+	return nil
+}
+
+// saveNew writes an image to the store as name, unless the same image has
+// already been read or is already there under that name. It reports
+// whether it wrote it.
+func saveNew(name string, data []byte, progress *Progress) (bool, error) {
+	id := imageID(data)
+	if _, seen := progress.Images[id]; seen {
+		return false, nil
+	}
+	if existing, err := readStoreFile(store, name); err == nil && imageID(existing) == id {
+		return false, nil
+	}
+	if err := writeStoreFile(store, name, data); err != nil {
+		return false, err
+	}
+	dim.Printf("  ↓ %s\n", name)
+	return true, nil
+}
+
+// --- local directory ----------------------------------------------------
+
+// dirSource is another local directory, such as a memory card or a synced
+// folder. Its images are copied unless they have been read before.
+type dirSource string
+
+func newDirSource(location string) (ImageSource, error) {
+	if location == "" {
+		return nil, fmt.Errorf("no directory given")
+	}
+	return dirSource(location), nil
+}
+
+func (d dirSource) String() string { return "dir:" + string(d) }
+
+func (d dirSource) Sync(ctx context.Context, progress *Progress) ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var saved []string
+	for _, e := range entries {
+		if e.IsDir() || !isImageFile(e.Name()) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return saved, err
+		}
+		data, err := os.ReadFile(filepath.Join(string(d), e.Name()))
+		if err != nil {
+			return saved, err
+		}
+		ok, err := saveNew(e.Name(), data, progress)
+		if err != nil {
+			return saved, err
+		}
+		if ok {
+			saved = append(saved, e.Name())
+		}
+	}
+	return saved, nil
+}
+
+// --- zip archive --------------------------------------------------------
+
+// zipSource is a zip archive, such as a shared drive folder downloaded all
+// at once. Images in folders inside it are named for their folder, so
+// Week6/ana.jpg is saved as Week6-ana.jpg.
+type zipSource string
+
+func newZipSource(location string) (ImageSource, error) {
+	if location == "" {
+		return nil, fmt.Errorf("no archive given")
+	}
+	return zipSource(location), nil
+}
+
+func (z zipSource) String() string { return "zip:" + string(z) }
+
+func (z zipSource) Sync(ctx context.Context, progress *Progress) ([]string, error) {
+	r, err := zip.OpenReader(string(z))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var saved []string
+	for _, f := range r.File {
+		// macOS adds resource forks under __MACOSX with the same names.
+		if f.FileInfo().IsDir() || !isImageFile(f.Name) || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return saved, err
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return saved, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		name := strings.ReplaceAll(strings.TrimPrefix(path.Clean(f.Name), "/"), "/", "-")
+		ok, err := saveNew(name, data, progress)
+		if err != nil {
+			return saved, err
+		}
+		if ok {
+			saved = append(saved, name)
+		}
+	}
+	return saved, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
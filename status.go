@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// teacherStatus is one classroom's line in the status report.
+type teacherStatus struct {
+	results, minutes, flagged, verified int
+}
+
+// runStatus reports how far along the images in the current directory are,
+// from the progress file alone: nothing is read or sent to the API.
+func runStatus(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: status")
+	}
+	images, err := findImages(store)
+	if err != nil {
+		return fmt.Errorf("finding images: %w", err)
+	}
+	progress, err := loadProgress()
+	if err != nil {
+		return err
+	}
+	ids := progress.identify(images)
+	done := make(map[string]bool)
+	for key := range progress.Completed {
+		done[imageOf(key)] = true
+	}
+
+	var completed, blank, pending int
+	var failed []string
+	for _, name := range images {
+		id := ids[name]
+		switch {
+		case done[id]:
+			completed++
+		case progress.Blank[id]:
+			blank++
+		case progress.Errors[name] != "":
+			failed = append(failed, name)
+		default:
+			pending++
+		}
+	}
+
+	logs := completedLogs(progress)
+	classes := make(map[string]*teacherStatus)
+	total := 0
+	for _, log := range logs {
+		class := classroomOf(log)
+		if classes[class] == nil {
+			classes[class] = &teacherStatus{}
+		}
+		t := classes[class]
+		t.results++
+		for _, e := range log.ReadingEntries {
+			t.minutes += e.Minutes
+			total += e.Minutes
+		}
+		if len(log.Flags) > 0 {
+			t.flagged++
+		}
+		if log.Verified {
+			t.verified++
+		}
+	}
+
+	fmt.Println()
+	bold.Println(glyphs("─── Status ───────────────────────────"))
+	fmt.Printf("  Images found:     %s\n", bold.Sprintf("%d", len(images)))
+	fmt.Printf("  Completed:        %s\n", green.Sprintf("%d", completed))
+	if blank > 0 {
+		fmt.Printf("  Blank forms:      %s\n", dim.Sprintf("%d", blank))
+	}
+	fmt.Printf("  Failed:           %s\n", red.Sprintf("%d", len(failed)))
+	fmt.Printf("  Pending:          %s\n", cyan.Sprintf("%d", pending))
+	fmt.Printf("  Results:          %s\n", bold.Sprintf("%d", len(logs)))
+	fmt.Printf("  Total minutes:    %s\n", bold.Sprintf("%d", total))
+	if len(progress.Review) > 0 {
+		fmt.Printf("  Flagged:          %s %s\n", yellow.Sprintf("%d", len(progress.Review)), dim.Sprint("(run `review` to see why)"))
+	}
+	bold.Println(glyphs("──────────────────────────────────────"))
+
+	if len(classes) > 0 {
+		names := make([]string, 0, len(classes))
+		for name := range classes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Println()
+		bold.Printf("  %-20s %8s %8s %8s %8s\n", "Classroom", "Results", "Minutes", "Flagged", "Verified")
+		for _, name := range names {
+			t := classes[name]
+			if name == "" {
+				name = "(no teacher)"
+			}
+			fmt.Printf("  %-20s %8d %8d %8d %8d\n", name, t.results, t.minutes, t.flagged, t.verified)
+		}
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		fmt.Println()
+		bold.Println("  Failed images")
+		for _, name := range failed {
+			reason := firstLine(progress.Errors[name])
+			if kind := progress.Failures[name]; kind != "" {
+				reason = kind + ": " + reason
+			}
+			fmt.Printf("  %s %s %s\n", red.Sprint("✗"), name, dim.Sprintf("(%s)", reason))
+		}
+		dim.Println("  Run `retry` to try them again, or `retry --kind <kind>` for one kind")
+	}
+	fmt.Println()
+	return nil
+}
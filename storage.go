@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store is a flat namespace of files that images are read from and outputs
+// are written to: the working directory by default, or a cloud bucket prefix.
+type Store interface {
+	List() ([]string, error)
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error)
+	Remove(name string) error
+	String() string
+}
+
+// statStore is a Store that can report a file's size and modification time
+// without reading it.
+type statStore interface {
+	Stat(name string) (os.FileInfo, error)
+}
+
+// store is where the current run reads images and writes progress and CSV.
+var store Store = dirStore(".")
+
+// openStore returns the Store for a local directory or an s3:// or gs:// URL.
+func openStore(location string) (Store, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"), strings.HasPrefix(location, "gs://"):
+		return newBucketStore(location)
+	default:
+		return dirStore(location), nil
+	}
+}
+
+// readStoreFile reads the whole of a named file from a store, decrypting it
+// if it is encrypted.
+func readStoreFile(s Store, name string) ([]byte, error) {
+	r, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return unseal(name, data)
+}
+
+// createStoreFile creates a named file in a store, encrypting what is
+// written to it if encryption is on.
+func createStoreFile(s Store, name string) (io.WriteCloser, error) {
+	w, err := s.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return seal(name, w)
+}
+
+// writeStoreFile replaces a named file in a store with data.
+func writeStoreFile(s Store, name string, data []byte) error {
+	w, err := createStoreFile(s, name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// --- local directory ----------------------------------------------------
+
+type dirStore string
+
+func (d dirStore) List() ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d dirStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(d.path(name))
+}
+
+func (d dirStore) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(d.path(name)), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(d.path(name))
+}
+
+func (d dirStore) Remove(name string) error {
+	return os.Remove(d.path(name))
+}
+
+func (d dirStore) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(d.path(name))
+}
+
+// path resolves name relative to the directory; absolute names are used as-is.
+func (d dirStore) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(string(d), name)
+}
+
+func (d dirStore) String() string { return string(d) }
+
+// --- S3 / GCS buckets ---------------------------------------------------
+
+// bucketStore talks to S3, or to GCS through its S3-compatible XML API, using
+// SigV4-signed requests so no cloud SDK is needed.
+type bucketStore struct {
+	scheme    string
+	bucket    string
+	prefix    string
+	endpoint  string
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+}
+
+func newBucketStore(location string) (*bucketStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	b := &bucketStore{
+		scheme: u.Scheme,
+		bucket: u.Host,
+		prefix: strings.TrimPrefix(u.Path, "/"),
+	}
+	if b.prefix != "" && !strings.HasSuffix(b.prefix, "/") {
+		b.prefix += "/"
+	}
+
+	if b.scheme == "gs" {
+		// GCS interoperability mode uses HMAC keys in place of AWS credentials.
+		b.endpoint = "https://storage.googleapis.com"
+		b.region = "auto"
+		b.accessKey = firstEnv("GCS_HMAC_ACCESS_ID", "AWS_ACCESS_KEY_ID")
+		b.secretKey = firstEnv("GCS_HMAC_SECRET", "AWS_SECRET_ACCESS_KEY")
+	} else {
+		b.region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+		if b.region == "" {
+			b.region = "us-east-1"
+		}
+		b.endpoint = os.Getenv("AWS_ENDPOINT_URL")
+		if b.endpoint == "" {
+			b.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", b.region)
+		}
+		b.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		b.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		b.token = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if b.accessKey == "" || b.secretKey == "" {
+		return nil, fmt.Errorf("no credentials for %s:// (set AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY or GCS_HMAC_ACCESS_ID/GCS_HMAC_SECRET)", b.scheme)
+	}
+	return b, nil
+}
+
+func (b *bucketStore) String() string {
+	return fmt.Sprintf("%s://%s/%s", b.scheme, b.bucket, b.prefix)
+}
+
+// List returns the object names directly under the prefix.
+func (b *bucketStore) List() ([]string, error) {
+	var names []string
+	token := ""
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {b.prefix},
+			"delimiter": {"/"},
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := b.do("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bucket listing: %w", err)
+		}
+		for _, c := range result.Contents {
+			if name := strings.TrimPrefix(c.Key, b.prefix); name != "" {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated {
+			return names, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (b *bucketStore) Open(name string) (io.ReadCloser, error) {
+	resp, err := b.do("GET", b.prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Create buffers writes in memory and uploads the object on Close.
+func (b *bucketStore) Create(name string) (io.WriteCloser, error) {
+	return &bucketWriter{store: b, key: b.prefix + name}, nil
+}
+
+func (b *bucketStore) Remove(name string) error {
+	resp, err := b.do("DELETE", b.prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+type bucketWriter struct {
+	bytes.Buffer
+	store *bucketStore
+	key   string
+}
+
+func (w *bucketWriter) Close() error {
+	resp, err := w.store.do("PUT", w.key, nil, w.Bytes())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a SigV4-signed request for key and returns the response, turning
+// non-2xx statuses into errors. A missing object maps to os.ErrNotExist.
+func (b *bucketStore) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	base, err := url.Parse(b.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u := *base
+	u.Path = "/" + b.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = awsEscapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	b.sign(req, body, time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s %s: %w", method, key, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("%s %s: %s\n%s", method, key, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (b *bucketStore) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if b.token != "" {
+		req.Header.Set("x-amz-security-token", b.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(req.Header.Get(k))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + b.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+b.secretKey), day)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signedHeaders, signature))
+}
+
+// awsEscapePath percent-encodes everything but unreserved characters and '/',
+// matching the canonical URI encoding SigV4 expects.
+func awsEscapePath(p string) string {
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// canonicalQuery encodes query parameters sorted by key, with spaces as %20
+// as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// firstEnv returns the first non-empty environment variable among keys.
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
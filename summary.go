@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Labels in the Full Name column of the rows summaryRows adds.
+const (
+	labelSubtotal = "Subtotal"
+	labelTotal    = "Total"
+	labelMean     = "Mean"
+	labelMedian   = "Median"
+)
+
+// isSummaryRow reports whether a row read back from an export is one that
+// summaryRows added rather than a student's.
+func isSummaryRow(name, source string) bool {
+	return source == "" && slices.Contains([]string{labelSubtotal, labelTotal, labelMean, labelMedian}, name)
+}
+
+// summaryRows returns the rows appended to a CSV or XLSX export with
+// --summary: a subtotal for each teacher, in the order the teachers first
+// appear, then a grand total and the mean and median minutes per student.
+// The rows line up with csvHeader(cols); the teacher goes in the Homeroom
+// Teacher column, the number of students in Grade, and with goals, how many
+// met theirs in Goal Met.
+func summaryRows(logs []ReadingLog, cols []DayConfig) [][]string {
+	if len(logs) == 0 {
+		return nil
+	}
+	width := len(csvHeader(cols))
+	total := 3 + len(cols)
+
+	// sum returns a subtotal or total row over logs.
+	sum := func(label, teacher string, logs []ReadingLog) []string {
+		row := make([]string, width)
+		row[0], row[1], row[2] = label, fmt.Sprintf("%d students", len(logs)), teacher
+		if len(logs) == 1 {
+			row[1] = "1 student"
+		}
+		minutes, met := 0, 0
+		for i, d := range cols {
+			day := 0
+			for _, log := range logs {
+				day += minutesOn(log, d.Date)
+			}
+			row[3+i] = strconv.Itoa(day)
+		}
+		for _, log := range logs {
+			t := totalMinutes(log)
+			minutes += t
+			if _, m := goalColumns(log.Grade, t); m == "yes" {
+				met++
+			}
+		}
+		row[total] = strconv.Itoa(minutes)
+		if cfg.hasGoals() {
+			row[total+2] = fmt.Sprintf("%d of %d", met, len(logs))
+		}
+		return row
+	}
+
+	var teachers []string
+	byTeacher := map[string][]ReadingLog{}
+	for _, log := range logs {
+		if _, ok := byTeacher[log.HomeroomTeacher]; !ok {
+			teachers = append(teachers, log.HomeroomTeacher)
+		}
+		byTeacher[log.HomeroomTeacher] = append(byTeacher[log.HomeroomTeacher], log)
+	}
+	var rows [][]string
+	for _, t := range teachers {
+		rows = append(rows, sum(labelSubtotal, t, byTeacher[t]))
+	}
+	rows = append(rows, sum(labelTotal, "", logs))
+
+	totals := make([]float64, len(logs))
+	var all float64
+	for i, log := range logs {
+		totals[i] = float64(totalMinutes(log))
+		all += totals[i]
+	}
+	mean, med := make([]string, width), make([]string, width)
+	mean[0], med[0] = labelMean, labelMedian
+	mean[total] = formatStat(all / float64(len(logs)))
+	med[total] = formatStat(median(totals))
+	return append(rows, mean, med)
+}
+
+// formatStat formats a mean or median to at most one decimal place.
+func formatStat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+// FormTemplate is one layout of reading log form. Grades that hand out
+// different forms each get a template with its own days, extra fields, and
+// instructions for the model.
+type FormTemplate struct {
+	Name        string            `yaml:"name" toml:"name"`
+	Description string            `yaml:"description" toml:"description"` // how to recognize the form, used for auto-detection
+	Days        []DayConfig       `yaml:"days" toml:"days"`               // default: the top-level days
+	Fields      []FieldConfig     `yaml:"fields" toml:"fields"`           // in addition to the top-level fields
+	Prompt      string            `yaml:"prompt" toml:"prompt"`           // extra instructions for this form
+	Regions     map[string]Region `yaml:"regions" toml:"regions"`         // where things are on the form, sent as close-ups; default: the top-level regions
+}
+
+// defaultTemplate is the form described by the top-level configuration,
+// used when no templates are configured.
+func defaultTemplate() FormTemplate {
+	return FormTemplate{Name: "default"}
+}
+
+func (t FormTemplate) days() []DayConfig {
+	if len(t.Days) > 0 {
+		return t.Days
+	}
+	return cfg.Days
+}
+
+// fields returns the top-level extra fields followed by the template's own.
+func (t FormTemplate) fields() []FieldConfig {
+	return append(slices.Clip(cfg.Fields), t.Fields...)
+}
+
+// dayList renders the form's days for the prompt, e.g.
+// "Friday 1/30, Saturday 1/31".
+func (t FormTemplate) dayList() string {
+	days := t.days()
+	parts := make([]string, len(days))
+	for i, d := range days {
+		parts[i] = d.Day + " " + d.Date
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formPrompt returns the template's extra instructions for the prompt.
+func formPrompt(t FormTemplate) string {
+	if strings.TrimSpace(t.Prompt) == "" {
+		return ""
+	}
+	return "\n\n" + strings.TrimSpace(t.Prompt)
+}
+
+// lookupTemplate returns the configured template with the given name.
+// "default" names the top-level form when no templates are configured.
+func lookupTemplate(name string) (FormTemplate, error) {
+	for _, t := range cfg.Templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	if name == "default" && len(cfg.Templates) == 0 {
+		return defaultTemplate(), nil
+	}
+	return FormTemplate{}, fmt.Errorf("no form template named %q", name)
+}
+
+// allFields returns every extra field across the top level and all
+// templates, so exports have a column for each.
+func allFields() []FieldConfig {
+	fields := slices.Clone(cfg.Fields)
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		seen[f.Name] = true
+	}
+	for _, t := range cfg.Templates {
+		for _, f := range t.Fields {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// validateTemplates checks the configured templates, filling in defaults.
+// A template's fields may not reuse a top-level field's name.
+func validateTemplates(templates []FormTemplate, days []DayConfig, fields []FieldConfig) error {
+	seen := make(map[string]bool)
+	for i := range templates {
+		t := &templates[i]
+		if t.Name == "" {
+			return fmt.Errorf("templates[%d]: name is required", i)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("templates: %q is defined twice", t.Name)
+		}
+		seen[t.Name] = true
+		if err := validateFields(t.Fields); err != nil {
+			return fmt.Errorf("templates.%s: %w", t.Name, err)
+		}
+		if err := validateFields(append(slices.Clip(fields), t.Fields...)); err != nil {
+			return fmt.Errorf("templates.%s: %w", t.Name, err)
+		}
+		formDays := days
+		if len(t.Days) > 0 {
+			formDays = t.Days
+		}
+		if err := validateRegions(t.Regions, formDays, append(slices.Clip(fields), t.Fields...)); err != nil {
+			return fmt.Errorf("templates.%s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// chooseTemplate picks the form template for an image: the one named with
+// --template, the only one configured, or the one the model recognizes.
+func (p *pipeline) chooseTemplate(ctx context.Context, mediaType, encoded string) (FormTemplate, error) {
+	if p.template != "" {
+		return lookupTemplate(p.template)
+	}
+	switch len(cfg.Templates) {
+	case 0:
+		return defaultTemplate(), nil
+	case 1:
+		return cfg.Templates[0], nil
+	}
+	name, err := p.client.DetectTemplate(ctx, mediaType, encoded, cfg.Templates)
+	if err != nil {
+		return FormTemplate{}, fmt.Errorf("detecting form template: %w", err)
+	}
+	return lookupTemplate(name)
+}
+
+// DetectTemplate asks Claude which of the templates an image shows.
+func (c *anthropicClient) DetectTemplate(ctx context.Context, mediaType, encodedImage string, templates []FormTemplate) (string, error) {
+	names := make([]any, len(templates))
+	var list strings.Builder
+	for i, t := range templates {
+		names[i] = t.Name
+		fmt.Fprintf(&list, "\n- %s: %s", t.Name, t.Description)
+	}
+	props := jsonschema.NewProperties()
+	props.Set("template", &jsonschema.Schema{Type: "string", Enum: names, Description: "Name of the matching form"})
+	schema := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           props,
+		Required:             []string{"template"},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+
+	prompt := fmt.Sprintf(`This image is a student reading log. Which of these forms is it?
+%s
+
+Answer with the name of the form that best matches.`, list.String())
+
+	text, err := c.ask(ctx, "detect-template", mediaType, encodedImage, prompt, generateJSONSchema(schema))
+	if err != nil {
+		return "", err
+	}
+	var answer struct {
+		Template string `json:"template"`
+	}
+	if err := json.Unmarshal([]byte(text), &answer); err != nil {
+		return "", fmt.Errorf("%w: %w\nraw: %s", errParse, err, text)
+	}
+	return answer.Template, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// asciiTerminal is set when the terminal can't be trusted to draw the
+// box-drawing and block characters of the banner, progress bar, and
+// summaries, which are then drawn in plain ASCII.
+var asciiTerminal = plainTerminal()
+
+// asciiGlyphs replaces each drawing character with an ASCII look-alike.
+var asciiGlyphs = strings.NewReplacer(
+	"┌", "+", "┐", "+", "└", "+", "┘", "+",
+	"─", "-", "│", "|",
+	"█", "#", "░", ".",
+	"✓", "+", "✗", "x", "○", "o",
+	"—", "-", "▸", ">", "⚠", "!", "·", "-", "↑", "^", "↓", "v", "→", "->", "–", "-", "⏸", "||",
+)
+
+// glyphs returns s as it should be drawn on this terminal.
+func glyphs(s string) string {
+	if asciiTerminal {
+		return asciiGlyphs.Replace(s)
+	}
+	return s
+}
+
+// headless is set when no one is at a terminal, such as in a container:
+// output is plain, warnings and errors are logged to stderr as structured
+// lines, and nothing waits for a person.
+var headless bool
+
+// setHeadless turns on headless operation.
+func setHeadless() {
+	headless, asciiTerminal, color.NoColor = true, true, true
+}
+
+// terminalOptions applies the options that every command accepts, wherever
+// they appear, and returns the other arguments: --no-color turns off color
+// (as does setting NO_COLOR), --ascii draws in plain ASCII, and --headless
+// runs without a terminal.
+func terminalOptions(args []string) []string {
+	rest := args[:0:0]
+	for _, arg := range args {
+		switch arg {
+		case "--no-color", "-no-color":
+			color.NoColor = true
+		case "--ascii", "-ascii":
+			asciiTerminal = true
+		case "--headless", "-headless":
+			setHeadless()
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest
+}
+
+// plainTerminal guesses whether the terminal lacks the characters used for
+// drawing: a dumb terminal, the legacy Windows console (Windows Terminal and
+// VS Code set WT_SESSION or TERM_PROGRAM), or a locale that isn't UTF-8.
+func plainTerminal() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return true
+	}
+	if runtime.GOOS == "windows" {
+		return os.Getenv("WT_SESSION") == "" && os.Getenv("TERM_PROGRAM") == ""
+	}
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			v = strings.ToUpper(v)
+			return !strings.Contains(v, "UTF-8") && !strings.Contains(v, "UTF8")
+		}
+	}
+	return false
+}
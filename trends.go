@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// classTrend is one class's average minutes per student in each week of
+// the challenge.
+type classTrend struct {
+	Class    string
+	Averages []float64 // by week; -1 for a week the class turned in no logs
+	Trend    string    // up, down, or steady over the recent weeks
+}
+
+// classTrends averages each class's minutes per student for every week in
+// logs, and judges each class's trend from its last window weeks with
+// logs: down if every one of them is below the one before, up if every one
+// is above.
+func classTrends(logs []ReadingLog, window int) (weeks []string, trends []classTrend) {
+	type tally struct{ minutes, students int }
+	byClass := make(map[string]map[string]*tally) // class → week → tally
+	weekSet := make(map[string]bool)
+	for _, t := range aggregateByWeek(logs) {
+		if byClass[t.class] == nil {
+			byClass[t.class] = make(map[string]*tally)
+		}
+		if byClass[t.class][t.week] == nil {
+			byClass[t.class][t.week] = &tally{}
+		}
+		byClass[t.class][t.week].minutes += t.minutes
+		byClass[t.class][t.week].students++
+		weekSet[t.week] = true
+	}
+
+	weeks = sortedKeys(weekSet)
+	for _, class := range sortedKeys(byClass) {
+		ct := classTrend{Class: class, Trend: "steady"}
+		var recent []float64
+		for _, w := range weeks {
+			t, ok := byClass[class][w]
+			if !ok {
+				ct.Averages = append(ct.Averages, -1)
+				continue
+			}
+			avg := float64(t.minutes) / float64(t.students)
+			ct.Averages = append(ct.Averages, avg)
+			recent = append(recent, avg)
+		}
+		if len(recent) > window {
+			recent = recent[len(recent)-window:]
+		}
+		if len(recent) >= 2 {
+			down, up := true, true
+			for i := 1; i < len(recent); i++ {
+				down = down && recent[i] < recent[i-1]
+				up = up && recent[i] > recent[i-1]
+			}
+			switch {
+			case down:
+				ct.Trend = "down"
+			case up:
+				ct.Trend = "up"
+			}
+		}
+		trends = append(trends, ct)
+	}
+	return weeks, trends
+}
+
+// studentWeek is one student's minutes in one week.
+type studentWeek struct {
+	class, week string
+	minutes     int
+}
+
+// aggregateByWeek totals each student's minutes in each week, so a student
+// with two logs in a week counts once.
+func aggregateByWeek(logs []ReadingLog) []studentWeek {
+	index := make(map[string]int)
+	var totals []studentWeek
+	for _, log := range logs {
+		key := studentKey(log) + "|" + weekOf(log)
+		i, ok := index[key]
+		if !ok {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, studentWeek{class: classroomOf(log), week: weekOf(log)})
+		}
+		totals[i].minutes += totalMinutes(log)
+	}
+	return totals
+}
+
+func runReportTrends(args []string) error {
+	fs := flag.NewFlagSet("report trends", flag.ContinueOnError)
+	window := fs.Int("weeks", 3, "judge each class's trend by its last N weeks")
+	csvPath := fs.String("csv", "", "also write the report to this CSV file")
+	parseFlags(fs, args)
+
+	if *window < 2 {
+		return fmt.Errorf("--weeks must be at least 2")
+	}
+	logs, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+	weeks, trends := classTrends(logs, *window)
+	if len(weeks) < 2 {
+		return fmt.Errorf("the history has only week %s; trends need at least two", weeks[0])
+	}
+
+	header := append(append([]string{"Class"}, weeks...), "Trend")
+	var rows [][]string
+	var down []classTrend
+	for _, t := range trends {
+		row := []string{t.Class}
+		for _, avg := range t.Averages {
+			if avg < 0 {
+				row = append(row, glyphs("–"))
+			} else {
+				row = append(row, fmt.Sprintf("%.0f", avg))
+			}
+		}
+		rows = append(rows, append(row, trendArrow(t.Trend)+" "+t.Trend))
+		if t.Trend == "down" {
+			down = append(down, t)
+		}
+	}
+
+	bold.Println("\n  Average minutes per student, by week")
+	fmt.Println()
+	printTable(header, rows)
+	if len(down) > 0 {
+		fmt.Println()
+		for _, t := range down {
+			var recent []string
+			for _, avg := range t.Averages {
+				if avg >= 0 {
+					recent = append(recent, fmt.Sprintf("%.0f", avg))
+				}
+			}
+			recent = recent[max(len(recent)-*window, 0):]
+			red.Println(glyphs(fmt.Sprintf("  ↓ %s is trending down: %s min per student", t.Class, strings.Join(recent, " → "))))
+		}
+	}
+
+	if *csvPath != "" {
+		csvRows := make([][]string, len(trends))
+		for i, t := range trends {
+			row := []string{t.Class}
+			for _, avg := range t.Averages {
+				if avg < 0 {
+					row = append(row, "")
+				} else {
+					row = append(row, fmt.Sprintf("%.1f", avg))
+				}
+			}
+			csvRows[i] = append(row, t.Trend)
+		}
+		if err := writeTableCSV(*csvPath, header, csvRows); err != nil {
+			return err
+		}
+		boldGrn.Printf("\n  Wrote %d class(es) to %s\n", len(csvRows), *csvPath)
+	}
+	return nil
+}
+
+// trendArrow is the arrow shown beside a trend.
+func trendArrow(trend string) string {
+	switch trend {
+	case "down":
+		return glyphs("↓")
+	case "up":
+		return glyphs("↑")
+	}
+	return glyphs("→")
+}
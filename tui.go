@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fatih/color"
+)
+
+// tuiView is the live display of a batch (--tui): an overall progress bar
+// with throughput and time remaining, the images being read, and the images
+// read so far, each of which opens into a panel with its result.
+type tuiView struct {
+	program *tea.Program
+	done    chan struct{}
+	stdout  *os.File  // restored when the display closes
+	colors  io.Writer // likewise
+}
+
+// Messages from the batch to the display.
+type (
+	tuiBegin struct{ name string }
+	tuiEnd   struct{ report imageReport }
+	tuiClose struct{}
+	tuiTick  struct{}
+)
+
+// newTUIView starts the display for total images, showing when limit is
+// holding requests back. While it runs, anything else printed to stdout is
+// dropped so it can't scramble the display; warnings and errors still go to
+// stderr.
+func newTUIView(total int, limit *rateLimiter) *tuiView {
+	v := &tuiView{done: make(chan struct{}), stdout: os.Stdout, colors: color.Output}
+	v.program = tea.NewProgram(&tuiModel{total: total, started: time.Now(), limit: limit}, tea.WithOutput(os.Stdout))
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout, color.Output = devNull, io.Discard
+	}
+	go func() {
+		defer close(v.done)
+		m, err := v.program.Run()
+		if err != nil {
+			logger.Error("live display failed", "err", err)
+			return
+		}
+		if m.(*tuiModel).interrupted {
+			v.restore()
+			red.Println("  Stopped; images read so far are saved, and the next run picks up from here")
+			exit(130)
+		}
+	}()
+	return v
+}
+
+func (v *tuiView) begin(_ int, path string) {
+	v.program.Send(tuiBegin{filepath.Base(path)})
+}
+
+func (v *tuiView) end(_ int, _ string, r imageReport) {
+	v.program.Send(tuiEnd{r})
+}
+
+func (v *tuiView) close() {
+	v.program.Send(tuiClose{})
+	<-v.done
+	v.restore()
+}
+
+func (v *tuiView) restore() {
+	if os.Stdout != v.stdout {
+		os.Stdout.Close()
+		os.Stdout, color.Output = v.stdout, v.colors
+	}
+}
+
+// tuiImage is an image on the display.
+type tuiImage struct {
+	name   string
+	began  time.Time
+	took   time.Duration
+	report imageReport
+	open   bool // showing its result panel
+}
+
+type tuiModel struct {
+	total       int // images to read
+	limit       *rateLimiter
+	started     time.Time
+	reading     []tuiImage // in the order they were sent off
+	finished    []tuiImage
+	cursor      int // selected finished image
+	height      int
+	closing     bool
+	interrupted bool
+}
+
+func (m *tuiModel) Init() tea.Cmd { return tick() }
+
+// tick redraws the display every second so times stay current.
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return tuiTick{} })
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tuiTick:
+		return m, tick()
+	case tuiBegin:
+		m.reading = append(m.reading, tuiImage{name: msg.name, began: time.Now()})
+	case tuiEnd:
+		img := tuiImage{name: msg.report.Image, began: time.Now(), report: msg.report}
+		for i, r := range m.reading {
+			if r.name == img.name {
+				img.began = r.began
+				m.reading = append(m.reading[:i], m.reading[i+1:]...)
+				break
+			}
+		}
+		img.took = time.Since(img.began)
+		following := m.cursor == len(m.finished)-1 || len(m.finished) == 0
+		m.finished = append(m.finished, img)
+		if following {
+			m.cursor = len(m.finished) - 1
+		}
+	case tuiClose:
+		m.closing = true
+		return m, tea.Quit
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.interrupted = true
+			return m, tea.Quit
+		case "up", "k":
+			m.cursor = max(m.cursor-1, 0)
+		case "down", "j":
+			m.cursor = min(m.cursor+1, len(m.finished)-1)
+		case "enter", " ", "right", "left":
+			if m.cursor < len(m.finished) {
+				m.finished[m.cursor].open = !m.finished[m.cursor].open
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+
+	done := len(m.finished)
+	elapsed := time.Since(m.started)
+	stats := []string{dim.Sprintf("%s elapsed", elapsed.Round(time.Second))}
+	if done > 0 {
+		perMinute := float64(done) / elapsed.Minutes()
+		stats = append(stats, dim.Sprintf("%.1f images/min", perMinute))
+		if done < m.total {
+			left := time.Duration(float64(elapsed) / float64(done) * float64(m.total-done))
+			stats = append(stats, cyan.Sprintf("ETA %s", left.Round(time.Second)))
+		}
+	}
+	if s := m.limit.status(); s != "" {
+		stats = append(stats, yellow.Sprint("⏸ "+s))
+	}
+	pct := 100.0
+	if m.total > 0 {
+		pct = float64(done) / float64(m.total) * 100
+	}
+	line("")
+	line("  %s %s %s  %s", bold.Sprintf("[%d/%d]", done, m.total), cyan.Sprint(renderBar(done, max(m.total, 1), 30)), dim.Sprintf("%.0f%%", pct), strings.Join(stats, dim.Sprint(" · ")))
+
+	if len(m.reading) > 0 {
+		line("")
+		line("  %s", bold.Sprint("Reading"))
+		for _, r := range m.reading {
+			line("    %s %s", yellow.Sprint(r.name), dim.Sprintf("%ds", int(time.Since(r.began).Seconds())))
+		}
+	}
+
+	if len(m.finished) > 0 {
+		line("")
+		line("  %s", bold.Sprint("Read"))
+		// Show as many finished images as fit, keeping the selected one in view.
+		rows := len(m.finished)
+		if m.height > 0 {
+			rows = max(m.height-len(m.reading)-12, 3)
+		}
+		first := max(min(m.cursor-rows/2, len(m.finished)-rows), 0)
+		for i := first; i < len(m.finished) && i < first+rows; i++ {
+			img := m.finished[i]
+			marker := "  "
+			if i == m.cursor && !m.closing {
+				marker = cyan.Sprint("▸ ")
+			}
+			line("  %s%s", marker, imageLine(img))
+			if img.open {
+				for _, l := range resultPanel(img.report) {
+					line("        %s", l)
+				}
+			}
+		}
+	}
+
+	if !m.closing {
+		line("")
+		line("  %s", dim.Sprint("↑/↓ select · enter show the result · q stop"))
+	}
+	return glyphs(b.String())
+}
+
+// imageLine summarizes an image that has been read.
+func imageLine(img tuiImage) string {
+	took := dim.Sprintf("%.1fs", img.took.Seconds())
+	switch img.report.Outcome {
+	case "failed":
+		return fmt.Sprintf("%s %s %s %s", red.Sprint("✗"), img.name, red.Sprint(firstLine(img.report.Error)), took)
+	case "blank":
+		return fmt.Sprintf("%s %s %s %s", dim.Sprint("○"), img.name, dim.Sprint("blank form"), took)
+	}
+	var students []string
+	flags := 0
+	for _, log := range img.report.Results {
+		students = append(students, fmt.Sprintf("%s | %s | %d min", log.FullName, classroomOf(log), totalMinutes(log)))
+		flags += len(log.Flags) + len(cfg.Validation.check(log))
+	}
+	s := fmt.Sprintf("%s %s %s %s", green.Sprint("✓"), img.name, dim.Sprint(strings.Join(students, "; ")), took)
+	if flags > 0 {
+		s += yellow.Sprintf("  ⚠ %d", flags)
+	}
+	return s
+}
+
+// resultPanel is the result of an image as shown when it is opened: each
+// day's minutes and anything flagged.
+func resultPanel(r imageReport) []string {
+	if r.Outcome == "failed" {
+		return strings.Split(r.Error, "\n")
+	}
+	var lines []string
+	for _, log := range r.Results {
+		lines = append(lines, bold.Sprintf("%s", log.FullName)+dim.Sprintf(" | %s | %s", log.Grade, log.HomeroomTeacher))
+		for _, e := range log.ReadingEntries {
+			minutes := dim.Sprint("—")
+			if e.Minutes > 0 {
+				minutes = green.Sprintf("%d min", e.Minutes)
+			}
+			lines = append(lines, fmt.Sprintf("%s %-14s %s", dim.Sprint("│"), dim.Sprintf("%s %s", e.Day, shortDate(e.Date)), minutes))
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", dim.Sprint("└"), boldGrn.Sprintf("Total: %d min", totalMinutes(log))))
+		for _, problem := range cfg.Validation.check(log) {
+			lines = append(lines, yellow.Sprintf("⚠ %s", problem))
+		}
+		for _, f := range log.Flags {
+			lines = append(lines, yellow.Sprintf("⚠ %s: %s", f.Check, f.Detail))
+		}
+	}
+	return lines
+}
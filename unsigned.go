@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// unsigned reports whether an entry has reading time but no parent
+// signature. Entries read before signatures were captured don't count.
+func unsigned(e ReadingEntry) bool {
+	return e.Minutes > 0 && e.Signed != nil && !*e.Signed
+}
+
+// runReportUnsigned lists days with minutes but no parent signature, so
+// teachers can follow up before counting them.
+func runReportUnsigned(args []string) error {
+	fs := flag.NewFlagSet("report unsigned", flag.ContinueOnError)
+	week := fs.String("week", "", "only list this week (default: the whole season)")
+	csvPath := fs.String("csv", "", "also write the list to this CSV file")
+	parseFlags(fs, args)
+	if err := normalizeWeek(week); err != nil {
+		return err
+	}
+
+	logs, err := seasonLogs()
+	if err != nil {
+		return err
+	}
+
+	header := []string{"Student", "Classroom", "Week", "Day", "Minutes", "Image"}
+	var rows [][]string
+	matched := 0
+	for _, log := range logs {
+		if *week != "" && weekOf(log) != *week {
+			continue
+		}
+		matched++
+		for _, e := range log.ReadingEntries {
+			if unsigned(e) {
+				rows = append(rows, []string{log.FullName, classroomOf(log), weekOf(log), e.Day + " " + shortDate(e.Date), fmt.Sprint(e.Minutes), log.Source})
+			}
+		}
+	}
+	if matched == 0 {
+		return fmt.Errorf("no results for week %s", *week)
+	}
+	if len(rows) == 0 {
+		green.Println("  Every day with reading time is signed")
+		return nil
+	}
+
+	printTable(header, rows)
+	if *csvPath != "" {
+		if err := writeTableCSV(*csvPath, header, rows); err != nil {
+			return err
+		}
+		boldGrn.Printf("\n  Wrote %d unsigned day(s) to %s\n", len(rows), *csvPath)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// releasesURL is where the latest release is looked up; releases are
+// published by GoReleaser (see .goreleaser.yaml).
+const releasesURL = "https://api.github.com/repos/jshiv/reading_logs_parser/releases/latest"
+
+// runVersion prints the version the binary was built as.
+func runVersion(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: version")
+	}
+	fmt.Printf("reading-logs-parser %s\n", version)
+	dim.Printf("  commit %s, built %s, %s %s/%s\n", commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// release is the part of a GitHub release that self-update needs.
+type release struct {
+	Tag    string `json:"tag_name"`
+	URL    string `json:"html_url"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// asset returns the download URL of the named file in the release.
+func (r release) asset(match func(string) bool) (name, url string, ok bool) {
+	for _, a := range r.Assets {
+		if match(a.Name) {
+			return a.Name, a.URL, true
+		}
+	}
+	return "", "", false
+}
+
+// runSelfUpdate replaces the running binary with the latest release for
+// this platform, after checking it against the release's checksums.
+func runSelfUpdate(args []string) error {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	check := fs.Bool("check", false, "only report whether a newer release is available")
+	force := fs.Bool("force", false, "install the latest release even if it isn't newer, or over a development build")
+	if extra := parseArgs(fs, args); len(extra) > 0 {
+		return fmt.Errorf("usage: self-update [--check] [--force]")
+	}
+
+	var latest release
+	if err := getJSON(releasesURL, &latest); err != nil {
+		return fmt.Errorf("checking for a new release: %w", err)
+	}
+	current, newest := strings.TrimPrefix(version, "v"), strings.TrimPrefix(latest.Tag, "v")
+	switch {
+	case current == newest && !*force:
+		green.Printf("  ✓ reading-logs-parser %s is the latest release\n", version)
+		return nil
+	case *check:
+		fmt.Printf("  %s is available (this is %s): %s\n", bold.Sprint(latest.Tag), version, latest.URL)
+		dim.Println("  Run `self-update` to install it")
+		return nil
+	case version == "dev" && !*force:
+		return fmt.Errorf("this is a development build; pass --force to replace it with release %s", latest.Tag)
+	}
+
+	ext := ".tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = ".zip"
+	}
+	suffix := fmt.Sprintf("_%s_%s%s", runtime.GOOS, runtime.GOARCH, ext)
+	archiveName, archiveURL, ok := latest.asset(func(name string) bool { return strings.HasSuffix(name, suffix) })
+	if !ok {
+		return fmt.Errorf("release %s has no build for %s/%s", latest.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+	_, sumsURL, ok := latest.asset(func(name string) bool { return name == "checksums.txt" })
+	if !ok {
+		return fmt.Errorf("release %s has no checksums.txt to verify the download with", latest.Tag)
+	}
+
+	fmt.Printf("  Downloading %s\n", archiveName)
+	archive, err := download(archiveURL)
+	if err != nil {
+		return err
+	}
+	sums, err := download(sumsURL)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(archiveName, archive, sums); err != nil {
+		return err
+	}
+	binary, err := extractBinary(archiveName, archive)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	if err := replaceExecutable(exe, binary); err != nil {
+		return fmt.Errorf("could not replace %s: %w", exe, err)
+	}
+	green.Printf("  ✓ Updated %s from %s to %s\n", exe, version, latest.Tag)
+	return nil
+}
+
+// getJSON fetches a GitHub API URL and decodes the response into v.
+func getJSON(url string, v any) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := updateClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+var updateClient = &http.Client{Timeout: 5 * time.Minute}
+
+// download fetches a release file.
+func download(url string) ([]byte, error) {
+	resp, err := updateClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", path.Base(url), resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifyChecksum checks data against its line in a checksums.txt, as
+// written by GoReleaser: "<sha256>  <file name>".
+func verifyChecksum(name string, data, sums []byte) error {
+	sum := sha256.Sum256(data)
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			if fields[0] != hex.EncodeToString(sum[:]) {
+				return fmt.Errorf("%s doesn't match its checksum; not installing it", name)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("checksums.txt has no entry for %s", name)
+}
+
+// extractBinary returns the reading-logs-parser executable from a release
+// archive.
+func extractBinary(name string, archive []byte) ([]byte, error) {
+	want := "reading-logs-parser"
+	if runtime.GOOS == "windows" {
+		want += ".exe"
+	}
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) == want {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("%s has no %s", name, want)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s has no %s", name, want)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag == tar.TypeReg && path.Base(h.Name) == want {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// replaceExecutable writes binary over the executable at exe. The new file
+// is written alongside and renamed into place, so a failed update leaves the
+// old binary working. Windows won't replace a running executable, but will
+// rename it, so there the old one is moved aside first and removed on the
+// next update.
+func replaceExecutable(exe string, binary []byte) error {
+	tmp := exe + ".new"
+	if err := os.WriteFile(tmp, binary, 0755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
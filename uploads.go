@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// filesBeta is the beta that lets messages refer to uploaded files.
+const filesBeta = anthropic.AnthropicBetaFilesAPI2025_04_14
+
+// uploads sends images to the Files API once and refers to them by ID, so
+// re-asks, verification, and retried requests don't send the bytes again.
+// A nil *uploads sends every image inline instead.
+type uploads struct {
+	client anthropic.Client
+	mu     sync.Mutex
+	ids    map[[sha256.Size]byte]string // file ID by image content
+}
+
+func newUploads(client anthropic.Client) *uploads {
+	return &uploads{client: client, ids: make(map[[sha256.Size]byte]string)}
+}
+
+// image returns an image block for an encoded image, uploading it first if
+// uploads are on.
+func (u *uploads) image(ctx context.Context, mediaType, encoded string) (anthropic.BetaContentBlockParamUnion, error) {
+	if u == nil {
+		return anthropic.NewBetaImageBlock(anthropic.BetaBase64ImageSourceParam{
+			Data:      encoded,
+			MediaType: anthropic.BetaBase64ImageSourceMediaType(mediaType),
+		}), nil
+	}
+	id, err := u.upload(ctx, mediaType, encoded)
+	if err != nil {
+		return anthropic.BetaContentBlockParamUnion{}, err
+	}
+	return anthropic.NewBetaImageBlock(anthropic.BetaFileImageSourceParam{FileID: id}), nil
+}
+
+// upload returns the file ID for an image, uploading it if it hasn't been.
+// The lock is held during the upload so an image is only sent once.
+func (u *uploads) upload(ctx context.Context, mediaType, encoded string) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := sha256.Sum256([]byte(encoded))
+	if id, ok := u.ids[key]; ok {
+		return id, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	file, err := u.client.Beta.Files.Upload(ctx, anthropic.BetaFileUploadParams{
+		File: anthropic.File(bytes.NewReader(data), "reading-log", mediaType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("uploading image: %w", err)
+	}
+	u.ids[key] = file.ID
+	return file.ID, nil
+}
+
+// release deletes an image's uploaded file, if it was uploaded.
+func (u *uploads) release(encoded string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := sha256.Sum256([]byte(encoded))
+	if id, ok := u.ids[key]; ok {
+		u.remove(id)
+		delete(u.ids, key)
+	}
+}
+
+// releaseAll deletes every file still uploaded, such as prompt examples,
+// and returns how many there were.
+func (u *uploads) releaseAll() int {
+	if u == nil {
+		return 0
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	n := len(u.ids)
+	for key, id := range u.ids {
+		u.remove(id)
+		delete(u.ids, key)
+	}
+	return n
+}
+
+// remove deletes one uploaded file. A failure is only a warning: the file
+// is left in the account's storage but nothing else is affected. It gives
+// up after a minute so a hung request can't hold up the end of a run.
+func (u *uploads) remove(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err := u.client.Beta.Files.Delete(ctx, id, anthropic.BetaFileDeleteParams{})
+	if err != nil {
+		logger.Warn("could not delete uploaded file "+id, "file_id", id, "err", err)
+	}
+}
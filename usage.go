@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Prices for prompt-cache tokens, relative to the model's input price:
+// reading from the cache costs a tenth, writing to it a quarter more.
+const (
+	cacheReadPrice  = 0.1
+	cacheWritePrice = 1.25
+)
+
+// usage totals the tokens billed across a run's API requests.
+type usage struct {
+	mu         sync.Mutex
+	requests   int
+	input      int64 // uncached input tokens
+	cacheRead  int64 // input tokens read from the prompt cache
+	cacheWrite int64 // input tokens written to the prompt cache
+	output     int64
+	costUSD    float64 // at the prices of the model that answered each request
+}
+
+// add records one response's usage by model. A nil *usage records nothing.
+func (u *usage) add(model anthropic.Model, m anthropic.BetaUsage) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.requests++
+	u.input += m.InputTokens
+	u.cacheRead += m.CacheReadInputTokens
+	u.cacheWrite += m.CacheCreationInputTokens
+	u.output += m.OutputTokens
+	in, out, _ := pricesFor(string(model))
+	u.costUSD += float64(m.InputTokens)/1e6*in +
+		float64(m.CacheReadInputTokens)/1e6*in*cacheReadPrice +
+		float64(m.CacheCreationInputTokens)/1e6*in*cacheWritePrice +
+		float64(m.OutputTokens)/1e6*out
+}
+
+// cost estimates the run's cost in USD.
+func (u *usage) cost() float64 {
+	return u.costUSD
+}
+
+// printUsage reports the tokens a run used, including how much of the
+// input was served from the prompt cache. Nothing is printed if no
+// requests were made.
+func printUsage(u *usage) {
+	if u == nil || u.requests == 0 {
+		return
+	}
+	cacheable := u.cacheRead + u.cacheWrite
+	fmt.Println()
+	bold.Println(summaryRule("API usage"))
+	summaryLine("Requests", bold.Sprintf("%d", u.requests))
+	summaryLine("Input tokens", bold.Sprintf("%d", u.input+cacheable))
+	if cacheable > 0 {
+		hits := float64(u.cacheRead) / float64(u.input+cacheable) * 100
+		summaryLine("Cache reads", green.Sprintf("%d", u.cacheRead)+" "+dim.Sprintf(msg("(%.0f%% of input)"), hits))
+		summaryLine("Cache writes", cyan.Sprintf("%d", u.cacheWrite))
+	}
+	summaryLine("Output tokens", bold.Sprintf("%d", u.output))
+	summaryLine("Estimated cost", bold.Sprintf("~$%.2f", u.cost()))
+	bold.Println(summaryRule(""))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidationRules bound what a plausible reading log looks like. A zero
+// limit turns that rule off.
+type ValidationRules struct {
+	MaxPerDay   int `yaml:"max_per_day" toml:"max_per_day"`   // minutes in a single day
+	MaxPerWeek  int `yaml:"max_per_week" toml:"max_per_week"` // minutes across the whole log
+	RepeatedMin int `yaml:"repeated_min" toml:"repeated_min"` // smallest value that is suspicious when every day repeats it
+	PhotoDays   int `yaml:"photo_days" toml:"photo_days"`     // days after a form's last day that its photo may be taken
+}
+
+// minRepeatedDays is how many filled-in days must share a value before the
+// repeated-value rule applies.
+const minRepeatedDays = 3
+
+func defaultValidationRules() ValidationRules {
+	return ValidationRules{MaxPerDay: 240, MaxPerWeek: 1200, RepeatedMin: 60, PhotoDays: 14}
+}
+
+// check returns a description of every rule the log breaks, and every
+// problem a registered validator finds.
+func (r ValidationRules) check(log ReadingLog) []string {
+	var problems []string
+	total := 0
+	var filled []int
+	for _, e := range log.ReadingEntries {
+		total += e.Minutes
+		if e.Minutes < 0 {
+			problems = append(problems, fmt.Sprintf("%s %s: negative minutes (%d)", e.Day, shortDate(e.Date), e.Minutes))
+		}
+		if r.MaxPerDay > 0 && e.Minutes > r.MaxPerDay {
+			problems = append(problems, fmt.Sprintf("%s %s: %d min exceeds the %d min daily limit", e.Day, shortDate(e.Date), e.Minutes, r.MaxPerDay))
+		}
+		if e.Minutes > 0 {
+			filled = append(filled, e.Minutes)
+		}
+	}
+	if r.MaxPerWeek > 0 && total > r.MaxPerWeek {
+		problems = append(problems, fmt.Sprintf("%d min total exceeds the %d min weekly limit", total, r.MaxPerWeek))
+	}
+	if r.RepeatedMin > 0 && len(filled) >= minRepeatedDays && len(filled) == len(log.ReadingEntries) && filled[0] >= r.RepeatedMin {
+		same := true
+		for _, m := range filled[1:] {
+			same = same && m == filled[0]
+		}
+		if same {
+			problems = append(problems, fmt.Sprintf("every day reports the same %d min", filled[0]))
+		}
+	}
+	problems = append(problems, checkPhotoTaken(log, r.PhotoDays)...)
+	for _, v := range validators {
+		problems = append(problems, v(log)...)
+	}
+	return problems
+}
+
+// checkPhotoTaken compares when a log's photo was taken with the days on
+// it: minutes can't have been read on a day after the photo, and a photo
+// taken long after the week, more than days days, suggests a log sent in
+// for the wrong week. Logs whose photo doesn't say when it was taken pass.
+func checkPhotoTaken(log ReadingLog, days int) []string {
+	if len(log.PhotoTaken) < 10 {
+		return nil
+	}
+	taken, err := time.Parse("2006-01-02", log.PhotoTaken[:10]) // the day on the camera's clock
+	if err != nil {
+		return nil
+	}
+
+	var problems []string
+	var last, lastFilled time.Time
+	for _, e := range log.ReadingEntries {
+		day, ok := dateNear(e.Date, taken)
+		if !ok {
+			continue
+		}
+		if day.After(last) {
+			last = day
+		}
+		if e.Minutes > 0 && day.After(taken) && day.After(lastFilled) {
+			lastFilled = day
+		}
+	}
+	if !lastFilled.IsZero() {
+		problems = append(problems, fmt.Sprintf("minutes logged for %s, after the photo was taken on %s", lastFilled.Format("1/2"), taken.Format("1/2")))
+	}
+	if days > 0 && !last.IsZero() && taken.After(last.AddDate(0, 0, days)) {
+		problems = append(problems, fmt.Sprintf("photo taken %s, more than %d days after the form's last day (%s)", taken.Format("1/2"), days, last.Format("1/2")))
+	}
+	return problems
+}
+
+// dateNear returns a date as written on the form, taking the year from the
+// form or otherwise the one that puts it closest to near, so a December
+// form photographed in January falls in the year before.
+func dateNear(s string, near time.Time) (time.Time, bool) {
+	year, month, day, ok := parseDate(s)
+	if !ok {
+		return time.Time{}, false
+	}
+	if year != 0 {
+		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), true
+	}
+	best := time.Time{}
+	for _, y := range []int{near.Year() - 1, near.Year(), near.Year() + 1} {
+		d := time.Date(y, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if best.IsZero() || d.Sub(near).Abs() < best.Sub(near).Abs() {
+			best = d
+		}
+	}
+	return best, true
+}
+
+// validateLogs applies the configured rules to completed results and records
+// violations in the review queue. If only is non-nil, just those files are
+// checked. It returns the number of results that break a rule.
+func validateLogs(p *Progress, only map[string]bool) int {
+	details := make(map[string][]string)
+	for name, log := range p.Completed {
+		if only != nil && !only[name] {
+			continue
+		}
+		if log.Verified {
+			continue // a person has already checked it
+		}
+		if problems := cfg.Validation.check(log); len(problems) > 0 {
+			details[name] = problems
+		}
+	}
+	setFlags(p, "validation", details, only)
+	return len(details)
+}
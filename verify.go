@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Temperatures used with --verify when both readings come from the same
+// model, so the second reading is independent of the first.
+const (
+	primaryTemperature = 0.0
+	verifyTemperature  = 1.0
+)
+
+// verify reads the image a second time with p.verifier and flags every
+// field the two readings disagree on. With several students on an image,
+// logs are compared in the order they were read.
+func (p *pipeline) verify(ctx context.Context, name, mediaType, encoded string, form FormTemplate, logs []*ReadingLog) error {
+	seconds, err := p.read(ctx, p.verifier, mediaType, encoded, form)
+	if err != nil {
+		return fmt.Errorf("verification: %w", err)
+	}
+	if seconds[0].Raw != "" {
+		p.artifacts.write(artifactRaw, name, ".verify.json", []byte(seconds[0].Raw))
+	}
+	if len(seconds) != len(logs) {
+		logs[0].Flags = append(logs[0].Flags, Flag{Check: "verify", Detail: fmt.Sprintf("found %d vs %d student(s)", len(logs), len(seconds))})
+	}
+
+	for i := range min(len(logs), len(seconds)) {
+		normalizeLog(seconds[i])
+		for _, d := range compareLogs(normalized(*logs[i]), *seconds[i]) {
+			logs[i].Flags = append(logs[i].Flags, Flag{Check: "verify", Detail: d})
+		}
+	}
+	return nil
+}
+
+// compareLogs describes each field on which two readings of the same image
+// disagree: the student, grade, and teacher, and each day's minutes and
+// signature. Differences in case and spacing are ignored.
+func compareLogs(a, b ReadingLog) []string {
+	var diffs []string
+	if !sameText(a.FullName, b.FullName) {
+		diffs = append(diffs, fmt.Sprintf("name: %q vs %q", a.FullName, b.FullName))
+	}
+	if !sameText(a.Grade, b.Grade) {
+		diffs = append(diffs, fmt.Sprintf("grade: %q vs %q", a.Grade, b.Grade))
+	}
+	if !sameText(a.HomeroomTeacher, b.HomeroomTeacher) {
+		diffs = append(diffs, fmt.Sprintf("teacher: %q vs %q", a.HomeroomTeacher, b.HomeroomTeacher))
+	}
+
+	other := make(map[string]ReadingEntry)
+	for _, e := range b.ReadingEntries {
+		other[dateKey(e.Date)] = e
+	}
+	for _, e := range a.ReadingEntries {
+		key := dateKey(e.Date)
+		o, ok := other[key]
+		delete(other, key)
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s %s: only in the first reading", e.Day, shortDate(e.Date)))
+		case e.Minutes != o.Minutes:
+			diffs = append(diffs, fmt.Sprintf("%s %s: %d vs %d min", e.Day, shortDate(e.Date), e.Minutes, o.Minutes))
+		case e.Signed != nil && o.Signed != nil && *e.Signed != *o.Signed:
+			diffs = append(diffs, fmt.Sprintf("%s %s: signed %t vs %t", e.Day, shortDate(e.Date), *e.Signed, *o.Signed))
+		}
+	}
+	for _, e := range b.ReadingEntries {
+		if _, ok := other[dateKey(e.Date)]; ok {
+			diffs = append(diffs, fmt.Sprintf("%s %s: only in the second reading", e.Day, shortDate(e.Date)))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// webhookAttempts is how many times a record is posted before giving up on
+// it, waiting twice as long after each failure.
+const webhookAttempts = 4
+
+// webhookRecord is one result as posted to --webhook.
+type webhookRecord struct {
+	Image    string     `json:"image"`
+	ParsedAt time.Time  `json:"parsed_at"`
+	Model    string     `json:"model"`
+	Run      string     `json:"run,omitempty"` // the run's ID in the run history, if kept
+	Result   ReadingLog `json:"result"`        // with the flags raised while reading
+}
+
+// webhook posts each result to a URL as it is read, or all of them in one
+// array at the end of the run. Posting happens in the background, so a slow
+// endpoint doesn't hold up reading. A nil *webhook posts nothing.
+type webhook struct {
+	url     string
+	batch   bool
+	queue   chan []webhookRecord
+	done    chan struct{}
+	pending []webhookRecord // held for the end of the run with batch
+	posted  int
+	failed  int
+}
+
+func newWebhook(url string, batch bool) *webhook {
+	if url == "" {
+		return nil
+	}
+	w := &webhook{url: url, batch: batch, queue: make(chan []webhookRecord, 64), done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		for records := range w.queue {
+			var message any = records
+			what := fmt.Sprintf("%d result(s)", len(records))
+			if !w.batch {
+				message, what = records[0], records[0].Image
+			}
+			if err := w.deliver(message); err != nil {
+				w.failed += len(records)
+				logger.Warn("could not post "+what+" to the webhook", "err", err)
+				continue
+			}
+			w.posted += len(records)
+		}
+	}()
+	return w
+}
+
+// send posts a result, or holds it for the end of the run with batch.
+func (w *webhook) send(r webhookRecord) {
+	if w == nil {
+		return
+	}
+	if w.batch {
+		w.pending = append(w.pending, r)
+		return
+	}
+	w.queue <- []webhookRecord{r}
+}
+
+// close posts the held results, waits for everything to be posted, and
+// returns how many results were posted and how many couldn't be.
+func (w *webhook) close() (posted, failed int) {
+	if w == nil {
+		return 0, 0
+	}
+	if w.batch && len(w.pending) > 0 {
+		w.queue <- w.pending
+	}
+	close(w.queue)
+	<-w.done
+	return w.posted, w.failed
+}
+
+// deliver posts a message, trying again after network errors, rate limits,
+// and server errors.
+func (w *webhook) deliver(message any) error {
+	wait := time.Second
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if err = postWebhook(w.url, message); err == nil {
+			return nil
+		}
+		var status *webhookStatus
+		if errors.As(err, &status) && !status.retryable() {
+			return err
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	return fmt.Errorf("%w (tried %d times)", err, webhookAttempts)
+}